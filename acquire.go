@@ -0,0 +1,63 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"context"
+	"sync"
+)
+
+// owner holds the ownership of the terminal modes taken by its user
+var owner = make(chan *ownership, 1)
+
+// ownership is an ownership of the terminal modes, released once
+type ownership struct {
+	once sync.Once
+}
+
+func (o *ownership) release() {
+	o.once.Do(func() {
+		<-owner
+	})
+}
+
+// Acquire takes the process wide ownership of the terminal modes, waiting
+// for its current owner to release it or ctx to be done, and returns the
+// function releasing it. Calling it again does nothing, so that it never
+// releases an ownership taken since by another user.
+// The libraries and applications putting the terminal in raw mode, e.g. a
+// prompt and a TUI, should hold it from SetRaw until Reset, so that neither
+// restores the terminal to a state saved from the other's raw mode.
+func Acquire(ctx context.Context) (release func(), err error) {
+	o := &ownership{}
+	select {
+	case owner <- o:
+		return o.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TryAcquire takes the terminal modes ownership if it is not held, and
+// returns the function releasing it, see Acquire
+func TryAcquire() (release func(), ok bool) {
+	o := &ownership{}
+	select {
+	case owner <- o:
+		return o.release, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.linka.cloud/console"
+)
+
+func TestTryAcquire(t *testing.T) {
+	release, ok := console.TryAcquire()
+	if !ok {
+		t.Fatal("not acquired")
+	}
+	if _, ok := console.TryAcquire(); ok {
+		t.Fatal("acquired twice")
+	}
+	release()
+	release2, ok := console.TryAcquire()
+	if !ok {
+		t.Fatal("not acquired after release")
+	}
+	// a stale release does not release the current owner
+	release()
+	if _, ok := console.TryAcquire(); ok {
+		t.Fatal("released by a previous owner")
+	}
+	release2()
+	release3, ok := console.TryAcquire()
+	if !ok {
+		t.Fatal("not acquired after release")
+	}
+	release3()
+}
+
+func TestAcquire(t *testing.T) {
+	release, err := console.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := console.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("acquire while held: %v, want %v", err, context.DeadlineExceeded)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	release, err = console.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
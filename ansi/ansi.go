@@ -0,0 +1,153 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ansi
+
+import (
+	"strconv"
+)
+
+const (
+	ESC = "\x1b"
+	CSI = ESC + "["
+	OSC = ESC + "]"
+	DCS = ESC + "P"
+	APC = ESC + "_"
+	ST  = ESC + "\\"
+	BEL = "\a"
+)
+
+const (
+	// SingleWidthLine (DECSWL) resets the current line to single width and height
+	SingleWidthLine = ESC + "#5"
+	// DoubleWidthLine (DECDWL) makes the current line double width
+	DoubleWidthLine = ESC + "#6"
+	// DoubleHeightTop (DECDHL) makes the current line the top half of a double height line
+	DoubleHeightTop = ESC + "#3"
+	// DoubleHeightBottom (DECDHL) makes the current line the bottom half of a double height line
+	DoubleHeightBottom = ESC + "#4"
+)
+
+// DoubleWidth returns text rendered on a double width line
+func DoubleWidth(text string) string {
+	return DoubleWidthLine + text + "\r\n"
+}
+
+// DoubleHeight returns text rendered as a double height (and double width)
+// line, which takes two rows on the screen
+func DoubleHeight(text string) string {
+	return DoubleHeightTop + text + "\r\n" + DoubleHeightBottom + text + "\r\n"
+}
+
+const (
+	// EnableFocusReporting makes the terminal report focus changes
+	// as CSI I (focus in) and CSI O (focus out)
+	EnableFocusReporting = CSI + "?1004h"
+	// DisableFocusReporting disables focus changes reporting
+	DisableFocusReporting = CSI + "?1004l"
+)
+
+const (
+	// EnableBracketedPaste makes the terminal wrap the pasted text between
+	// CSI 200 ~ and CSI 201 ~
+	EnableBracketedPaste = CSI + "?2004h"
+	// DisableBracketedPaste disables bracketed paste
+	DisableBracketedPaste = CSI + "?2004l"
+)
+
+const (
+	// BeginSynchronizedUpdate (DEC mode 2026) makes the terminal hold
+	// rendering until EndSynchronizedUpdate is received
+	BeginSynchronizedUpdate = CSI + "?2026h"
+	// EndSynchronizedUpdate renders the updates received since BeginSynchronizedUpdate
+	EndSynchronizedUpdate = CSI + "?2026l"
+	// RequestSynchronizedUpdate asks the terminal if it supports synchronized updates
+	RequestSynchronizedUpdate = CSI + "?2026$p"
+)
+
+const (
+	// EnterAltScreen saves the cursor and switches to the cleared alternate
+	// screen, which has no scrollback
+	EnterAltScreen = CSI + "?1049h"
+	// ExitAltScreen switches back to the main screen and restores the cursor
+	ExitAltScreen = CSI + "?1049l"
+)
+
+const (
+	HideCursor  = CSI + "?25l"
+	ShowCursor  = CSI + "?25h"
+	ResetStyle  = CSI + "m"
+	EraseScreen = CSI + "2J"
+	EraseLine   = CSI + "2K"
+	// EraseLineRight erases from the cursor to the end of the line
+	EraseLineRight = CSI + "K"
+	// EraseDown erases from the cursor to the end of the screen
+	EraseDown = CSI + "J"
+)
+
+// CursorUp returns the sequence moving the cursor n rows up
+func CursorUp(n int) string {
+	return CSI + strconv.Itoa(n) + "A"
+}
+
+// CursorDown returns the sequence moving the cursor n rows down
+func CursorDown(n int) string {
+	return CSI + strconv.Itoa(n) + "B"
+}
+
+// CursorForward returns the sequence moving the cursor n columns right
+func CursorForward(n int) string {
+	return CSI + strconv.Itoa(n) + "C"
+}
+
+// CursorBack returns the sequence moving the cursor n columns left
+func CursorBack(n int) string {
+	return CSI + strconv.Itoa(n) + "D"
+}
+
+// CursorPosition returns the sequence moving the cursor to the zero based
+// row and column
+func CursorPosition(row, col int) string {
+	return CSI + strconv.Itoa(row+1) + ";" + strconv.Itoa(col+1) + "H"
+}
+
+// AppendCursorPosition appends the CursorPosition sequence to b without
+// allocating if b has enough capacity
+func AppendCursorPosition(b []byte, row, col int) []byte {
+	b = append(b, CSI...)
+	b = strconv.AppendInt(b, int64(row+1), 10)
+	b = append(b, ';')
+	b = strconv.AppendInt(b, int64(col+1), 10)
+	return append(b, 'H')
+}
+
+// SGR returns the Select Graphic Rendition sequence for the given parameters
+func SGR(params string) string {
+	return CSI + params + "m"
+}
+
+// AppendSGR appends the SGR sequence to b without allocating if b has
+// enough capacity
+func AppendSGR(b []byte, params string) []byte {
+	b = append(b, CSI...)
+	b = append(b, params...)
+	return append(b, 'm')
+}
+
+const (
+	// EnableMouse enables mouse buttons and drag reporting using the SGR encoding
+	EnableMouse = CSI + "?1000h" + CSI + "?1002h" + CSI + "?1006h"
+	// DisableMouse disables mouse reporting
+	DisableMouse = CSI + "?1006l" + CSI + "?1002l" + CSI + "?1000l"
+)
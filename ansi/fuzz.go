@@ -0,0 +1,58 @@
+//go:build gofuzz
+// +build gofuzz
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ansi
+
+// Fuzz is the go-fuzz (and libFuzzer, through go-fuzz-build -libfuzzer)
+// entry point: it checks that malformed sequences, written at once or split
+// at any position, do not make the Parser panic.
+// The corpus is in testdata/corpus.
+func Fuzz(data []byte) int {
+	split := 0
+	if len(data) > 0 {
+		split = int(data[0]) % len(data)
+	}
+	p := NewParser(nopHandler{})
+	p.Write(data)
+	p = NewParser(nopHandler{})
+	p.Write(data[:split])
+	p.Write(data[split:])
+	for _, c := range data {
+		if c == 0x1b {
+			return 1
+		}
+	}
+	return 0
+}
+
+type nopHandler struct{}
+
+func (nopHandler) Print(r rune) {}
+
+func (nopHandler) Execute(c byte) {}
+
+func (nopHandler) ESC(seq Sequence) {
+	seq.Param(0, 1)
+}
+
+func (nopHandler) CSI(seq Sequence) {
+	for i := range seq.Params {
+		seq.Param(i, 1)
+	}
+}
+
+func (nopHandler) OSC(data []byte) {}
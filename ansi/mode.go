@@ -0,0 +1,68 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ansi
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// ModeSetting is the state of a terminal mode as reported by a DECRPM response
+type ModeSetting int
+
+const (
+	ModeNotRecognized ModeSetting = iota
+	ModeSet
+	ModeReset
+	ModePermanentlySet
+	ModePermanentlyReset
+)
+
+// Supported reports whether the terminal knows the mode
+func (m ModeSetting) Supported() bool {
+	return m != ModeNotRecognized && m != ModePermanentlyReset
+}
+
+// RequestMode returns the DECRQM sequence querying a private mode
+func RequestMode(mode int) string {
+	return CSI + "?" + strconv.Itoa(mode) + "$p"
+}
+
+// ParseModeReport parses a DECRPM response (CSI ? mode ; setting $ y).
+// It returns false if b does not contain a complete report.
+func ParseModeReport(b []byte) (mode int, setting ModeSetting, ok bool) {
+	i := bytes.Index(b, []byte(CSI+"?"))
+	if i < 0 {
+		return 0, 0, false
+	}
+	b = b[i+3:]
+	j := bytes.Index(b, []byte("$y"))
+	if j < 0 {
+		return 0, 0, false
+	}
+	parts := bytes.Split(b[:j], []byte(";"))
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	m, err := strconv.Atoi(string(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	s, err := strconv.Atoi(string(parts[1]))
+	if err != nil || s < 0 || s > 4 {
+		return 0, 0, false
+	}
+	return m, ModeSetting(s), true
+}
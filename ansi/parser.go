@@ -0,0 +1,293 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ansi
+
+import (
+	"unicode/utf8"
+)
+
+// Sequence is an escape sequence decoded by a Parser
+type Sequence struct {
+	// Prefix is the private parameter marker of a CSI sequence
+	// ('?', '>', '<' or '='), 0 if none
+	Prefix byte
+	// Params are the numeric parameters, -1 when omitted.
+	// Sub-parameters (separated by ':') are flattened.
+	Params []int
+	// Intermediate are the intermediate bytes (0x20 to 0x2f)
+	Intermediate []byte
+	// Final is the final byte
+	Final byte
+}
+
+// Param returns the i-th parameter, or def if it is omitted or zero
+func (s Sequence) Param(i, def int) int {
+	if i >= len(s.Params) || s.Params[i] <= 0 {
+		return def
+	}
+	return s.Params[i]
+}
+
+// Handler receives the elements decoded by a Parser
+type Handler interface {
+	// Print is called for each printable character
+	Print(r rune)
+	// Execute is called for the C0 control characters (e.g. '\r', '\n')
+	Execute(c byte)
+	// ESC is called for the escape sequences other than CSI, OSC and the
+	// strings (DCS, APC, PM and SOS)
+	ESC(seq Sequence)
+	// CSI is called for the control sequences
+	CSI(seq Sequence)
+	// OSC is called for the operating system commands with their data,
+	// e.g. "0;title", which is only valid during the call
+	OSC(data []byte)
+}
+
+const (
+	stateGround = iota
+	stateEscape
+	stateCSI
+	stateOSC
+	// stateString ignores the DCS, APC, PM and SOS strings content
+	stateString
+	// stateStringEsc is an escape in a string, which may be the beginning
+	// of the string terminator
+	stateStringEsc
+)
+
+const (
+	// DefaultMaxOSC is the default maximum OSC data size
+	DefaultMaxOSC = 4096
+	// DefaultMaxParams is the default maximum number of parameters of a
+	// control sequence
+	DefaultMaxParams = 32
+	// maxIntermediate is the maximum number of intermediate bytes, no
+	// sequence uses more than two
+	maxIntermediate = 4
+)
+
+// ParserOption configures a Parser
+type ParserOption func(p *Parser)
+
+// WithMaxOSC sets the maximum OSC data size, the rest is dropped,
+// defaults to DefaultMaxOSC
+func WithMaxOSC(n int) ParserOption {
+	return func(p *Parser) {
+		p.maxOSC = n
+	}
+}
+
+// WithMaxParams sets the maximum number of parameters of a control
+// sequence, the extra ones are dropped, defaults to DefaultMaxParams
+func WithMaxParams(n int) ParserOption {
+	return func(p *Parser) {
+		p.maxParams = n
+	}
+}
+
+// Parser decodes a terminal output stream, calling the Handler for each
+// character, control and escape sequence. It implements io.Writer, and keeps
+// the incomplete sequences and characters across writes.
+type Parser struct {
+	h     Handler
+	state int
+	// osc reports whether the string being read is an OSC
+	osc bool
+	seq Sequence
+	// param is the parameter being read, -1 if none
+	param int
+	data  []byte
+	// r holds the bytes of an incomplete UTF-8 character
+	r  [utf8.UTFMax]byte
+	rn int
+
+	maxOSC    int
+	maxParams int
+}
+
+// NewParser returns a Parser calling h. The size of the sequences is
+// bounded, so that an untrusted stream cannot exhaust the memory.
+func NewParser(h Handler, opts ...ParserOption) *Parser {
+	p := &Parser{h: h, maxOSC: DefaultMaxOSC, maxParams: DefaultMaxParams}
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+func (p *Parser) Write(b []byte) (int, error) {
+	for _, c := range b {
+		p.feed(c)
+	}
+	return len(b), nil
+}
+
+func (p *Parser) reset(state int) {
+	p.state = state
+	p.seq = Sequence{}
+	p.param = -1
+	p.data = p.data[:0]
+}
+
+func (p *Parser) feed(c byte) {
+	switch p.state {
+	case stateGround:
+		p.ground(c)
+	case stateEscape:
+		p.escape(c)
+	case stateCSI:
+		p.csi(c)
+	case stateOSC, stateString:
+		p.str(c)
+	case stateStringEsc:
+		if c == '\\' {
+			p.endString()
+			return
+		}
+		// an escape sequence aborting the string
+		p.endString()
+		p.reset(stateEscape)
+		p.feed(c)
+	}
+}
+
+func (p *Parser) ground(c byte) {
+	if p.rn > 0 || c >= utf8.RuneSelf {
+		if p.rn > 0 && (c < 0x80 || c > 0xbf) {
+			// invalid continuation: drop the incomplete character
+			p.rn = 0
+			p.h.Print(utf8.RuneError)
+			if c < utf8.RuneSelf {
+				p.ground(c)
+				return
+			}
+		}
+		p.r[p.rn] = c
+		p.rn++
+		if utf8.FullRune(p.r[:p.rn]) {
+			r, _ := utf8.DecodeRune(p.r[:p.rn])
+			p.rn = 0
+			p.h.Print(r)
+		}
+		return
+	}
+	switch {
+	case c == 0x1b:
+		p.reset(stateEscape)
+	case c < 0x20 || c == 0x7f:
+		p.h.Execute(c)
+	default:
+		p.h.Print(rune(c))
+	}
+}
+
+func (p *Parser) escape(c byte) {
+	switch {
+	case c == 0x1b:
+		p.reset(stateEscape)
+	case c == 0x18 || c == 0x1a:
+		p.state = stateGround
+	case c < 0x20:
+		p.h.Execute(c)
+	case c < 0x30:
+		p.intermediate(c)
+	case len(p.seq.Intermediate) > 0:
+		p.seq.Final = c
+		p.state = stateGround
+		p.h.ESC(p.seq)
+	case c == '[':
+		p.reset(stateCSI)
+	case c == ']':
+		p.reset(stateOSC)
+		p.osc = true
+	case c == 'P', c == '_', c == '^', c == 'X':
+		p.reset(stateString)
+		p.osc = false
+	default:
+		p.seq.Final = c
+		p.state = stateGround
+		p.h.ESC(p.seq)
+	}
+}
+
+func (p *Parser) csi(c byte) {
+	switch {
+	case c == 0x1b:
+		p.reset(stateEscape)
+	case c == 0x18 || c == 0x1a:
+		p.state = stateGround
+	case c < 0x20:
+		p.h.Execute(c)
+	case c >= '0' && c <= '9':
+		if p.param < 0 {
+			p.param = 0
+		}
+		if p.param < 1<<16 {
+			p.param = p.param*10 + int(c-'0')
+		}
+	case c == ';' || c == ':':
+		p.push(p.param)
+		p.param = -1
+	case c >= '<' && c <= '?':
+		if len(p.seq.Params) == 0 && p.param < 0 {
+			p.seq.Prefix = c
+		}
+	case c < 0x30:
+		p.intermediate(c)
+	case c <= 0x7e:
+		if p.param >= 0 || len(p.seq.Params) > 0 {
+			p.push(p.param)
+		}
+		p.seq.Final = c
+		p.state = stateGround
+		p.h.CSI(p.seq)
+	}
+}
+
+// push appends the parameter if the maximum is not reached
+func (p *Parser) push(param int) {
+	if len(p.seq.Params) < p.maxParams {
+		p.seq.Params = append(p.seq.Params, param)
+	}
+}
+
+func (p *Parser) intermediate(c byte) {
+	if len(p.seq.Intermediate) < maxIntermediate {
+		p.seq.Intermediate = append(p.seq.Intermediate, c)
+	}
+}
+
+func (p *Parser) str(c byte) {
+	switch c {
+	case 0x1b:
+		p.state = stateStringEsc
+	case 0x07:
+		p.endString()
+	case 0x18, 0x1a:
+		p.state = stateGround
+	default:
+		if p.osc && len(p.data) < p.maxOSC {
+			p.data = append(p.data, c)
+		}
+	}
+}
+
+func (p *Parser) endString() {
+	p.state = stateGround
+	if p.osc {
+		p.h.OSC(p.data)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ansi
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Multiplexer is a terminal multiplexer, which only forwards to the outer
+// terminal the sequences it does not interpret when they are wrapped in its
+// passthrough envelope, e.g. the inline images or OSC 52
+type Multiplexer int
+
+const (
+	NoMultiplexer Multiplexer = iota
+	Tmux
+	Screen
+)
+
+func (m Multiplexer) String() string {
+	switch m {
+	case Tmux:
+		return "tmux"
+	case Screen:
+		return "screen"
+	default:
+		return "none"
+	}
+}
+
+// DetectMultiplexer returns the multiplexer the process runs in, guessed
+// from the environment variables returned by getenv.
+// TERM=screen is not enough to detect screen, tmux using it too.
+func DetectMultiplexer(getenv func(string) string) Multiplexer {
+	switch {
+	case getenv("TMUX") != "" || strings.HasPrefix(getenv("TERM"), "tmux"):
+		return Tmux
+	case getenv("STY") != "":
+		return Screen
+	default:
+		return NoMultiplexer
+	}
+}
+
+// screenChunk is the maximum length of the strings forwarded by screen
+const screenChunk = 768
+
+// Wrap returns seq wrapped in the passthrough envelope of the multiplexer,
+// seq as is for NoMultiplexer.
+// tmux only forwards it if its allow-passthrough option is on.
+func (m Multiplexer) Wrap(seq string) string {
+	switch m {
+	case Tmux:
+		return DCS + "tmux;" + strings.Replace(seq, ESC, ESC+ESC, -1) + ST
+	case Screen:
+		// the strings are cut after each escape so that the terminators
+		// of seq do not terminate them
+		var b strings.Builder
+		for len(seq) > 0 {
+			n := len(seq)
+			if n > screenChunk {
+				n = screenChunk
+			}
+			if i := strings.IndexByte(seq[:n], ESC[0]); i >= 0 {
+				n = i + 1
+			}
+			b.WriteString(DCS)
+			b.WriteString(seq[:n])
+			b.WriteString(ST)
+			seq = seq[n:]
+		}
+		return b.String()
+	default:
+		return seq
+	}
+}
+
+var passthrough int32 = 1
+
+// SetPassthrough enables or disables the wrapping done by Passthrough,
+// enabled by default, e.g. if the multiplexer is configured to forward the
+// sequences itself
+func SetPassthrough(enabled bool) {
+	v := int32(0)
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&passthrough, v)
+}
+
+// PassthroughMultiplexer returns the multiplexer the process runs in,
+// NoMultiplexer if the passthrough is disabled with SetPassthrough
+func PassthroughMultiplexer() Multiplexer {
+	if atomic.LoadInt32(&passthrough) == 0 {
+		return NoMultiplexer
+	}
+	return DetectMultiplexer(os.Getenv)
+}
+
+// Passthrough wraps seq in the passthrough envelope of the multiplexer
+// returned by PassthroughMultiplexer
+func Passthrough(seq string) string {
+	return PassthroughMultiplexer().Wrap(seq)
+}
+
+// SetClipboard returns the OSC 52 sequence copying text to the system
+// clipboard, wrapped with Passthrough
+func SetClipboard(text string) string {
+	return Passthrough(OSC + "52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + BEL)
+}
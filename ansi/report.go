@@ -0,0 +1,114 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ansi
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+const (
+	// RequestPrimaryAttributes (DA1) is answered by virtually all terminals,
+	// which makes it a good sentinel after other queries
+	RequestPrimaryAttributes = CSI + "c"
+	// RequestSecondaryAttributes (DA2) asks for the terminal type and version
+	RequestSecondaryAttributes = CSI + ">c"
+	// RequestVersion (XTVERSION) asks for the terminal name and version
+	RequestVersion = CSI + ">0q"
+	// RequestWindowPixels (XTWINOPS 14) asks for the text area size in
+	// pixels, answered with the report 4
+	RequestWindowPixels = CSI + "14t"
+	// RequestCellPixels (XTWINOPS 16) asks for the cell size in pixels,
+	// answered with the report 6
+	RequestCellPixels = CSI + "16t"
+)
+
+// ParsePrimaryAttributes parses a DA1 response: CSI ? Ps ; ... c
+func ParsePrimaryAttributes(b []byte) ([]int, bool) {
+	return parseAttributes(b, CSI+"?")
+}
+
+// ParseSecondaryAttributes parses a DA2 response: CSI > Pp ; Pv ; Pc c
+func ParseSecondaryAttributes(b []byte) ([]int, bool) {
+	return parseAttributes(b, CSI+">")
+}
+
+func parseAttributes(b []byte, prefix string) ([]int, bool) {
+	for {
+		i := bytes.Index(b, []byte(prefix))
+		if i < 0 {
+			return nil, false
+		}
+		b = b[i+len(prefix):]
+		j := 0
+		for j < len(b) && (b[j] >= '0' && b[j] <= '9' || b[j] == ';') {
+			j++
+		}
+		if j == len(b) {
+			return nil, false
+		}
+		if b[j] != 'c' {
+			continue
+		}
+		var out []int
+		for _, p := range strings.Split(string(b[:j]), ";") {
+			if n, err := strconv.Atoi(p); err == nil {
+				out = append(out, n)
+			}
+		}
+		return out, true
+	}
+}
+
+// ParseVersion parses an XTVERSION response: DCS > | text ST
+func ParseVersion(b []byte) (string, bool) {
+	i := bytes.Index(b, []byte(DCS+">|"))
+	if i < 0 {
+		return "", false
+	}
+	b = b[i+4:]
+	j := bytes.Index(b, []byte(ST))
+	if j < 0 {
+		return "", false
+	}
+	return string(b[:j]), true
+}
+
+// ParseWindowReport parses an XTWINOPS size report of the given kind,
+// e.g. 4 or 6: CSI kind ; height ; width t
+func ParseWindowReport(b []byte, kind int) (height, width int, ok bool) {
+	prefix := []byte(CSI + strconv.Itoa(kind) + ";")
+	for {
+		i := bytes.Index(b, prefix)
+		if i < 0 {
+			return 0, 0, false
+		}
+		b = b[i+len(prefix):]
+		j := bytes.IndexByte(b, 't')
+		if j < 0 {
+			return 0, 0, false
+		}
+		v := strings.Split(string(b[:j]), ";")
+		if len(v) != 2 {
+			continue
+		}
+		h, err1 := strconv.Atoi(v[0])
+		w, err2 := strconv.Atoi(v[1])
+		if err1 == nil && err2 == nil {
+			return h, w, true
+		}
+	}
+}
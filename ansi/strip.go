@@ -0,0 +1,89 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ansi
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// StripWriter writes the text written to it without its escape sequences
+// and control characters, e.g. to log the output of a terminal application.
+// The newlines and tabs are kept, and the carriage returns too unless they
+// end a line. The sequences may be split across writes.
+type StripWriter struct {
+	w io.Writer
+	p *Parser
+	h *stripHandler
+}
+
+// NewStripWriter returns a StripWriter writing to w
+func NewStripWriter(w io.Writer) *StripWriter {
+	h := &stripHandler{}
+	return &StripWriter{w: w, p: NewParser(h), h: h}
+}
+
+func (s *StripWriter) Write(p []byte) (int, error) {
+	s.h.buf = s.h.buf[:0]
+	s.p.Write(p)
+	if len(s.h.buf) == 0 {
+		return len(p), nil
+	}
+	if _, err := s.w.Write(s.h.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// stripHandler is the Handler collecting the text
+type stripHandler struct {
+	buf []byte
+	// cr is a carriage return not written yet, dropped if a newline
+	// follows
+	cr bool
+}
+
+func (h *stripHandler) flushCR() {
+	if h.cr {
+		h.buf = append(h.buf, '\r')
+		h.cr = false
+	}
+}
+
+func (h *stripHandler) Print(r rune) {
+	h.flushCR()
+	var e [utf8.UTFMax]byte
+	h.buf = append(h.buf, e[:utf8.EncodeRune(e[:], r)]...)
+}
+
+func (h *stripHandler) Execute(c byte) {
+	switch c {
+	case '\r':
+		h.flushCR()
+		h.cr = true
+	case '\n':
+		h.cr = false
+		h.buf = append(h.buf, c)
+	case '\t':
+		h.flushCR()
+		h.buf = append(h.buf, c)
+	}
+}
+
+func (h *stripHandler) ESC(seq Sequence) {}
+
+func (h *stripHandler) CSI(seq Sequence) {}
+
+func (h *stripHandler) OSC(data []byte) {}
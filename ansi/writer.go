@@ -0,0 +1,79 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ansi
+
+import (
+	"io"
+	"sync"
+)
+
+// Writer writes escape sequences to a terminal
+type Writer struct {
+	w    io.Writer
+	mu   sync.Mutex
+	sync bool
+	lvl  int
+}
+
+// NewWriter returns a Writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w *Writer) WriteString(s string) (int, error) {
+	return io.WriteString(w.w, s)
+}
+
+// SetSyncSupported enables synchronized updates, which should only be done
+// when the terminal reported supporting them (see RequestSynchronizedUpdate),
+// as unsupported terminals may display the sequences.
+func (w *Writer) SetSyncSupported(v bool) {
+	w.mu.Lock()
+	w.sync = v
+	w.mu.Unlock()
+}
+
+// BeginSync starts a synchronized update: the terminal will not render
+// anything until the matching EndSync.
+// Calls can be nested, only the outermost pair emits sequences.
+func (w *Writer) BeginSync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lvl++
+	if !w.sync || w.lvl > 1 {
+		return nil
+	}
+	_, err := io.WriteString(w.w, BeginSynchronizedUpdate)
+	return err
+}
+
+// EndSync ends a synchronized update started with BeginSync
+func (w *Writer) EndSync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.lvl == 0 {
+		return nil
+	}
+	w.lvl--
+	if !w.sync || w.lvl > 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.w, EndSynchronizedUpdate)
+	return err
+}
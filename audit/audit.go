@@ -0,0 +1,345 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit logs the interactive sessions of a Term for compliance
+// purposes.
+//
+// The log is a stream of JSON records, one per line: a start record holding
+// the session metadata, followed by the input and output records.
+// The input typed while the echo is disabled, e.g. passwords, is redacted
+// unless WithoutRedaction is used: the console reporting the echo state,
+// usually the PTY running the session, is passed to New.
+// The records are hash chained: each record holds the hash of the previous
+// one, and its own hash computed over its content, so that the modification,
+// insertion or removal of a record within the log is detected by Verify.
+// The chain alone does not detect a log rewritten from scratch, nor its last
+// records being dropped: WithKey computes the hashes as HMACs so that they
+// cannot be forged without the key, and the Anchor of the Logger, stored out
+// of band, lets Verify detect the truncation of the log.
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/term"
+)
+
+var (
+	ErrTampered = errors.New("audit: log tampered")
+	// ErrNoEchoSource is returned by New when the input is logged and
+	// redacted, but no echo source is provided
+	ErrNoEchoSource = errors.New("audit: no echo source to redact the input")
+)
+
+// Type is the type of a record
+type Type string
+
+const (
+	// TypeStart is the first record of a log, holding the metadata
+	TypeStart Type = "start"
+	// TypeInput records the data read from the Term
+	TypeInput Type = "input"
+	// TypeOutput records the data written to the Term
+	TypeOutput Type = "output"
+)
+
+// Record is an entry of the log
+type Record struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Type Type      `json:"type"`
+	// Meta is the session metadata (e.g. user, remote address),
+	// only set on the start record
+	Meta map[string]string `json:"meta,omitempty"`
+	Data []byte            `json:"data,omitempty"`
+	// Redacted is set on the input records whose data was dropped because
+	// it was typed while the echo was disabled
+	Redacted bool `json:"redacted,omitempty"`
+	// Prev is the hash of the previous record
+	Prev string `json:"prev"`
+	// Hash is the hash of the record, computed with Hash empty
+	Hash string `json:"hash"`
+}
+
+// hash returns the hash of the record, an HMAC if key is not nil
+func (r Record) hash(key []byte) (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:]), nil
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Anchor identifies a point of the log, to be stored out of band so that
+// Verify detects the records removed before it
+type Anchor struct {
+	// Count is the number of records written
+	Count uint64 `json:"count"`
+	// Hash is the hash of the last record written
+	Hash string `json:"hash"`
+}
+
+type options struct {
+	input    bool
+	output   bool
+	now      func() time.Time
+	echo     console.EchoReporter
+	noRedact bool
+	key      []byte
+}
+
+// Option configures a Logger
+type Option func(o *options)
+
+// WithoutInput disables the input logging
+func WithoutInput() Option {
+	return func(o *options) {
+		o.input = false
+	}
+}
+
+// WithoutOutput disables the output logging
+func WithoutOutput() Option {
+	return func(o *options) {
+		o.output = false
+	}
+}
+
+// WithoutRedaction logs the input typed while the echo is disabled, the
+// echo source passed to New can then be nil
+func WithoutRedaction() Option {
+	return func(o *options) {
+		o.noRedact = true
+	}
+}
+
+// WithKey computes the records hashes as HMAC-SHA256 with the key, so that
+// the log cannot be rewritten without it. Verify must then use WithVerifyKey.
+func WithKey(key []byte) Option {
+	return func(o *options) {
+		o.key = append([]byte(nil), key...)
+	}
+}
+
+// WithClock sets the function returning the records time,
+// defaults to time.Now
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// Logger writes the audit log
+type Logger struct {
+	w    io.Writer
+	opts options
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+	err  error
+}
+
+// New returns a Logger writing to w, starting the log with the metadata.
+// echo is the console checked for the echo state, usually the PTY running the
+// session: the input typed while its echo is disabled, or while its state
+// cannot be read, is redacted. It returns ErrNoEchoSource if echo is nil,
+// unless the input is not logged or not redacted.
+func New(w io.Writer, echo console.EchoReporter, meta map[string]string, opts ...Option) (*Logger, error) {
+	o := options{input: true, output: true, now: time.Now, echo: echo}
+	for _, v := range opts {
+		v(&o)
+	}
+	if echo == nil && o.input && !o.noRedact {
+		return nil, ErrNoEchoSource
+	}
+	l := &Logger{w: w, opts: o}
+	if err := l.log(Record{Type: TypeStart, Meta: meta}); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Log appends a record of the given type with a copy of the data.
+// Once writing the log failed, it keeps returning the error.
+func (l *Logger) Log(typ Type, data []byte) error {
+	if typ == TypeInput && !l.opts.input || typ == TypeOutput && !l.opts.output {
+		return nil
+	}
+	if typ == TypeInput && l.redact() {
+		return l.log(Record{Type: typ, Redacted: true})
+	}
+	return l.log(Record{Type: typ, Data: append([]byte(nil), data...)})
+}
+
+// redact reports whether the input must be redacted
+func (l *Logger) redact() bool {
+	if l.opts.noRedact {
+		return false
+	}
+	off, err := l.opts.echo.EchoDisabled()
+	return err != nil || off
+}
+
+// Anchor returns the Anchor of the records written so far
+func (l *Logger) Anchor() Anchor {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Anchor{Count: l.seq, Hash: l.prev}
+}
+
+func (l *Logger) log(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	r.Seq, r.Time, r.Prev = l.seq, l.opts.now(), l.prev
+	if l.err = l.write(r); l.err != nil {
+		return l.err
+	}
+	l.seq++
+	return nil
+}
+
+func (l *Logger) write(r Record) (err error) {
+	if r.Hash, err = r.hash(l.opts.key); err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	l.prev = r.Hash
+	return nil
+}
+
+// Wrap returns a Term logging the data read from and written to t.
+// It fails closed: the reads and writes return the logging errors.
+func Wrap(t term.Term, l *Logger) term.Term {
+	return &auditTerm{Term: t, l: l}
+}
+
+type auditTerm struct {
+	term.Term
+	l *Logger
+}
+
+func (a *auditTerm) Read(p []byte) (int, error) {
+	n, err := a.Term.Read(p)
+	if n > 0 {
+		if lerr := a.l.Log(TypeInput, p[:n]); lerr != nil {
+			return n, lerr
+		}
+	}
+	return n, err
+}
+
+func (a *auditTerm) Write(p []byte) (int, error) {
+	n, err := a.Term.Write(p)
+	if n > 0 {
+		if lerr := a.l.Log(TypeOutput, p[:n]); lerr != nil {
+			return n, lerr
+		}
+	}
+	return n, err
+}
+
+type verifyOptions struct {
+	key    []byte
+	anchor *Anchor
+}
+
+// VerifyOption configures Verify
+type VerifyOption func(o *verifyOptions)
+
+// WithVerifyKey sets the key the log was written with, see WithKey
+func WithVerifyKey(key []byte) VerifyOption {
+	return func(o *verifyOptions) {
+		o.key = key
+	}
+}
+
+// WithAnchor checks that the log holds the records identified by the Anchor,
+// detecting the log truncation. The records written after the Anchor
+// was taken are allowed.
+func WithAnchor(a Anchor) VerifyOption {
+	return func(o *verifyOptions) {
+		o.anchor = &a
+	}
+}
+
+// Verify reads the log and checks its hash chain, returning ErrTampered
+// if it was modified. Without WithAnchor, the removal of the last records
+// is not detected.
+func Verify(r io.Reader, opts ...VerifyOption) error {
+	var o verifyOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64<<10), 16<<20)
+	var seq uint64
+	prev := ""
+	for ; s.Scan(); seq++ {
+		var rec Record
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return fmt.Errorf("%w: record %d: %v", ErrTampered, seq, err)
+		}
+		if rec.Seq != seq || rec.Prev != prev || (seq == 0) != (rec.Type == TypeStart) {
+			return fmt.Errorf("%w: record %d out of sequence", ErrTampered, seq)
+		}
+		h, err := rec.hash(o.key)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(h), []byte(rec.Hash)) {
+			return fmt.Errorf("%w: record %d hash mismatch", ErrTampered, seq)
+		}
+		if a := o.anchor; a != nil && seq+1 == a.Count && rec.Hash != a.Hash {
+			return fmt.Errorf("%w: record %d does not match the anchor", ErrTampered, seq)
+		}
+		prev = rec.Hash
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if seq == 0 {
+		return fmt.Errorf("%w: empty log", ErrTampered)
+	}
+	if o.anchor != nil && seq < o.anchor.Count {
+		return fmt.Errorf("%w: truncated to %d records, want at least %d", ErrTampered, seq, o.anchor.Count)
+	}
+	return nil
+}
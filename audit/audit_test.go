@@ -0,0 +1,195 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type echo struct {
+	off bool
+	err error
+}
+
+func (e *echo) EchoDisabled() (bool, error) {
+	return e.off, e.err
+}
+
+func TestNewEchoSource(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		err  error
+	}{
+		{name: "redacted", err: ErrNoEchoSource},
+		{name: "without redaction", opts: []Option{WithoutRedaction()}},
+		{name: "without input", opts: []Option{WithoutInput()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if _, err := New(&b, nil, nil, tt.opts...); !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		echo     echo
+		opts     []Option
+		redacted bool
+	}{
+		{name: "echo on"},
+		{name: "echo off", echo: echo{off: true}, redacted: true},
+		{name: "echo error", echo: echo{err: errors.New("unsupported")}, redacted: true},
+		{name: "without redaction", echo: echo{off: true}, opts: []Option{WithoutRedaction()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			l, err := New(&b, &tt.echo, nil, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Log(TypeInput, []byte("secret")); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(b.String(), `"redacted":true`); got != tt.redacted {
+				t.Fatalf("redacted: got %v, want %v: %s", got, tt.redacted, b.String())
+			}
+			if err := Verify(&b); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	var b bytes.Buffer
+	l, err := New(&b, &echo{}, map[string]string{"user": "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log(TypeInput, []byte("ls\r")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log(TypeOutput, []byte("file\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(b.String(), "\n")
+	tests := []struct {
+		name string
+		log  string
+	}{
+		{name: "modified", log: strings.Replace(b.String(), `"bob"`, `"eve"`, 1)},
+		{name: "removed", log: lines[0] + lines[2]},
+		{name: "swapped", log: lines[0] + lines[2] + lines[1]},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(strings.NewReader(tt.log)); !errors.Is(err, ErrTampered) {
+				t.Fatalf("got %v, want %v", err, ErrTampered)
+			}
+		})
+	}
+}
+
+func TestVerifyKey(t *testing.T) {
+	var b bytes.Buffer
+	l, err := New(&b, &echo{}, map[string]string{"user": "bob"}, WithKey([]byte("key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log(TypeOutput, []byte("file\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	// a log rewritten from scratch has a valid plain hash chain
+	var forged bytes.Buffer
+	f, err := New(&forged, &echo{}, map[string]string{"user": "eve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Log(TypeOutput, []byte("file\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		log  string
+		key  []byte
+		err  error
+	}{
+		{name: "valid", log: b.String(), key: []byte("key")},
+		{name: "wrong key", log: b.String(), key: []byte("other"), err: ErrTampered},
+		{name: "no key", log: b.String(), err: ErrTampered},
+		{name: "forged", log: forged.String(), key: []byte("key"), err: ErrTampered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(strings.NewReader(tt.log), WithVerifyKey(tt.key)); !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyAnchor(t *testing.T) {
+	var b bytes.Buffer
+	l, err := New(&b, &echo{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"a", "b"} {
+		if err := l.Log(TypeOutput, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := l.Anchor()
+	if a.Count != 3 {
+		t.Fatalf("anchor count: got %d, want 3", a.Count)
+	}
+	anchored := b.String()
+	if err := l.Log(TypeOutput, []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(anchored, "\n")
+	tests := []struct {
+		name   string
+		log    string
+		anchor Anchor
+		err    error
+	}{
+		{name: "anchored", log: anchored, anchor: a},
+		{name: "appended", log: b.String(), anchor: a},
+		{name: "truncated", log: lines[0] + lines[1], anchor: a, err: ErrTampered},
+		{name: "other log", log: anchored, anchor: Anchor{Count: 3, Hash: "0"}, err: ErrTampered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(strings.NewReader(tt.log)); err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(strings.NewReader(tt.log), WithAnchor(tt.anchor)); !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
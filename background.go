@@ -0,0 +1,100 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackgroundTimeout is the time to wait for the terminal to report its
+// background color
+var BackgroundTimeout = 100 * time.Millisecond
+
+const requestBackground = "\x1b]11;?\x1b\\"
+
+// QueryBackground asks the terminal for its background color (OSC 11)
+// and returns its 8 bits components.
+// The console must be in raw mode.
+func QueryBackground(c Console, timeout time.Duration) (r, g, b uint8, err error) {
+	res, err := Query(c, requestBackground, timeout, func(b []byte) bool {
+		return bytes.HasSuffix(b, []byte("\a")) || bytes.HasSuffix(b, []byte("\x1b\\"))
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return parseBackground(res)
+}
+
+// parseBackground parses an OSC 11 response: ESC ] 11 ; rgb:RRRR/GGGG/BBBB ST
+func parseBackground(res []byte) (r, g, b uint8, err error) {
+	i := bytes.Index(res, []byte("rgb:"))
+	if i < 0 {
+		return 0, 0, 0, errors.New("invalid background color response")
+	}
+	s := strings.TrimRight(string(res[i+4:]), "\a\x1b\\")
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return 0, 0, 0, errors.New("invalid background color response")
+	}
+	var v [3]uint8
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 16, 16)
+		if err != nil || len(p) == 0 || len(p) > 4 {
+			return 0, 0, 0, errors.New("invalid background color response")
+		}
+		// scale the 1 to 4 hex digits component to 8 bits
+		max := uint64(1)<<(4*uint(len(p))) - 1
+		v[i] = uint8(n * 255 / max)
+	}
+	return v[0], v[1], v[2], nil
+}
+
+// isDark reports whether a color is dark using its relative luminance
+func isDark(r, g, b uint8) bool {
+	return 0.2126*float64(r)+0.7152*float64(g)+0.0722*float64(b) < 128
+}
+
+// colorFGBGDark uses the $COLORFGBG variable set by some terminals
+// (e.g. rxvt, konsole) in the "fg;bg" or "fg;default;bg" form
+func colorFGBGDark(getenv func(string) string) (dark bool, ok bool) {
+	v := getenv("COLORFGBG")
+	if v == "" {
+		return false, false
+	}
+	parts := strings.Split(v, ";")
+	bg, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return false, false
+	}
+	return bg < 7 || bg == 8, true
+}
+
+// isDarkBackground queries the terminal background, using query which must
+// handle the raw mode, and falls back to the environment heuristics.
+// Terminals are assumed to be dark when nothing is known.
+func isDarkBackground(query func() (r, g, b uint8, err error)) bool {
+	if r, g, b, err := query(); err == nil {
+		return isDark(r, g, b)
+	}
+	if dark, ok := colorFGBGDark(os.Getenv); ok {
+		return dark
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caps
+
+import (
+	"os"
+	"strings"
+)
+
+// Graphics is a set of inline image protocols supported by a terminal
+type Graphics uint8
+
+const (
+	Sixel Graphics = 1 << iota
+	ITerm2
+	Kitty
+)
+
+// Has reports whether all the protocols in o are supported
+func (g Graphics) Has(o Graphics) bool {
+	return g&o == o
+}
+
+// Profile describes the capabilities of a terminal
+type Profile struct {
+	// Term is the value of $TERM
+	Term string
+	// Program is the terminal emulator name, as reported by $TERM_PROGRAM
+	// or guessed from emulator specific variables
+	Program string
+	// Graphics is the set of supported inline image protocols
+	Graphics Graphics
+	// SyncOutput reports whether the terminal supports synchronized updates
+	// (DEC mode 2026)
+	SyncOutput bool
+	// Color is the color support level
+	Color ColorProfile
+	// Version is the terminal name and version as reported by XTVERSION,
+	// only set by Probe
+	Version string
+	// DeviceAttributes are the primary device attributes (DA1),
+	// only set by Probe
+	DeviceAttributes []int
+	// SecondaryAttributes are the terminal type, firmware version and
+	// ROM cartridge number (DA2), only set by Probe
+	SecondaryAttributes []int
+	// BracketedPaste reports whether the terminal supports bracketed paste
+	// (DEC mode 2004), only set by Probe
+	BracketedPaste bool
+	// FocusReporting reports whether the terminal supports focus reporting
+	// (DEC mode 1004), only set by Probe
+	FocusReporting bool
+	// Probed reports whether the profile was completed with the terminal answers
+	Probed bool
+}
+
+// Detect returns the capability profile of the current process' terminal
+// guessed from the environment
+func Detect() Profile {
+	return FromEnv(os.Getenv)
+}
+
+// FromEnv returns the capability profile guessed from the environment
+// variables returned by getenv, assuming the output is a terminal
+func FromEnv(getenv func(string) string) Profile {
+	p := Profile{
+		Term:    getenv("TERM"),
+		Program: getenv("TERM_PROGRAM"),
+		Color:   colorFromEnv(getenv, true),
+	}
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || strings.HasPrefix(p.Term, "xterm-kitty"):
+		p.Program = "kitty"
+	case getenv("WEZTERM_EXECUTABLE") != "":
+		p.Program = "WezTerm"
+	case getenv("KONSOLE_VERSION") != "":
+		p.Program = "konsole"
+	}
+	p.applyProgram(getenv("TERM_PROGRAM_VERSION"))
+	if getenv("WT_SESSION") != "" {
+		p.SyncOutput = true
+	}
+	switch {
+	case strings.HasPrefix(p.Term, "foot"):
+		p.Graphics |= Sixel
+		p.SyncOutput = true
+	case strings.HasPrefix(p.Term, "mlterm"), strings.HasPrefix(p.Term, "yaft"):
+		p.Graphics |= Sixel
+	case strings.Contains(p.Term, "sixel"):
+		p.Graphics |= Sixel
+	}
+	return p
+}
+
+// applyProgram sets the capabilities known for the terminal emulator
+func (p *Profile) applyProgram(version string) {
+	switch p.Program {
+	case "kitty":
+		p.Graphics |= Kitty
+		p.SyncOutput = true
+	case "WezTerm":
+		p.Graphics |= Kitty | ITerm2 | Sixel
+		p.SyncOutput = true
+	case "iTerm.app":
+		p.Graphics |= ITerm2
+		if strings.HasPrefix(version, "3.5") {
+			p.Graphics |= Sixel
+			p.SyncOutput = true
+		}
+	case "konsole":
+		p.Graphics |= Kitty | Sixel
+	case "mintty":
+		p.Graphics |= ITerm2 | Sixel
+	case "ghostty":
+		p.Graphics |= Kitty
+		p.SyncOutput = true
+	case "foot":
+		p.Graphics |= Sixel
+		p.SyncOutput = true
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caps
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go.linka.cloud/console"
+)
+
+// ColorProfile is the color support level of a terminal
+type ColorProfile int
+
+const (
+	// NoColor disables colors
+	NoColor ColorProfile = iota
+	// ANSI is the 16 colors palette
+	ANSI
+	// ANSI256 is the 256 colors palette
+	ANSI256
+	// TrueColor is the 24 bits colors
+	TrueColor
+)
+
+func (p ColorProfile) String() string {
+	switch p {
+	case NoColor:
+		return "none"
+	case ANSI:
+		return "ansi"
+	case ANSI256:
+		return "ansi256"
+	case TrueColor:
+		return "truecolor"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseColorProfile parses a color profile name as returned by String
+func ParseColorProfile(s string) (ColorProfile, error) {
+	switch strings.ToLower(s) {
+	case "none", "no", "ascii", "0":
+		return NoColor, nil
+	case "ansi", "16", "1":
+		return ANSI, nil
+	case "ansi256", "256", "2":
+		return ANSI256, nil
+	case "truecolor", "24bit", "3":
+		return TrueColor, nil
+	}
+	return NoColor, fmt.Errorf("invalid color profile %q", s)
+}
+
+var (
+	overrideMu sync.RWMutex
+	override   *ColorProfile
+)
+
+// OverrideColor forces the color profile returned by the detection
+// functions, regardless of the environment, e.g. in tests.
+// The returned function restores the previous behavior.
+func OverrideColor(p ColorProfile) (restore func()) {
+	overrideMu.Lock()
+	defer overrideMu.Unlock()
+	prev := override
+	override = &p
+	return func() {
+		overrideMu.Lock()
+		defer overrideMu.Unlock()
+		override = prev
+	}
+}
+
+func overridden() (ColorProfile, bool) {
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+	if override == nil {
+		return 0, false
+	}
+	return *override, true
+}
+
+// DetectFor returns the capability profile for output written to f:
+// colors are disabled if f is not a terminal, unless forced by the environment
+func DetectFor(f *os.File) Profile {
+	p := Detect()
+	if _, ok := overridden(); ok {
+		return p
+	}
+	p.Color = colorFromEnv(os.Getenv, isTerminal(f))
+	return p
+}
+
+// colorFromEnv implements the NO_COLOR (https://no-color.org),
+// CLICOLOR / CLICOLOR_FORCE (https://bixense.com/clicolors) and FORCE_COLOR
+// (https://force-color.org) conventions on top of the terminal detection.
+// FORCE_COLOR takes precedence over NO_COLOR which takes precedence over
+// CLICOLOR_FORCE and CLICOLOR.
+func colorFromEnv(getenv func(string) string, tty bool) ColorProfile {
+	if p, ok := overridden(); ok {
+		return p
+	}
+	detected := termColor(getenv)
+	if v := getenv("FORCE_COLOR"); v != "" {
+		switch strings.ToLower(v) {
+		case "0", "false", "no":
+			return NoColor
+		case "2":
+			return ANSI256
+		case "3":
+			return TrueColor
+		}
+		if detected < ANSI {
+			return ANSI
+		}
+		return detected
+	}
+	if getenv("NO_COLOR") != "" {
+		return NoColor
+	}
+	if v := getenv("CLICOLOR_FORCE"); v != "" && v != "0" {
+		if detected < ANSI {
+			return ANSI
+		}
+		return detected
+	}
+	if getenv("CLICOLOR") == "0" || !tty {
+		return NoColor
+	}
+	return detected
+}
+
+// termColor returns the color profile guessed from the terminal type
+func termColor(getenv func(string) string) ColorProfile {
+	t := getenv("TERM")
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return TrueColor
+	}
+	switch getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "ghostty", "vscode":
+		return TrueColor
+	case "Apple_Terminal":
+		return ANSI256
+	}
+	if getenv("WT_SESSION") != "" || getenv("KITTY_WINDOW_ID") != "" {
+		return TrueColor
+	}
+	switch {
+	case t == "" || t == "dumb":
+		return NoColor
+	case strings.Contains(t, "truecolor") || strings.Contains(t, "direct") || strings.HasPrefix(t, "xterm-kitty"):
+		return TrueColor
+	case strings.Contains(t, "256color"):
+		return ANSI256
+	default:
+		return ANSI
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	_, err := console.FromFile(f)
+	return err == nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caps
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/ansi"
+)
+
+// DA1 attribute announcing sixel graphics support
+const attrSixel = 4
+
+// Probe completes the environment based profile with the answers of the
+// terminal to the XTVERSION, DECRQM, DA2 and DA1 queries.
+// All the queries are sent at once, the DA1 answer marking the end of the
+// responses, so unsupported queries only cost the DA1 round trip.
+// The console must be in raw mode.
+func Probe(c console.Console, timeout time.Duration) (Profile, error) {
+	p := Detect()
+	req := ansi.RequestVersion +
+		ansi.RequestMode(2026) +
+		ansi.RequestMode(2004) +
+		ansi.RequestMode(1004) +
+		ansi.RequestSecondaryAttributes +
+		ansi.RequestPrimaryAttributes
+	res, err := console.Query(c, req, timeout, func(b []byte) bool {
+		_, ok := ansi.ParsePrimaryAttributes(b)
+		return ok
+	})
+	if err != nil {
+		return p, err
+	}
+	p.applyResponses(res)
+	return p, nil
+}
+
+func (p *Profile) applyResponses(res []byte) {
+	p.Probed = true
+	if v, ok := ansi.ParseVersion(res); ok {
+		p.Version = v
+		if name := programFromVersion(v); name != "" && name != p.Program {
+			p.Program = name
+			p.applyProgram(v)
+		}
+	}
+	for b := res; ; {
+		mode, s, ok := ansi.ParseModeReport(b)
+		if !ok {
+			break
+		}
+		switch mode {
+		case 2026:
+			p.SyncOutput = s.Supported()
+		case 2004:
+			p.BracketedPaste = s.Supported()
+		case 1004:
+			p.FocusReporting = s.Supported()
+		}
+		b = b[bytes.Index(b, []byte("$y"))+2:]
+	}
+	if v, ok := ansi.ParseSecondaryAttributes(res); ok {
+		p.SecondaryAttributes = v
+	}
+	if v, ok := ansi.ParsePrimaryAttributes(res); ok {
+		p.DeviceAttributes = v
+		for i, a := range v {
+			// the first attribute is the terminal class
+			if i > 0 && a == attrSixel {
+				p.Graphics |= Sixel
+			}
+		}
+	}
+}
+
+// programFromVersion returns the program name as reported in $TERM_PROGRAM
+// from the XTVERSION answer, e.g. "kitty(0.26.5)" or "WezTerm 20230712"
+func programFromVersion(v string) string {
+	name := strings.FieldsFunc(v, func(r rune) bool {
+		return r == '(' || r == ' '
+	})
+	if len(name) == 0 {
+		return ""
+	}
+	switch n := strings.ToLower(name[0]); n {
+	case "iterm2":
+		return "iTerm.app"
+	case "wezterm":
+		return "WezTerm"
+	default:
+		return n
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caps
+
+import (
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/ansi"
+)
+
+// QuerySyncOutput asks the terminal whether it supports synchronized updates.
+// The console must be in raw mode.
+func QuerySyncOutput(c console.Console, timeout time.Duration) (bool, error) {
+	res, err := console.Query(c, ansi.RequestSynchronizedUpdate, timeout, func(b []byte) bool {
+		_, _, ok := ansi.ParseModeReport(b)
+		return ok
+	})
+	if err != nil {
+		return false, err
+	}
+	_, s, _ := ansi.ParseModeReport(res)
+	return s.Supported(), nil
+}
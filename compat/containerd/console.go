@@ -0,0 +1,113 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package containerd implements the github.com/containerd/console API on the
+// console package, so that the projects using it can switch with their call
+// sites unchanged.
+package containerd
+
+import (
+	"os"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/pty"
+)
+
+// ErrNotAConsole is returned when a file is not a console
+var ErrNotAConsole = console.ErrNotAConsole
+
+// File is the file of a console
+type File = console.File
+
+// WinSize is the window size of a console
+type WinSize = console.WinSize
+
+// Console is a terminal
+type Console interface {
+	File
+
+	// Resize resizes the console to the provided window size
+	Resize(WinSize) error
+	// ResizeFrom resizes the console to the size of the provided one
+	ResizeFrom(Console) error
+	// SetRaw sets the console in raw mode
+	SetRaw() error
+	// DisableEcho disables echo on the console
+	DisableEcho() error
+	// Reset restores the console to its orignal state
+	Reset() error
+	// Size returns the window size of the console
+	Size() (WinSize, error)
+}
+
+type wrapper struct {
+	console.Console
+}
+
+func (w wrapper) ResizeFrom(c Console) error {
+	ws, err := c.Size()
+	if err != nil {
+		return err
+	}
+	return w.Resize(ws)
+}
+
+// Current returns the current process' console
+func Current() Console {
+	c := console.Current()
+	if c == nil {
+		return nil
+	}
+	return wrapper{c}
+}
+
+// ConsoleFromFile returns a console using the provided file, which must be
+// an *os.File
+func ConsoleFromFile(f File) (Console, error) {
+	v, ok := f.(*os.File)
+	if !ok {
+		return nil, ErrNotAConsole
+	}
+	c, err := console.FromFile(v)
+	if err != nil {
+		return nil, err
+	}
+	return wrapper{c}, nil
+}
+
+// NewPty returns a new PTY master and the path of its slave
+func NewPty() (Console, string, error) {
+	m, s, err := pty.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	name := s.Name()
+	s.Close()
+	c, err := console.FromFile(m)
+	if err != nil {
+		m.Close()
+		return nil, "", err
+	}
+	return wrapper{c}, name, nil
+}
+
+// ClearONLCR disables the newline translation to CRLF of the terminal fd
+func ClearONLCR(fd uintptr) error {
+	return setONLCR(fd, false)
+}
+
+// SetONLCR enables the newline translation to CRLF of the terminal fd
+func SetONLCR(fd uintptr) error {
+	return setONLCR(fd, true)
+}
@@ -0,0 +1,41 @@
+//go:build linux || aix || solaris
+// +build linux aix solaris
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
+
+// setONLCR sets or clears the ONLCR output flag of the terminal fd
+func setONLCR(fd uintptr, set bool) error {
+	t, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+	if set {
+		t.Oflag |= unix.OPOST | unix.ONLCR
+	} else {
+		t.Oflag &^= unix.ONLCR
+	}
+	return unix.IoctlSetTermios(int(fd), ioctlSetTermios, t)
+}
@@ -0,0 +1,38 @@
+//go:build !windows && !js && !plan9 && !wasip1 && !dragonfly
+// +build !windows,!js,!plan9,!wasip1,!dragonfly
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xterm
+
+import (
+	"io"
+	"syscall"
+)
+
+// fdReader reads fd without wrapping it in an os.File, which would close it
+// once garbage collected
+type fdReader int
+
+func (r fdReader) Read(p []byte) (int, error) {
+	n, err := syscall.Read(int(r), p)
+	if n < 0 {
+		n = 0
+	}
+	if n == 0 && err == nil {
+		return 0, io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xterm implements the golang.org/x/term API, on the terminal
+// library used by the console package, to ease the migration of the code
+// bases using x/term: the imports are switched, then the call sites moved
+// to the console and term packages at their own pace.
+package xterm
+
+import (
+	"io"
+)
+
+// readPassword reads a line from r, handling the backspaces
+func readPassword(r io.Reader) ([]byte, error) {
+	var buf [1]byte
+	var b []byte
+	for {
+		n, err := r.Read(buf[:])
+		if n > 0 {
+			switch buf[0] {
+			case '\b', 0x7f:
+				if len(b) > 0 {
+					b = b[:len(b)-1]
+				}
+			case '\n':
+				return b, nil
+			case '\r':
+				// the line end on windows is CRLF
+			default:
+				b = append(b, buf[0])
+			}
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(b) > 0 {
+				err = nil
+			}
+			return b, err
+		}
+	}
+}
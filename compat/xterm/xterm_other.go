@@ -0,0 +1,55 @@
+//go:build !(!js && !plan9 && !wasip1 && !dragonfly)
+// +build js plan9 wasip1 dragonfly
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xterm
+
+import (
+	"go.linka.cloud/console"
+)
+
+// State is the state of a terminal, to be restored with Restore
+type State struct{}
+
+// IsTerminal returns false on this platform
+func IsTerminal(fd int) bool {
+	return false
+}
+
+// MakeRaw returns console.ErrUnsupported on this platform
+func MakeRaw(fd int) (*State, error) {
+	return nil, console.ErrUnsupported
+}
+
+// GetState returns console.ErrUnsupported on this platform
+func GetState(fd int) (*State, error) {
+	return nil, console.ErrUnsupported
+}
+
+// Restore returns console.ErrUnsupported on this platform
+func Restore(fd int, state *State) error {
+	return console.ErrUnsupported
+}
+
+// GetSize returns console.ErrUnsupported on this platform
+func GetSize(fd int) (width, height int, err error) {
+	return 0, 0, console.ErrUnsupported
+}
+
+// ReadPassword returns console.ErrUnsupported on this platform
+func ReadPassword(fd int) ([]byte, error) {
+	return nil, console.ErrUnsupported
+}
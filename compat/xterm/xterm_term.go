@@ -0,0 +1,99 @@
+//go:build !js && !plan9 && !wasip1 && !dragonfly
+// +build !js,!plan9,!wasip1,!dragonfly
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xterm
+
+import (
+	"github.com/moby/term"
+
+	"go.linka.cloud/console"
+)
+
+// State is the state of a terminal, to be restored with Restore
+type State struct {
+	s *term.State
+	// release releases the terminal modes ownership taken by MakeRaw
+	release func()
+}
+
+// IsTerminal reports whether fd is a terminal
+func IsTerminal(fd int) bool {
+	return term.IsTerminal(uintptr(fd))
+}
+
+// MakeRaw puts the terminal fd in raw mode and returns its previous state.
+// It holds the terminal modes ownership until the state is restored, and
+// returns console.ErrBusy if it is held, see console.Acquire.
+func MakeRaw(fd int) (*State, error) {
+	release, ok := console.TryAcquire()
+	if !ok {
+		return nil, console.ErrBusy
+	}
+	s, err := term.MakeRaw(uintptr(fd))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &State{s: s, release: release}, nil
+}
+
+// GetState returns the current state of the terminal fd
+func GetState(fd int) (*State, error) {
+	s, err := term.SaveState(uintptr(fd))
+	if err != nil {
+		return nil, err
+	}
+	return &State{s: s}, nil
+}
+
+// Restore restores the terminal fd to the state
+func Restore(fd int, state *State) error {
+	if state.release != nil {
+		defer state.release()
+	}
+	return term.RestoreTerminal(uintptr(fd), state.s)
+}
+
+// GetSize returns the size of the terminal fd
+func GetSize(fd int) (width, height int, err error) {
+	ws, err := term.GetWinsize(uintptr(fd))
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Width), int(ws.Height), nil
+}
+
+// ReadPassword reads a line from the terminal fd without echoing it,
+// the line end is not returned.
+// It returns console.ErrBusy if the terminal modes ownership is held, see
+// console.Acquire.
+func ReadPassword(fd int) ([]byte, error) {
+	release, ok := console.TryAcquire()
+	if !ok {
+		return nil, console.ErrBusy
+	}
+	defer release()
+	s, err := term.SaveState(uintptr(fd))
+	if err != nil {
+		return nil, err
+	}
+	if err := term.DisableEcho(uintptr(fd), s); err != nil {
+		return nil, err
+	}
+	defer term.RestoreTerminal(uintptr(fd), s)
+	return readPassword(fdReader(fd))
+}
@@ -0,0 +1,60 @@
+//go:build linux || darwin
+// +build linux darwin
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xterm
+
+import (
+	"testing"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/pty"
+)
+
+func TestMakeRaw(t *testing.T) {
+	m, s, err := pty.Open()
+	if err != nil {
+		t.Skipf("no PTY: %v", err)
+	}
+	defer m.Close()
+	defer s.Close()
+	fd := int(s.Fd())
+	st, err := MakeRaw(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MakeRaw(fd); err != console.ErrBusy {
+		t.Fatalf("second MakeRaw: %v, want %v", err, console.ErrBusy)
+	}
+	if _, err := ReadPassword(fd); err != console.ErrBusy {
+		t.Fatalf("ReadPassword while raw: %v, want %v", err, console.ErrBusy)
+	}
+	if err := Restore(fd, st); err != nil {
+		t.Fatal(err)
+	}
+	release, ok := console.TryAcquire()
+	if !ok {
+		t.Fatal("not released by Restore")
+	}
+	// restoring again does not release the new owner
+	if err := Restore(fd, st); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := console.TryAcquire(); ok {
+		t.Fatal("released by a previous owner")
+	}
+	release()
+}
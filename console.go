@@ -40,8 +40,10 @@ type WinSize struct {
 	Height uint16
 	// Width of the console
 	Width uint16
-	x     uint16
-	y     uint16
+	// PixelWidth is the width of the console in pixels, 0 if unknown
+	PixelWidth uint16
+	// PixelHeight is the height of the console in pixels, 0 if unknown
+	PixelHeight uint16
 }
 
 type Console interface {
@@ -49,6 +51,9 @@ type Console interface {
 
 	// Resize resizes the console to the provided window size
 	Resize(WinSize) error
+	// ResizeFrom resizes the console to the window size of the provided
+	// one, e.g. a PTY to the size of the local console
+	ResizeFrom(Console) error
 	// SetRaw sets the console in raw mode
 	SetRaw() error
 	// DisableEcho disables echo on the console
@@ -57,20 +62,55 @@ type Console interface {
 	Reset() error
 	// Size returns the window size of the console
 	Size() (WinSize, error)
+	// IsDarkBackground reports whether the console background is dark,
+	// querying the terminal and falling back to environment heuristics
+	IsDarkBackground() bool
+	// Clone returns an independent Console on a duplicate of the underlying
+	// file descriptor, with its own saved state: setting its modes and
+	// resetting it does not interfere with the original Console saved state,
+	// and closing it leaves the original open
+	Clone() (Console, error)
 }
 
-// Current returns the current process' console
+// Current returns the current process' console, or a Dumb console on the
+// standard input and output if the environment is not interactive, see
+// Environment
 func Current() (c Console) {
-	var err error
-	// Usually all three streams (stdin, stdout, and stderr)
-	// are open to the same console, but some might be redirected,
-	// so try all three.
-	for _, s := range []*os.File{os.Stderr, os.Stdout, os.Stdin} {
-		if c, err = FromFile(s); err == nil {
-			return c
+	if Environment().Interactive() {
+		// Usually all three streams (stdin, stdout, and stderr)
+		// are open to the same console, but some might be redirected,
+		// so try all three.
+		for _, s := range []*os.File{os.Stderr, os.Stdout, os.Stdin} {
+			if c, err := FromFile(s); err == nil {
+				return c
+			}
 		}
 	}
-	// One of the std streams should always be a console
-	// for the design of this function.
-	panic(err)
+	return Dumb(os.Stdin, os.Stdout)
+}
+
+// SizeNotifier is implemented by the consoles notifying their size changes,
+// which saves polling them
+type SizeNotifier interface {
+	// NotifySize returns a channel receiving the new size each time the
+	// console is resized
+	NotifySize() <-chan WinSize
+}
+
+// RawOutputSetter is implemented by the consoles able to keep the output
+// processing in raw mode, so that the terminal still turns "\n" into
+// "\r\n" (onlcr), see TranslateCRLF
+type RawOutputSetter interface {
+	// SetRawOutput sets the console in raw mode like SetRaw, except for
+	// the output processing, which is left enabled
+	SetRawOutput() error
+}
+
+// EchoReporter is implemented by the consoles able to report whether the
+// input is read without being echoed, e.g. while a password is typed
+type EchoReporter interface {
+	// EchoDisabled reports whether the echo is disabled while the console
+	// is in cooked mode, as done by DisableEcho and the password prompts.
+	// The raw mode, which also disables the echo, is not reported.
+	EchoDisabled() (bool, error)
 }
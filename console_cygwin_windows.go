@@ -0,0 +1,247 @@
+//go:build windows
+// +build windows
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// cygwinPipe matches the name of the named pipes used as ptys by Cygwin and
+// MSYS2 terminals (mintty, Git Bash), which are not Windows consoles
+var cygwinPipe = regexp.MustCompile(`\\(cygwin|msys)-[0-9a-f]{16}-pty[0-9]+-(from|to)-master`)
+
+// isCygwinPty reports whether the handle is a Cygwin or MSYS pty
+func isCygwinPty(h windows.Handle) bool {
+	if t, err := windows.GetFileType(h); err != nil || t != windows.FILE_TYPE_PIPE {
+		return false
+	}
+	// FILE_NAME_INFO: a DWORD length followed by the UTF-16 name
+	buf := make([]byte, 4+windows.MAX_PATH*2)
+	if err := windows.GetFileInformationByHandleEx(h, windows.FileNameInfo, &buf[0], uint32(len(buf))); err != nil {
+		return false
+	}
+	l := *(*uint32)(unsafe.Pointer(&buf[0])) / 2
+	if int(l) > (len(buf)-4)/2 {
+		return false
+	}
+	name := make([]uint16, l)
+	for i := range name {
+		name[i] = *(*uint16)(unsafe.Pointer(&buf[4+2*i]))
+	}
+	return cygwinPipe.MatchString(string(utf16.Decode(name)))
+}
+
+// cygwin is a console backed by a Cygwin / MSYS pty.
+// Its modes can only be changed by Cygwin programs, so stty is used.
+type cygwin struct {
+	closer
+	f     File
+	mu    sync.Mutex
+	state string
+}
+
+func newCygwin(f File) (Console, error) {
+	if _, err := exec.LookPath("stty"); err != nil {
+		return nil, fmt.Errorf("%w: cygwin pty requires stty: %v", ErrNotAConsole, err)
+	}
+	return &cygwin{f: f}, nil
+}
+
+func (c *cygwin) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	if f, ok := c.f.(*os.File); ok {
+		cmd.Stdin = f
+	} else {
+		cmd.Stdin = os.Stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (c *cygwin) save() error {
+	if c.state != "" {
+		return nil
+	}
+	s, err := c.stty("-g")
+	if err != nil {
+		return err
+	}
+	c.state = s
+	return nil
+}
+
+func (c *cygwin) Clone() (Console, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	f, err := dupFile(c.f)
+	if err != nil {
+		return nil, err
+	}
+	return &cygwin{f: f}, nil
+}
+
+func (c *cygwin) Read(p []byte) (int, error) {
+	n, err := c.f.Read(p)
+	if err != nil && c.isClosed() {
+		err = ErrClosed
+	}
+	return n, err
+}
+
+func (c *cygwin) Write(p []byte) (int, error) {
+	n, err := c.f.Write(p)
+	if err != nil && c.isClosed() {
+		err = ErrClosed
+	}
+	return n, err
+}
+
+// Close closes the console file, it can be called multiple times
+func (c *cygwin) Close() error {
+	if !c.close() {
+		return nil
+	}
+	return c.f.Close()
+}
+
+func (c *cygwin) Fd() uintptr {
+	return c.f.Fd()
+}
+
+func (c *cygwin) Name() string {
+	return c.f.Name()
+}
+
+func (c *cygwin) SetRaw() error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.save(); err != nil {
+		return err
+	}
+	_, err := c.stty("raw", "-echo")
+	return err
+}
+
+func (c *cygwin) DisableEcho() error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.save(); err != nil {
+		return err
+	}
+	_, err := c.stty("-echo")
+	return err
+}
+
+func (c *cygwin) Reset() error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == "" {
+		return nil
+	}
+	_, err := c.stty(c.state)
+	return err
+}
+
+func (c *cygwin) Size() (WinSize, error) {
+	if c.isClosed() {
+		return WinSize{}, ErrClosed
+	}
+	out, err := c.stty("size")
+	if err != nil {
+		return WinSize{}, err
+	}
+	parts := strings.Fields(out)
+	if len(parts) != 2 {
+		return WinSize{}, fmt.Errorf("unexpected stty size output: %q", out)
+	}
+	rows, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return WinSize{}, err
+	}
+	cols, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return WinSize{}, err
+	}
+	return WinSize{Height: uint16(rows), Width: uint16(cols)}, nil
+}
+
+func (c *cygwin) Resize(ws WinSize) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	_, err := c.stty("rows", strconv.Itoa(int(ws.Height)), "cols", strconv.Itoa(int(ws.Width)))
+	return err
+}
+
+func (c *cygwin) ResizeFrom(other Console) error {
+	return resizeFrom(c, other)
+}
+
+func (c *cygwin) EchoDisabled() (bool, error) {
+	if c.isClosed() {
+		return false, ErrClosed
+	}
+	out, err := c.stty("-a")
+	if err != nil {
+		return false, err
+	}
+	echo, icanon := true, false
+	for _, v := range strings.Fields(out) {
+		switch strings.TrimSuffix(v, ";") {
+		case "-echo":
+			echo = false
+		case "icanon":
+			icanon = true
+		}
+	}
+	return !echo && icanon, nil
+}
+
+// IsDarkBackground only uses the environment heuristics, as the pipe cannot
+// be waited on for the terminal response
+func (c *cygwin) IsDarkBackground() bool {
+	return isDarkBackground(func() (r, g, b uint8, err error) {
+		return 0, 0, 0, ErrUnsupported
+	})
+}
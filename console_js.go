@@ -0,0 +1,315 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+var (
+	xmu     sync.Mutex
+	current *xterm
+)
+
+// SetXterm registers the xterm.js Terminal used as the console of the
+// standard streams, so that FromFile(os.Stdin) and Current work.
+// fit is the terminal FitAddon, used to compute the size, and may be
+// js.Undefined().
+func SetXterm(term, fit js.Value) Console {
+	c := FromXterm(term, fit)
+	xmu.Lock()
+	current = c.(*xterm)
+	xmu.Unlock()
+	return c
+}
+
+// FromFile returns the console registered with SetXterm for the standard
+// streams
+func FromFile(f *os.File) (Console, error) {
+	if f != os.Stdin && f != os.Stdout && f != os.Stderr {
+		return nil, ErrNotAConsole
+	}
+	xmu.Lock()
+	defer xmu.Unlock()
+	if current == nil {
+		return nil, ErrNotAConsole
+	}
+	return current, nil
+}
+
+// FromXterm returns a Console bridging an xterm.js Terminal.
+// As xterm.js has no line discipline, the cooked mode (when the console
+// is not raw) is emulated: input is echoed and delivered line by line.
+func FromXterm(term, fit js.Value) Console {
+	c := &xterm{
+		t:     term,
+		fit:   fit,
+		echo:  true,
+		ready: make(chan struct{}, 1),
+		sizes: make(chan WinSize, 1),
+	}
+	onData := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) > 0 {
+			c.input(args[0].String())
+		}
+		return nil
+	})
+	onResize := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if ws, err := c.Size(); err == nil {
+			select {
+			case c.sizes <- ws:
+			default:
+				select {
+				case <-c.sizes:
+				default:
+				}
+				c.sizes <- ws
+			}
+		}
+		return nil
+	})
+	c.funcs = []js.Func{onData, onResize}
+	c.disposables = []js.Value{term.Call("onData", onData), term.Call("onResize", onResize)}
+	return c
+}
+
+type xterm struct {
+	t   js.Value
+	fit js.Value
+
+	mu      sync.Mutex
+	raw     bool
+	echo    bool
+	closed  bool
+	pending []byte
+	line    []rune
+	ready   chan struct{}
+	sizes   chan WinSize
+
+	funcs       []js.Func
+	disposables []js.Value
+}
+
+// input is called by xterm.js when data is typed
+func (c *xterm) input(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.raw {
+		c.pending = append(c.pending, s...)
+		c.signal()
+		return
+	}
+	for _, r := range s {
+		switch r {
+		case '\r', '\n':
+			c.pending = append(c.pending, string(c.line)...)
+			c.pending = append(c.pending, '\n')
+			c.line = c.line[:0]
+			c.output("\r\n")
+			c.signal()
+		case 0x7f, '\b':
+			if len(c.line) > 0 {
+				c.line = c.line[:len(c.line)-1]
+				c.output("\b \b")
+			}
+		case 0x04:
+			// Ctrl-D flushes the line, or signals EOF on an empty one
+			c.pending = append(c.pending, string(c.line)...)
+			c.line = c.line[:0]
+			c.signal()
+		default:
+			c.line = append(c.line, r)
+			c.output(string(r))
+		}
+	}
+}
+
+func (c *xterm) output(s string) {
+	if c.echo {
+		c.t.Call("write", s)
+	}
+}
+
+func (c *xterm) signal() {
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// waitInput implements the inputWaiter interface used by Query
+func (c *xterm) waitInput(timeout time.Duration) (bool, error) {
+	c.mu.Lock()
+	n := len(c.pending)
+	c.mu.Unlock()
+	if n > 0 {
+		return true, nil
+	}
+	select {
+	case <-c.ready:
+		c.signal()
+		return true, nil
+	case <-time.After(timeout):
+		return false, nil
+	}
+}
+
+func (c *xterm) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, ErrClosed
+		}
+		if len(c.pending) > 0 {
+			n := copy(p, c.pending)
+			c.pending = c.pending[n:]
+			if len(c.pending) > 0 {
+				c.signal()
+			}
+			c.mu.Unlock()
+			return n, nil
+		}
+		c.mu.Unlock()
+		<-c.ready
+	}
+}
+
+func (c *xterm) Write(p []byte) (int, error) {
+	if c.isClosed() {
+		return 0, ErrClosed
+	}
+	b := js.Global().Get("Uint8Array").New(len(p))
+	js.CopyBytesToJS(b, p)
+	c.t.Call("write", b)
+	return len(p), nil
+}
+
+func (c *xterm) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	for _, d := range c.disposables {
+		d.Call("dispose")
+	}
+	for _, f := range c.funcs {
+		f.Release()
+	}
+	c.signal()
+	return nil
+}
+
+func (c *xterm) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *xterm) Fd() uintptr {
+	return 0
+}
+
+func (c *xterm) Name() string {
+	return "xterm.js"
+}
+
+// Clone returns a new console on the same terminal, with its own emulated
+// modes and input: each console receives a copy of the terminal input
+func (c *xterm) Clone() (Console, error) {
+	return FromXterm(c.t, c.fit), nil
+}
+
+func (c *xterm) SetRaw() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.raw = true
+	return nil
+}
+
+func (c *xterm) DisableEcho() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.echo = false
+	return nil
+}
+
+func (c *xterm) EchoDisabled() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.raw && !c.echo, nil
+}
+
+func (c *xterm) Reset() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.raw, c.echo = false, true
+	return nil
+}
+
+// Size returns the size proposed by the fit addon if any, the terminal
+// size otherwise
+func (c *xterm) Size() (WinSize, error) {
+	if c.fit.Truthy() {
+		if d := c.fit.Call("proposeDimensions"); d.Truthy() {
+			return WinSize{Height: uint16(d.Get("rows").Int()), Width: uint16(d.Get("cols").Int())}, nil
+		}
+	}
+	return WinSize{Height: uint16(c.t.Get("rows").Int()), Width: uint16(c.t.Get("cols").Int())}, nil
+}
+
+func (c *xterm) Resize(ws WinSize) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	c.t.Call("resize", int(ws.Width), int(ws.Height))
+	return nil
+}
+
+func (c *xterm) ResizeFrom(other Console) error {
+	return resizeFrom(c, other)
+}
+
+func (c *xterm) NotifySize() <-chan WinSize {
+	return c.sizes
+}
+
+// IsDarkBackground uses the terminal theme background, xterm.js default
+// theme being dark
+func (c *xterm) IsDarkBackground() bool {
+	bg := c.t.Get("options").Get("theme")
+	if !bg.Truthy() || !bg.Get("background").Truthy() {
+		return true
+	}
+	s := strings.TrimPrefix(bg.Get("background").String(), "#")
+	if len(s) < 6 {
+		return true
+	}
+	v, err := strconv.ParseUint(s[:6], 16, 32)
+	if err != nil {
+		return true
+	}
+	return isDark(uint8(v>>16), uint8(v>>8), uint8(v))
+}
@@ -0,0 +1,32 @@
+//go:build plan9 || wasip1 || dragonfly
+// +build plan9 wasip1 dragonfly
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"os"
+	"time"
+)
+
+// FromFile is not supported on this platform
+func FromFile(f *os.File) (Console, error) {
+	return nil, ErrUnsupported
+}
+
+func waitInput(fd uintptr, timeout time.Duration) (bool, error) {
+	return false, ErrUnsupported
+}
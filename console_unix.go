@@ -1,5 +1,5 @@
-//go:build !windows
-// +build !windows
+//go:build !windows && !js && !plan9 && !wasip1 && !dragonfly
+// +build !windows,!js,!plan9,!wasip1,!dragonfly
 
 // Copyright 2022 Linka Cloud  All rights reserved.
 //
@@ -22,6 +22,7 @@ import (
 	"sync"
 
 	"github.com/moby/term"
+	"golang.org/x/sys/unix"
 )
 
 // FromFile returns a Console from the provided file
@@ -33,20 +34,50 @@ func FromFile(f *os.File) (Console, error) {
 }
 
 type console struct {
-	f     *os.File
-	mu    sync.Mutex
-	state *term.State
+	closer
+	f  *os.File
+	mu sync.Mutex
+	// state is the original state of the terminal, shared with the other
+	// consoles on the same descriptor
+	state  *term.State
+	shared *sharedState
+}
+
+func (c *console) Clone() (Console, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	fd, err := unix.Dup(int(c.f.Fd()))
+	if err != nil {
+		return nil, wrapError(c.f, "clone", err)
+	}
+	return &console{f: os.NewFile(uintptr(fd), c.f.Name())}, nil
 }
 
 func (c *console) Read(p []byte) (n int, err error) {
-	return c.f.Read(p)
+	n, err = c.f.Read(p)
+	if err != nil && c.isClosed() {
+		err = ErrClosed
+	}
+	return n, err
 }
 
 func (c *console) Write(p []byte) (n int, err error) {
-	return c.f.Write(p)
+	n, err = c.f.Write(p)
+	if err != nil && c.isClosed() {
+		err = ErrClosed
+	}
+	return n, err
 }
 
+// Close closes the console file, it can be called multiple times
 func (c *console) Close() error {
+	if !c.close() {
+		return nil
+	}
+	c.mu.Lock()
+	c.release(c.f.Fd())
+	c.mu.Unlock()
 	return c.f.Close()
 }
 
@@ -59,38 +90,129 @@ func (c *console) Name() string {
 }
 
 func (c *console) SetRaw() (err error) {
+	if c.isClosed() {
+		return ErrClosed
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.state, err = term.SetRawTerminal(c.f.Fd())
-	return err
+	if err := c.acquire(); err != nil {
+		return wrapError(c.f, "set raw", err)
+	}
+	// unlike SetRawTerminal, MakeRaw does not install a handler exiting the
+	// process on SIGINT, the signals are left to the application
+	_, err = term.MakeRaw(c.f.Fd())
+	return wrapError(c.f, "set raw", err)
+}
+
+func (c *console) SetRawOutput() error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.acquire(); err != nil {
+		return wrapError(c.f, "set raw output", err)
+	}
+	if _, err := term.MakeRaw(c.f.Fd()); err != nil {
+		return wrapError(c.f, "set raw output", err)
+	}
+	t, err := unix.IoctlGetTermios(int(c.f.Fd()), ioctlGetTermios)
+	if err != nil {
+		return wrapError(c.f, "set raw output", err)
+	}
+	t.Oflag |= unix.OPOST | unix.ONLCR
+	return wrapError(c.f, "set raw output", unix.IoctlSetTermios(int(c.f.Fd()), ioctlSetTermios, t))
 }
 
 func (c *console) DisableEcho() error {
+	if c.isClosed() {
+		return ErrClosed
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return term.DisableEcho(c.f.Fd(), c.state)
+	if err := c.acquire(); err != nil {
+		return wrapError(c.f, "disable echo", err)
+	}
+	return wrapError(c.f, "disable echo", term.DisableEcho(c.f.Fd(), c.state))
 }
 
+// Reset restores the original state of the terminal, unless other consoles
+// on the same descriptor changed it and were not reset yet. It does nothing
+// if the console modes were not changed since the last Reset.
 func (c *console) Reset() error {
+	if c.isClosed() {
+		return ErrClosed
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return term.RestoreTerminal(c.f.Fd(), c.state)
+	// the console modes were not changed, or were already reset
+	if c.shared == nil {
+		return nil
+	}
+	st := c.state
+	if !c.release(c.f.Fd()) {
+		return nil
+	}
+	return wrapError(c.f, "reset", term.RestoreTerminal(c.f.Fd(), st))
 }
 
 func (c *console) Size() (WinSize, error) {
-	ws, err := term.GetWinsize(c.f.Fd())
+	if c.isClosed() {
+		return WinSize{}, ErrClosed
+	}
+	ws, err := unix.IoctlGetWinsize(int(c.f.Fd()), unix.TIOCGWINSZ)
 	if err != nil {
-		return WinSize{}, err
+		return WinSize{}, wrapError(c.f, "size", err)
 	}
 	return WinSize{
-		Height: ws.Height,
-		Width:  ws.Width,
+		Height:      ws.Row,
+		Width:       ws.Col,
+		PixelWidth:  ws.Xpixel,
+		PixelHeight: ws.Ypixel,
 	}, nil
 }
 
 func (c *console) Resize(size WinSize) error {
-	return term.SetWinsize(c.f.Fd(), &term.Winsize{
-		Height: size.Height,
-		Width:  size.Width,
+	if c.isClosed() {
+		return ErrClosed
+	}
+	return wrapError(c.f, "resize", unix.IoctlSetWinsize(int(c.f.Fd()), unix.TIOCSWINSZ, &unix.Winsize{
+		Row:    size.Height,
+		Col:    size.Width,
+		Xpixel: size.PixelWidth,
+		Ypixel: size.PixelHeight,
+	}))
+}
+
+func (c *console) ResizeFrom(other Console) error {
+	return resizeFrom(c, other)
+}
+
+func (c *console) EchoDisabled() (bool, error) {
+	if c.isClosed() {
+		return false, ErrClosed
+	}
+	t, err := unix.IoctlGetTermios(int(c.f.Fd()), ioctlGetTermios)
+	if err != nil {
+		return false, wrapError(c.f, "get termios", err)
+	}
+	return t.Lflag&unix.ECHO == 0 && t.Lflag&unix.ICANON != 0, nil
+}
+
+func (c *console) IsDarkBackground() bool {
+	return isDarkBackground(func() (r, g, b uint8, err error) {
+		if c.isClosed() {
+			return 0, 0, 0, ErrClosed
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.state == nil {
+			state, err := term.MakeRaw(c.f.Fd())
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			defer term.RestoreTerminal(c.f.Fd(), state)
+		}
+		return QueryBackground(c, BackgroundTimeout)
 	})
 }
@@ -0,0 +1,235 @@
+//go:build linux
+// +build linux
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/pty"
+)
+
+func openPTY(t *testing.T) *os.File {
+	t.Helper()
+	m, s, err := pty.Open()
+	if err != nil {
+		t.Skipf("no PTY: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		m.Close()
+	})
+	return s
+}
+
+func fromFile(t *testing.T, f *os.File) console.Console {
+	t.Helper()
+	c, err := console.FromFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the saved states are shared by descriptor, which the next test reuses
+	t.Cleanup(func() {
+		c.Close()
+	})
+	return c
+}
+
+// isRaw reports whether the terminal is in non canonical mode
+func isRaw(t *testing.T, f *os.File) bool {
+	t.Helper()
+	tios, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tios.Lflag&unix.ICANON == 0
+}
+
+func TestConsoleReset(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, c1, c2 console.Console)
+		raw  bool
+	}{
+		{
+			name: "not raw",
+			run: func(t *testing.T, c1, c2 console.Console) {
+				if err := c1.Reset(); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "raw",
+			run: func(t *testing.T, c1, c2 console.Console) {
+				if err := c1.SetRaw(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			raw: true,
+		},
+		{
+			name: "reset",
+			run: func(t *testing.T, c1, c2 console.Console) {
+				if err := c1.SetRaw(); err != nil {
+					t.Fatal(err)
+				}
+				if err := c1.Reset(); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "shared",
+			run: func(t *testing.T, c1, c2 console.Console) {
+				if err := c1.SetRaw(); err != nil {
+					t.Fatal(err)
+				}
+				if err := c2.SetRaw(); err != nil {
+					t.Fatal(err)
+				}
+				if err := c1.Reset(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			raw: true,
+		},
+		{
+			name: "shared reset",
+			run: func(t *testing.T, c1, c2 console.Console) {
+				if err := c1.SetRaw(); err != nil {
+					t.Fatal(err)
+				}
+				if err := c2.SetRaw(); err != nil {
+					t.Fatal(err)
+				}
+				if err := c1.Reset(); err != nil {
+					t.Fatal(err)
+				}
+				if err := c2.Reset(); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "second reset",
+			run: func(t *testing.T, c1, c2 console.Console) {
+				if err := c1.SetRaw(); err != nil {
+					t.Fatal(err)
+				}
+				if err := c1.Reset(); err != nil {
+					t.Fatal(err)
+				}
+				if err := c2.SetRaw(); err != nil {
+					t.Fatal(err)
+				}
+				// the state saved by c1 is released, it must not be
+				// restored over the mode set by c2
+				if err := c1.Reset(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			raw: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := openPTY(t)
+			c1, c2 := fromFile(t, f), fromFile(t, f)
+			tt.run(t, c1, c2)
+			if raw := isRaw(t, f); raw != tt.raw {
+				t.Fatalf("raw: %v, want %v", raw, tt.raw)
+			}
+		})
+	}
+}
+
+// echoes reports whether the terminal echoes the input
+func echoes(t *testing.T, f *os.File) bool {
+	t.Helper()
+	tios, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tios.Lflag&unix.ECHO != 0
+}
+
+func TestDumbEcho(t *testing.T) {
+	f := openPTY(t)
+	c := console.Dumb(f, f)
+	t.Cleanup(func() {
+		c.Reset()
+		c.Close()
+	})
+	if err := c.SetRaw(); err != nil {
+		t.Fatal(err)
+	}
+	if isRaw(t, f) {
+		t.Fatal("dumb console set in raw mode")
+	}
+	if err := c.DisableEcho(); err != nil {
+		t.Fatal(err)
+	}
+	if echoes(t, f) {
+		t.Fatal("echo not disabled")
+	}
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if !echoes(t, f) {
+		t.Fatal("echo not restored")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if err := console.Dumb(r, w).DisableEcho(); !errors.Is(err, console.ErrUnsupported) {
+		t.Fatalf("disable echo: %v, want %v", err, console.ErrUnsupported)
+	}
+}
+
+func TestConsoleSetRawOutput(t *testing.T) {
+	f := openPTY(t)
+	c := fromFile(t, f)
+	if err := c.(console.RawOutputSetter).SetRawOutput(); err != nil {
+		t.Fatal(err)
+	}
+	if !isRaw(t, f) {
+		t.Fatal("not raw")
+	}
+	tios, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tios.Oflag&(unix.OPOST|unix.ONLCR) != unix.OPOST|unix.ONLCR {
+		t.Fatalf("output processing disabled: %#o", tios.Oflag)
+	}
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if isRaw(t, f) {
+		t.Fatal("raw after reset")
+	}
+}
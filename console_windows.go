@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 
 	"golang.org/x/sys/windows"
 )
@@ -34,6 +35,7 @@ var (
 func (m *master) initStdios() {
 	m.in = windows.Handle(os.Stdin.Fd())
 	if err := windows.GetConsoleMode(m.in, &m.inMode); err == nil {
+		m.inOrig = m.inMode
 		// Validate that windows.ENABLE_VIRTUAL_TERMINAL_INPUT is supported, but do not set it.
 		if err = windows.SetConsoleMode(m.in, m.inMode|windows.ENABLE_VIRTUAL_TERMINAL_INPUT); err == nil {
 			vtInputSupported = true
@@ -47,6 +49,7 @@ func (m *master) initStdios() {
 
 	m.out = windows.Handle(os.Stdout.Fd())
 	if err := windows.GetConsoleMode(m.out, &m.outMode); err == nil {
+		m.outOrig = m.outMode
 		if err := windows.SetConsoleMode(m.out, m.outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err == nil {
 			m.outMode |= windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING
 		} else {
@@ -58,6 +61,7 @@ func (m *master) initStdios() {
 
 	m.err = windows.Handle(os.Stderr.Fd())
 	if err := windows.GetConsoleMode(m.err, &m.errMode); err == nil {
+		m.errOrig = m.errMode
 		if err := windows.SetConsoleMode(m.err, m.errMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err == nil {
 			m.errMode |= windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING
 		} else {
@@ -68,44 +72,85 @@ func (m *master) initStdios() {
 	}
 }
 
+// master is the Windows console.
+// The *Mode fields are the cooked modes, with virtual terminal processing
+// enabled on the outputs if supported, and the *Orig fields are the modes
+// found when the console was created, restored by Reset.
 type master struct {
 	in     windows.Handle
 	inMode uint32
+	inOrig uint32
 
 	out     windows.Handle
 	outMode uint32
+	outOrig uint32
 
 	err     windows.Handle
 	errMode uint32
+	errOrig uint32
+
+	closer
+
+	mu  sync.Mutex
+	raw bool
+	// rmu guards the raw mode read state
+	rmu       sync.Mutex
+	pending   []byte
+	surrogate uint16
+	sizes     chan WinSize
 }
 
 func (m *master) SetRaw() error {
+	if m.isClosed() {
+		return ErrClosed
+	}
+	// makeInputRaw enables windows.ENABLE_VIRTUAL_TERMINAL_INPUT if supported
 	if err := makeInputRaw(m.in, m.inMode); err != nil {
 		return err
 	}
-
-	// Set StdOut and StdErr to raw mode, we ignore failures since
-	// windows.DISABLE_NEWLINE_AUTO_RETURN might not be supported on this version of
-	// Windows.
-
-	windows.SetConsoleMode(m.out, m.outMode|windows.DISABLE_NEWLINE_AUTO_RETURN)
-
-	windows.SetConsoleMode(m.err, m.errMode|windows.DISABLE_NEWLINE_AUTO_RETURN)
+	m.mu.Lock()
+	m.raw = true
+	m.mu.Unlock()
+
+	// Set StdOut and StdErr to raw mode with virtual terminal processing so
+	// that escape sequences are interpreted like on unix terminals.
+	// We ignore failures since windows.DISABLE_NEWLINE_AUTO_RETURN and
+	// windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING might not be supported on
+	// this version of Windows.
+	setOutputRaw(m.out, m.outMode)
+	setOutputRaw(m.err, m.errMode)
 
 	return nil
 }
 
+func setOutputRaw(fd windows.Handle, mode uint32) {
+	mode |= windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING
+	if err := windows.SetConsoleMode(fd, mode|windows.DISABLE_NEWLINE_AUTO_RETURN); err == nil {
+		return
+	}
+	if err := windows.SetConsoleMode(fd, mode); err == nil {
+		return
+	}
+	windows.SetConsoleMode(fd, mode&^windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+}
+
 func (m *master) Reset() error {
+	if m.isClosed() {
+		return ErrClosed
+	}
+	m.mu.Lock()
+	m.raw = false
+	m.mu.Unlock()
 	for _, s := range []struct {
 		fd   windows.Handle
 		mode uint32
 	}{
-		{m.in, m.inMode},
-		{m.out, m.outMode},
-		{m.err, m.errMode},
+		{m.in, m.inOrig},
+		{m.out, m.outOrig},
+		{m.err, m.errOrig},
 	} {
 		if err := windows.SetConsoleMode(s.fd, s.mode); err != nil {
-			return fmt.Errorf("unable to restore console mode: %w", err)
+			return opError("reset", s.fd, err)
 		}
 	}
 
@@ -113,10 +158,13 @@ func (m *master) Reset() error {
 }
 
 func (m *master) Size() (WinSize, error) {
+	if m.isClosed() {
+		return WinSize{}, ErrClosed
+	}
 	var info windows.ConsoleScreenBufferInfo
 	err := windows.GetConsoleScreenBufferInfo(m.out, &info)
 	if err != nil {
-		return WinSize{}, fmt.Errorf("unable to get console info: %w", err)
+		return WinSize{}, opError("size", m.out, err)
 	}
 
 	winsize := WinSize{
@@ -136,26 +184,83 @@ func (m *master) ResizeFrom(c Console) error {
 }
 
 func (m *master) DisableEcho() error {
+	if m.isClosed() {
+		return ErrClosed
+	}
 	mode := m.inMode &^ windows.ENABLE_ECHO_INPUT
 	mode |= windows.ENABLE_PROCESSED_INPUT
 	mode |= windows.ENABLE_LINE_INPUT
 
 	if err := windows.SetConsoleMode(m.in, mode); err != nil {
-		return fmt.Errorf("unable to set console to disable echo: %w", err)
+		return opError("disable echo", m.in, err)
 	}
 
 	return nil
 }
 
+func (m *master) EchoDisabled() (bool, error) {
+	if m.isClosed() {
+		return false, ErrClosed
+	}
+	var mode uint32
+	if err := windows.GetConsoleMode(m.in, &mode); err != nil {
+		return false, opError("get mode", m.in, err)
+	}
+	return mode&windows.ENABLE_ECHO_INPUT == 0 && mode&windows.ENABLE_LINE_INPUT != 0, nil
+}
+
+func (m *master) IsDarkBackground() bool {
+	return isDarkBackground(func() (r, g, b uint8, err error) {
+		if !vtInputSupported {
+			return 0, 0, 0, ErrUnsupported
+		}
+		var mode uint32
+		if err := windows.GetConsoleMode(m.in, &mode); err != nil {
+			return 0, 0, 0, err
+		}
+		if err := makeInputRaw(m.in, mode); err != nil {
+			return 0, 0, 0, err
+		}
+		defer windows.SetConsoleMode(m.in, mode)
+		return QueryBackground(m, BackgroundTimeout)
+	})
+}
+
+// Close marks the console closed, the standard streams are left open
 func (m *master) Close() error {
+	m.close()
 	return nil
 }
 
+// Clone returns a new console on the standard streams, saving the modes
+// found at the time it is cloned
+func (m *master) Clone() (Console, error) {
+	c := &master{sizes: make(chan WinSize, 1)}
+	c.initStdios()
+	return c, nil
+}
+
 func (m *master) Read(b []byte) (int, error) {
+	if m.isClosed() {
+		return 0, ErrClosed
+	}
+	m.mu.Lock()
+	raw := m.raw
+	m.mu.Unlock()
+	m.rmu.Lock()
+	defer m.rmu.Unlock()
+	// in raw mode, the console input records are read directly to receive
+	// the window size events along with the keys
+	if raw || len(m.pending) > 0 {
+		return m.readRaw(b)
+	}
 	return os.Stdin.Read(b)
 }
 
 func (m *master) Write(b []byte) (int, error) {
+	if m.isClosed() {
+		return 0, ErrClosed
+	}
 	return os.Stdout.Write(b)
 }
 
@@ -181,10 +286,10 @@ func makeInputRaw(fd windows.Handle, mode uint32) error {
 	mode &^= windows.ENABLE_ECHO_INPUT
 	mode &^= windows.ENABLE_LINE_INPUT
 	mode &^= windows.ENABLE_MOUSE_INPUT
-	mode &^= windows.ENABLE_WINDOW_INPUT
 	mode &^= windows.ENABLE_PROCESSED_INPUT
 
 	// Enable these modes
+	mode |= windows.ENABLE_WINDOW_INPUT
 	mode |= windows.ENABLE_EXTENDED_FLAGS
 	mode |= windows.ENABLE_INSERT_MODE
 	mode |= windows.ENABLE_QUICK_EDIT_MODE
@@ -194,12 +299,22 @@ func makeInputRaw(fd windows.Handle, mode uint32) error {
 	}
 
 	if err := windows.SetConsoleMode(fd, mode); err != nil {
-		return fmt.Errorf("unable to set console to raw mode: %w", err)
+		return opError("set raw", fd, err)
 	}
 
 	return nil
 }
 
+// dupFile returns a new file on a duplicate of the file handle
+func dupFile(f File) (*os.File, error) {
+	p := windows.CurrentProcess()
+	var h windows.Handle
+	if err := windows.DuplicateHandle(p, windows.Handle(f.Fd()), p, &h, 0, false, windows.DUPLICATE_SAME_ACCESS); err != nil {
+		return nil, opError("clone", windows.Handle(f.Fd()), err)
+	}
+	return os.NewFile(uintptr(h), f.Name()), nil
+}
+
 func checkConsole(f File) error {
 	var mode uint32
 	if err := windows.GetConsoleMode(windows.Handle(f.Fd()), &mode); err != nil {
@@ -212,7 +327,7 @@ func newMaster(f File) (Console, error) {
 	if f != os.Stdin && f != os.Stdout && f != os.Stderr {
 		return nil, errors.New("creating a console from a file is not supported on windows")
 	}
-	m := &master{}
+	m := &master{sizes: make(chan WinSize, 1)}
 	m.initStdios()
 	return m, nil
 }
@@ -220,6 +335,10 @@ func newMaster(f File) (Console, error) {
 // FromFile returns a console using the provided file
 func FromFile(f File) (Console, error) {
 	if err := checkConsole(f); err != nil {
+		// Cygwin and MSYS ptys (mintty, Git Bash) are named pipes
+		if isCygwinPty(windows.Handle(f.Fd())) {
+			return newCygwin(f)
+		}
 		return nil, err
 	}
 	return newMaster(f)
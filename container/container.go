@@ -0,0 +1,123 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package container attaches a Term to the TTY of a container process, e.g.
+// a Docker exec session or a containerd task, without depending on their
+// client libraries: the application creates the process with a TTY and
+// passes its streams and resize call.
+//
+// With Docker, the streams are the hijacked connection of the exec start (or
+// container attach) call, see Hijacked, and the resize call is
+// ContainerExecResize:
+//
+//	resp, err := cli.ContainerExecAttach(ctx, id, types.ExecStartCheck{Tty: true})
+//	...
+//	err = container.Attach(ctx, t, container.Hijacked(resp.Conn, resp.Reader),
+//		func(ctx context.Context, rows, cols uint) error {
+//			return cli.ContainerExecResize(ctx, id, types.ResizeOptions{Height: rows, Width: cols})
+//		})
+//
+// With containerd, the streams are the ones of the task cio.Creator, and the
+// resize call is the task (or process) Resize.
+package container
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+
+	"go.linka.cloud/console/term"
+)
+
+// ResizeFunc resizes the container TTY
+type ResizeFunc func(ctx context.Context, rows, cols uint) error
+
+// Streams are the standard streams of a container process with a TTY
+type Streams struct {
+	// Stdin receives the Term input, closed, or its write side if it
+	// supports it, when the Term is closed but not detached
+	Stdin io.Writer
+	// Stdout is the TTY output, written to the Term
+	Stdout io.Reader
+}
+
+// Hijacked returns the Streams of a Docker hijacked connection, where r is
+// the reader buffering the data read with the HTTP response, if any
+func Hijacked(conn net.Conn, r *bufio.Reader) Streams {
+	s := Streams{Stdin: conn, Stdout: conn}
+	if r != nil {
+		s.Stdout = r
+	}
+	return s
+}
+
+// Attach connects the Term, in raw mode, to the container process Streams:
+// the Term input is written to Stdin, Stdout is written to the Term, and the
+// TTY is resized with resize, if not nil, when the Term is.
+// It returns when the process output ends, closing the Term, or when the
+// Term is closed or detached, returning ErrDetached in this case, leaving
+// the process running.
+func Attach(ctx context.Context, t term.Term, s Streams, resize ResizeFunc) error {
+	if resize != nil {
+		sz := t.Size()
+		if err := resize(ctx, uint(sz.Rows), uint(sz.Cols)); err != nil {
+			return err
+		}
+	}
+	go func() {
+		io.Copy(s.Stdin, t)
+		// a detached process keeps running
+		if t.Reason() != term.ReasonDetached {
+			closeWrite(s.Stdin)
+		}
+	}()
+	out := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(t, s.Stdout)
+		out <- err
+	}()
+	sizes := t.WatchSize()
+	for {
+		select {
+		case sz, ok := <-sizes:
+			if !ok {
+				sizes = nil
+				continue
+			}
+			if resize != nil {
+				resize(ctx, uint(sz.Rows), uint(sz.Cols))
+			}
+		case err := <-out:
+			t.Close()
+			return err
+		case <-t.Done():
+			return t.Wait()
+		case <-ctx.Done():
+			t.Close()
+			return ctx.Err()
+		}
+	}
+}
+
+// closeWrite signals the end of the input to w
+func closeWrite(w io.Writer) error {
+	switch c := w.(type) {
+	case interface{ CloseWrite() error }:
+		return c.CloseWrite()
+	case io.Closer:
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"os"
+	"sync"
+
+	"go.linka.cloud/console/ansi"
+)
+
+// Dumb returns a Console reading from in and writing to out for the
+// terminals not interpreting the escape sequences, e.g. $TERM=dumb, or the
+// standard streams which are not terminals, e.g. in a continuous
+// integration service.
+// The escape sequences are removed from its output, SetRaw leaves its
+// modes untouched, its size is the one returned by SizeWithFallback, and
+// closing it leaves in and out open.
+// DisableEcho and Reset act on in if it is a terminal, e.g. with
+// $TERM=dumb, and return ErrUnsupported otherwise.
+func Dumb(in, out File) Console {
+	d := &dumb{in: in, out: out}
+	if f, ok := in.(*os.File); ok {
+		d.tty, _ = FromFile(f)
+	}
+	d.w = ansi.NewStripWriter(out)
+	return d
+}
+
+// IsDumb reports whether c is a Dumb console
+func IsDumb(c Console) bool {
+	_, ok := c.(*dumb)
+	return ok
+}
+
+type dumb struct {
+	closer
+	in  File
+	out File
+	// tty is the console of in if it is a terminal, whose echo can be
+	// disabled
+	tty Console
+	// mu serializes the writes to w, which strips the escape sequences
+	mu sync.Mutex
+	w  *ansi.StripWriter
+}
+
+func (d *dumb) Read(p []byte) (int, error) {
+	if d.isClosed() {
+		return 0, ErrClosed
+	}
+	return d.in.Read(p)
+}
+
+func (d *dumb) Write(p []byte) (int, error) {
+	if d.isClosed() {
+		return 0, ErrClosed
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.w.Write(p)
+}
+
+func (d *dumb) Close() error {
+	d.close()
+	return nil
+}
+
+// Fd returns the input descriptor, waited for by WaitInput
+func (d *dumb) Fd() uintptr {
+	return d.in.Fd()
+}
+
+func (d *dumb) Name() string {
+	return d.out.Name()
+}
+
+func (d *dumb) Clone() (Console, error) {
+	return Dumb(d.in, d.out), nil
+}
+
+func (d *dumb) SetRaw() error {
+	return nil
+}
+
+func (d *dumb) DisableEcho() error {
+	if d.isClosed() {
+		return ErrClosed
+	}
+	if d.tty == nil {
+		return ErrUnsupported
+	}
+	return d.tty.DisableEcho()
+}
+
+func (d *dumb) Reset() error {
+	if d.isClosed() {
+		return ErrClosed
+	}
+	if d.tty == nil {
+		return ErrUnsupported
+	}
+	return d.tty.Reset()
+}
+
+func (d *dumb) Size() (WinSize, error) {
+	f, _ := d.out.(*os.File)
+	ws, _ := SizeWithFallback(f)
+	return ws, nil
+}
+
+func (d *dumb) Resize(WinSize) error {
+	return ErrUnsupported
+}
+
+func (d *dumb) ResizeFrom(Console) error {
+	return ErrUnsupported
+}
+
+// IsDarkBackground only uses the environment heuristics
+func (d *dumb) IsDarkBackground() bool {
+	return isDarkBackground(func() (r, g, b uint8, err error) {
+		return 0, 0, 0, ErrUnsupported
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"os"
+	"strings"
+)
+
+// Env is the environment of the process as detected by Environment
+type Env struct {
+	// CI is the name of the continuous integration service the process
+	// runs in, "ci" if it is not known, empty if none
+	CI string
+	// DumbTerm reports whether $TERM is "dumb", a terminal not interpreting
+	// the escape sequences
+	DumbTerm bool
+	// TTY reports whether one of the standard streams is a terminal
+	TTY bool
+}
+
+// Interactive reports whether the terminal features, e.g. the escape
+// sequences and the raw mode, can be used: the process runs on a terminal
+// which is not dumb, outside of a continuous integration service
+func (e Env) Interactive() bool {
+	return e.TTY && !e.DumbTerm && e.CI == ""
+}
+
+// Environment detects the environment of the process, Current returning
+// a Dumb console if it is not interactive
+func Environment() Env {
+	tty := false
+	for _, s := range []*os.File{os.Stderr, os.Stdout, os.Stdin} {
+		if _, err := FromFile(s); err == nil {
+			tty = true
+			break
+		}
+	}
+	return EnvironmentFrom(os.Getenv, tty)
+}
+
+// ciVars are the variables set by the continuous integration services
+var ciVars = []struct {
+	name string
+	env  string
+}{
+	{"github-actions", "GITHUB_ACTIONS"},
+	{"gitlab", "GITLAB_CI"},
+	{"circleci", "CIRCLECI"},
+	{"travis", "TRAVIS"},
+	{"buildkite", "BUILDKITE"},
+	{"jenkins", "JENKINS_URL"},
+	{"teamcity", "TEAMCITY_VERSION"},
+	{"azure-pipelines", "TF_BUILD"},
+	{"bitbucket", "BITBUCKET_BUILD_NUMBER"},
+	{"drone", "DRONE"},
+	{"appveyor", "APPVEYOR"},
+	{"codebuild", "CODEBUILD_BUILD_ID"},
+}
+
+// EnvironmentFrom returns the environment detected from the variables
+// returned by getenv, tty reporting whether the process runs on a terminal
+func EnvironmentFrom(getenv func(string) string, tty bool) Env {
+	e := Env{
+		DumbTerm: getenv("TERM") == "dumb",
+		TTY:      tty,
+	}
+	for _, v := range ciVars {
+		if getenv(v.env) != "" {
+			e.CI = v.name
+			return e
+		}
+	}
+	switch strings.ToLower(getenv("CI")) {
+	case "", "0", "false", "no":
+	default:
+		e.CI = "ci"
+	}
+	return e
+}
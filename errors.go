@@ -0,0 +1,75 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+)
+
+var (
+	// ErrClosed is returned by the operations on a closed console
+	ErrClosed = errors.New("console closed")
+	// ErrNotForeground is returned when the console settings cannot be
+	// changed because the process is not in its foreground process group
+	ErrNotForeground = errors.New("process not in the console foreground process group")
+	// ErrBusy is returned when the terminal modes cannot be changed because
+	// their ownership is held, see Acquire
+	ErrBusy = errors.New("terminal modes owned by another user")
+)
+
+// OpError is returned when a console operation fails with a system error
+type OpError struct {
+	// Op is the failed operation, e.g. "resize"
+	Op string
+	// Name and Fd identify the console
+	Name string
+	Fd   uintptr
+	Err  error
+	// kind is ErrClosed or ErrNotForeground if the error is one of them
+	kind error
+}
+
+func (e *OpError) Error() string {
+	s := e.Op + " " + e.Name + " (fd " + strconv.FormatUint(uint64(e.Fd), 10) + "): " + e.Err.Error()
+	if e.kind != nil {
+		s += ": " + e.kind.Error()
+	}
+	return s
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is ErrClosed or ErrNotForeground
+func (e *OpError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// closer tracks whether a console was closed
+type closer struct {
+	closed int32
+}
+
+// close marks the console closed and reports whether it was open
+func (c *closer) close() bool {
+	return atomic.CompareAndSwapInt32(&c.closed, 0, 1)
+}
+
+func (c *closer) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) != 0
+}
@@ -0,0 +1,59 @@
+//go:build !windows && !js && !plan9 && !wasip1 && !dragonfly
+// +build !windows,!js,!plan9,!wasip1,!dragonfly
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// wrapError wraps the error of the operation on the console file f in a
+// PermissionError or an OpError
+func wrapError(f File, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, os.ErrPermission) {
+		e := &PermissionError{Op: op, Name: f.Name(), Err: err}
+		if o, err := GetOwnership(f); err == nil {
+			e.Owner = &o
+		}
+		return e
+	}
+	e := &OpError{Op: op, Name: f.Name(), Fd: f.Fd(), Err: err}
+	switch {
+	case errors.Is(err, os.ErrClosed), errors.Is(err, unix.EBADF):
+		e.kind = ErrClosed
+	case errors.Is(err, unix.EIO) && !foreground(f):
+		e.kind = ErrNotForeground
+	}
+	return e
+}
+
+// foreground reports whether the process is in the foreground process group
+// of the console, or if it is unknown
+func foreground(f File) bool {
+	pgrp, err := unix.IoctlGetInt(int(f.Fd()), unix.TIOCGPGRP)
+	if err != nil {
+		return true
+	}
+	own, err := unix.Getpgid(0)
+	return err != nil || pgrp == own
+}
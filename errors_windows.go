@@ -0,0 +1,34 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// opError wraps the error of the operation on the console handle h in an
+// OpError
+func opError(op string, h windows.Handle, err error) error {
+	if err == nil {
+		return nil
+	}
+	e := &OpError{Op: op, Name: "console", Fd: uintptr(h), Err: err}
+	if errors.Is(err, windows.ERROR_INVALID_HANDLE) {
+		e.kind = ErrClosed
+	}
+	return e
+}
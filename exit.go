@@ -0,0 +1,85 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	hmu   sync.Mutex
+	hooks []*hook
+)
+
+type hook struct {
+	fn   func() error
+	once sync.Once
+	err  error
+}
+
+func (h *hook) run() error {
+	h.once.Do(func() {
+		h.err = h.fn()
+	})
+	return h.err
+}
+
+// OnExit registers fn to restore a terminal state changed by the process,
+// e.g. the raw mode or the mouse reporting, if the process exits with Exit
+// or calls Unwind before it is restored.
+// It returns the function restoring the state: it calls fn, if it was not
+// called yet, unregisters it and returns its error.
+// The functions registered are called in the reverse order, so that a
+// partial initialization unwinds like a complete one.
+func OnExit(fn func() error) (done func() error) {
+	h := &hook{fn: fn}
+	hmu.Lock()
+	hooks = append(hooks, h)
+	hmu.Unlock()
+	return func() error {
+		hmu.Lock()
+		for i, v := range hooks {
+			if v == h {
+				hooks = append(hooks[:i:i], hooks[i+1:]...)
+				break
+			}
+		}
+		hmu.Unlock()
+		return h.run()
+	}
+}
+
+// Unwind calls the functions registered with OnExit which were not called
+// yet, the last registered first, e.g. before the process exits on a panic
+func Unwind() {
+	for {
+		hmu.Lock()
+		if len(hooks) == 0 {
+			hmu.Unlock()
+			return
+		}
+		h := hooks[len(hooks)-1]
+		hooks = hooks[:len(hooks)-1]
+		hmu.Unlock()
+		h.run()
+	}
+}
+
+// Exit calls Unwind and exits the process with the status code
+func Exit(code int) {
+	Unwind()
+	os.Exit(code)
+}
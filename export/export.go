@@ -0,0 +1,96 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export renders the content of a terminal, e.g. the scrollback
+// and the screen of a vt.Terminal or of a replayed session, as plain text,
+// HTML with inline styles, or ANSI text, to embed terminal output in
+// reports and web pages.
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/audit"
+	"go.linka.cloud/console/screen"
+	"go.linka.cloud/console/vt"
+)
+
+// Text writes the text of the rows, without the styles, one line per row
+func Text(w io.Writer, rows [][]screen.Cell) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range rows {
+		var b strings.Builder
+		for _, c := range r {
+			b.WriteString(c.String())
+		}
+		bw.WriteString(strings.TrimRight(b.String(), " "))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// ANSI writes the rows with their styles as SGR sequences, one line per
+// row, e.g. to be printed on a terminal or kept as a raw dump
+func ANSI(w io.Writer, rows [][]screen.Cell) error {
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	for _, r := range rows {
+		buf = buf[:0]
+		style := ""
+		for _, c := range r {
+			if c.Continuation {
+				continue
+			}
+			if c.Style != style {
+				buf = append(buf, ansi.ResetStyle...)
+				if c.Style != "" {
+					buf = ansi.AppendSGR(buf, c.Style)
+				}
+				style = c.Style
+			}
+			buf = append(buf, c.String()...)
+		}
+		if style != "" {
+			buf = append(buf, ansi.ResetStyle...)
+		}
+		bw.Write(buf)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// FromAudit replays the output records of an audit log in a new
+// vt.Terminal of the given size, whose Snapshot can then be exported
+func FromAudit(r io.Reader, cols, rows int, opts ...vt.Option) (*vt.Terminal, error) {
+	t := vt.New(cols, rows, opts...)
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64<<10), 16<<20)
+	for s.Scan() {
+		var rec audit.Record
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+		if rec.Type == audit.TypeOutput {
+			t.Write(rec.Data)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
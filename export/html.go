@@ -0,0 +1,195 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"go.linka.cloud/console/palette"
+	"go.linka.cloud/console/screen"
+	"go.linka.cloud/console/vt"
+)
+
+type htmlOptions struct {
+	fg, bg palette.RGB
+	colors [16]palette.RGB
+}
+
+// HTMLOption configures HTML
+type HTMLOption func(o *htmlOptions)
+
+// WithTheme sets the default foreground and background colors,
+// defaults to light gray on black
+func WithTheme(fg, bg palette.RGB) HTMLOption {
+	return func(o *htmlOptions) {
+		o.fg, o.bg = fg, bg
+	}
+}
+
+// WithColors sets the values of the 16 ANSI colors,
+// defaults to palette.ANSI
+func WithColors(colors [16]palette.RGB) HTMLOption {
+	return func(o *htmlOptions) {
+		o.colors = colors
+	}
+}
+
+// HTML writes the rows as a pre element, the styles being rendered as
+// spans with inline CSS
+func HTML(w io.Writer, rows [][]screen.Cell, opts ...HTMLOption) error {
+	o := htmlOptions{fg: palette.ANSI[7], bg: palette.ANSI[0], colors: palette.ANSI}
+	for _, v := range opts {
+		v(&o)
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<pre style="color:%s;background-color:%s">`, css(o.fg), css(o.bg))
+	for _, r := range rows {
+		var text strings.Builder
+		style := ""
+		flush := func() {
+			if text.Len() == 0 {
+				return
+			}
+			if s := o.css(style); s != "" {
+				fmt.Fprintf(bw, `<span style="%s">%s</span>`, s, html.EscapeString(text.String()))
+			} else {
+				bw.WriteString(html.EscapeString(text.String()))
+			}
+			text.Reset()
+		}
+		for _, c := range r {
+			if c.Continuation {
+				continue
+			}
+			if c.Style != style {
+				flush()
+				style = c.Style
+			}
+			text.WriteString(c.String())
+		}
+		flush()
+		bw.WriteByte('\n')
+	}
+	bw.WriteString("</pre>\n")
+	return bw.Flush()
+}
+
+// css returns the CSS declarations of the SGR parameters
+func (o htmlOptions) css(sgr string) string {
+	if sgr == "" {
+		return ""
+	}
+	var params []int
+	for _, v := range strings.Split(sgr, ";") {
+		n, _ := strconv.Atoi(v)
+		params = append(params, n)
+	}
+	var fg, bg *palette.RGB
+	var decls []string
+	var bold, reverse, hidden bool
+	var decorations []string
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 1:
+			bold = true
+		case p == 2:
+			decls = append(decls, "opacity:0.5")
+		case p == 3:
+			decls = append(decls, "font-style:italic")
+		case p == 4:
+			decorations = append(decorations, "underline")
+		case p == 7:
+			reverse = true
+		case p == 8:
+			hidden = true
+		case p == 9:
+			decorations = append(decorations, "line-through")
+		case p >= 30 && p <= 37:
+			fg = &o.colors[p-30]
+		case p >= 90 && p <= 97:
+			fg = &o.colors[p-90+8]
+		case p >= 40 && p <= 47:
+			bg = &o.colors[p-40]
+		case p >= 100 && p <= 107:
+			bg = &o.colors[p-100+8]
+		case p == 38 || p == 48:
+			c, n := o.extended(params[i:])
+			i += n
+			if c == nil {
+				continue
+			}
+			if p == 38 {
+				fg = c
+			} else {
+				bg = c
+			}
+		}
+	}
+	if reverse {
+		f, b := o.fg, o.bg
+		if fg != nil {
+			f = *fg
+		}
+		if bg != nil {
+			b = *bg
+		}
+		fg, bg = &b, &f
+	}
+	if fg != nil {
+		decls = append(decls, "color:"+css(*fg))
+	}
+	if bg != nil {
+		decls = append(decls, "background-color:"+css(*bg))
+	}
+	if bold {
+		decls = append(decls, "font-weight:bold")
+	}
+	if hidden {
+		decls = append(decls, "visibility:hidden")
+	}
+	if len(decorations) > 0 {
+		decls = append(decls, "text-decoration:"+strings.Join(decorations, " "))
+	}
+	return strings.Join(decls, ";")
+}
+
+// extended decodes the 256 or true color parameters starting with 38 or 48,
+// see vt.ParseExtendedColor, and returns the color and the number of
+// parameters used after the first
+func (o htmlOptions) extended(params []int) (*palette.RGB, int) {
+	e, n, ok := vt.ParseExtendedColor(params)
+	switch {
+	case !ok:
+		return nil, n
+	case e.RGB:
+		return &palette.RGB{R: e.R, G: e.G, B: e.B}, n
+	case e.Index < 16:
+		c := o.colors[e.Index]
+		return &c, n
+	default:
+		c := palette.Xterm256(e.Index)
+		return &c, n
+	}
+}
+
+func css(c palette.RGB) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// File is a Store persisting the entries in a file, one per line, the
+// newlines and backslashes of the entries being escaped.
+// The file is locked while it is read or written, so that it can be shared
+// by the processes. The lines longer than an escaped entry of MaxEntrySize
+// are skipped when it is loaded.
+type File struct {
+	path string
+	max  int
+	mu   sync.Mutex
+}
+
+// NewFile returns a File store, created with mode 0600 if it does not
+// exist. With max greater than 0, the file is truncated to its max last
+// entries when it is loaded.
+func NewFile(path string, max int) *File {
+	return &File{path: path, max: max}
+}
+
+func (f *File) Load() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	unlock, err := lock(file)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var entries []string
+	r := bufio.NewReader(file)
+	for {
+		line, ok, err := readLine(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			entries = append(entries, unescape(string(line)))
+		}
+	}
+	if f.max <= 0 || len(entries) <= f.max {
+		return entries, nil
+	}
+	entries = entries[len(entries)-f.max:]
+	var b bytes.Buffer
+	for _, v := range entries {
+		b.WriteString(escape(v))
+		b.WriteByte('\n')
+	}
+	if err := file.Truncate(0); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(b.Bytes()); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Append returns ErrEntryTooLong if the entry is longer than MaxEntrySize
+func (f *File) Append(entry string) error {
+	if len(entry) > MaxEntrySize {
+		return ErrEntryTooLong
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	unlock, err := lock(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	_, err = io.WriteString(file, escape(entry)+"\n")
+	unlock()
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// maxLine is the maximum size of a line, an escaped entry of MaxEntrySize
+const maxLine = 2 * MaxEntrySize
+
+// readLine reads a line without its newline, and reports whether it is not
+// longer than maxLine, the longer ones being discarded
+func readLine(r *bufio.Reader) ([]byte, bool, error) {
+	var line []byte
+	n := 0
+	for {
+		p, err := r.ReadSlice('\n')
+		n += len(p)
+		if n <= maxLine+1 {
+			line = append(line, p...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && n > 0 {
+			err = nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			return line[:len(line)-1], true, nil
+		}
+		return line, n <= maxLine, nil
+	}
+}
+
+var (
+	escaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	unescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
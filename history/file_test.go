@@ -0,0 +1,53 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileLongEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	long := strings.Repeat("x", maxLine+1)
+	if err := ioutil.WriteFile(path, []byte("first\n"+long+"\nsecond\n"+long+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFile(path, 0)
+	if err := f.Append(long); !errors.Is(err, ErrEntryTooLong) {
+		t.Fatalf("append: %v, want %v", err, ErrEntryTooLong)
+	}
+	h, err := New(WithStore(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Add(long); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Add("third"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := f.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", "second", "third"}; !reflect.DeepEqual(entries, want) {
+		t.Fatalf("got %q, want %q", entries, want)
+	}
+}
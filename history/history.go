@@ -0,0 +1,193 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history provides the input history of the line editors, kept in
+// memory and persisted in a Store, e.g. a file shared by the sessions like
+// the shells history.
+package history
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxSize is the default number of entries kept by a History
+const DefaultMaxSize = 1000
+
+// MaxEntrySize is the maximum size in bytes of an entry, the longer ones
+// are not added
+const MaxEntrySize = 1 << 20
+
+// ErrEntryTooLong is returned by the stores appending an entry longer than
+// MaxEntrySize
+var ErrEntryTooLong = errors.New("history: entry too long")
+
+// Dedupe is how the duplicated entries are handled
+type Dedupe int
+
+const (
+	// DedupeNone keeps all the entries
+	DedupeNone Dedupe = iota
+	// DedupeConsecutive ignores an entry identical to the previous one,
+	// like the bash ignoredups
+	DedupeConsecutive
+	// DedupeAll removes the previous occurrences of an entry, like the
+	// bash erasedups
+	DedupeAll
+)
+
+// Store persists the history entries
+type Store interface {
+	// Load returns the stored entries, oldest first
+	Load() ([]string, error)
+	// Append stores a new entry
+	Append(entry string) error
+}
+
+type options struct {
+	store  Store
+	max    int
+	dedupe Dedupe
+}
+
+// Option configures a History
+type Option func(o *options)
+
+// WithStore sets the Store the History is loaded from and the entries are
+// appended to
+func WithStore(s Store) Option {
+	return func(o *options) {
+		o.store = s
+	}
+}
+
+// WithMaxSize sets the number of entries kept, the oldest being dropped,
+// defaults to DefaultMaxSize, 0 keeps them all
+func WithMaxSize(n int) Option {
+	return func(o *options) {
+		o.max = n
+	}
+}
+
+// WithDedupe sets how the duplicated entries are handled, defaults to
+// DedupeConsecutive
+func WithDedupe(d Dedupe) Option {
+	return func(o *options) {
+		o.dedupe = d
+	}
+}
+
+// History is the list of the entered lines
+type History struct {
+	opts    options
+	mu      sync.RWMutex
+	entries []string
+}
+
+// New returns a History loaded from its Store, if any
+func New(opts ...Option) (*History, error) {
+	o := options{max: DefaultMaxSize, dedupe: DedupeConsecutive}
+	for _, v := range opts {
+		v(&o)
+	}
+	h := &History{opts: o}
+	if o.store == nil {
+		return h, nil
+	}
+	entries, err := o.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range entries {
+		h.add(v)
+	}
+	return h, nil
+}
+
+// Add adds an entry to the History and its Store, the blank entries, the
+// ones longer than MaxEntrySize and the duplicates ignored by the dedupe
+// mode are not added
+func (h *History) Add(entry string) error {
+	h.mu.Lock()
+	ok := h.add(entry)
+	h.mu.Unlock()
+	if !ok || h.opts.store == nil {
+		return nil
+	}
+	return h.opts.store.Append(entry)
+}
+
+// add adds the entry and reports whether it was, h.mu must be held
+func (h *History) add(entry string) bool {
+	if len(entry) > MaxEntrySize || strings.TrimSpace(entry) == "" {
+		return false
+	}
+	switch h.opts.dedupe {
+	case DedupeConsecutive:
+		if n := len(h.entries); n > 0 && h.entries[n-1] == entry {
+			return false
+		}
+	case DedupeAll:
+		for i := 0; i < len(h.entries); i++ {
+			if h.entries[i] == entry {
+				h.entries = append(h.entries[:i], h.entries[i+1:]...)
+				i--
+			}
+		}
+	}
+	h.entries = append(h.entries, entry)
+	if h.opts.max > 0 && len(h.entries) > h.opts.max {
+		h.entries = append(h.entries[:0], h.entries[len(h.entries)-h.opts.max:]...)
+	}
+	return true
+}
+
+// Len returns the number of entries
+func (h *History) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.entries)
+}
+
+// At returns the i-th entry, the oldest being 0
+func (h *History) At(i int) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.entries[i]
+}
+
+// Entries returns a copy of the entries, oldest first
+func (h *History) Entries() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]string(nil), h.entries...)
+}
+
+// Search returns the index of the most recent entry containing s before
+// the index from, e.g. Len() to search all the entries, for the reverse
+// incremental search
+func (h *History) Search(s string, from int) (int, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if from > len(h.entries) {
+		from = len(h.entries)
+	}
+	for i := from - 1; i >= 0; i-- {
+		if strings.Contains(h.entries[i], s) {
+			return i, true
+		}
+	}
+	return -1, false
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"sync"
+)
+
+// Memory is a Store keeping the entries in memory, e.g. to share them
+// between the sessions of a server
+type Memory struct {
+	max     int
+	mu      sync.Mutex
+	entries []string
+}
+
+// NewMemory returns a Memory store keeping up to max entries, 0 keeps them
+// all
+func NewMemory(max int) *Memory {
+	return &Memory{max: max}
+}
+
+func (m *Memory) Load() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.entries...), nil
+}
+
+func (m *Memory) Append(entry string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entry)
+	if m.max > 0 && len(m.entries) > m.max {
+		m.entries = append(m.entries[:0], m.entries[len(m.entries)-m.max:]...)
+	}
+	return nil
+}
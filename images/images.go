@@ -0,0 +1,193 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/caps"
+)
+
+var ErrNoProtocol = errors.New("terminal does not support inline images")
+
+// Protocol is an inline image protocol
+type Protocol int
+
+const (
+	None Protocol = iota
+	Sixel
+	ITerm2
+	Kitty
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case Sixel:
+		return "sixel"
+	case ITerm2:
+		return "iterm2"
+	case Kitty:
+		return "kitty"
+	default:
+		return "none"
+	}
+}
+
+// Select returns the best protocol supported by the profile
+func Select(p caps.Profile) Protocol {
+	switch {
+	case p.Graphics.Has(caps.Kitty):
+		return Kitty
+	case p.Graphics.Has(caps.ITerm2):
+		return ITerm2
+	case p.Graphics.Has(caps.Sixel):
+		return Sixel
+	default:
+		return None
+	}
+}
+
+type options struct {
+	protocol   Protocol
+	cols, rows int
+	cellW      int
+	cellH      int
+	console    console.Console
+	timeout    time.Duration
+}
+
+type Option func(o *options)
+
+// WithProtocol forces the protocol to use instead of the detected one
+func WithProtocol(p Protocol) Option {
+	return func(o *options) {
+		o.protocol = p
+	}
+}
+
+// WithSize sets the terminal size in cells the image must fit in
+func WithSize(cols, rows int) Option {
+	return func(o *options) {
+		o.cols = cols
+		o.rows = rows
+	}
+}
+
+// WithCellSize sets the size in pixels of a terminal cell,
+// used to compute the image maximum size in pixels
+func WithCellSize(width, height int) Option {
+	return func(o *options) {
+		o.cellW = width
+		o.cellH = height
+	}
+}
+
+// WithConsole sets the terminal size in cells from the console, and the
+// cell size from its metrics, see QueryMetrics, keeping the default or
+// provided cell size if they are not reported
+func WithConsole(c console.Console, timeout time.Duration) Option {
+	return func(o *options) {
+		o.console = c
+		o.timeout = timeout
+	}
+}
+
+// Write writes the image to w using the best protocol supported by the
+// current terminal, downscaling it to fit in the terminal size if provided.
+// It is wrapped for the multiplexer the process runs in, see
+// ansi.Passthrough.
+func Write(w io.Writer, img image.Image, opts ...Option) error {
+	o := options{protocol: Select(caps.Detect()), cellW: 10, cellH: 20}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.console != nil {
+		if ws, err := o.console.Size(); err == nil {
+			o.cols, o.rows = int(ws.Width), int(ws.Height)
+		}
+		if m, err := QueryMetrics(o.console, o.timeout); err == nil && m.CellWidth > 0 && m.CellHeight > 0 {
+			o.cellW, o.cellH = m.CellWidth, m.CellHeight
+		}
+	}
+	if o.cols > 0 && o.rows > 0 {
+		// keep the last row free for the cursor
+		rows := o.rows - 1
+		if rows < 1 {
+			rows = 1
+		}
+		img = Fit(img, o.cols*o.cellW, rows*o.cellH)
+	}
+	if o.protocol == None {
+		return ErrNoProtocol
+	}
+	if m := ansi.PassthroughMultiplexer(); m != ansi.NoMultiplexer {
+		// the image is wrapped in the multiplexer passthrough envelope
+		var buf bytes.Buffer
+		if err := write(&buf, o.protocol, img); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, m.Wrap(buf.String()))
+		return err
+	}
+	return write(w, o.protocol, img)
+}
+
+func write(w io.Writer, p Protocol, img image.Image) error {
+	switch p {
+	case Sixel:
+		return writeSixel(w, img)
+	case ITerm2:
+		return writeITerm2(w, img)
+	case Kitty:
+		return writeKitty(w, img)
+	default:
+		return ErrNoProtocol
+	}
+}
+
+// Fit downscales the image using nearest neighbour sampling so that it fits
+// in the given size, preserving its aspect ratio.
+// The image is returned as is if it already fits.
+func Fit(img image.Image, maxWidth, maxHeight int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxWidth && h <= maxHeight || w == 0 || h == 0 {
+		return img
+	}
+	nw, nh := maxWidth, h*maxWidth/w
+	if nh > maxHeight {
+		nw, nh = w*maxHeight/h, maxHeight
+	}
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+	out := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		sy := b.Min.Y + y*h/nh
+		for x := 0; x < nw; x++ {
+			out.Set(x, y, img.At(b.Min.X+x*w/nw, sy))
+		}
+	}
+	return out
+}
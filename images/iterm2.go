@@ -0,0 +1,37 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"go.linka.cloud/console/ansi"
+)
+
+func writeITerm2(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	b := img.Bounds()
+	_, err := fmt.Fprintf(w, "%s1337;File=inline=1;size=%d;width=%dpx;height=%dpx;preserveAspectRatio=1:%s%s",
+		ansi.OSC, buf.Len(), b.Dx(), b.Dy(), base64.StdEncoding.EncodeToString(buf.Bytes()), ansi.BEL)
+	return err
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"io"
+
+	"go.linka.cloud/console/ansi"
+)
+
+// kittyChunk is the maximum payload size of a single kitty graphics command
+const kittyChunk = 4096
+
+func writeKitty(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	bw := bufio.NewWriter(w)
+	for first := true; first || len(data) > 0; first = false {
+		n := len(data)
+		if n > kittyChunk {
+			n = kittyChunk
+		}
+		bw.WriteString(ansi.APC + "G")
+		if first {
+			bw.WriteString("a=T,f=100,")
+		}
+		if n < len(data) {
+			bw.WriteString("m=1;")
+		} else {
+			bw.WriteString("m=0;")
+		}
+		bw.WriteString(data[:n])
+		bw.WriteString(ansi.ST)
+		data = data[n:]
+	}
+	return bw.Flush()
+}
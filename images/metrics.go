@@ -0,0 +1,68 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/ansi"
+)
+
+// Metrics are the terminal dimensions in pixels
+type Metrics struct {
+	// Width and Height are the size of the text area
+	Width, Height int
+	// CellWidth and CellHeight are the size of a cell
+	CellWidth, CellHeight int
+}
+
+// QueryMetrics returns the terminal dimensions in pixels, from the console
+// window size if it reports them, querying the terminal with XTWINOPS
+// otherwise, in which case the console must be in raw mode.
+// It returns console.ErrUnsupported if the terminal does not report them.
+func QueryMetrics(c console.Console, timeout time.Duration) (Metrics, error) {
+	ws, err := c.Size()
+	if err != nil {
+		return Metrics{}, err
+	}
+	cols, rows := int(ws.Width), int(ws.Height)
+	if ws.PixelWidth > 0 && ws.PixelHeight > 0 && cols > 0 && rows > 0 {
+		return metrics(int(ws.PixelWidth), int(ws.PixelHeight), cols, rows), nil
+	}
+	req := ansi.RequestCellPixels + ansi.RequestWindowPixels + ansi.RequestPrimaryAttributes
+	res, err := console.Query(c, req, timeout, func(b []byte) bool {
+		_, ok := ansi.ParsePrimaryAttributes(b)
+		return ok
+	})
+	if err != nil {
+		return Metrics{}, err
+	}
+	h, w, ok := ansi.ParseWindowReport(res, 4)
+	if ch, cw, cok := ansi.ParseWindowReport(res, 6); cok && cw > 0 && ch > 0 {
+		if !ok {
+			w, h = cw*cols, ch*rows
+		}
+		return Metrics{Width: w, Height: h, CellWidth: cw, CellHeight: ch}, nil
+	}
+	if !ok || w <= 0 || h <= 0 || cols <= 0 || rows <= 0 {
+		return Metrics{}, console.ErrUnsupported
+	}
+	return metrics(w, h, cols, rows), nil
+}
+
+func metrics(width, height, cols, rows int) Metrics {
+	return Metrics{Width: width, Height: height, CellWidth: width / cols, CellHeight: height / rows}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"io"
+
+	"go.linka.cloud/console/ansi"
+)
+
+func writeSixel(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette.Plan9)
+	draw.FloydSteinberg.Draw(p, p.Bounds(), img, b.Min)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s0;1;0q\"1;1;%d;%d", ansi.DCS, p.Rect.Dx(), p.Rect.Dy())
+	var defined [256]bool
+	for _, i := range p.Pix {
+		defined[i] = true
+	}
+	for i, c := range p.Palette {
+		if !defined[i] {
+			continue
+		}
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+	width, height := p.Rect.Dx(), p.Rect.Dy()
+	band := make([]byte, width)
+	for y := 0; y < height; y += 6 {
+		used := make(map[uint8]struct{})
+		for dy := 0; dy < 6 && y+dy < height; dy++ {
+			for x := 0; x < width; x++ {
+				used[p.ColorIndexAt(x, y+dy)] = struct{}{}
+			}
+		}
+		first := true
+		for i := range p.Palette {
+			if _, ok := used[uint8(i)]; !ok {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && y+dy < height; dy++ {
+					if p.ColorIndexAt(x, y+dy) == uint8(i) {
+						bits |= 1 << dy
+					}
+				}
+				band[x] = '?' + bits
+			}
+			if !first {
+				bw.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(bw, "#%d", i)
+			writeRLE(bw, band)
+		}
+		bw.WriteByte('-')
+	}
+	bw.WriteString(ansi.ST)
+	return bw.Flush()
+}
+
+func writeRLE(w *bufio.Writer, band []byte) {
+	for i := 0; i < len(band); {
+		j := i + 1
+		for j < len(band) && band[j] == band[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(w, "!%d%c", n, band[i])
+		} else {
+			w.Write(band[i:j])
+		}
+		i = j
+	}
+}
@@ -0,0 +1,512 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	pasteStart = []byte("\x1b[200~")
+	pasteEnd   = []byte("\x1b[201~")
+)
+
+// Parse decodes the first event in buf and returns it along with the number
+// of bytes it used.
+// It returns 0 if buf only contains the beginning of an event.
+func Parse(buf []byte) (Event, int) {
+	if len(buf) == 0 {
+		return nil, 0
+	}
+	switch c := buf[0]; {
+	case c == 0x1b:
+		if bytes.HasPrefix(buf, pasteStart) {
+			return parsePaste(buf, 0)
+		}
+		return parseEscape(buf)
+	case c < 0x20 || c == 0x7f:
+		return control(c), 1
+	case c < utf8.RuneSelf:
+		return KeyEvent{Key: KeyRune, Rune: rune(c)}, 1
+	}
+	if !utf8.FullRune(buf) {
+		return nil, 0
+	}
+	r, n := utf8.DecodeRune(buf)
+	return KeyEvent{Key: KeyRune, Rune: r}, n
+}
+
+// parsePaste parses a bracketed paste, looking for its end from the offset
+// from, where the previous attempt stopped
+func parsePaste(buf []byte, from int) (Event, int) {
+	if from < len(pasteStart) {
+		from = len(pasteStart)
+	}
+	i := bytes.Index(buf[from:], pasteEnd)
+	if i < 0 {
+		return nil, 0
+	}
+	i += from
+	return PasteEvent(buf[len(pasteStart):i]), i + len(pasteEnd)
+}
+
+func control(c byte) KeyEvent {
+	switch c {
+	case '\r', '\n':
+		return KeyEvent{Key: KeyEnter}
+	case '\t':
+		return KeyEvent{Key: KeyTab}
+	case 0x7f, 0x08:
+		return KeyEvent{Key: KeyBackspace}
+	case 0x1b:
+		return KeyEvent{Key: KeyEscape}
+	case 0x00:
+		return KeyEvent{Key: KeyRune, Rune: ' ', Mod: ModCtrl}
+	case 0x1c, 0x1d, 0x1e, 0x1f:
+		return KeyEvent{Key: KeyRune, Rune: rune(c) + '\\' - 0x1c, Mod: ModCtrl}
+	default:
+		return KeyEvent{Key: KeyRune, Rune: rune(c) + 'a' - 1, Mod: ModCtrl}
+	}
+}
+
+func parseEscape(buf []byte) (Event, int) {
+	if len(buf) == 1 {
+		return nil, 0
+	}
+	switch buf[1] {
+	case '[':
+		return parseCSI(buf)
+	case 'O':
+		if len(buf) < 3 {
+			return nil, 0
+		}
+		if k, ok := ss3Keys[buf[2]]; ok {
+			return KeyEvent{Key: k}, 3
+		}
+		return UnknownEvent(buf[:3]), 3
+	case 0x1b:
+		return KeyEvent{Key: KeyEscape}, 1
+	}
+	ev, n := Parse(buf[1:])
+	if n == 0 {
+		return nil, 0
+	}
+	if k, ok := ev.(KeyEvent); ok {
+		k.Mod |= ModAlt
+		return k, n + 1
+	}
+	return UnknownEvent(buf[:n+1]), n + 1
+}
+
+var ss3Keys = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+var csiKeys = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+var tildeKeys = map[int]Key{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPageUp,
+	6:  KeyPageDown,
+	7:  KeyHome,
+	8:  KeyEnd,
+	11: KeyF1,
+	12: KeyF2,
+	13: KeyF3,
+	14: KeyF4,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+func parseCSI(buf []byte) (Event, int) {
+	i := 2
+	for ; i < len(buf); i++ {
+		if buf[i] >= 0x40 && buf[i] <= 0x7e {
+			break
+		}
+		if buf[i] < 0x20 {
+			// not a valid sequence: treat the introducer as alt+[
+			return KeyEvent{Key: KeyRune, Rune: '[', Mod: ModAlt}, 2
+		}
+	}
+	if i == len(buf) {
+		return nil, 0
+	}
+	n := i + 1
+	final := buf[i]
+	params := strings.Split(string(buf[2:i]), ";")
+	mod := Mod(0)
+	if len(params) > 1 {
+		if m, err := strconv.Atoi(params[1]); err == nil && m > 1 {
+			mod = Mod(m - 1)
+		}
+	}
+	switch final {
+	case 'I':
+		return FocusGained{}, n
+	case 'O':
+		return FocusLost{}, n
+	case 'Z':
+		return KeyEvent{Key: KeyTab, Mod: ModShift}, n
+	case '~':
+		p, err := strconv.Atoi(params[0])
+		if k, ok := tildeKeys[p]; ok && err == nil {
+			return KeyEvent{Key: k, Mod: mod}, n
+		}
+	default:
+		if k, ok := csiKeys[final]; ok {
+			return KeyEvent{Key: k, Mod: mod}, n
+		}
+	}
+	return UnknownEvent(buf[:n]), n
+}
+
+// bulkRead is the read size used while receiving a paste
+const bulkRead = 64 << 10
+
+// Decoder reads events from a terminal input stream
+type Decoder struct {
+	r   io.Reader
+	buf []byte
+	tmp []byte
+	err error
+	// scan is the offset from which to look for the end of the bracketed
+	// paste at the beginning of buf
+	scan int
+	// threshold is the minimum size of the printable bursts reported as
+	// paste events, 0 disables it
+	threshold int
+	// interval is the delay between reads under which the input is
+	// probably pasted, 0 disables the heuristic
+	interval time.Duration
+	// last is the time of the last read, burst is set if it followed the
+	// previous one within interval
+	last  time.Time
+	burst bool
+	// shared is set when the next event was read along with the last one
+	shared bool
+	pasted bool
+	// escTimeout is how long the rest of an escape sequence is waited for,
+	// 0 if the reads are not timed
+	escTimeout time.Duration
+	// reads receives the input read by the goroutine started for the timed
+	// reads
+	reads chan readResult
+}
+
+type readResult struct {
+	b   []byte
+	err error
+}
+
+// NewDecoder returns a Decoder reading from r, usually a raw mode console
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, tmp: make([]byte, 256)}
+}
+
+// SetPasteThreshold enables the bulk mode for terminals not supporting
+// bracketed paste: runs of at least n printable bytes received at once are
+// reported as a single PasteEvent instead of one KeyEvent per rune.
+// Typed keys are read one by one, so they are not affected.
+// 0 disables it, which is the default.
+func (d *Decoder) SetPasteThreshold(n int) {
+	d.threshold = n
+}
+
+// DefaultEscapeTimeout is an escape timeout long enough for the sequences
+// split by slow links
+const DefaultEscapeTimeout = 50 * time.Millisecond
+
+// SetEscapeTimeout sets how long the rest of an escape sequence is waited
+// for, like vim's ttimeoutlen: an escape not followed by the rest of a
+// sequence within timeout is the escape key.
+// By default, an escape at the end of a read is the escape key, as
+// terminals write sequences at once, which misparses the sequences split
+// across reads, e.g. by a network link.
+// With a timeout, the input is read by a goroutine until it fails.
+func (d *Decoder) SetEscapeTimeout(timeout time.Duration) {
+	d.escTimeout = timeout
+}
+
+// DefaultPasteInterval is a delay between reads under which the input is
+// faster than typed
+const DefaultPasteInterval = 5 * time.Millisecond
+
+// SetPasteInterval enables the paste heuristic for terminals not supporting
+// bracketed paste: the events read along with other events, or within
+// interval of the previous read, are flagged as probably pasted, see Pasted.
+// The keys typed while the application is not reading, or repeated faster
+// than interval, are flagged too.
+// 0 disables it, which is the default.
+func (d *Decoder) SetPasteInterval(interval time.Duration) {
+	d.interval = interval
+}
+
+// Pasted reports whether the last event returned by ReadEvent is probably
+// part of a paste, e.g. for a line editor to disable auto-indentation and
+// completion. It is always true for a PasteEvent, and false for the other
+// events if SetPasteInterval was not called.
+func (d *Decoder) Pasted() bool {
+	return d.pasted
+}
+
+// ReadEvent returns the next input event
+func (d *Decoder) ReadEvent() (Event, error) {
+	ev, err := d.readEvent()
+	if ev != nil {
+		d.mark(ev)
+	}
+	return ev, err
+}
+
+// mark records whether ev is probably pasted
+func (d *Decoder) mark(ev Event) {
+	if _, ok := ev.(PasteEvent); ok {
+		d.pasted = true
+	} else {
+		d.pasted = d.interval > 0 && (d.burst || d.shared || len(d.buf) > 0)
+	}
+	d.shared = len(d.buf) > 0
+}
+
+func (d *Decoder) readEvent() (Event, error) {
+	for {
+		if bytes.HasPrefix(d.buf, pasteStart) {
+			if ev, n := parsePaste(d.buf, d.scan); n > 0 {
+				d.buf, d.scan = d.buf[n:], 0
+				return ev, nil
+			}
+			if d.err == nil {
+				// the end sequence may be split across reads
+				d.scan = len(d.buf) - len(pasteEnd) + 1
+				d.fill(bulkRead)
+				continue
+			}
+			d.scan = 0
+		} else if n := d.printable(); n > 0 {
+			ev := PasteEvent(d.buf[:n])
+			d.buf = d.buf[n:]
+			return ev, nil
+		} else if n, more := d.text(); more && d.err == nil {
+			// the rest of the text is in the next read
+			d.fill(len(d.tmp))
+			continue
+		} else if n > 0 {
+			ev := TextEvent(d.buf[:n])
+			d.buf = d.buf[n:]
+			return ev, nil
+		}
+		if ev, n := Parse(d.buf); n > 0 {
+			d.buf = d.buf[n:]
+			return ev, nil
+		}
+		if len(d.buf) > 0 && d.err != nil {
+			return d.incomplete(), nil
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		if d.escTimeout > 0 && len(d.buf) > 0 && d.buf[0] == 0x1b {
+			// an escape not followed by the rest of the sequence in time
+			// is the escape key
+			if !d.fillWithin(d.escTimeout) {
+				return d.incomplete(), nil
+			}
+			continue
+		}
+		// a lone escape at the end of a read is the escape key:
+		// terminals write sequences at once
+		if d.escTimeout == 0 && len(d.buf) == 1 && d.buf[0] == 0x1b {
+			return d.incomplete(), nil
+		}
+		d.fill(len(d.tmp))
+	}
+}
+
+// fill reads up to n bytes from the input
+func (d *Decoder) fill(n int) {
+	if d.escTimeout > 0 {
+		d.received(<-d.start())
+		return
+	}
+	if len(d.tmp) < n {
+		d.tmp = make([]byte, n)
+	}
+	n, err := d.r.Read(d.tmp[:n])
+	d.received(readResult{b: d.tmp[:n], err: err})
+}
+
+// fillWithin reads the input if it is received within timeout and reports
+// whether it was
+func (d *Decoder) fillWithin(timeout time.Duration) bool {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case r := <-d.start():
+		d.received(r)
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// asyncRead is the read size of the timed reads goroutine
+const asyncRead = 4 << 10
+
+// start starts the timed reads goroutine if needed and returns its channel
+func (d *Decoder) start() <-chan readResult {
+	if d.reads != nil {
+		return d.reads
+	}
+	d.reads = make(chan readResult)
+	go func() {
+		for {
+			b := make([]byte, asyncRead)
+			n, err := d.r.Read(b)
+			d.reads <- readResult{b: b[:n], err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return d.reads
+}
+
+// received appends the input read to the buffer
+func (d *Decoder) received(r readResult) {
+	d.buf = append(d.buf, r.b...)
+	d.err = r.err
+	if d.interval > 0 && len(r.b) > 0 {
+		now := time.Now()
+		d.burst = !d.last.IsZero() && now.Sub(d.last) < d.interval
+		d.last = now
+	}
+}
+
+// printable returns the length of the run of printable bytes at the
+// beginning of the buffer if it reaches the paste threshold, 0 otherwise.
+// An incomplete rune at the end of the buffer is left for the next read.
+func (d *Decoder) printable() int {
+	if d.threshold <= 0 || len(d.buf) < d.threshold {
+		return 0
+	}
+	n := 0
+	for n < len(d.buf) {
+		c := d.buf[n]
+		if c < utf8.RuneSelf {
+			if c < 0x20 || c == 0x7f {
+				break
+			}
+			n++
+			continue
+		}
+		if !utf8.FullRune(d.buf[n:]) {
+			break
+		}
+		_, l := utf8.DecodeRune(d.buf[n:])
+		n += l
+	}
+	if n < d.threshold {
+		return 0
+	}
+	return n
+}
+
+// zwj is the zero width joiner of the emoji sequences
+const zwj = '\u200d'
+
+// text returns the length of the text committed by an input method at the
+// beginning of the buffer, 0 if there is none: a run of at least two
+// printable non ASCII runes received at once, e.g. a CJK composition, or an
+// ASCII character followed by combining marks.
+// The ASCII runs are left to be decoded as keys, as typed ahead keys are.
+// It also reports whether the text may continue in the next read, the buffer
+// ending with an incomplete rune.
+func (d *Decoder) text() (n int, more bool) {
+	runes, ascii := 0, true
+	for n < len(d.buf) {
+		c := d.buf[n]
+		if c < utf8.RuneSelf {
+			if runes > 0 || c < 0x20 || c == 0x7f {
+				break
+			}
+			n, runes = n+1, 1
+			continue
+		}
+		if !utf8.FullRune(d.buf[n:]) {
+			more = runes > 0
+			break
+		}
+		r, l := utf8.DecodeRune(d.buf[n:])
+		if r == utf8.RuneError && l == 1 || !unicode.IsPrint(r) && r != zwj {
+			break
+		}
+		// an ASCII character only starts a text with its combining marks
+		if runes == 1 && ascii && !unicode.Is(unicode.M, r) {
+			break
+		}
+		n, runes, ascii = n+l, runes+1, false
+	}
+	if runes < 2 {
+		return 0, more
+	}
+	return n, more
+}
+
+// incomplete flushes the incomplete sequence left at the end of the stream
+func (d *Decoder) incomplete() Event {
+	if d.buf[0] == 0x1b && len(d.buf) == 1 {
+		d.buf = d.buf[:0]
+		return KeyEvent{Key: KeyEscape}
+	}
+	ev := UnknownEvent(append([]byte(nil), d.buf...))
+	d.buf = d.buf[:0]
+	return ev
+}
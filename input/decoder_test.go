@@ -0,0 +1,158 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+// reads returns one of its chunks per Read
+type reads [][]byte
+
+func (r *reads) Read(p []byte) (int, error) {
+	if len(*r) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, (*r)[0])
+	if n < len((*r)[0]) {
+		(*r)[0] = (*r)[0][n:]
+	} else {
+		*r = (*r)[1:]
+	}
+	return n, nil
+}
+
+func decode(t *testing.T, chunks ...string) []Event {
+	t.Helper()
+	var r reads
+	for _, v := range chunks {
+		r = append(r, []byte(v))
+	}
+	d := NewDecoder(&r)
+	var events []Event
+	for {
+		ev, err := d.ReadEvent()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+}
+
+func key(r rune) KeyEvent {
+	return KeyEvent{Key: KeyRune, Rune: r}
+}
+
+func TestDecoderText(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []Event
+	}{
+		{
+			name:   "cjk commit",
+			chunks: []string{"日本語"},
+			want:   []Event{TextEvent("日本語")},
+		},
+		{
+			name:   "hangul commit",
+			chunks: []string{"한국어"},
+			want:   []Event{TextEvent("한국어")},
+		},
+		{
+			name:   "single rune",
+			chunks: []string{"日"},
+			want:   []Event{key('日')},
+		},
+		{
+			name:   "single rune split",
+			chunks: []string{"\xe6\x97", "\xa5"},
+			want:   []Event{key('日')},
+		},
+		{
+			name:   "precomposed",
+			chunks: []string{"é"},
+			want:   []Event{key('é')},
+		},
+		{
+			name:   "combining mark",
+			chunks: []string{"e\u0301"},
+			want:   []Event{TextEvent("e\u0301")},
+		},
+		{
+			name:   "combining mark split",
+			chunks: []string{"e\xcc", "\x81"},
+			want:   []Event{TextEvent("e\u0301")},
+		},
+		{
+			name:   "commit split in the second rune",
+			chunks: []string{"日\xe6", "\x9c\xac語"},
+			want:   []Event{TextEvent("日本語")},
+		},
+		{
+			name:   "commit split in the last rune",
+			chunks: []string{"日本\xe8\xaa", "\x9e"},
+			want:   []Event{TextEvent("日本語")},
+		},
+		{
+			name:   "commit split in every rune",
+			chunks: []string{"\xe6\x97", "\xa5\xe6", "\x9c\xac\xe8", "\xaa\x9e"},
+			want:   []Event{TextEvent("日本語")},
+		},
+		{
+			name:   "zwj sequence",
+			chunks: []string{"👨\u200d👩"},
+			want:   []Event{TextEvent("👨\u200d👩")},
+		},
+		{
+			name:   "ascii keys",
+			chunks: []string{"ab"},
+			want:   []Event{key('a'), key('b')},
+		},
+		{
+			name:   "commit followed by ascii",
+			chunks: []string{"日本ab"},
+			want:   []Event{TextEvent("日本"), key('a'), key('b')},
+		},
+		{
+			name:   "commit followed by enter",
+			chunks: []string{"日本\r"},
+			want:   []Event{TextEvent("日本"), KeyEvent{Key: KeyEnter}},
+		},
+		{
+			name:   "key before commit",
+			chunks: []string{"\x1b[A日本"},
+			want:   []Event{KeyEvent{Key: KeyUp}, TextEvent("日本")},
+		},
+		{
+			name:   "incomplete at end of input",
+			chunks: []string{"日\xe6\x9c"},
+			want:   []Event{key('日'), UnknownEvent("\xe6\x9c")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decode(t, tt.chunks...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
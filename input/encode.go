@@ -0,0 +1,130 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// Encode returns the input a terminal sends for the event, as decoded by
+// Parse, e.g. to feed synthetic input to an application.
+// The keys are encoded like xterm does, the modifiers a key cannot carry
+// being dropped, e.g. ctrl+enter is sent as enter.
+func Encode(ev Event) []byte {
+	return AppendEvent(nil, ev)
+}
+
+// AppendEvent appends the encoded event to b, see Encode
+func AppendEvent(b []byte, ev Event) []byte {
+	switch ev := ev.(type) {
+	case KeyEvent:
+		return appendKey(b, ev)
+	case PasteEvent:
+		b = append(b, pasteStart...)
+		b = append(b, ev...)
+		return append(b, pasteEnd...)
+	case TextEvent:
+		return append(b, ev...)
+	case UnknownEvent:
+		return append(b, ev...)
+	case FocusGained:
+		return append(b, "\x1b[I"...)
+	case FocusLost:
+		return append(b, "\x1b[O"...)
+	}
+	return b
+}
+
+var letterKeys = map[Key]byte{
+	KeyUp:    'A',
+	KeyDown:  'B',
+	KeyRight: 'C',
+	KeyLeft:  'D',
+	KeyHome:  'H',
+	KeyEnd:   'F',
+	KeyF1:    'P',
+	KeyF2:    'Q',
+	KeyF3:    'R',
+	KeyF4:    'S',
+}
+
+var tildeCodes = map[Key]int{
+	KeyInsert:   2,
+	KeyDelete:   3,
+	KeyPageUp:   5,
+	KeyPageDown: 6,
+	KeyF5:       15,
+	KeyF6:       17,
+	KeyF7:       18,
+	KeyF8:       19,
+	KeyF9:       20,
+	KeyF10:      21,
+	KeyF11:      23,
+	KeyF12:      24,
+}
+
+func appendKey(b []byte, k KeyEvent) []byte {
+	if c, ok := letterKeys[k.Key]; ok {
+		if k.Mod == 0 {
+			if k.Key >= KeyF1 {
+				return append(b, 0x1b, 'O', c)
+			}
+			return append(b, 0x1b, '[', c)
+		}
+		b = append(b, "\x1b[1;"...)
+		b = strconv.AppendInt(b, int64(k.Mod)+1, 10)
+		return append(b, c)
+	}
+	if c, ok := tildeCodes[k.Key]; ok {
+		b = append(b, 0x1b, '[')
+		b = strconv.AppendInt(b, int64(c), 10)
+		if k.Mod != 0 {
+			b = append(b, ';')
+			b = strconv.AppendInt(b, int64(k.Mod)+1, 10)
+		}
+		return append(b, '~')
+	}
+	if k.Key == KeyTab && k.Mod&ModShift != 0 {
+		return append(b, "\x1b[Z"...)
+	}
+	if k.Mod&ModAlt != 0 && k.Key != KeyEscape {
+		b = append(b, 0x1b)
+	}
+	switch k.Key {
+	case KeyEnter:
+		return append(b, '\r')
+	case KeyTab:
+		return append(b, '\t')
+	case KeyBackspace:
+		return append(b, 0x7f)
+	case KeyEscape:
+		return append(b, 0x1b)
+	case KeyRune:
+		if k.Mod&ModCtrl != 0 {
+			switch r := k.Rune; {
+			case r == ' ':
+				return append(b, 0)
+			case r >= 'a' && r <= 'z':
+				return append(b, byte(r-'a'+1))
+			case r >= '\\' && r <= '_':
+				return append(b, byte(r-'\\'+0x1c))
+			}
+		}
+		var e [utf8.UTFMax]byte
+		return append(b, e[:utf8.EncodeRune(e[:], k.Rune)]...)
+	}
+	return b
+}
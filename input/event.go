@@ -0,0 +1,164 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"strings"
+)
+
+// Event is an input event decoded from the terminal input stream
+type Event interface {
+	isEvent()
+}
+
+// Key identifies a key which is not a printable character
+type Key int
+
+const (
+	KeyRune Key = iota
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyPageUp
+	KeyPageDown
+	KeyInsert
+	KeyDelete
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+)
+
+var keyNames = map[Key]string{
+	KeyEnter:     "enter",
+	KeyTab:       "tab",
+	KeyBackspace: "backspace",
+	KeyEscape:    "esc",
+	KeyUp:        "up",
+	KeyDown:      "down",
+	KeyRight:     "right",
+	KeyLeft:      "left",
+	KeyHome:      "home",
+	KeyEnd:       "end",
+	KeyPageUp:    "pgup",
+	KeyPageDown:  "pgdown",
+	KeyInsert:    "insert",
+	KeyDelete:    "delete",
+	KeyF1:        "f1",
+	KeyF2:        "f2",
+	KeyF3:        "f3",
+	KeyF4:        "f4",
+	KeyF5:        "f5",
+	KeyF6:        "f6",
+	KeyF7:        "f7",
+	KeyF8:        "f8",
+	KeyF9:        "f9",
+	KeyF10:       "f10",
+	KeyF11:       "f11",
+	KeyF12:       "f12",
+}
+
+func (k Key) String() string {
+	if k == KeyRune {
+		return "rune"
+	}
+	if n, ok := keyNames[k]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// Mod is a set of key modifiers
+type Mod uint8
+
+const (
+	ModShift Mod = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// KeyEvent is a key press
+type KeyEvent struct {
+	Key Key
+	// Rune is the typed character when Key is KeyRune
+	Rune rune
+	Mod  Mod
+}
+
+// String returns the key in the "ctrl+alt+x" form
+func (k KeyEvent) String() string {
+	var b strings.Builder
+	if k.Mod&ModCtrl != 0 {
+		b.WriteString("ctrl+")
+	}
+	if k.Mod&ModAlt != 0 {
+		b.WriteString("alt+")
+	}
+	if k.Mod&ModShift != 0 {
+		b.WriteString("shift+")
+	}
+	switch {
+	case k.Key != KeyRune:
+		b.WriteString(k.Key.String())
+	case k.Rune == ' ':
+		b.WriteString("space")
+	default:
+		b.WriteRune(k.Rune)
+	}
+	return b.String()
+}
+
+// FocusGained is sent when the terminal window gains the focus,
+// if focus reporting is enabled
+type FocusGained struct{}
+
+// FocusLost is sent when the terminal window loses the focus,
+// if focus reporting is enabled
+type FocusLost struct{}
+
+// UnknownEvent is an escape sequence the decoder does not understand
+type UnknownEvent []byte
+
+// PasteEvent is a text pasted in the terminal, delimited by the bracketed
+// paste sequences if enabled, or a large burst of printable input, see
+// Decoder.SetPasteThreshold
+type PasteEvent string
+
+// TextEvent is a text committed at once by an input method, e.g. a CJK
+// composition, or a character followed by combining marks, which the
+// Decoder does not split into one KeyEvent per rune
+type TextEvent string
+
+func (KeyEvent) isEvent()     {}
+func (FocusGained) isEvent()  {}
+func (FocusLost) isEvent()    {}
+func (UnknownEvent) isEvent() {}
+func (PasteEvent) isEvent()   {}
+func (TextEvent) isEvent()    {}
@@ -0,0 +1,72 @@
+//go:build gofuzz
+// +build gofuzz
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"io"
+)
+
+// Fuzz is the go-fuzz (and libFuzzer, through go-fuzz-build -libfuzzer)
+// entry point: it checks that the Decoder neither panics nor loops on
+// malformed input, read in chunks whose size is taken from the first byte,
+// which also sets the paste threshold.
+// The corpus is in testdata/corpus.
+func Fuzz(data []byte) int {
+	if len(data) == 0 {
+		return 0
+	}
+	chunk, threshold := int(data[0]&0x0f)+1, int(data[0]>>4)
+	d := NewDecoder(&chunkReader{b: data[1:], n: chunk})
+	d.SetPasteThreshold(threshold)
+	events := 0
+	for {
+		ev, err := d.ReadEvent()
+		if err != nil {
+			break
+		}
+		if ev == nil {
+			panic("nil event")
+		}
+		// each event consumes at least one byte
+		if events++; events > len(data) {
+			panic("decoder does not make progress")
+		}
+	}
+	if events == 0 {
+		return 0
+	}
+	return 1
+}
+
+// chunkReader returns at most n bytes per Read
+type chunkReader struct {
+	b []byte
+	n int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.b) == 0 {
+		return 0, io.EOF
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	n := copy(p, r.b)
+	r.b = r.b[n:]
+	return n, nil
+}
@@ -0,0 +1,132 @@
+//go:build windows
+// +build windows
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procReadConsoleInputW = windows.NewLazySystemDLL("kernel32.dll").NewProc("ReadConsoleInputW")
+
+const (
+	keyEvent              = 0x0001
+	windowBufferSizeEvent = 0x0004
+)
+
+// inputRecord is the INPUT_RECORD structure
+type inputRecord struct {
+	eventType uint16
+	_         uint16
+	event     [16]byte
+}
+
+// keyEventRecord is the KEY_EVENT_RECORD structure
+type keyEventRecord struct {
+	keyDown         int32
+	repeatCount     uint16
+	virtualKeyCode  uint16
+	virtualScanCode uint16
+	unicodeChar     uint16
+	controlKeyState uint32
+}
+
+func readConsoleInput(h windows.Handle, recs []inputRecord) (int, error) {
+	var n uint32
+	r, _, err := procReadConsoleInputW.Call(uintptr(h), uintptr(unsafe.Pointer(&recs[0])), uintptr(len(recs)), uintptr(unsafe.Pointer(&n)))
+	if r == 0 {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// readRaw reads the console input records, translating the key events to
+// their characters, which are the escape sequences for the special keys as
+// virtual terminal input is enabled, and notifying the window size events
+func (m *master) readRaw(b []byte) (int, error) {
+	var recs [16]inputRecord
+	for len(m.pending) == 0 {
+		n, err := readConsoleInput(m.in, recs[:])
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range recs[:n] {
+			switch r.eventType {
+			case keyEvent:
+				k := (*keyEventRecord)(unsafe.Pointer(&r.event[0]))
+				if k.keyDown == 0 || k.unicodeChar == 0 {
+					continue
+				}
+				for i := uint16(0); i < k.repeatCount || i == 0; i++ {
+					m.appendUTF16(k.unicodeChar)
+				}
+			case windowBufferSizeEvent:
+				if ws, err := m.Size(); err == nil {
+					m.notify(ws)
+				}
+			}
+		}
+	}
+	n := copy(b, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+// appendUTF16 appends the UTF-16 code unit to the pending input,
+// joining the surrogate pairs
+func (m *master) appendUTF16(c uint16) {
+	if utf16.IsSurrogate(rune(c)) {
+		if m.surrogate == 0 {
+			m.surrogate = c
+			return
+		}
+		r := utf16.DecodeRune(rune(m.surrogate), rune(c))
+		m.surrogate = 0
+		m.pending = append(m.pending, string(r)...)
+		return
+	}
+	m.surrogate = 0
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], rune(c))
+	m.pending = append(m.pending, buf[:n]...)
+}
+
+// notify sends the size to the watcher, replacing the previous size
+// if it was not received yet
+func (m *master) notify(ws WinSize) {
+	for {
+		select {
+		case m.sizes <- ws:
+			return
+		default:
+		}
+		select {
+		case <-m.sizes:
+		default:
+		}
+	}
+}
+
+// NotifySize returns the channel receiving the size changes, which are only
+// reported while the console is in raw mode and read
+func (m *master) NotifySize() <-chan WinSize {
+	return m.sizes
+}
@@ -0,0 +1,296 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package intercept reconstructs the command lines typed in an interactive
+// session and submits them to a policy before they are run, e.g. to enforce
+// command allow and deny lists on a jump host.
+//
+// The session output is interpreted by a virtual terminal, and when the
+// user hits Enter, the line is read from the screen, from the cursor position
+// left by the last output before the user started typing, i.e. the end of the
+// prompt: this handles the shell line editing, completion and history.
+// The position follows the screen scrolling as the line wraps, and the line
+// is blocked if its beginning was scrolled out of the scrollback.
+// The lines typed in full screen applications (using the alternate screen)
+// are not intercepted.
+package intercept
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.linka.cloud/console/term"
+	"go.linka.cloud/console/vt"
+)
+
+// killLine moves the cursor to the end of the line being edited (Ctrl-E)
+// and erases it (Ctrl-U), as Ctrl-U only kills the text before the cursor
+const killLine = "\x05\x15"
+
+// Policy decides whether a command line can be run: it returns the line to
+// run, which may be rewritten, and false to block it
+type Policy func(line string) (string, bool)
+
+type options struct {
+	settle time.Duration
+	onDeny func(line string)
+	vt     []vt.Option
+}
+
+// Option configures an Interceptor
+type Option func(o *options)
+
+// WithSettle sets how long the output must be quiet before the line is
+// read from the screen, which lets the remote side echo the typed keys,
+// defaults to 20ms. The wait is bounded to ten times this duration.
+func WithSettle(d time.Duration) Option {
+	return func(o *options) {
+		o.settle = d
+	}
+}
+
+// WithOnDeny sets a callback notified when a line is blocked,
+// e.g. to print a message to the user
+func WithOnDeny(fn func(line string)) Option {
+	return func(o *options) {
+		o.onDeny = fn
+	}
+}
+
+// WithVTOptions sets the options of the virtual terminal following the
+// session screen, e.g. the scrollback size set by term.Config.VTOptions
+func WithVTOptions(opts ...vt.Option) Option {
+	return func(o *options) {
+		o.vt = append(o.vt, opts...)
+	}
+}
+
+// Interceptor submits the command lines typed in a session to a Policy
+type Interceptor struct {
+	vt     *vt.Terminal
+	policy Policy
+	opts   options
+
+	mu sync.Mutex
+	// started is set when the user started typing a line, the output
+	// is then the echo of the typed keys
+	started bool
+	// col, row is the cursor position after the last output received
+	// while the user was not typing, and scrolled the number of lines the
+	// screen had scrolled then
+	col, row, scrolled int
+	lastOut            time.Time
+}
+
+// New returns an Interceptor for a terminal of the given size
+func New(cols, rows int, p Policy, opts ...Option) *Interceptor {
+	o := options{settle: 20 * time.Millisecond}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &Interceptor{vt: vt.New(cols, rows, o.vt...), policy: p, opts: o}
+}
+
+// Resize resizes the virtual terminal, it must follow the session
+// terminal size
+func (i *Interceptor) Resize(cols, rows int) {
+	i.vt.Resize(cols, rows)
+}
+
+// Output returns a writer forwarding the session output to w,
+// which must be used for the Interceptor to see the output
+func (i *Interceptor) Output(w io.Writer) io.Writer {
+	return writer{i: i, w: w}
+}
+
+type writer struct {
+	i *Interceptor
+	w io.Writer
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	w.i.vt.Write(p)
+	w.i.mu.Lock()
+	w.i.lastOut = time.Now()
+	if !w.i.started {
+		w.i.col, w.i.row = w.i.vt.Cursor()
+		w.i.scrolled = w.i.vt.Scrolled()
+	}
+	w.i.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// Input returns the middleware intercepting the typed lines
+func (i *Interceptor) Input() term.InputMiddleware {
+	return func(next io.Reader) io.Reader {
+		return &reader{i: i, r: next}
+	}
+}
+
+type reader struct {
+	i   *Interceptor
+	r   io.Reader
+	out []byte
+	// rest is the input not processed yet, starting with a line end
+	rest []byte
+	err  error
+	// abandoned is the time the line was abandoned with Ctrl-C or Ctrl-D,
+	// the new prompt is awaited before processing the rest of the input
+	abandoned time.Time
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if len(r.rest) == 0 {
+			if r.err != nil {
+				err := r.err
+				r.err = nil
+				return 0, err
+			}
+			buf := make([]byte, len(p))
+			n, err := r.r.Read(buf)
+			r.rest, r.err = buf[:n], err
+			if n == 0 {
+				continue
+			}
+		}
+		r.process()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// process moves the input from rest to out, up to the next line end,
+// which is submitted to the policy
+func (r *reader) process() {
+	i := r.i
+	if !r.abandoned.IsZero() {
+		if !i.vt.AltScreen() {
+			i.settle(r.abandoned)
+		}
+		r.abandoned = time.Time{}
+	}
+	if r.rest[0] == '\r' || r.rest[0] == '\n' {
+		r.out = append(r.out, i.submit(r.rest[0])...)
+		r.rest = r.rest[1:]
+		return
+	}
+	n := len(r.rest)
+	for k, c := range r.rest {
+		if c == '\r' || c == '\n' {
+			n = k
+			break
+		}
+		if c == '\x03' || c == '\x04' {
+			n = k + 1
+			r.abandoned = time.Now()
+			break
+		}
+	}
+	i.typed(r.rest[:n])
+	r.out = append(r.out, r.rest[:n]...)
+	r.rest = r.rest[n:]
+}
+
+// typed records that the user is typing a line
+func (i *Interceptor) typed(p []byte) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if k := strings.LastIndexAny(string(p), "\x03\x04"); k >= 0 {
+		// Ctrl-C and Ctrl-D abandon the line, the shell prints a new prompt
+		i.started = false
+		p = p[k+1:]
+	}
+	if len(p) != 0 {
+		i.started = true
+	}
+}
+
+// submit reads the typed line and returns the input to forward for the
+// line end c according to the policy
+func (i *Interceptor) submit(c byte) []byte {
+	i.mu.Lock()
+	col, row, scrolled := i.col, i.row, i.scrolled
+	i.started = false
+	i.mu.Unlock()
+	if i.vt.AltScreen() {
+		return []byte{c}
+	}
+	i.settle(time.Time{})
+	line, ok := i.line(col, row, scrolled)
+	if ok && line == "" {
+		return []byte{c}
+	}
+	run := line
+	if ok {
+		run, ok = i.policy(line)
+	}
+	if !ok {
+		if i.opts.onDeny != nil {
+			i.opts.onDeny(line)
+		}
+		return []byte(killLine)
+	}
+	if run != line {
+		return []byte(killLine + run + string(c))
+	}
+	return []byte{c}
+}
+
+// settle waits for the output to be quiet, and if since is not zero,
+// to have been received after since
+func (i *Interceptor) settle(since time.Time) {
+	deadline := time.Now().Add(10 * i.opts.settle)
+	for time.Now().Before(deadline) {
+		i.mu.Lock()
+		last := i.lastOut
+		i.mu.Unlock()
+		quiet := time.Since(last)
+		if (since.IsZero() || last.After(since)) && quiet >= i.opts.settle {
+			return
+		}
+		if quiet >= i.opts.settle {
+			quiet = 0
+		}
+		time.Sleep(i.opts.settle - quiet)
+	}
+}
+
+// line returns the text from the position col, row to the end of the
+// cursor row, as the line may wrap. The row is moved up by the lines
+// scrolled since, and may then be a scrollback line: it returns false if
+// it is no longer kept.
+func (i *Interceptor) line(col, row, scrolled int) (string, bool) {
+	_, crow := i.vt.Cursor()
+	row -= i.vt.Scrolled() - scrolled
+	if crow < row {
+		// the screen was cleared
+		col, row = 0, crow
+	}
+	if row < -i.vt.ScrollbackLen() {
+		return "", false
+	}
+	var b strings.Builder
+	for y := row; y <= crow; y++ {
+		if y == row {
+			b.WriteString(i.vt.LineFrom(col, y))
+		} else {
+			b.WriteString(i.vt.Line(y))
+		}
+	}
+	return strings.TrimSpace(b.String()), true
+}
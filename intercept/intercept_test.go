@@ -0,0 +1,181 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intercept
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shell echoes the forwarded input like a shell line editor
+type shell struct {
+	out  io.Writer
+	line bytes.Buffer
+}
+
+func (s *shell) input(p []byte) {
+	for _, c := range p {
+		switch c {
+		case '\r':
+			s.out.Write([]byte("\r\n$ "))
+			s.line.Reset()
+		case '\x03':
+			s.out.Write([]byte("^C\r\n$ "))
+			s.line.Reset()
+		case '\x05':
+		case '\x15':
+			s.out.Write([]byte("\r\x1b[K$ "))
+			s.line.Reset()
+		default:
+			s.out.Write([]byte{c})
+			s.line.WriteByte(c)
+		}
+	}
+}
+
+type chunks [][]byte
+
+func (c *chunks) Read(p []byte) (int, error) {
+	if len(*c) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, (*c)[0])
+	*c = (*c)[1:]
+	return n, nil
+}
+
+// run types the input in a shell running on a cols x rows terminal, the
+// prompt being printed after the before output
+func run(t *testing.T, cols, rows int, before string, input ...string) (forwarded string, checked []string) {
+	t.Helper()
+	policy := func(line string) (string, bool) {
+		checked = append(checked, line)
+		if strings.HasPrefix(line, "rm") {
+			return line, false
+		}
+		if line == "ll" {
+			return "ls -l", true
+		}
+		return line, true
+	}
+	i := New(cols, rows, policy, WithSettle(time.Millisecond))
+	var screen bytes.Buffer
+	sh := &shell{out: i.Output(&screen)}
+	sh.out.Write([]byte(before + "$ "))
+	var in chunks
+	for _, v := range input {
+		in = append(in, []byte(v))
+	}
+	r := i.Input()(&in)
+	var fwd bytes.Buffer
+	b := make([]byte, 64)
+	for {
+		n, err := r.Read(b)
+		fwd.Write(b[:n])
+		sh.input(b[:n])
+		if err != nil {
+			break
+		}
+	}
+	return fwd.String(), checked
+}
+
+func TestInterceptor(t *testing.T) {
+	// typed in reads of 50 bytes, wrapping on more rows than the
+	// scrollback keeps
+	var long []string
+	for k := 0; k < 500; k++ {
+		long = append(long, strings.Repeat("x", 50))
+	}
+	tests := []struct {
+		name       string
+		cols, rows int
+		before     string
+		input      []string
+		want       string
+		checked    []string
+	}{
+		{
+			name:    "allowed",
+			input:   []string{"echo hi", "\r"},
+			want:    "echo hi\r",
+			checked: []string{"echo hi"},
+		},
+		{
+			name:    "denied",
+			input:   []string{"rm -rf /", "\r"},
+			want:    "rm -rf /" + killLine,
+			checked: []string{"rm -rf /"},
+		},
+		{
+			name:    "rewritten",
+			input:   []string{"ll\r"},
+			want:    "ll" + killLine + "ls -l\r",
+			checked: []string{"ll"},
+		},
+		{
+			name:    "abandoned in the same read",
+			input:   []string{"ls\x03rm -rf /\r"},
+			want:    "ls\x03rm -rf /" + killLine,
+			checked: []string{"rm -rf /"},
+		},
+		{
+			name:    "abandoned",
+			input:   []string{"ls", "\x03", "rm -rf /\r"},
+			want:    "ls\x03rm -rf /" + killLine,
+			checked: []string{"rm -rf /"},
+		},
+		{
+			name:    "empty line",
+			input:   []string{"\r", "\r"},
+			want:    "\r\r",
+			checked: nil,
+		},
+		{
+			name:    "wrapped on the last row",
+			cols:    20,
+			rows:    3,
+			before:  "\r\n\r\n",
+			input:   []string{"rm -rf / #xxxxxxxxxxxxxxxxxxx", "\r"},
+			want:    "rm -rf / #xxxxxxxxxxxxxxxxxxx" + killLine,
+			checked: []string{"rm -rf / #xxxxxxxxxxxxxxxxxxx"},
+		},
+		{
+			name:    "wrapped out of the scrollback",
+			cols:    20,
+			rows:    3,
+			input:   append(append([]string{"ls "}, long...), "\r"),
+			want:    "ls " + strings.Join(long, "") + killLine,
+			checked: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cols == 0 {
+				tt.cols, tt.rows = 40, 10
+			}
+			got, checked := run(t, tt.cols, tt.rows, tt.before, tt.input...)
+			if got != tt.want {
+				t.Errorf("forwarded %q, want %q", got, tt.want)
+			}
+			if strings.Join(checked, "|") != strings.Join(tt.checked, "|") {
+				t.Errorf("checked %q, want %q", checked, tt.checked)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymap
+
+import (
+	"time"
+
+	"go.linka.cloud/console/input"
+)
+
+const (
+	// DefaultRepeatThreshold is the delay under which a key pressed again is
+	// auto-repeated, terminals repeating keys every 30ms or so
+	DefaultRepeatThreshold = 50 * time.Millisecond
+	// DefaultChordTimeout is the delay under which the keys form a chord,
+	// like vim's timeoutlen
+	DefaultChordTimeout = time.Second
+	// DefaultChordLength is the number of keys kept in a chord
+	DefaultChordLength = 4
+)
+
+// Meta describes a key event in the context of the previous ones
+type Meta struct {
+	// Repeat is the number of times the key was auto-repeated, 0 if it was
+	// pressed
+	Repeat int
+	// Chord is the sequence of the keys typed within the chord timeout
+	// of each other, ending with the key, e.g. "g g"
+	Chord Sequence
+}
+
+type detectorOptions struct {
+	repeat time.Duration
+	chord  time.Duration
+	length int
+}
+
+// DetectorOption configures a Detector
+type DetectorOption func(o *detectorOptions)
+
+// WithRepeatThreshold sets the repeat threshold, 0 disables the repeat
+// detection
+func WithRepeatThreshold(d time.Duration) DetectorOption {
+	return func(o *detectorOptions) {
+		o.repeat = d
+	}
+}
+
+// WithChordTimeout sets the chord timeout, 0 disables the chord detection
+func WithChordTimeout(d time.Duration) DetectorOption {
+	return func(o *detectorOptions) {
+		o.chord = d
+	}
+}
+
+// WithChordLength sets the number of keys kept in a chord
+func WithChordLength(n int) DetectorOption {
+	return func(o *detectorOptions) {
+		o.length = n
+	}
+}
+
+// Detector detects the key auto-repeat and chords from the keys timing
+type Detector struct {
+	opts  detectorOptions
+	last  time.Time
+	meta  Meta
+	chord Sequence
+}
+
+// NewDetector returns a Detector
+func NewDetector(opts ...DetectorOption) *Detector {
+	o := detectorOptions{repeat: DefaultRepeatThreshold, chord: DefaultChordTimeout, length: DefaultChordLength}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &Detector{opts: o}
+}
+
+// Feed records the key, pressed now, and returns its Meta
+func (d *Detector) Feed(k input.KeyEvent) Meta {
+	return d.FeedAt(k, time.Now())
+}
+
+// FeedAt records the key pressed at t, e.g. the time it was read, and
+// returns its Meta
+func (d *Detector) FeedAt(k input.KeyEvent, t time.Time) Meta {
+	elapsed := t.Sub(d.last)
+	first := d.last.IsZero()
+	d.last = t
+	var prev input.KeyEvent
+	if len(d.chord) > 0 {
+		prev = d.chord[len(d.chord)-1]
+	}
+	repeat := 0
+	if !first && d.opts.repeat > 0 && elapsed < d.opts.repeat && len(d.chord) > 0 && prev == k {
+		repeat = d.meta.Repeat + 1
+	}
+	if first || d.opts.chord <= 0 || elapsed >= d.opts.chord {
+		d.chord = d.chord[:0]
+	}
+	// the auto-repeated keys are not part of the chords
+	if repeat == 0 || len(d.chord) == 0 {
+		d.chord = append(d.chord, k)
+	}
+	if n := d.opts.length; n > 0 && len(d.chord) > n {
+		d.chord = append(d.chord[:0], d.chord[len(d.chord)-n:]...)
+	}
+	d.meta = Meta{Repeat: repeat, Chord: append(Sequence(nil), d.chord...)}
+	return d.meta
+}
+
+// Reset forgets the previous keys
+func (d *Detector) Reset() {
+	d.last, d.meta, d.chord = time.Time{}, Meta{}, d.chord[:0]
+}
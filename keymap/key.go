@@ -0,0 +1,156 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymap
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.linka.cloud/console/input"
+)
+
+var namedKeys = map[string]input.Key{
+	"enter":     input.KeyEnter,
+	"return":    input.KeyEnter,
+	"tab":       input.KeyTab,
+	"backspace": input.KeyBackspace,
+	"esc":       input.KeyEscape,
+	"escape":    input.KeyEscape,
+	"up":        input.KeyUp,
+	"down":      input.KeyDown,
+	"right":     input.KeyRight,
+	"left":      input.KeyLeft,
+	"home":      input.KeyHome,
+	"end":       input.KeyEnd,
+	"pgup":      input.KeyPageUp,
+	"pageup":    input.KeyPageUp,
+	"pgdown":    input.KeyPageDown,
+	"pagedown":  input.KeyPageDown,
+	"insert":    input.KeyInsert,
+	"delete":    input.KeyDelete,
+	"del":       input.KeyDelete,
+	"f1":        input.KeyF1,
+	"f2":        input.KeyF2,
+	"f3":        input.KeyF3,
+	"f4":        input.KeyF4,
+	"f5":        input.KeyF5,
+	"f6":        input.KeyF6,
+	"f7":        input.KeyF7,
+	"f8":        input.KeyF8,
+	"f9":        input.KeyF9,
+	"f10":       input.KeyF10,
+	"f11":       input.KeyF11,
+	"f12":       input.KeyF12,
+}
+
+// ParseKey parses a key in the "ctrl+alt+shift+key" form, e.g. "ctrl+x",
+// "alt+enter", "space" or "a".
+// Keys are normalized to the events produced by the input decoder,
+// e.g. "ctrl+i" is "tab" and "ctrl+h" is "backspace".
+func ParseKey(s string) (input.KeyEvent, error) {
+	var k input.KeyEvent
+	parts := strings.Split(s, "+")
+	// support "ctrl++"
+	if strings.HasSuffix(s, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	for _, m := range parts[:len(parts)-1] {
+		switch strings.ToLower(m) {
+		case "ctrl", "c", "control":
+			k.Mod |= input.ModCtrl
+		case "alt", "a", "meta", "m":
+			k.Mod |= input.ModAlt
+		case "shift", "s":
+			k.Mod |= input.ModShift
+		default:
+			return k, fmt.Errorf("invalid key %q: unknown modifier %q", s, m)
+		}
+	}
+	name := parts[len(parts)-1]
+	if name == "" {
+		return k, fmt.Errorf("invalid key %q", s)
+	}
+	if key, ok := namedKeys[strings.ToLower(name)]; ok {
+		k.Key = key
+		return k, nil
+	}
+	switch strings.ToLower(name) {
+	case "space":
+		name = " "
+	case "plus":
+		name = "+"
+	}
+	r, n := utf8.DecodeRuneInString(name)
+	if n != len(name) {
+		return k, fmt.Errorf("invalid key %q: unknown key %q", s, name)
+	}
+	k.Key, k.Rune = input.KeyRune, r
+	return normalize(k), nil
+}
+
+// normalize returns the key as produced by the input decoder
+func normalize(k input.KeyEvent) input.KeyEvent {
+	if k.Key != input.KeyRune || k.Mod&input.ModCtrl == 0 {
+		return k
+	}
+	k.Rune = unicode.ToLower(k.Rune)
+	mod := k.Mod &^ input.ModCtrl
+	switch k.Rune {
+	case 'i':
+		return input.KeyEvent{Key: input.KeyTab, Mod: mod}
+	case 'm', 'j':
+		return input.KeyEvent{Key: input.KeyEnter, Mod: mod}
+	case 'h':
+		return input.KeyEvent{Key: input.KeyBackspace, Mod: mod}
+	case '[':
+		return input.KeyEvent{Key: input.KeyEscape, Mod: mod}
+	case '@':
+		k.Rune = ' '
+	case '/':
+		// terminals send ctrl+/ as ctrl+_
+		k.Rune = '_'
+	}
+	return k
+}
+
+// Sequence is a sequence of keys, e.g. "ctrl+x ctrl+s"
+type Sequence []input.KeyEvent
+
+// ParseSequence parses space separated keys
+func ParseSequence(s string) (Sequence, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty key sequence")
+	}
+	seq := make(Sequence, 0, len(fields))
+	for _, f := range fields {
+		k, err := ParseKey(f)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, k)
+	}
+	return seq, nil
+}
+
+func (s Sequence) String() string {
+	parts := make([]string, len(s))
+	for i, k := range s {
+		parts[i] = k.String()
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,250 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymap
+
+import (
+	"sync"
+
+	"go.linka.cloud/console/input"
+)
+
+// Action is the name of an action bound to a key sequence
+type Action string
+
+// None unbinds a key sequence when used in overrides
+const None Action = ""
+
+// Keymap maps key sequences to actions
+type Keymap struct {
+	mu       sync.RWMutex
+	bindings map[string]Action
+	// prefixes counts the bindings starting with a sequence
+	prefixes map[string]int
+}
+
+// New returns an empty Keymap
+func New() *Keymap {
+	return &Keymap{bindings: make(map[string]Action), prefixes: make(map[string]int)}
+}
+
+// Bind binds the key sequence (e.g. "ctrl+x ctrl+s") to the action,
+// replacing any existing binding
+func (m *Keymap) Bind(seq string, action Action) error {
+	s, err := ParseSequence(seq)
+	if err != nil {
+		return err
+	}
+	m.BindSequence(s, action)
+	return nil
+}
+
+// MustBind is like Bind but panics if the sequence is invalid.
+// It is intended for default bindings.
+func (m *Keymap) MustBind(seq string, action Action) *Keymap {
+	if err := m.Bind(seq, action); err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// BindSequence binds the key sequence to the action
+func (m *Keymap) BindSequence(seq Sequence, action Action) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unbind(seq)
+	if action == None {
+		return
+	}
+	m.bindings[seq.String()] = action
+	for i := 1; i < len(seq); i++ {
+		m.prefixes[seq[:i].String()]++
+	}
+}
+
+// Unbind removes the key sequence binding
+func (m *Keymap) Unbind(seq string) error {
+	s, err := ParseSequence(seq)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unbind(s)
+	return nil
+}
+
+func (m *Keymap) unbind(seq Sequence) {
+	k := seq.String()
+	if _, ok := m.bindings[k]; !ok {
+		return
+	}
+	delete(m.bindings, k)
+	for i := 1; i < len(seq); i++ {
+		p := seq[:i].String()
+		if m.prefixes[p]--; m.prefixes[p] <= 0 {
+			delete(m.prefixes, p)
+		}
+	}
+}
+
+// Override applies user bindings, as sequence to action name, on top of the
+// existing ones. Binding a sequence to None removes it.
+func (m *Keymap) Override(bindings map[string]Action) error {
+	for seq, a := range bindings {
+		if err := m.Bind(seq, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lookup returns the action bound to the sequence
+func (m *Keymap) Lookup(seq Sequence) (Action, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.bindings[seq.String()]
+	return a, ok
+}
+
+// Bindings returns a copy of the bindings
+func (m *Keymap) Bindings() map[string]Action {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]Action, len(m.bindings))
+	for k, v := range m.bindings {
+		out[k] = v
+	}
+	return out
+}
+
+// Clone returns a copy of the Keymap, e.g. to customize a preset
+func (m *Keymap) Clone() *Keymap {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c := New()
+	for k, v := range m.bindings {
+		c.bindings[k] = v
+	}
+	for k, v := range m.prefixes {
+		c.prefixes[k] = v
+	}
+	return c
+}
+
+func (m *Keymap) isPrefix(seq Sequence) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.prefixes[seq.String()] > 0
+}
+
+// Result is the outcome of feeding a key to a Matcher
+type Result struct {
+	// Action is the matched action, if Matched is true
+	Action  Action
+	Matched bool
+	// Pending is true when the keys fed so far are the prefix of a binding
+	Pending bool
+	// Keys are the keys which did not match any binding and should be
+	// handled as regular input
+	Keys Sequence
+	// Meta describes the key fed, if the Matcher has a Detector
+	Meta Meta
+}
+
+// Matcher resolves key events to actions, handling multi-keys sequences
+type Matcher struct {
+	km      *Keymap
+	pending Sequence
+	det     *Detector
+}
+
+// SetDetector makes the Matcher detect the auto-repeat and chords of the
+// keys fed, reported in Result.Meta: the pending sequence is dropped when
+// the chord timeout expires, its keys being handled as regular input
+func (mt *Matcher) SetDetector(d *Detector) {
+	mt.det = d
+}
+
+// Matcher returns a new Matcher for the Keymap
+func (m *Keymap) Matcher() *Matcher {
+	return &Matcher{km: m}
+}
+
+// Feed adds a key to the current sequence and resolves it
+func (mt *Matcher) Feed(k input.KeyEvent) Result {
+	if mt.det == nil {
+		return mt.feed(k)
+	}
+	m := mt.det.Feed(k)
+	var expired Sequence
+	if len(m.Chord) == 1 && m.Repeat == 0 {
+		expired = mt.Reset()
+	}
+	res := mt.feed(k)
+	if len(expired) > 0 {
+		res.Keys = append(expired, res.Keys...)
+	}
+	res.Meta = m
+	return res
+}
+
+func (mt *Matcher) feed(k input.KeyEvent) Result {
+	seq := append(mt.pending, k)
+	if a, ok := mt.km.Lookup(seq); ok {
+		mt.pending = nil
+		return Result{Action: a, Matched: true}
+	}
+	if mt.km.isPrefix(seq) {
+		mt.pending = seq
+		return Result{Pending: true}
+	}
+	mt.pending = nil
+	if len(seq) == 1 {
+		return Result{Keys: seq}
+	}
+	// the sequence is broken: replay the previous keys as input and
+	// resolve the last one alone
+	res := mt.feed(k)
+	res.Keys = append(append(Sequence(nil), seq[:len(seq)-1]...), res.Keys...)
+	return res
+}
+
+// Reset drops the pending sequence, e.g. on timeout
+func (mt *Matcher) Reset() Sequence {
+	p := mt.pending
+	mt.pending = nil
+	return p
+}
+
+var (
+	regMu    sync.RWMutex
+	registry = make(map[string]*Keymap)
+)
+
+// Register makes a named keymap available to the components using the
+// registry, e.g. the line editor presets
+func Register(name string, km *Keymap) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	registry[name] = km
+}
+
+// Get returns the named keymap from the registry
+func Get(name string) (*Keymap, bool) {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	km, ok := registry[name]
+	return km, ok
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kube wires a Term to the Kubernetes exec and attach streams, as
+// kubectl exec -it does, without depending on client-go: the application
+// adapts the SizeQueue to the remotecommand.TerminalSizeQueue interface and
+// runs the executor with Stream:
+//
+//	type sizeQueue struct{ *kube.SizeQueue }
+//
+//	func (q sizeQueue) Next() *remotecommand.TerminalSize {
+//		w, h, ok := q.SizeQueue.Next()
+//		if !ok {
+//			return nil
+//		}
+//		return &remotecommand.TerminalSize{Width: w, Height: h}
+//	}
+//
+//	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+//	...
+//	err = kube.Stream(ctx, t, func(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+//		return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+//			Stdin:             stdin,
+//			Stdout:            stdout,
+//			Tty:               true,
+//			TerminalSizeQueue: sizeQueue{kube.NewSizeQueue(t)},
+//		})
+//	})
+//
+// The same applies to the WebSocket executor and to the attach requests.
+package kube
+
+import (
+	"context"
+	"io"
+
+	"go.linka.cloud/console/term"
+)
+
+// SizeQueue returns the successive sizes of a Term, as expected by the
+// remotecommand.TerminalSizeQueue interface
+type SizeQueue struct {
+	t       term.Term
+	started bool
+}
+
+// NewSizeQueue returns a SizeQueue for the Term
+func NewSizeQueue(t term.Term) *SizeQueue {
+	return &SizeQueue{t: t}
+}
+
+// Next returns the current size on the first call, then blocks until the
+// Term is resized and returns the new size, width first.
+// It returns false once the Term is closed.
+func (q *SizeQueue) Next() (width, height uint16, ok bool) {
+	if !q.started {
+		q.started = true
+		sz := q.t.Size()
+		return uint16(sz.Cols), uint16(sz.Rows), true
+	}
+	sz, ok := <-q.t.WatchSize()
+	if !ok {
+		return 0, 0, false
+	}
+	return uint16(sz.Cols), uint16(sz.Rows), true
+}
+
+// StreamFunc streams the standard streams of a remote process until it
+// exits or ctx is cancelled, e.g. with a remotecommand Executor
+type StreamFunc func(ctx context.Context, stdin io.Reader, stdout io.Writer) error
+
+// Stream runs stream with the Term as the process standard streams, and
+// cancels its context when the Term is closed or detached.
+// It closes the Term when stream returns, and returns its error, or the
+// Term error if it was closed first, e.g. ErrDetached.
+func Stream(ctx context.Context, t term.Term, stream StreamFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := stream(ctx, t, t)
+	select {
+	case <-t.Done():
+		return t.Wait()
+	default:
+	}
+	t.Close()
+	return err
+}
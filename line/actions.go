@@ -0,0 +1,321 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"io"
+	"unicode"
+
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/keymap"
+	"go.linka.cloud/console/screen"
+)
+
+// The actions of the Editor, named after their readline counterparts
+const (
+	AcceptLine         keymap.Action = "accept-line"
+	InsertNewline      keymap.Action = "insert-newline"
+	Interrupt          keymap.Action = "interrupt"
+	DeleteCharOrEOF    keymap.Action = "delete-char-or-eof"
+	BackwardChar       keymap.Action = "backward-char"
+	ForwardChar        keymap.Action = "forward-char"
+	BackwardWord       keymap.Action = "backward-word"
+	ForwardWord        keymap.Action = "forward-word"
+	BeginningOfLine    keymap.Action = "beginning-of-line"
+	EndOfLine          keymap.Action = "end-of-line"
+	BackwardDeleteChar keymap.Action = "backward-delete-char"
+	DeleteChar         keymap.Action = "delete-char"
+	BackwardKillWord   keymap.Action = "backward-kill-word"
+	KillWord           keymap.Action = "kill-word"
+	KillLine           keymap.Action = "kill-line"
+	UnixLineDiscard    keymap.Action = "unix-line-discard"
+	Yank               keymap.Action = "yank"
+	TransposeChars     keymap.Action = "transpose-chars"
+	PreviousHistory    keymap.Action = "previous-history"
+	NextHistory        keymap.Action = "next-history"
+	UpLineOrHistory    keymap.Action = "up-line-or-history"
+	DownLineOrHistory  keymap.Action = "down-line-or-history"
+	Complete           keymap.Action = "complete"
+	CompleteBackward   keymap.Action = "complete-backward"
+	Cancel             keymap.Action = "cancel"
+	ClearScreen        keymap.Action = "clear-screen"
+)
+
+// DefaultKeymap returns the emacs key bindings
+func DefaultKeymap() *keymap.Keymap {
+	return keymap.New().
+		MustBind("enter", AcceptLine).
+		MustBind("alt+enter", InsertNewline).
+		MustBind("ctrl+c", Interrupt).
+		MustBind("ctrl+d", DeleteCharOrEOF).
+		MustBind("left", BackwardChar).
+		MustBind("ctrl+b", BackwardChar).
+		MustBind("right", ForwardChar).
+		MustBind("ctrl+f", ForwardChar).
+		MustBind("alt+b", BackwardWord).
+		MustBind("ctrl+left", BackwardWord).
+		MustBind("alt+f", ForwardWord).
+		MustBind("ctrl+right", ForwardWord).
+		MustBind("home", BeginningOfLine).
+		MustBind("ctrl+a", BeginningOfLine).
+		MustBind("end", EndOfLine).
+		MustBind("ctrl+e", EndOfLine).
+		MustBind("backspace", BackwardDeleteChar).
+		MustBind("delete", DeleteChar).
+		MustBind("ctrl+w", BackwardKillWord).
+		MustBind("alt+backspace", BackwardKillWord).
+		MustBind("alt+d", KillWord).
+		MustBind("ctrl+k", KillLine).
+		MustBind("ctrl+u", UnixLineDiscard).
+		MustBind("ctrl+y", Yank).
+		MustBind("ctrl+t", TransposeChars).
+		MustBind("ctrl+_", Undo).
+		MustBind("ctrl+x ctrl+u", Undo).
+		MustBind("alt+_", Redo).
+		MustBind("up", UpLineOrHistory).
+		MustBind("ctrl+p", UpLineOrHistory).
+		MustBind("down", DownLineOrHistory).
+		MustBind("ctrl+n", DownLineOrHistory).
+		MustBind("tab", Complete).
+		MustBind("shift+tab", CompleteBackward).
+		MustBind("esc", Cancel).
+		MustBind("ctrl+g", Cancel).
+		MustBind("ctrl+l", ClearScreen)
+}
+
+// do runs the action and reports whether the line is done
+func (e *Editor) do(a keymap.Action) (string, bool, error) {
+	if e.doMode(a) {
+		e.normal()
+		e.closeMenu()
+		e.render()
+		return "", false, nil
+	}
+	switch a {
+	case AcceptLine:
+		if e.menu != nil && e.menu.sel >= 0 {
+			e.applyCompletion(e.menu.items[e.menu.sel])
+			e.render()
+			return "", false, nil
+		}
+		if e.opts.accept != nil && !e.opts.accept(string(e.buf)) {
+			e.insert([]rune{'\n'})
+			break
+		}
+		e.finish()
+		line := string(e.buf)
+		if e.opts.history != nil {
+			e.opts.history.Add(line)
+		}
+		return line, true, nil
+	case Interrupt:
+		e.finish()
+		return "", true, ErrInterrupted
+	case InsertNewline:
+		e.insert([]rune{'\n'})
+	case DeleteCharOrEOF:
+		if len(e.buf) == 0 {
+			e.finish()
+			return "", true, io.EOF
+		}
+		e.remove(e.pos, clamp(e.pos+1, 0, len(e.buf)))
+	case BackwardChar:
+		e.pos = clamp(e.pos-1, 0, len(e.buf))
+	case ForwardChar:
+		e.pos = clamp(e.pos+1, 0, len(e.buf))
+	case BackwardWord:
+		e.pos = e.wordStart()
+	case ForwardWord:
+		e.pos = e.wordEnd()
+	case BeginningOfLine:
+		e.pos = e.lineStart(e.pos)
+	case EndOfLine:
+		e.pos = e.lineEnd(e.pos)
+	case BackwardDeleteChar:
+		e.remove(clamp(e.pos-1, 0, len(e.buf)), e.pos)
+		e.edit = editDeleting
+		if e.menu != nil {
+			e.refilter()
+			e.render()
+			return "", false, nil
+		}
+	case DeleteChar:
+		e.remove(e.pos, clamp(e.pos+1, 0, len(e.buf)))
+		e.edit = editDeleting
+	case Undo:
+		e.undo(&e.undos, &e.redos)
+	case Redo:
+		e.undo(&e.redos, &e.undos)
+	case BackwardKillWord:
+		e.kill = e.remove(e.wordStart(), e.pos)
+	case KillWord:
+		e.kill = e.remove(e.pos, e.wordEnd())
+	case KillLine:
+		// at the end of a line, its newline is killed
+		end := e.lineEnd(e.pos)
+		if end == e.pos {
+			end = clamp(end+1, 0, len(e.buf))
+		}
+		e.kill = e.remove(e.pos, end)
+	case UnixLineDiscard:
+		e.kill = e.remove(e.lineStart(e.pos), e.pos)
+	case Yank:
+		e.insert(append([]rune(nil), e.kill...))
+	case TransposeChars:
+		e.transpose()
+	case PreviousHistory:
+		e.browse(-1)
+	case NextHistory:
+		e.browse(1)
+	case UpLineOrHistory:
+		if !e.vertical(-1) {
+			e.browse(-1)
+		}
+	case DownLineOrHistory:
+		if !e.vertical(1) {
+			e.browse(1)
+		}
+	case Complete, CompleteBackward:
+		e.complete(a == CompleteBackward)
+		e.render()
+		return "", false, nil
+	case Cancel:
+		e.cancelCompletion()
+	case ClearScreen:
+		e.t.Write([]byte(ansi.EraseScreen + ansi.CursorPosition(0, 0)))
+		e.row = 0
+	}
+	e.normal()
+	e.closeMenu()
+	e.render()
+	return "", false, nil
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func isSpace(r rune) bool {
+	return unicode.IsSpace(r)
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// wordStart returns the beginning of the word before the cursor
+func (e *Editor) wordStart() int {
+	i := e.pos
+	for i > 0 && !isWord(e.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWord(e.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor
+func (e *Editor) wordEnd() int {
+	i := e.pos
+	for i < len(e.buf) && !isWord(e.buf[i]) {
+		i++
+	}
+	for i < len(e.buf) && isWord(e.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// lineStart returns the beginning of the line of the rune i
+func (e *Editor) lineStart(i int) int {
+	for i > 0 && e.buf[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+// lineEnd returns the end of the line of the rune i, before its newline
+func (e *Editor) lineEnd(i int) int {
+	for i < len(e.buf) && e.buf[i] != '\n' {
+		i++
+	}
+	return i
+}
+
+// vertical moves the cursor to the previous or next line, keeping its
+// column if possible, and reports whether there was such a line
+func (e *Editor) vertical(delta int) bool {
+	start := e.lineStart(e.pos)
+	var i int
+	switch {
+	case delta < 0 && start > 0:
+		i = e.lineStart(start - 1)
+	case delta > 0 && e.lineEnd(e.pos) < len(e.buf):
+		i = e.lineEnd(e.pos) + 1
+	default:
+		return false
+	}
+	col := screen.StringWidth(string(e.buf[start:e.pos]))
+	for w := 0; i < len(e.buf) && e.buf[i] != '\n'; i++ {
+		if w += screen.RuneWidth(e.buf[i]); w > col {
+			break
+		}
+	}
+	e.pos = i
+	return true
+}
+
+// transpose swaps the characters before and at the cursor, or the two
+// last ones at the end of the line
+func (e *Editor) transpose() {
+	if len(e.buf) < 2 || e.pos == 0 {
+		return
+	}
+	i := e.pos
+	if i == len(e.buf) {
+		i--
+	}
+	e.buf[i-1], e.buf[i] = e.buf[i], e.buf[i-1]
+	e.pos = i + 1
+	e.edited()
+}
+
+// browse moves in the history by delta entries
+func (e *Editor) browse(delta int) {
+	h := e.opts.history
+	if h == nil {
+		return
+	}
+	i := e.hist + delta
+	if i < 0 || i > h.Len() {
+		return
+	}
+	if e.hist == h.Len() {
+		e.saved = append(e.saved[:0], e.buf...)
+	}
+	e.hist = i
+	if i == h.Len() {
+		e.set(e.saved)
+		return
+	}
+	e.set([]rune(h.At(i)))
+}
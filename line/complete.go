@@ -0,0 +1,348 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/screen"
+)
+
+// Completion is a completion candidate
+type Completion struct {
+	// Text replaces the completed word
+	Text string
+	// Display is shown in the menu instead of Text, if set
+	Display string
+	// Description is shown next to the candidate in the menu
+	Description string
+}
+
+func (c Completion) display() string {
+	if c.Display != "" {
+		return c.Display
+	}
+	return c.Text
+}
+
+// CompletionRequest describes the line being completed
+type CompletionRequest struct {
+	// Line is the line and Pos the cursor position in it, in runes
+	Line string
+	Pos  int
+	// Word is the word before the cursor being completed, starting at
+	// Start
+	Word  string
+	Start int
+}
+
+// Completer provides the completions of a line.
+// It is called in its own goroutine, and ctx is cancelled once its
+// completions are no longer needed, e.g. when the line was changed.
+type Completer interface {
+	Complete(ctx context.Context, req CompletionRequest) ([]Completion, error)
+}
+
+// CompleterFunc is a function used as a Completer
+type CompleterFunc func(ctx context.Context, req CompletionRequest) ([]Completion, error)
+
+func (f CompleterFunc) Complete(ctx context.Context, req CompletionRequest) ([]Completion, error) {
+	return f(ctx, req)
+}
+
+// MatchFunc reports whether the candidate matches the completed word, and
+// how well, the best matches being shown first
+type MatchFunc func(word, candidate string) (score int, ok bool)
+
+// Prefix matches the candidates starting with the word
+func Prefix(word, candidate string) (int, bool) {
+	return 0, strings.HasPrefix(candidate, word)
+}
+
+// Fuzzy matches the candidates containing the runes of the word in order,
+// ignoring the case. The matches at the beginning of the candidate or of
+// its words, and the consecutive ones, score higher.
+func Fuzzy(word, candidate string) (int, bool) {
+	if word == "" {
+		return 0, true
+	}
+	score, prev, consecutive := 0, rune(0), false
+	w := []rune(strings.ToLower(word))
+	i := 0
+	for j, r := range candidate {
+		if i == len(w) {
+			break
+		}
+		if unicode.ToLower(r) != w[i] {
+			consecutive = false
+			prev = r
+			continue
+		}
+		score++
+		switch {
+		case j == 0:
+			score += 8
+		case !isWord(prev) || unicode.IsUpper(r) && unicode.IsLower(prev):
+			score += 4
+		}
+		if consecutive {
+			score += 3
+		}
+		if r == w[i] {
+			score++
+		}
+		consecutive = true
+		prev = r
+		i++
+	}
+	if i < len(w) {
+		return 0, false
+	}
+	// the shorter candidates are closer matches
+	return score*64 - utf8.RuneCountInString(candidate), true
+}
+
+type completionResult struct {
+	rev   int
+	req   CompletionRequest
+	items []Completion
+	err   error
+}
+
+// completion is a pending completion request
+type completion struct {
+	cancel   context.CancelFunc
+	backward bool
+}
+
+// menu is the completion menu
+type menu struct {
+	// all are the completions, items the ones matching the word
+	all   []Completion
+	items []Completion
+	// sel is the selected item, -1 if none
+	sel int
+	// start is the beginning of the completed word
+	start int
+}
+
+// complete requests the completions, or selects the next one if the menu
+// is shown
+func (e *Editor) complete(backward bool) {
+	if m := e.menu; m != nil {
+		switch {
+		case !backward:
+			m.sel = (m.sel + 1) % len(m.items)
+		case m.sel <= 0:
+			m.sel = len(m.items) - 1
+		default:
+			m.sel--
+		}
+		return
+	}
+	if e.opts.completer == nil {
+		return
+	}
+	e.cancelCompletion()
+	start := e.pos
+	for start > 0 && !unicode.IsSpace(e.buf[start-1]) {
+		start--
+	}
+	req := CompletionRequest{
+		Line:  string(e.buf),
+		Pos:   e.pos,
+		Word:  string(e.buf[start:e.pos]),
+		Start: start,
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	e.comp = &completion{cancel: cancel, backward: backward}
+	rev, c := e.rev, e.opts.completer
+	go func() {
+		items, err := c.Complete(ctx, req)
+		select {
+		case e.results <- completionResult{rev: rev, req: req, items: items, err: err}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// completed handles the completions of the pending request
+func (e *Editor) completed(r completionResult) {
+	if e.comp == nil || r.rev != e.rev {
+		return
+	}
+	backward := e.comp.backward
+	e.cancelCompletion()
+	if r.err != nil {
+		return
+	}
+	items := e.match(r.req.Word, r.items)
+	switch len(items) {
+	case 0:
+		e.t.Write([]byte(ansi.BEL))
+		return
+	case 1:
+		e.replace(r.req.Start, items[0].Text)
+		e.render()
+		return
+	}
+	if p := commonPrefix(items); len(p) > len(r.req.Word) && strings.HasPrefix(p, r.req.Word) {
+		e.replace(r.req.Start, p)
+	}
+	e.menu = &menu{all: r.items, items: items, sel: -1, start: r.req.Start}
+	if backward {
+		e.menu.sel = len(items) - 1
+	}
+	e.render()
+}
+
+// match returns the completions matching the word, best first
+func (e *Editor) match(word string, all []Completion) []Completion {
+	type scored struct {
+		c     Completion
+		score int
+	}
+	var s []scored
+	for _, c := range all {
+		if score, ok := e.opts.match(word, c.Text); ok {
+			s = append(s, scored{c, score})
+		}
+	}
+	sort.SliceStable(s, func(i, j int) bool {
+		return s[i].score > s[j].score
+	})
+	items := make([]Completion, len(s))
+	for i, v := range s {
+		items[i] = v.c
+	}
+	return items
+}
+
+// refilter matches the menu completions against the word being typed,
+// closing the menu if none matches
+func (e *Editor) refilter() {
+	m := e.menu
+	if m == nil {
+		return
+	}
+	if e.pos < m.start {
+		e.closeMenu()
+		return
+	}
+	m.items = e.match(string(e.buf[m.start:e.pos]), m.all)
+	m.sel = -1
+	if len(m.items) == 0 {
+		e.closeMenu()
+	}
+}
+
+// replace replaces the word from start to the cursor with s
+func (e *Editor) replace(start int, s string) {
+	e.remove(start, e.pos)
+	e.insert([]rune(s))
+}
+
+// applyCompletion replaces the completed word and closes the menu
+func (e *Editor) applyCompletion(c Completion) {
+	e.replace(e.menu.start, c.Text)
+	e.closeMenu()
+}
+
+func (e *Editor) closeMenu() {
+	e.menu = nil
+}
+
+// cancelCompletion cancels the pending completion request
+func (e *Editor) cancelCompletion() {
+	if e.comp != nil {
+		e.comp.cancel()
+		e.comp = nil
+	}
+}
+
+func commonPrefix(items []Completion) string {
+	p := items[0].Text
+	for _, c := range items[1:] {
+		for !strings.HasPrefix(c.Text, p) {
+			_, n := utf8.DecodeLastRuneInString(p)
+			p = p[:len(p)-n]
+		}
+	}
+	return p
+}
+
+// lines renders the menu in rows of cols columns: the completions with a
+// description are shown one per row, the others in as many columns as fit.
+// Only maxRows rows around the selection are shown.
+func (m *menu) lines(cols, maxRows int) []string {
+	if maxRows <= 0 {
+		maxRows = DefaultMenuRows
+	}
+	dw, desc := 0, false
+	for _, c := range m.items {
+		if w := screen.StringWidth(c.display()); w > dw {
+			dw = w
+		}
+		desc = desc || c.Description != ""
+	}
+	cw := dw + 2
+	ncols := 1
+	if !desc && cw < cols {
+		ncols = cols / cw
+	}
+	nrows := (len(m.items) + ncols - 1) / ncols
+	// the items are laid out in columns
+	first := 0
+	if nrows > maxRows && m.sel >= 0 {
+		if r := m.sel % nrows; r >= maxRows {
+			first = r - maxRows + 1
+		}
+	}
+	last := first + maxRows
+	if last > nrows {
+		last = nrows
+	}
+	var lines []string
+	for r := first; r < last; r++ {
+		var b strings.Builder
+		for c := 0; c < ncols; c++ {
+			i := c*nrows + r
+			if i >= len(m.items) {
+				break
+			}
+			it := m.items[i]
+			s := pad(it.display(), dw)
+			if desc && it.Description != "" {
+				s += "  " + it.Description
+			}
+			s = truncate(s, cols-1)
+			if i == m.sel {
+				b.WriteString(ansi.SGR("7") + s + ansi.ResetStyle)
+			} else {
+				b.WriteString(s)
+			}
+			if c < ncols-1 {
+				b.WriteString("  ")
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
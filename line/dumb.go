@@ -0,0 +1,75 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"context"
+	"io"
+
+	"go.linka.cloud/console/input"
+)
+
+// readDumb reads a line on a dumb Term: the prompt is written once and the
+// line is not redrawn, the terminal echoing the input if it does.
+// Only the characters, Backspace, Enter, Ctrl-C and Ctrl-D are handled.
+func (e *Editor) readDumb(ctx context.Context) (string, error) {
+	e.t.Write([]byte(e.opts.prompt))
+	for {
+		select {
+		case ev, ok := <-e.events:
+			if !ok {
+				return "", e.rerr
+			}
+			k, ok := ev.ev.(input.KeyEvent)
+			switch {
+			case !ok:
+				switch ev := ev.ev.(type) {
+				case input.TextEvent:
+					e.buf = append(e.buf, []rune(string(ev))...)
+				case input.PasteEvent:
+					e.buf = append(e.buf, []rune(normalize(string(ev)))...)
+				}
+			case k.Key == input.KeyEnter:
+				if e.opts.accept != nil && !e.opts.accept(string(e.buf)) {
+					e.buf = append(e.buf, '\n')
+					e.t.Write([]byte(e.continuation()))
+					continue
+				}
+				line := string(e.buf)
+				if e.opts.history != nil {
+					e.opts.history.Add(line)
+				}
+				return line, nil
+			case k.Key == input.KeyBackspace:
+				if len(e.buf) > 0 {
+					e.buf = e.buf[:len(e.buf)-1]
+				}
+			case k.Key == input.KeyTab:
+				e.buf = append(e.buf, '\t')
+			case k.Key != input.KeyRune:
+			case k.Mod == input.ModCtrl && k.Rune == 'c':
+				return "", ErrInterrupted
+			case k.Mod == input.ModCtrl && k.Rune == 'd':
+				if len(e.buf) == 0 {
+					return "", io.EOF
+				}
+			case k.Mod&(input.ModCtrl|input.ModAlt) == 0:
+				e.buf = append(e.buf, k.Rune)
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
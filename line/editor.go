@@ -0,0 +1,406 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package line provides a line editor reading from a Term, with the emacs
+// key bindings, a history and an asynchronous completion.
+package line
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.linka.cloud/console/history"
+	"go.linka.cloud/console/input"
+	"go.linka.cloud/console/keymap"
+	"go.linka.cloud/console/term"
+)
+
+// ErrInterrupted is returned by ReadLine when the line is interrupted,
+// e.g. with Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// DefaultMenuRows is the default number of rows of the completion menu
+const DefaultMenuRows = 8
+
+type options struct {
+	prompt      string
+	history     *history.History
+	keymap      *keymap.Keymap
+	completer   Completer
+	match       MatchFunc
+	menuRows    int
+	highlighter Highlighter
+	cont        *string
+	right       string
+	transient   *string
+	accept      func(line string) bool
+	mode        Mode
+	onMode      func(m Mode)
+	undoPause   time.Duration
+}
+
+// Option configures an Editor
+type Option func(o *options)
+
+// WithPrompt sets the prompt, which may be styled
+func WithPrompt(p string) Option {
+	return func(o *options) {
+		o.prompt = p
+	}
+}
+
+// WithContinuationPrompt sets the prompt of the lines following the first
+// one, defaults to spaces as wide as the prompt
+func WithContinuationPrompt(p string) Option {
+	return func(o *options) {
+		o.cont = &p
+	}
+}
+
+// WithRightPrompt sets a prompt aligned on the right of the first row,
+// hidden when the line reaches it
+func WithRightPrompt(p string) Option {
+	return func(o *options) {
+		o.right = p
+	}
+}
+
+// WithTransientPrompt sets the prompt replacing the prompt of the lines
+// once they are done, the right prompt being removed, e.g. to keep the
+// scrollback compact
+func WithTransientPrompt(p string) Option {
+	return func(o *options) {
+		o.transient = &p
+	}
+}
+
+// WithAccept sets the function reporting whether Enter accepts the line,
+// a newline being inserted otherwise, e.g. until a statement is terminated
+// by a semicolon
+func WithAccept(fn func(line string) bool) Option {
+	return func(o *options) {
+		o.accept = fn
+	}
+}
+
+// WithHistory sets the History browsed by the editor and the accepted lines
+// are added to
+func WithHistory(h *history.History) Option {
+	return func(o *options) {
+		o.history = h
+	}
+}
+
+// WithKeymap sets the key bindings of the emacs mode, defaults to the
+// "emacs" keymap of the registry
+func WithKeymap(km *keymap.Keymap) Option {
+	return func(o *options) {
+		o.keymap = km
+	}
+}
+
+// WithMode sets the editing mode, ModeEmacs (the default) or ModeViInsert
+// for the vi key bindings
+func WithMode(m Mode) Option {
+	return func(o *options) {
+		o.mode = m
+	}
+}
+
+// WithModeFunc sets the function called with the editing mode when it
+// changes, e.g. to show a vi mode indicator
+func WithModeFunc(fn func(m Mode)) Option {
+	return func(o *options) {
+		o.onMode = fn
+	}
+}
+
+// WithCompleter sets the provider of the completions
+func WithCompleter(c Completer) Option {
+	return func(o *options) {
+		o.completer = c
+	}
+}
+
+// WithMatch sets how the completions are matched against the completed
+// word, defaults to Fuzzy
+func WithMatch(fn MatchFunc) Option {
+	return func(o *options) {
+		o.match = fn
+	}
+}
+
+// WithHighlighter sets the Highlighter styling the line
+func WithHighlighter(h Highlighter) Option {
+	return func(o *options) {
+		o.highlighter = h
+	}
+}
+
+// WithUndoPause sets the pause after which the typed characters start a new
+// undo group, defaults to DefaultUndoPause
+func WithUndoPause(d time.Duration) Option {
+	return func(o *options) {
+		o.undoPause = d
+	}
+}
+
+// WithMenuRows sets the maximum number of rows of the completion menu,
+// defaults to DefaultMenuRows
+func WithMenuRows(n int) Option {
+	return func(o *options) {
+		o.menuRows = n
+	}
+}
+
+type event struct {
+	ev  input.Event
+	err error
+}
+
+// Editor reads lines from a Term.
+// It reads the Term input from the first ReadLine call until the input
+// fails, so the Term should not be read by anything else.
+type Editor struct {
+	t    term.Term
+	opts options
+	// mt is the Matcher of the current mode
+	mt       *keymap.Matcher
+	matchers map[Mode]*keymap.Matcher
+	// base is the mode each line starts in
+	base Mode
+	mode Mode
+
+	start  sync.Once
+	events chan event
+	// rerr is the input error, set before events is closed
+	rerr error
+
+	// the state of the line being read, owned by ReadLine
+	ctx  context.Context
+	buf  []rune
+	pos  int
+	rev  int
+	kill []rune
+	// row is the row of the cursor, relative to the first row of the
+	// prompt
+	row int
+	// done is true while the done line is rendered
+	done bool
+	// hist is the index of the history entry being edited, the history
+	// length for the new line, saved while browsing the history
+	hist  int
+	saved []rune
+
+	// undos and redos are the undo stacks, edit the kind of change made
+	// by the current event, and last, lastPos and lastEdit describe the
+	// previous change
+	undos    []snapshot
+	redos    []snapshot
+	edit     edit
+	last     edit
+	lastPos  int
+	lastEdit time.Time
+
+	comp *completion
+	menu *menu
+	// results receives the completions from the providers
+	results chan completionResult
+
+	// hl is the styled hlLine, cached while the line does not change
+	hlLine string
+	hl     string
+}
+
+// New returns an Editor reading from the Term
+func New(t term.Term, opts ...Option) *Editor {
+	o := options{match: Fuzzy, menuRows: DefaultMenuRows, undoPause: DefaultUndoPause}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.mode == "" {
+		o.mode = ModeEmacs
+	}
+	e := &Editor{
+		t:        t,
+		opts:     o,
+		matchers: make(map[Mode]*keymap.Matcher),
+		base:     o.mode,
+		events:   make(chan event),
+		results:  make(chan completionResult),
+	}
+	if o.keymap != nil {
+		e.matchers[ModeEmacs] = o.keymap.Matcher()
+	}
+	return e
+}
+
+// read sends the Term input events to ReadLine
+func (e *Editor) read() {
+	d := input.NewDecoder(e.t)
+	d.SetEscapeTimeout(input.DefaultEscapeTimeout)
+	for {
+		ev, err := d.ReadEvent()
+		if err != nil {
+			e.rerr = err
+			close(e.events)
+			return
+		}
+		e.events <- event{ev: ev}
+	}
+}
+
+// ReadLine reads a line, returning io.EOF if the input ended or Ctrl-D was
+// typed on an empty line, ErrInterrupted if it was interrupted, or the
+// context error. It must not be called concurrently.
+// The line may span several lines, see WithAccept and InsertNewline.
+// ReadLine receives the Term WatchSize sizes to redraw the line when the
+// terminal is resized.
+// On a dumb Term, the line is neither redrawn nor edited with the key
+// bindings, see Term.Dumb.
+func (e *Editor) ReadLine(ctx context.Context) (string, error) {
+	e.start.Do(func() {
+		go e.read()
+	})
+	e.ctx = ctx
+	e.buf, e.pos, e.row, e.saved = e.buf[:0], 0, 0, nil
+	e.hist = 0
+	if e.opts.history != nil {
+		e.hist = e.opts.history.Len()
+	}
+	if e.t.Dumb() {
+		return e.readDumb(ctx)
+	}
+	e.resetUndo()
+	e.setMode(e.base)
+	e.render()
+	sizes := e.t.WatchSize()
+	for {
+		select {
+		case sz, ok := <-sizes:
+			if !ok {
+				sizes = nil
+				continue
+			}
+			e.resized(sz.Cols)
+		case ev, ok := <-e.events:
+			if !ok {
+				e.finish()
+				return "", e.rerr
+			}
+			s := e.checkpoint()
+			if line, done, err := e.handle(ev.ev); done {
+				return line, err
+			}
+			e.commit(s)
+		case r := <-e.results:
+			s := e.checkpoint()
+			e.completed(r)
+			e.commit(s)
+		case <-ctx.Done():
+			e.finish()
+			return "", ctx.Err()
+		}
+	}
+}
+
+// handle handles an input event and reports whether the line is done
+func (e *Editor) handle(ev input.Event) (string, bool, error) {
+	switch ev := ev.(type) {
+	case input.KeyEvent:
+		res := e.mt.Feed(ev)
+		if res.Matched {
+			return e.do(res.Action)
+		}
+		for _, k := range res.Keys {
+			if e.mode != ModeViNormal && k.Key == input.KeyRune && k.Mod&(input.ModCtrl|input.ModAlt) == 0 {
+				e.insert([]rune{k.Rune})
+				e.edit = editTyping
+			}
+		}
+		if len(res.Keys) > 0 {
+			e.refilter()
+			e.render()
+		}
+	case input.TextEvent:
+		e.insert([]rune(string(ev)))
+		e.edit = editTyping
+		e.refilter()
+		e.render()
+	case input.PasteEvent:
+		e.insert([]rune(normalize(string(ev))))
+		e.closeMenu()
+		e.render()
+	}
+	return "", false, nil
+}
+
+// normalize replaces the line breaks of the pasted text with newlines
+func normalize(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	return strings.Replace(s, "\r", "\n", -1)
+}
+
+// finish moves the cursor after the line
+func (e *Editor) finish() {
+	e.cancelCompletion()
+	e.menu = nil
+	e.pos = len(e.buf)
+	e.done = true
+	e.render()
+	e.done = false
+	e.t.Write([]byte("\r\n"))
+	e.row = 0
+}
+
+// edited records a change of the line
+func (e *Editor) edited() {
+	e.rev++
+	e.cancelCompletion()
+}
+
+func (e *Editor) insert(r []rune) {
+	if len(r) == 0 {
+		return
+	}
+	e.buf = append(e.buf[:e.pos], append(r, e.buf[e.pos:]...)...)
+	e.pos += len(r)
+	e.edited()
+}
+
+// remove removes the runes from i to j
+func (e *Editor) remove(i, j int) []rune {
+	if i >= j {
+		return nil
+	}
+	del := append([]rune(nil), e.buf[i:j]...)
+	e.buf = append(e.buf[:i], e.buf[j:]...)
+	if e.pos > j {
+		e.pos -= j - i
+	} else if e.pos > i {
+		e.pos = i
+	}
+	e.edited()
+	return del
+}
+
+// set replaces the line
+func (e *Editor) set(s []rune) {
+	e.buf = append(e.buf[:0], s...)
+	e.pos = len(e.buf)
+	e.edited()
+}
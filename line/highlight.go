@@ -0,0 +1,55 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"strings"
+
+	"go.linka.cloud/console/style"
+)
+
+// Segment is a styled part of the line
+type Segment struct {
+	Text  string
+	Style style.Style
+}
+
+// Highlighter returns the styled segments of the line, e.g. for a REPL to
+// color its syntax. It is called each time the line changes, and the
+// segments texts must add up to the line, which is otherwise shown
+// unstyled.
+type Highlighter func(line string) []Segment
+
+// highlight returns the line styled by the Highlighter
+func (e *Editor) highlight(line string) string {
+	if e.opts.highlighter == nil {
+		return line
+	}
+	if line == e.hlLine && e.hl != "" {
+		return e.hl
+	}
+	segs := e.opts.highlighter(line)
+	var b, text strings.Builder
+	p := style.Profile()
+	for _, s := range segs {
+		text.WriteString(s.Text)
+		b.WriteString(s.Style.RenderWith(p, s.Text))
+	}
+	if text.String() != line {
+		return line
+	}
+	e.hlLine, e.hl = line, b.String()
+	return e.hl
+}
@@ -0,0 +1,256 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"go.linka.cloud/console/keymap"
+)
+
+// Mode is an editing mode, its key bindings being the keymap registered
+// under its name in the keymap registry
+type Mode string
+
+const (
+	// ModeEmacs is the default editing mode
+	ModeEmacs Mode = "emacs"
+	// ModeViInsert is the vi insert mode, each line starts in when editing
+	// with vi key bindings
+	ModeViInsert Mode = "vi-insert"
+	// ModeViNormal is the vi normal (command) mode, entered with Escape
+	ModeViNormal Mode = "vi-normal"
+)
+
+// The vi and editing mode actions
+const (
+	EmacsEditingMode keymap.Action = "emacs-editing-mode"
+	ViEditingMode    keymap.Action = "vi-editing-mode"
+	ViMovementMode   keymap.Action = "vi-movement-mode"
+	ViInsertMode     keymap.Action = "vi-insertion-mode"
+	ViInsertBeg      keymap.Action = "vi-insert-beg"
+	ViAppendMode     keymap.Action = "vi-append-mode"
+	ViAppendEOL      keymap.Action = "vi-append-eol"
+	ViForwardWord    keymap.Action = "vi-forward-word"
+	ViEndWord        keymap.Action = "vi-end-word"
+	ViKillWord       keymap.Action = "vi-kill-word"
+	ViChangeToEOL    keymap.Action = "vi-change-to-eol"
+	ViChangeLine     keymap.Action = "vi-change-line"
+	ViPut            keymap.Action = "vi-put"
+	KillWholeLine    keymap.Action = "kill-whole-line"
+)
+
+func init() {
+	keymap.Register(string(ModeEmacs), DefaultKeymap())
+	keymap.Register(string(ModeViInsert), ViInsertKeymap())
+	keymap.Register(string(ModeViNormal), ViNormalKeymap())
+}
+
+// ViInsertKeymap returns the vi insert mode key bindings: the emacs ones,
+// Escape entering the normal mode
+func ViInsertKeymap() *keymap.Keymap {
+	return DefaultKeymap().
+		MustBind("esc", ViMovementMode)
+}
+
+// ViNormalKeymap returns the vi normal mode key bindings
+func ViNormalKeymap() *keymap.Keymap {
+	return keymap.New().
+		MustBind("enter", AcceptLine).
+		MustBind("ctrl+c", Interrupt).
+		MustBind("ctrl+d", DeleteCharOrEOF).
+		MustBind("ctrl+l", ClearScreen).
+		MustBind("i", ViInsertMode).
+		MustBind("insert", ViInsertMode).
+		MustBind("I", ViInsertBeg).
+		MustBind("a", ViAppendMode).
+		MustBind("A", ViAppendEOL).
+		MustBind("h", BackwardChar).
+		MustBind("left", BackwardChar).
+		MustBind("backspace", BackwardChar).
+		MustBind("l", ForwardChar).
+		MustBind("right", ForwardChar).
+		MustBind("space", ForwardChar).
+		MustBind("w", ViForwardWord).
+		MustBind("b", BackwardWord).
+		MustBind("e", ViEndWord).
+		MustBind("0", BeginningOfLine).
+		MustBind("home", BeginningOfLine).
+		MustBind("$", EndOfLine).
+		MustBind("end", EndOfLine).
+		MustBind("x", DeleteChar).
+		MustBind("delete", DeleteChar).
+		MustBind("X", BackwardDeleteChar).
+		MustBind("D", KillLine).
+		MustBind("d d", KillWholeLine).
+		MustBind("d w", ViKillWord).
+		MustBind("d b", BackwardKillWord).
+		MustBind("d $", KillLine).
+		MustBind("d 0", UnixLineDiscard).
+		MustBind("C", ViChangeToEOL).
+		MustBind("S", ViChangeLine).
+		MustBind("c c", ViChangeLine).
+		MustBind("p", ViPut).
+		MustBind("P", Yank).
+		MustBind("u", Undo).
+		MustBind("ctrl+r", Redo).
+		MustBind("k", UpLineOrHistory).
+		MustBind("up", UpLineOrHistory).
+		MustBind("j", DownLineOrHistory).
+		MustBind("down", DownLineOrHistory).
+		MustBind("tab", Complete)
+}
+
+// preset returns the built-in keymap of the mode
+func preset(m Mode) *keymap.Keymap {
+	switch m {
+	case ModeViInsert:
+		return ViInsertKeymap()
+	case ModeViNormal:
+		return ViNormalKeymap()
+	default:
+		return DefaultKeymap()
+	}
+}
+
+// matcher returns the Matcher of the mode keymap, taken from the registry
+// when the mode is first used
+func (e *Editor) matcher(m Mode) *keymap.Matcher {
+	if mt, ok := e.matchers[m]; ok {
+		return mt
+	}
+	km, ok := keymap.Get(string(m))
+	if !ok {
+		km = preset(m)
+	}
+	mt := km.Matcher()
+	e.matchers[m] = mt
+	return mt
+}
+
+// setMode switches to the mode, notifying the mode function if it changed
+func (e *Editor) setMode(m Mode) {
+	changed := m != e.mode
+	e.mode = m
+	e.mt = e.matcher(m)
+	e.mt.Reset()
+	if changed && e.opts.onMode != nil {
+		e.opts.onMode(m)
+	}
+}
+
+// doMode runs the vi and editing mode actions and reports whether the
+// action was one of them
+func (e *Editor) doMode(a keymap.Action) bool {
+	switch a {
+	case EmacsEditingMode:
+		e.base = ModeEmacs
+		e.setMode(ModeEmacs)
+	case ViEditingMode:
+		e.base = ModeViInsert
+		e.setMode(ModeViInsert)
+	case ViMovementMode:
+		e.cancelCompletion()
+		// the cursor moves back onto the last inserted character
+		if e.pos > e.lineStart(e.pos) {
+			e.pos--
+		}
+		e.setMode(ModeViNormal)
+	case ViInsertMode:
+		e.setMode(ModeViInsert)
+	case ViInsertBeg:
+		e.pos = e.lineStart(e.pos)
+		e.setMode(ModeViInsert)
+	case ViAppendMode:
+		if e.pos < e.lineEnd(e.pos) {
+			e.pos++
+		}
+		e.setMode(ModeViInsert)
+	case ViAppendEOL:
+		e.pos = e.lineEnd(e.pos)
+		e.setMode(ModeViInsert)
+	case ViForwardWord:
+		e.pos = e.nextWord()
+	case ViEndWord:
+		e.pos = clamp(e.endWord(), 0, len(e.buf))
+	case ViKillWord:
+		e.kill = e.remove(e.pos, e.nextWord())
+	case ViChangeToEOL:
+		e.kill = e.remove(e.pos, e.lineEnd(e.pos))
+		e.setMode(ModeViInsert)
+	case ViChangeLine:
+		e.kill = e.remove(e.lineStart(e.pos), e.lineEnd(e.pos))
+		e.setMode(ModeViInsert)
+	case KillWholeLine:
+		start, end := e.lineStart(e.pos), e.lineEnd(e.pos)
+		// the newline goes with the line
+		if end < len(e.buf) {
+			end++
+		} else if start > 0 {
+			start--
+		}
+		e.kill = e.remove(start, end)
+	case ViPut:
+		if len(e.kill) == 0 {
+			break
+		}
+		if e.pos < e.lineEnd(e.pos) {
+			e.pos++
+		}
+		e.insert(append([]rune(nil), e.kill...))
+		// the cursor ends on the last character put
+		e.pos--
+	default:
+		return false
+	}
+	return true
+}
+
+// normal keeps the cursor on a character in the vi normal mode
+func (e *Editor) normal() {
+	if e.mode == ModeViNormal && e.pos == e.lineEnd(e.pos) && e.pos > e.lineStart(e.pos) {
+		e.pos--
+	}
+}
+
+// nextWord returns the beginning of the next word, vi words being runs of
+// word or of punctuation characters
+func (e *Editor) nextWord() int {
+	i := e.pos
+	if i < len(e.buf) && !isSpace(e.buf[i]) {
+		w := isWord(e.buf[i])
+		for i < len(e.buf) && !isSpace(e.buf[i]) && isWord(e.buf[i]) == w {
+			i++
+		}
+	}
+	for i < len(e.buf) && isSpace(e.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// endWord returns the last character of the word ending after the cursor
+func (e *Editor) endWord() int {
+	i := e.pos + 1
+	for i < len(e.buf) && isSpace(e.buf[i]) {
+		i++
+	}
+	if i >= len(e.buf) {
+		return len(e.buf) - 1
+	}
+	w := isWord(e.buf[i])
+	for i+1 < len(e.buf) && !isSpace(e.buf[i+1]) && isWord(e.buf[i+1]) == w {
+		i++
+	}
+	return i
+}
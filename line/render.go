@@ -0,0 +1,165 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"strings"
+
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/screen"
+)
+
+// render redraws the prompt, the line and the completion menu, and places
+// the cursor.
+// The terminal wraps the line, the rows it takes are computed from the
+// Term width.
+func (e *Editor) render() {
+	cols := e.cols()
+	var b strings.Builder
+	b.WriteString(ansi.HideCursor)
+	if e.row > 0 {
+		b.WriteString(ansi.CursorUp(e.row))
+	}
+	b.WriteString("\r" + ansi.EraseDown)
+	b.WriteString(e.prompt())
+	b.WriteString(strings.Replace(e.highlight(string(e.buf)), "\n", "\r\n"+e.continuation(), -1))
+	row, w := e.wrap(len(e.buf), cols)
+	// the cursor stays on the last column of a full row until something
+	// is written
+	if w > 0 && w%cols == 0 {
+		b.WriteString("\r\n")
+	}
+	row += w / cols
+	if e.menu != nil {
+		for _, l := range e.menu.lines(cols, e.opts.menuRows) {
+			b.WriteString("\r\n" + l)
+			row++
+		}
+	}
+	if r := e.right(cols); r != "" {
+		if row > 0 {
+			b.WriteString(ansi.CursorUp(row))
+		}
+		b.WriteString("\r" + ansi.CursorForward(cols-screen.VisibleWidth(r)-1) + r)
+		row = 0
+	}
+	crow, ccol := e.position(e.pos, cols)
+	if row > crow {
+		b.WriteString(ansi.CursorUp(row - crow))
+	} else if crow > row {
+		b.WriteString(ansi.CursorDown(crow - row))
+	}
+	b.WriteString("\r")
+	if ccol > 0 {
+		b.WriteString(ansi.CursorForward(ccol))
+	}
+	b.WriteString(ansi.ShowCursor)
+	e.row = crow
+	e.t.Write([]byte(b.String()))
+}
+
+// resized redraws the line for the new width.
+// The terminals reflowing the wrapped lines move the cursor to its position
+// in the new width, which the redraw starts from.
+func (e *Editor) resized(cols int) {
+	if cols <= 0 {
+		return
+	}
+	e.row, _ = e.position(e.pos, cols)
+	e.render()
+}
+
+func (e *Editor) cols() int {
+	if cols := e.t.Size().Cols; cols > 0 {
+		return cols
+	}
+	return 80
+}
+
+// prompt returns the prompt, the transient one once the line is done
+func (e *Editor) prompt() string {
+	if e.done && e.opts.transient != nil {
+		return *e.opts.transient
+	}
+	return e.opts.prompt
+}
+
+// continuation returns the prompt of the lines following the first one
+func (e *Editor) continuation() string {
+	if e.opts.cont != nil {
+		return *e.opts.cont
+	}
+	return strings.Repeat(" ", screen.VisibleWidth(e.prompt()))
+}
+
+// right returns the right prompt if it fits on the first row, at least a
+// column away from the line and without using the last column
+func (e *Editor) right(cols int) string {
+	if e.opts.right == "" || e.done && e.opts.transient != nil {
+		return ""
+	}
+	_, w := e.wrap(e.lineEnd(0), cols)
+	if w+screen.VisibleWidth(e.opts.right)+2 > cols {
+		return ""
+	}
+	return e.opts.right
+}
+
+// wrap returns the row starting the line of the rune i, relative to the
+// first row of the prompt, and the width of that line up to i, including
+// its prompt
+func (e *Editor) wrap(i, cols int) (row, w int) {
+	w = screen.VisibleWidth(e.prompt())
+	cw := screen.VisibleWidth(e.continuation())
+	for _, r := range e.buf[:i] {
+		if r != '\n' {
+			w += screen.RuneWidth(r)
+			continue
+		}
+		// an empty line takes a row
+		row += (w + cols - 1) / cols
+		if w == 0 {
+			row++
+		}
+		w = cw
+	}
+	return row, w
+}
+
+// position returns the row and column of the rune i, the row being
+// relative to the first row of the prompt
+func (e *Editor) position(i, cols int) (row, col int) {
+	row, w := e.wrap(i, cols)
+	return row + w/cols, w % cols
+}
+
+// truncate truncates s to the width w
+func truncate(s string, w int) string {
+	n := 0
+	for i, r := range s {
+		if n += screen.RuneWidth(r); n > w {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// pad pads s with spaces to the width w
+func pad(s string, w int) string {
+	if n := screen.StringWidth(s); n < w {
+		return s + strings.Repeat(" ", w-n)
+	}
+	return s
+}
@@ -0,0 +1,278 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"context"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/input"
+	"go.linka.cloud/console/screen"
+)
+
+// DefaultMask is the default rune echoed for each character of a secret
+const DefaultMask = '*'
+
+type secretOptions struct {
+	prompt   string
+	mask     rune
+	onChange func(secret []rune) string
+}
+
+// SecretOption configures ReadSecret
+type SecretOption func(o *secretOptions)
+
+// WithSecretPrompt sets the prompt of the secret
+func WithSecretPrompt(p string) SecretOption {
+	return func(o *secretOptions) {
+		o.prompt = p
+	}
+}
+
+// WithMask sets the rune echoed for each character, defaults to DefaultMask.
+// A zero mask hides the secret completely, its length included.
+func WithMask(r rune) SecretOption {
+	return func(o *secretOptions) {
+		o.mask = r
+	}
+}
+
+// WithSecretFunc sets the function called with the secret each time it
+// changes, returning a hint shown after the input, e.g. a strength meter.
+// The secret must not be retained: it is cleared once read.
+func WithSecretFunc(fn func(secret []rune) string) SecretOption {
+	return func(o *secretOptions) {
+		o.onChange = fn
+	}
+}
+
+// secret is the state of the secret being read
+type secret struct {
+	opts secretOptions
+	buf  []rune
+	hint string
+	// shown is set once the prompt is written on a dumb Term
+	shown bool
+}
+
+// ReadSecret reads a secret, e.g. a password, without echoing it. It can
+// be typed or pasted, the pasted line breaks being dropped, and erased
+// with Backspace or Ctrl-U.
+// It returns the same errors as ReadLine. The returned secret can be
+// cleared by the caller once used.
+// On a dumb Term, the secret is not masked, the echo of the terminal being
+// disabled, and console.ErrUnsupported is returned if its input is not a
+// terminal.
+func (e *Editor) ReadSecret(ctx context.Context, opts ...SecretOption) ([]byte, error) {
+	s := &secret{opts: secretOptions{mask: DefaultMask}}
+	for _, v := range opts {
+		v(&s.opts)
+	}
+	restore, err := e.t.DisableEcho()
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	e.start.Do(func() {
+		go e.read()
+	})
+	defer func() {
+		for i := range s.buf {
+			s.buf[i] = 0
+		}
+	}()
+	e.row = 0
+	e.t.Write([]byte(ansi.EnableBracketedPaste))
+	defer console.OnExit(func() error {
+		_, err := e.t.Write([]byte(ansi.DisableBracketedPaste))
+		return err
+	})()
+	e.renderSecret(s)
+	sizes := e.t.WatchSize()
+	for {
+		select {
+		case sz, ok := <-sizes:
+			if !ok {
+				sizes = nil
+				continue
+			}
+			if sz.Cols > 0 {
+				e.row = e.secretWidth(s) / sz.Cols
+				e.renderSecret(s)
+			}
+		case ev, ok := <-e.events:
+			if !ok {
+				e.finishSecret(s)
+				return nil, e.rerr
+			}
+			if done, err := e.handleSecret(s, ev.ev); done {
+				e.finishSecret(s)
+				if err != nil {
+					return nil, err
+				}
+				return encode(s.buf), nil
+			}
+		case <-ctx.Done():
+			e.finishSecret(s)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// handleSecret handles an input event and reports whether the secret is
+// done
+func (e *Editor) handleSecret(s *secret, ev input.Event) (bool, error) {
+	n := len(s.buf)
+	switch ev := ev.(type) {
+	case input.KeyEvent:
+		switch {
+		case ev.Key == input.KeyEnter:
+			return true, nil
+		case ev.Key == input.KeyRune && ev.Mod == input.ModCtrl && ev.Rune == 'c':
+			return true, ErrInterrupted
+		case ev.Key == input.KeyRune && ev.Mod == input.ModCtrl && ev.Rune == 'd':
+			if len(s.buf) == 0 {
+				return true, io.EOF
+			}
+		case ev.Key == input.KeyRune && ev.Mod == input.ModCtrl && ev.Rune == 'u':
+			s.clear()
+		case ev.Key == input.KeyBackspace:
+			if len(s.buf) > 0 {
+				s.buf[len(s.buf)-1] = 0
+				s.buf = s.buf[:len(s.buf)-1]
+			}
+		case ev.Key == input.KeyRune && ev.Mod&(input.ModCtrl|input.ModAlt) == 0:
+			s.add([]rune{ev.Rune})
+		}
+	case input.TextEvent:
+		s.add([]rune(string(ev)))
+	case input.PasteEvent:
+		s.add([]rune(strings.NewReplacer("\r", "", "\n", "").Replace(string(ev))))
+	}
+	// the secret is only appended to or erased
+	if len(s.buf) != n {
+		e.secretChanged(s)
+	}
+	return false, nil
+}
+
+// add appends the runes to the secret, clearing the previous buffer when
+// it grows
+func (s *secret) add(r []rune) {
+	if len(s.buf)+len(r) > cap(s.buf) {
+		buf := make([]rune, len(s.buf), 2*cap(s.buf)+len(r))
+		copy(buf, s.buf)
+		s.clear()
+		s.buf = buf
+	}
+	s.buf = append(s.buf, r...)
+}
+
+func (s *secret) clear() {
+	for i := range s.buf {
+		s.buf[i] = 0
+	}
+	s.buf = s.buf[:0]
+}
+
+func (e *Editor) secretChanged(s *secret) {
+	if s.opts.onChange != nil {
+		s.hint = s.opts.onChange(s.buf)
+	}
+	e.renderSecret(s)
+}
+
+// secretWidth returns the width of the prompt and the masked secret
+func (e *Editor) secretWidth(s *secret) int {
+	w := screen.VisibleWidth(s.opts.prompt)
+	if s.opts.mask != 0 {
+		w += len(s.buf) * screen.RuneWidth(s.opts.mask)
+	}
+	return w
+}
+
+// renderSecret redraws the prompt, the masked secret and the hint, like
+// render
+func (e *Editor) renderSecret(s *secret) {
+	if e.t.Dumb() {
+		// the secret is not masked: the terminal echo is disabled
+		if !s.shown {
+			e.t.Write([]byte(s.opts.prompt))
+			s.shown = true
+		}
+		return
+	}
+	cols := e.cols()
+	var b strings.Builder
+	b.WriteString(ansi.HideCursor)
+	if e.row > 0 {
+		b.WriteString(ansi.CursorUp(e.row))
+	}
+	b.WriteString("\r" + ansi.EraseDown)
+	b.WriteString(s.opts.prompt)
+	if s.opts.mask != 0 {
+		b.WriteString(strings.Repeat(string(s.opts.mask), len(s.buf)))
+	}
+	b.WriteString(s.hint)
+	w := e.secretWidth(s)
+	end := w + screen.VisibleWidth(s.hint)
+	if end > 0 && end%cols == 0 {
+		b.WriteString("\r\n")
+	}
+	row := end / cols
+	crow, ccol := w/cols, w%cols
+	if row > crow {
+		b.WriteString(ansi.CursorUp(row - crow))
+	}
+	b.WriteString("\r")
+	if ccol > 0 {
+		b.WriteString(ansi.CursorForward(ccol))
+	}
+	b.WriteString(ansi.ShowCursor)
+	e.row = crow
+	e.t.Write([]byte(b.String()))
+}
+
+// finishSecret removes the hint and moves the cursor after the secret
+func (e *Editor) finishSecret(s *secret) {
+	if e.t.Dumb() {
+		// the line break is not echoed either
+		e.t.Write([]byte("\r\n"))
+		return
+	}
+	s.hint = ""
+	e.renderSecret(s)
+	e.t.Write([]byte("\r\n"))
+	e.row = 0
+}
+
+// encode returns the UTF-8 encoding of the runes, without an intermediate
+// string which could not be cleared
+func encode(r []rune) []byte {
+	n := 0
+	for _, v := range r {
+		n += utf8.RuneLen(v)
+	}
+	b := make([]byte, n)
+	i := 0
+	for _, v := range r {
+		i += utf8.EncodeRune(b[i:], v)
+	}
+	return b
+}
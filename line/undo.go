@@ -0,0 +1,95 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"time"
+
+	"go.linka.cloud/console/keymap"
+)
+
+// DefaultUndoPause is the default pause after which the typed characters
+// start a new undo group
+const DefaultUndoPause = time.Second
+
+// The undo actions
+const (
+	Undo keymap.Action = "undo"
+	Redo keymap.Action = "redo"
+)
+
+// edit is the kind of change made by an event, the consecutive typing or
+// deleting changes being undone together
+type edit int
+
+const (
+	editOther edit = iota
+	editTyping
+	editDeleting
+	// editUndo is a change made by Undo or Redo, which is not recorded
+	editUndo
+)
+
+// snapshot is a state of the line
+type snapshot struct {
+	buf []rune
+	pos int
+}
+
+func (e *Editor) checkpoint() snapshot {
+	return snapshot{buf: append([]rune(nil), e.buf...), pos: e.pos}
+}
+
+// commit records the line state s, taken before an event, on the undo
+// stack if the event changed the line, unless the change continues the
+// current undo group: the characters typed or deleted in a row, without
+// pausing, a typed word ending the group
+func (e *Editor) commit(s snapshot) {
+	kind := e.edit
+	e.edit = editOther
+	if kind == editUndo || string(s.buf) == string(e.buf) {
+		return
+	}
+	now := time.Now()
+	group := kind != editOther && kind == e.last && s.pos == e.lastPos && now.Sub(e.lastEdit) < e.opts.undoPause
+	if group && kind == editTyping && s.pos > 0 && s.pos < len(e.buf) {
+		group = isWord(e.buf[s.pos]) || !isWord(e.buf[s.pos-1])
+	}
+	e.last, e.lastPos, e.lastEdit = kind, e.pos, now
+	e.redos = e.redos[:0]
+	if !group {
+		e.undos = append(e.undos, s)
+	}
+}
+
+// undo restores the previous state of the line, saving the current one on
+// the other stack
+func (e *Editor) undo(from, to *[]snapshot) {
+	if len(*from) == 0 {
+		return
+	}
+	s := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, e.checkpoint())
+	e.set(s.buf)
+	e.pos = clamp(s.pos, 0, len(e.buf))
+	e.edit, e.last = editUndo, editOther
+}
+
+// resetUndo clears the undo stacks for a new line
+func (e *Editor) resetUndo() {
+	e.undos, e.redos = e.undos[:0], e.redos[:0]
+	e.edit, e.last = editOther, editOther
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package live draws live widgets, e.g. spinners or progress bars, in a
+// region at the bottom of the output redrawn in place, and writes the
+// application logs above it.
+package live
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/screen"
+	"go.linka.cloud/console/term"
+)
+
+// Region is a region at the bottom of the Term output, showing the lines
+// of the live widgets.
+// Nothing else should be written to the Term while the region is shown,
+// but through its Writers.
+// On a dumb Term, the region is not shown until Done writes its lines.
+type Region struct {
+	mu    sync.Mutex
+	t     term.Term
+	lines []string
+	// rows is the number of rows drawn, the cursor being on the last one
+	rows int
+}
+
+// New returns an empty Region drawn on the Term
+func New(t term.Term) *Region {
+	return &Region{t: t}
+}
+
+// Set replaces the lines of the region and redraws it
+func (r *Region) Set(lines ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if equal(lines, r.lines) {
+		return nil
+	}
+	r.lines = append(r.lines[:0], lines...)
+	if r.t.Dumb() {
+		return nil
+	}
+	var b bytes.Buffer
+	r.erase(&b)
+	r.draw(&b)
+	_, err := r.t.Write(b.Bytes())
+	return err
+}
+
+// Clear erases the region
+func (r *Region) Clear() error {
+	return r.Set()
+}
+
+// Done leaves the lines of the region in place, the region being empty
+// after them
+func (r *Region) Done() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b bytes.Buffer
+	if r.t.Dumb() && len(r.lines) > 0 {
+		// the lines were not drawn
+		b.WriteString(strings.Join(r.lines, "\r\n") + "\r\n")
+	} else if r.rows > 0 {
+		b.WriteString("\r\n")
+	}
+	r.lines, r.rows = r.lines[:0], 0
+	if b.Len() == 0 {
+		return nil
+	}
+	_, err := r.t.Write(b.Bytes())
+	return err
+}
+
+// Writer returns a writer writing above the region: the region is erased,
+// the complete lines written and the region redrawn.
+// The partial lines are buffered until their newline is written, so each
+// goroutine should use its own Writer.
+func (r *Region) Writer() *Writer {
+	return &Writer{r: r}
+}
+
+// erase moves the cursor to the first row of the region and erases it
+func (r *Region) erase(b *bytes.Buffer) {
+	if r.rows == 0 {
+		return
+	}
+	b.WriteString("\r")
+	if r.rows > 1 {
+		b.WriteString(ansi.CursorUp(r.rows - 1))
+	}
+	b.WriteString(ansi.EraseDown)
+	r.rows = 0
+}
+
+// draw draws the lines, the cursor being left at the end of the last one
+func (r *Region) draw(b *bytes.Buffer) {
+	cols := r.t.Size().Cols
+	if cols <= 0 {
+		cols = 80
+	}
+	for i, l := range r.lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+		// a full row leaves the cursor on its last column
+		if w := screen.VisibleWidth(l); w > cols {
+			r.rows += (w + cols - 1) / cols
+		} else {
+			r.rows++
+		}
+	}
+}
+
+// write writes the lines above the region
+func (r *Region) write(p []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b bytes.Buffer
+	r.erase(&b)
+	b.WriteString(crlf(string(p)))
+	if !r.t.Dumb() {
+		r.draw(&b)
+	}
+	_, err := r.t.Write(b.Bytes())
+	return err
+}
+
+// crlf terminates the lines with "\r\n", as the Term is in raw mode
+func crlf(s string) string {
+	return strings.Replace(strings.Replace(s, "\r\n", "\n", -1), "\n", "\r\n", -1)
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Writer is a line buffered writer returned by Region.Writer
+type Writer struct {
+	r   *Region
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	i := bytes.LastIndexByte(p, '\n')
+	if i < 0 {
+		w.buf = append(w.buf, p...)
+		return len(p), nil
+	}
+	lines := append(w.buf, p[:i+1]...)
+	if err := w.r.write(lines); err != nil {
+		return 0, err
+	}
+	w.buf = append(w.buf[:0], p[i+1:]...)
+	return len(p), nil
+}
+
+// Flush writes the buffered partial line, terminated by a newline
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.r.write(append(w.buf, '\n'))
+	w.buf = w.buf[:0]
+	return err
+}
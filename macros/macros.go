@@ -0,0 +1,235 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package macros
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"go.linka.cloud/console/input"
+)
+
+var (
+	ErrRecording    = errors.New("a macro is already being recorded")
+	ErrNotRecording = errors.New("no macro is being recorded")
+	ErrNotFound     = errors.New("macro not found")
+)
+
+// Macro is a recorded sequence of input events
+type Macro struct {
+	Name string
+	raw  []byte
+}
+
+// Bytes returns the raw input of the macro
+func (m Macro) Bytes() []byte {
+	return append([]byte(nil), m.raw...)
+}
+
+// Events returns the decoded input events of the macro
+func (m Macro) Events() []input.Event {
+	var evs []input.Event
+	for b := m.raw; len(b) > 0; {
+		ev, n := input.Parse(b)
+		if n == 0 {
+			evs = append(evs, input.UnknownEvent(b))
+			break
+		}
+		evs = append(evs, ev)
+		b = b[n:]
+	}
+	return evs
+}
+
+// Recorder records macros from the input stream and replays them
+type Recorder struct {
+	mu        sync.Mutex
+	macros    map[string][]byte
+	bindings  map[input.KeyEvent]string
+	recording string
+	active    bool
+	buf       []byte
+	queue     []byte
+}
+
+// New returns an empty Recorder
+func New() *Recorder {
+	return &Recorder{
+		macros:   make(map[string][]byte),
+		bindings: make(map[input.KeyEvent]string),
+	}
+}
+
+// Start starts recording the input events to the named macro
+func (r *Recorder) Start(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active {
+		return ErrRecording
+	}
+	r.recording, r.active, r.buf = name, true, nil
+	return nil
+}
+
+// Stop stops the recording and saves the macro, replacing any macro
+// with the same name
+func (r *Recorder) Stop() (Macro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.active {
+		return Macro{}, ErrNotRecording
+	}
+	r.active = false
+	r.macros[r.recording] = r.buf
+	return Macro{Name: r.recording, raw: r.buf}, nil
+}
+
+// Recording returns the name of the macro being recorded, if any
+func (r *Recorder) Recording() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recording, r.active
+}
+
+// Macro returns the named macro
+func (r *Recorder) Macro(name string) (Macro, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.macros[name]
+	return Macro{Name: name, raw: b}, ok
+}
+
+// Set saves a macro, e.g. loaded from a configuration file
+func (r *Recorder) Set(name string, raw []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.macros[name] = append([]byte(nil), raw...)
+}
+
+// Delete removes the named macro
+func (r *Recorder) Delete(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.macros, name)
+}
+
+// Bind makes the key replay the named macro when typed.
+// The key itself is not forwarded.
+func (r *Recorder) Bind(key input.KeyEvent, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bindings[key] = name
+}
+
+// Unbind removes the key binding
+func (r *Recorder) Unbind(key input.KeyEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.bindings, key)
+}
+
+// Play queues the named macro to be replayed in the input stream
+// on the next read
+func (r *Recorder) Play(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.play(name)
+}
+
+func (r *Recorder) play(name string) error {
+	b, ok := r.macros[name]
+	if !ok {
+		return ErrNotFound
+	}
+	r.queue = append(r.queue, b...)
+	return nil
+}
+
+// Middleware returns the input middleware recording and replaying the macros,
+// usable as a term.InputMiddleware
+func (r *Recorder) Middleware() func(next io.Reader) io.Reader {
+	return func(next io.Reader) io.Reader {
+		return &reader{rec: r, r: next}
+	}
+}
+
+type reader struct {
+	rec *Recorder
+	r   io.Reader
+	in  []byte
+	out []byte
+	err error
+	tmp [512]byte
+}
+
+func (m *reader) Read(p []byte) (int, error) {
+	for len(m.out) == 0 {
+		m.rec.mu.Lock()
+		if len(m.rec.queue) > 0 {
+			m.out = append(m.out, m.rec.queue...)
+			m.rec.queue = m.rec.queue[:0]
+		}
+		m.rec.mu.Unlock()
+		if len(m.out) > 0 {
+			break
+		}
+		if m.err != nil {
+			err := m.err
+			m.err = nil
+			return 0, err
+		}
+		n, err := m.r.Read(m.tmp[:])
+		m.in = append(m.in, m.tmp[:n]...)
+		m.err = err
+		m.process(err != nil)
+	}
+	n := copy(p, m.out)
+	m.out = m.out[n:]
+	return n, nil
+}
+
+// process splits the input into events, recording them or replacing
+// the bound keys with their macro
+func (m *reader) process(flush bool) {
+	m.rec.mu.Lock()
+	defer m.rec.mu.Unlock()
+	for len(m.in) > 0 {
+		ev, n := input.Parse(m.in)
+		if n == 0 {
+			// a lone escape at the end of a read is the escape key
+			if !flush && !(len(m.in) == 1 && m.in[0] == 0x1b) {
+				return
+			}
+			n = len(m.in)
+		}
+		raw := m.in[:n]
+		if k, ok := ev.(input.KeyEvent); ok {
+			if name, ok := m.rec.bindings[k]; ok {
+				if m.rec.play(name) == nil {
+					m.out = append(m.out, m.rec.queue...)
+					m.rec.queue = m.rec.queue[:0]
+				}
+				m.in = m.in[n:]
+				continue
+			}
+		}
+		if m.rec.active {
+			m.rec.buf = append(m.rec.buf, raw...)
+		}
+		m.out = append(m.out, raw...)
+		m.in = m.in[n:]
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// frameType identifies the kind of a frame
+type frameType uint8
+
+const (
+	// frameOpen opens a channel
+	frameOpen frameType = iota + 1
+	// frameData carries the channel data
+	frameData
+	// frameResize carries the new size of the channel terminal: rows,
+	// columns, and optionally the width and height in pixels, as big endian
+	// uint16
+	frameResize
+	// frameClose closes a channel
+	frameClose
+	// framePing is a keep alive or a latency probe, answered with a
+	// framePong, on channel 0: the payload is a timestamp the other side
+	// sends back
+	framePing
+	// framePong answers a framePing with its payload
+	framePong
+	// frameWindow grants the other side the right to send more data on the
+	// channel: the payload is the number of bytes as big endian uint32
+	frameWindow
+	// frameSettings advertises the features supported by the sender, on
+	// channel 0: the payload is a byte of flags
+	frameSettings
+	// frameDeflate carries channel data compressed with deflate: the
+	// channel data is a single stream, flushed at the end of each frame
+	frameDeflate
+)
+
+// settingDeflate advertises the deflate compression support
+const settingDeflate = 1 << 0
+
+const (
+	// headerSize is the size of the frame header: the type, the channel
+	// id and the payload length, as big endian uint32
+	headerSize = 9
+	// maxPayload is the maximum size of a frame payload
+	maxPayload = 32 << 10
+)
+
+var errFrameTooLarge = errors.New("mux: frame too large")
+
+type frame struct {
+	typ     frameType
+	id      uint32
+	payload []byte
+}
+
+func writeFrame(w io.Writer, f frame) error {
+	b := make([]byte, headerSize+len(f.payload))
+	b[0] = byte(f.typ)
+	binary.BigEndian.PutUint32(b[1:5], f.id)
+	binary.BigEndian.PutUint32(b[5:9], uint32(len(f.payload)))
+	copy(b[headerSize:], f.payload)
+	_, err := w.Write(b)
+	return err
+}
+
+func readFrame(r io.Reader) (frame, error) {
+	var h [headerSize]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{typ: frameType(h[0]), id: binary.BigEndian.Uint32(h[1:5])}
+	n := binary.BigEndian.Uint32(h[5:9])
+	if n > maxPayload {
+		return frame{}, fmt.Errorf("%w: %d bytes", errFrameTooLarge, n)
+	}
+	if n > 0 {
+		f.payload = make([]byte, n)
+		if _, err := io.ReadFull(r, f.payload); err != nil {
+			return frame{}, err
+		}
+	}
+	return f, nil
+}
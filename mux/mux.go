@@ -0,0 +1,710 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mux multiplexes several terminal sessions over a single
+// connection.
+//
+// Each session is carried by a Channel, opened by one side of the Session
+// and accepted by the other. The protocol is made of frames: a one byte type
+// (open, data, resize, close, window, and ping or pong for the keep
+// alives and latency probes), the channel id and the payload length as big
+// endian uint32, followed by the payload.
+// The channels are flow controlled: each side grants the other a window of
+// data it can send, which it extends as the data is read, so that a slow
+// reader pauses the writer instead of having the data buffered.
+package mux
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"go.linka.cloud/console/term"
+)
+
+var (
+	ErrClosed = errors.New("mux: session closed")
+	// ErrWindowExceeded is returned by the Channel Read when the other side
+	// sent more data than the window it was granted
+	ErrWindowExceeded = errors.New("mux: channel window exceeded")
+)
+
+// errInflateClosed stops the channel decompressor when the channel is closed
+var errInflateClosed = errors.New("mux: decompression closed")
+
+type options struct {
+	keepAlive time.Duration
+	probe     time.Duration
+	window    int
+	// level is the deflate compression level, compression is disabled
+	// if it is 0
+	level int
+}
+
+// Option configures a Session
+type Option func(o *options)
+
+// WithKeepAlive makes the Session send a ping when no frame was sent during
+// the interval, which the other side answers, so that the quiet sessions are
+// not closed by the NAT and firewall idle timeouts
+func WithKeepAlive(interval time.Duration) Option {
+	return func(o *options) {
+		o.keepAlive = interval
+	}
+}
+
+// WithProbe makes the Session send a ping at the given interval to measure
+// the round trip time, reported by RTT
+func WithProbe(interval time.Duration) Option {
+	return func(o *options) {
+		o.probe = interval
+	}
+}
+
+// WithWindow sets the amount of data the other side can send on a channel
+// before it is read, defaults to 256KiB
+func WithWindow(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.window = size
+		}
+	}
+}
+
+// WithCompression enables the deflate compression of the channels data
+// with the given level (see compress/flate), which greatly reduces the
+// bandwidth used by the terminal applications redraws.
+// It is negotiated: the data is only compressed if the other side supports
+// it too, each side compresses the data it sends if it enabled it.
+func WithCompression(level int) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// Session multiplexes channels over a connection
+type Session struct {
+	conn io.ReadWriteCloser
+	opts options
+	// wmu serializes the frames writes and guards lastWrite
+	wmu       sync.Mutex
+	lastWrite time.Time
+
+	mu    sync.Mutex
+	chans map[uint32]*Channel
+	next  uint32
+	// deflate is set when the other side advertised the deflate support
+	deflate bool
+	// epoch is the reference of the pings timestamps
+	epoch time.Time
+	rtt   time.Duration
+	// pong is closed and replaced when a pong is received
+	pong chan struct{}
+
+	accept chan *Channel
+	close  chan struct{}
+	conce  sync.Once
+	err    error
+}
+
+// Client returns the client side Session of the connection
+func Client(conn io.ReadWriteCloser, opts ...Option) *Session {
+	return newSession(conn, 1, opts...)
+}
+
+// Server returns the server side Session of the connection
+func Server(conn io.ReadWriteCloser, opts ...Option) *Session {
+	return newSession(conn, 2, opts...)
+}
+
+// newSession returns a Session allocating the channel ids from first, so
+// that the ids opened by both sides do not collide
+func newSession(conn io.ReadWriteCloser, first uint32, opts ...Option) *Session {
+	o := options{window: 256 << 10}
+	for _, v := range opts {
+		v(&o)
+	}
+	s := &Session{
+		conn:   conn,
+		opts:   o,
+		chans:  make(map[uint32]*Channel),
+		next:   first,
+		accept: make(chan *Channel, 16),
+		close:  make(chan struct{}),
+		epoch:  time.Now(),
+		pong:   make(chan struct{}),
+	}
+	go s.run()
+	go s.write(frame{typ: frameSettings, payload: []byte{settingDeflate}})
+	if o.keepAlive > 0 {
+		go s.ticker(o.keepAlive, true)
+	}
+	if o.probe > 0 {
+		go s.ticker(o.probe, false)
+	}
+	return s
+}
+
+// ticker sends a ping at each interval, only if the Session was idle if
+// idle is true
+func (s *Session) ticker(interval time.Duration, idle bool) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.wmu.Lock()
+			quiet := time.Since(s.lastWrite) >= interval
+			s.wmu.Unlock()
+			if (!idle || quiet) && s.ping() != nil {
+				return
+			}
+		case <-s.close:
+			return
+		}
+	}
+}
+
+// ping sends a ping timestamped with the time elapsed since the epoch,
+// which the other side sends back in its pong
+func (s *Session) ping() error {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(time.Since(s.epoch)))
+	return s.write(frame{typ: framePing, payload: b})
+}
+
+func (s *Session) ponged(p []byte) {
+	if len(p) != 8 {
+		return
+	}
+	rtt := time.Since(s.epoch) - time.Duration(binary.BigEndian.Uint64(p))
+	s.mu.Lock()
+	s.rtt = rtt
+	close(s.pong)
+	s.pong = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// Ping measures the round trip time to the other side
+func (s *Session) Ping(ctx context.Context) (time.Duration, error) {
+	s.mu.Lock()
+	pong := s.pong
+	s.mu.Unlock()
+	if err := s.ping(); err != nil {
+		return 0, err
+	}
+	select {
+	case <-pong:
+		return s.RTT(), nil
+	case <-s.close:
+		return 0, ErrClosed
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// RTT returns the last round trip time measured, by Ping, the keep alives
+// or the probes, 0 if none was
+func (s *Session) RTT() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rtt
+}
+
+// Open opens a new channel
+func (s *Session) Open() (*Channel, error) {
+	s.mu.Lock()
+	select {
+	case <-s.close:
+		s.mu.Unlock()
+		return nil, ErrClosed
+	default:
+	}
+	c := newChannel(s, s.next)
+	s.next += 2
+	s.chans[c.id] = c
+	s.mu.Unlock()
+	if err := s.write(frame{typ: frameOpen, id: c.id}); err != nil {
+		s.remove(c.id)
+		return nil, err
+	}
+	if err := c.grant(s.opts.window); err != nil {
+		s.remove(c.id)
+		return nil, err
+	}
+	return c, nil
+}
+
+// Accept waits for and returns the next channel opened by the other side
+func (s *Session) Accept() (*Channel, error) {
+	select {
+	case c := <-s.accept:
+		return c, nil
+	case <-s.close:
+		return nil, ErrClosed
+	}
+}
+
+// Done returns a channel closed when the Session is closed
+func (s *Session) Done() <-chan struct{} {
+	return s.close
+}
+
+// Err returns the error which closed the Session, nil if it was closed
+// with Close or if it is still running
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close closes the Session, its channels and the connection
+func (s *Session) Close() error {
+	return s.closeWith(nil)
+}
+
+func (s *Session) closeWith(cause error) error {
+	var err error
+	s.conce.Do(func() {
+		s.mu.Lock()
+		s.err = cause
+		chans := s.chans
+		s.chans = make(map[uint32]*Channel)
+		close(s.close)
+		s.mu.Unlock()
+		for _, c := range chans {
+			c.remoteClose()
+		}
+		err = s.conn.Close()
+	})
+	return err
+}
+
+func (s *Session) write(f frame) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	select {
+	case <-s.close:
+		return ErrClosed
+	default:
+	}
+	s.lastWrite = time.Now()
+	return writeFrame(s.conn, f)
+}
+
+func (s *Session) get(id uint32) *Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chans[id]
+}
+
+func (s *Session) remove(id uint32) {
+	s.mu.Lock()
+	delete(s.chans, id)
+	s.mu.Unlock()
+}
+
+// run reads the frames and dispatches them to the channels
+func (s *Session) run() {
+	for {
+		f, err := readFrame(s.conn)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			s.closeWith(err)
+			return
+		}
+		switch f.typ {
+		case frameOpen:
+			s.mu.Lock()
+			if _, ok := s.chans[f.id]; ok {
+				s.mu.Unlock()
+				continue
+			}
+			c := newChannel(s, f.id)
+			s.chans[f.id] = c
+			s.mu.Unlock()
+			go c.grant(s.opts.window)
+			select {
+			case s.accept <- c:
+			case <-s.close:
+				return
+			}
+		case frameData:
+			if c := s.get(f.id); c != nil {
+				c.push(f.payload)
+			}
+		case frameDeflate:
+			if c := s.get(f.id); c != nil {
+				c.inflate(f.payload)
+			}
+		case frameSettings:
+			if len(f.payload) > 0 {
+				s.mu.Lock()
+				s.deflate = f.payload[0]&settingDeflate != 0
+				s.mu.Unlock()
+			}
+		case frameResize:
+			if c := s.get(f.id); c != nil && (len(f.payload) == 4 || len(f.payload) == 8) {
+				sz := term.Size{
+					Rows: int(binary.BigEndian.Uint16(f.payload[0:2])),
+					Cols: int(binary.BigEndian.Uint16(f.payload[2:4])),
+				}
+				if len(f.payload) == 8 {
+					sz.PixelWidth = int(binary.BigEndian.Uint16(f.payload[4:6]))
+					sz.PixelHeight = int(binary.BigEndian.Uint16(f.payload[6:8]))
+				}
+				c.resized(sz)
+			}
+		case frameClose:
+			if c := s.get(f.id); c != nil {
+				s.remove(f.id)
+				c.remoteClose()
+			}
+		case frameWindow:
+			if c := s.get(f.id); c != nil && len(f.payload) == 4 {
+				c.credited(int(binary.BigEndian.Uint32(f.payload)))
+			}
+		case framePing:
+			// answered asynchronously, so that a blocked write does not
+			// stop the frames dispatch
+			go s.write(frame{typ: framePong, payload: f.payload})
+		case framePong:
+			s.ponged(f.payload)
+		}
+	}
+}
+
+// Channel is a session multiplexed over the connection
+type Channel struct {
+	s  *Session
+	id uint32
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	// eof is set when the other side closed the channel
+	eof bool
+	// err is the error which closed the channel, returned by Read instead
+	// of io.EOF
+	err error
+	// closed is set by Close
+	closed bool
+	// credit is the amount of data the other side accepts
+	credit int
+	// consumed is the amount of data read not granted back yet
+	consumed int
+	// window is the amount of data the other side was granted and did
+	// not send yet
+	window int
+
+	// wmu serializes the writes, and guards the compression state
+	wmu sync.Mutex
+	zw  *flate.Writer
+	zb  bytes.Buffer
+	// zin feeds the decompression goroutine, done when zdone is closed,
+	// both guarded by mu
+	zin   *io.PipeWriter
+	zdone chan struct{}
+
+	size term.Size
+	sch  chan term.Size
+}
+
+func newChannel(s *Session, id uint32) *Channel {
+	c := &Channel{s: s, id: id, sch: make(chan term.Size, 1)}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// ID returns the channel id
+func (c *Channel) ID() uint32 {
+	return c.id
+}
+
+// push buffers the data received, closing the channel with
+// ErrWindowExceeded if it does not fit in the window
+func (c *Channel) push(p []byte) error {
+	c.mu.Lock()
+	if len(p) > c.window {
+		c.mu.Unlock()
+		c.fail(ErrWindowExceeded)
+		return ErrWindowExceeded
+	}
+	c.window -= len(p)
+	c.buf.Write(p)
+	c.mu.Unlock()
+	c.cond.Broadcast()
+	return nil
+}
+
+// fail closes the channel on the other side error: Read returns err once
+// the data received before was read
+func (c *Channel) fail(err error) {
+	c.mu.Lock()
+	if c.eof || c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.eof, c.err = true, err
+	zin := c.zin
+	c.mu.Unlock()
+	c.cond.Broadcast()
+	stopInflate(zin)
+	c.s.remove(c.id)
+	// sent asynchronously, as fail is called by the frames dispatch
+	go c.s.write(frame{typ: frameClose, id: c.id})
+}
+
+// grant allows the other side to send n more bytes
+func (c *Channel) grant(n int) error {
+	c.mu.Lock()
+	c.window += n
+	c.mu.Unlock()
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(n))
+	return c.s.write(frame{typ: frameWindow, id: c.id, payload: b})
+}
+
+func (c *Channel) credited(n int) {
+	c.mu.Lock()
+	c.credit += n
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+func (c *Channel) resized(sz term.Size) {
+	c.mu.Lock()
+	c.size = sz
+	c.mu.Unlock()
+	select {
+	case <-c.sch:
+	default:
+	}
+	c.sch <- sz
+}
+
+// inflate decompresses the data with the channel decompressor, started on
+// the first compressed frame. The channel is closed with the decompression
+// error, if any.
+func (c *Channel) inflate(p []byte) {
+	c.mu.Lock()
+	if c.eof || c.closed {
+		c.mu.Unlock()
+		return
+	}
+	if c.zin == nil {
+		r, w := io.Pipe()
+		done := make(chan struct{})
+		c.zin, c.zdone = w, done
+		go func() {
+			defer close(done)
+			zr := flate.NewReader(r)
+			buf := make([]byte, maxPayload)
+			for {
+				n, err := zr.Read(buf)
+				if n > 0 {
+					if perr := c.push(buf[:n]); perr != nil {
+						err = perr
+					}
+				}
+				if err != nil {
+					if err != errInflateClosed {
+						c.fail(err)
+					}
+					r.CloseWithError(err)
+					return
+				}
+			}
+		}()
+	}
+	zin := c.zin
+	c.mu.Unlock()
+	// written unlocked, as the decompression goroutine pushes the data
+	if _, err := zin.Write(p); err != nil {
+		c.fail(err)
+	}
+}
+
+// stopInflate stops the decompression goroutine fed by zin, if any,
+// dropping the data being decompressed
+func stopInflate(zin *io.PipeWriter) {
+	if zin != nil {
+		zin.CloseWithError(errInflateClosed)
+	}
+}
+
+func (c *Channel) remoteClose() {
+	c.mu.Lock()
+	zin, zdone := c.zin, c.zdone
+	c.mu.Unlock()
+	if zin != nil {
+		// deliver the data being decompressed before the end of stream
+		zin.CloseWithError(errInflateClosed)
+		<-zdone
+	}
+	c.mu.Lock()
+	c.eof = true
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+// Read reads the data sent by the other side, it returns io.EOF once the
+// other side closed the channel and all the data was read, or the error
+// which closed the channel, e.g. ErrWindowExceeded
+func (c *Channel) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	for c.buf.Len() == 0 && !c.eof && !c.closed {
+		c.cond.Wait()
+	}
+	if c.closed {
+		c.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	if c.buf.Len() == 0 {
+		err := c.err
+		c.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	n, _ := c.buf.Read(p)
+	c.consumed += n
+	grant := 0
+	if c.consumed >= c.s.opts.window/2 {
+		grant, c.consumed = c.consumed, 0
+	}
+	c.mu.Unlock()
+	if grant > 0 {
+		c.grant(grant)
+	}
+	return n, nil
+}
+
+// Write sends the data to the other side, blocking while its window
+// is full
+func (c *Channel) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	n := 0
+	for len(p) > 0 {
+		c.mu.Lock()
+		for c.credit == 0 && !c.closed && !c.eof {
+			c.cond.Wait()
+		}
+		if c.closed || c.eof {
+			c.mu.Unlock()
+			return n, io.ErrClosedPipe
+		}
+		// leave room for the compression overhead
+		m := len(p)
+		if m > maxPayload-1024 {
+			m = maxPayload - 1024
+		}
+		if m > c.credit {
+			m = c.credit
+		}
+		c.credit -= m
+		c.mu.Unlock()
+		f, err := c.frame(p[:m])
+		if err != nil {
+			return n, err
+		}
+		if err := c.s.write(f); err != nil {
+			return n, err
+		}
+		n += m
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// frame returns the data frame carrying p, compressed if both sides enabled
+// it, wmu must be held
+func (c *Channel) frame(p []byte) (frame, error) {
+	if c.zw == nil {
+		c.s.mu.Lock()
+		deflate := c.s.deflate
+		c.s.mu.Unlock()
+		if c.s.opts.level == 0 || !deflate {
+			return frame{typ: frameData, id: c.id, payload: p}, nil
+		}
+		zw, err := flate.NewWriter(&c.zb, c.s.opts.level)
+		if err != nil {
+			return frame{}, err
+		}
+		c.zw = zw
+	}
+	c.zb.Reset()
+	if _, err := c.zw.Write(p); err != nil {
+		return frame{}, err
+	}
+	if err := c.zw.Flush(); err != nil {
+		return frame{}, err
+	}
+	return frame{typ: frameDeflate, id: c.id, payload: c.zb.Bytes()}, nil
+}
+
+// Resize sends the new terminal size to the other side
+func (c *Channel) Resize(sz term.Size) error {
+	b := make([]byte, 4, 8)
+	binary.BigEndian.PutUint16(b[0:2], uint16(sz.Rows))
+	binary.BigEndian.PutUint16(b[2:4], uint16(sz.Cols))
+	if sz.PixelWidth > 0 || sz.PixelHeight > 0 {
+		b = b[:8]
+		binary.BigEndian.PutUint16(b[4:6], uint16(sz.PixelWidth))
+		binary.BigEndian.PutUint16(b[6:8], uint16(sz.PixelHeight))
+	}
+	return c.s.write(frame{typ: frameResize, id: c.id, payload: b})
+}
+
+// Size returns the last terminal size sent by the other side
+func (c *Channel) Size() term.Size {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// WatchSize returns a channel receiving the sizes sent by the other side.
+// Only the latest size is kept if it is not received.
+func (c *Channel) WatchSize() <-chan term.Size {
+	return c.sch
+}
+
+// Close closes the channel: the other side reads io.EOF
+func (c *Channel) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	zin := c.zin
+	c.mu.Unlock()
+	c.cond.Broadcast()
+	stopInflate(zin)
+	c.s.remove(c.id)
+	err := c.s.write(frame{typ: frameClose, id: c.id})
+	if errors.Is(err, ErrClosed) {
+		return nil
+	}
+	return err
+}
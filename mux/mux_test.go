@@ -0,0 +1,222 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// peer is the raw side of a connection to a Session
+type peer struct {
+	t      *testing.T
+	conn   net.Conn
+	frames chan frame
+}
+
+func newPeer(t *testing.T, opts ...Option) (*Session, *peer) {
+	a, b := net.Pipe()
+	s := Server(a, opts...)
+	p := &peer{t: t, conn: b, frames: make(chan frame, 16)}
+	go func() {
+		defer close(p.frames)
+		for {
+			f, err := readFrame(b)
+			if err != nil {
+				return
+			}
+			p.frames <- f
+		}
+	}()
+	t.Cleanup(func() {
+		s.Close()
+		b.Close()
+	})
+	return s, p
+}
+
+func (p *peer) write(f frame) {
+	if err := writeFrame(p.conn, f); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// wait waits for a frame of the given type on the channel
+func (p *peer) wait(typ frameType, id uint32) frame {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case f, ok := <-p.frames:
+			if !ok {
+				p.t.Fatalf("connection closed waiting for frame %d", typ)
+			}
+			if f.typ == typ && f.id == id {
+				return f
+			}
+		case <-timeout:
+			p.t.Fatalf("timeout waiting for frame %d", typ)
+		}
+	}
+}
+
+func TestChannelProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []frame
+		data   string
+		err    error
+	}{
+		{
+			name:   "window exceeded",
+			frames: []frame{{typ: frameData, payload: []byte("0123456789")}, {typ: frameData, payload: []byte("0123456789")}},
+			data:   "0123456789",
+			err:    ErrWindowExceeded,
+		},
+		{
+			name:   "corrupted compressed data",
+			frames: []frame{{typ: frameDeflate, payload: []byte{0xff, 0xff, 0xff, 0xff}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, p := newPeer(t, WithWindow(16))
+			p.write(frame{typ: frameOpen, id: 1})
+			c, err := s.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.wait(frameWindow, 1)
+			for _, f := range tt.frames {
+				f.id = 1
+				p.write(f)
+			}
+			p.wait(frameClose, 1)
+			b, err := ioutil.ReadAll(c)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if string(b) != tt.data {
+				t.Fatalf("got %q, want %q", b, tt.data)
+			}
+		})
+	}
+}
+
+func TestChannelCompression(t *testing.T) {
+	a, b := net.Pipe()
+	client := Client(a, WithCompression(flate.BestSpeed), WithWindow(64))
+	server := Server(b, WithCompression(flate.BestSpeed), WithWindow(64))
+	defer client.Close()
+	defer server.Close()
+	// let the settings be exchanged
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 1024)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+	go func() {
+		c.Write(data)
+		c.Close()
+	}()
+	sc, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(data) {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+	if _, err := sc.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestChannelInflateStopped(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(t *testing.T, p *peer, c *Channel)
+	}{
+		{
+			name: "close",
+			stop: func(t *testing.T, p *peer, c *Channel) {
+				if err := c.Close(); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "fail",
+			stop: func(t *testing.T, p *peer, c *Channel) {
+				p.write(frame{typ: frameData, id: c.ID(), payload: make([]byte, 32)})
+				p.wait(frameClose, c.ID())
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, p := newPeer(t, WithWindow(16))
+			n := runtime.NumGoroutine()
+			for id := uint32(1); id <= 50; id++ {
+				p.write(frame{typ: frameOpen, id: id})
+				c, err := s.Accept()
+				if err != nil {
+					t.Fatal(err)
+				}
+				p.wait(frameWindow, id)
+				// the stream is flushed but not ended, the decompression
+				// goroutine waits for more data
+				var b bytes.Buffer
+				zw, _ := flate.NewWriter(&b, flate.BestSpeed)
+				zw.Write([]byte("data"))
+				zw.Flush()
+				p.write(frame{typ: frameDeflate, id: id, payload: b.Bytes()})
+				got := make([]byte, 4)
+				if _, err := io.ReadFull(c, got); err != nil {
+					t.Fatal(err)
+				}
+				tt.stop(t, p, c)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for runtime.NumGoroutine() > n {
+				if time.Now().After(deadline) {
+					t.Fatalf("goroutines: %d, want %d", runtime.NumGoroutine(), n)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"context"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/term"
+)
+
+// Attach connects the Term to the channel, usually on the client side:
+// the Term input is sent to the channel, the channel data is written to the
+// Term, and the Term size changes are sent to the other side.
+// It returns when one of them is closed, closing the other.
+func Attach(ctx context.Context, t term.Term, c *Channel) error {
+	if err := c.Resize(t.Size()); err != nil {
+		return err
+	}
+	go func() {
+		for sz := range t.WatchSize() {
+			if c.Resize(sz) != nil {
+				return
+			}
+		}
+	}()
+	return console.Proxy(ctx, t, c)
+}
+
+// Serve connects the channel to the console, usually a pty running a shell
+// on the server side: the channel data is written to the console, the
+// console output is sent to the channel, and the console is resized with the
+// sizes sent by the other side.
+// It returns when one of them is closed, closing the other.
+func Serve(ctx context.Context, c *Channel, con console.Console) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sz := <-c.WatchSize():
+				con.Resize(sz.WinSize())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return console.Proxy(ctx, c, con)
+}
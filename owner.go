@@ -0,0 +1,51 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"fmt"
+	"os"
+)
+
+// Ownership is the owner and permissions of a console device
+type Ownership struct {
+	UID  int
+	GID  int
+	Mode os.FileMode
+}
+
+// PermissionError is returned when the console cannot be changed because
+// the process is not allowed to, e.g. a privilege-separated process using a
+// tty it does not own
+type PermissionError struct {
+	// Op is the failed operation
+	Op string
+	// Name is the console name
+	Name string
+	// Owner is the console ownership, if known
+	Owner *Ownership
+	Err   error
+}
+
+func (e *PermissionError) Error() string {
+	if e.Owner == nil {
+		return fmt.Sprintf("%s %s: %v", e.Op, e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v: the console is owned by %d:%d with mode %v, use SetOwnership to give it to the process", e.Op, e.Name, e.Err, e.Owner.UID, e.Owner.GID, e.Owner.Mode)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,45 @@
+//go:build !windows && !js && !plan9 && !wasip1 && !dragonfly
+// +build !windows,!js,!plan9,!wasip1,!dragonfly
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetOwnership returns the owner and permissions of the console device
+func GetOwnership(f File) (Ownership, error) {
+	var st unix.Stat_t
+	if err := unix.Fstat(int(f.Fd()), &st); err != nil {
+		return Ownership{}, &os.PathError{Op: "stat", Path: f.Name(), Err: err}
+	}
+	return Ownership{UID: int(st.Uid), GID: int(st.Gid), Mode: os.FileMode(st.Mode & 0777)}, nil
+}
+
+// SetOwnership changes the owner and permissions of the console device,
+// e.g. to give it to the user of a privilege-separated shell
+func SetOwnership(f File, o Ownership) error {
+	if err := unix.Fchown(int(f.Fd()), o.UID, o.GID); err != nil {
+		return &os.PathError{Op: "chown", Path: f.Name(), Err: err}
+	}
+	if err := unix.Fchmod(int(f.Fd()), uint32(o.Mode.Perm())); err != nil {
+		return &os.PathError{Op: "chmod", Path: f.Name(), Err: err}
+	}
+	return nil
+}
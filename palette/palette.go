@@ -0,0 +1,143 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package palette
+
+import (
+	"math"
+)
+
+// RGB is a 24 bits color
+type RGB struct {
+	R, G, B uint8
+}
+
+// ANSI is the xterm default values of the 16 ANSI colors.
+// The actual values depend on the terminal theme.
+var ANSI = [16]RGB{
+	{0, 0, 0},
+	{205, 0, 0},
+	{0, 205, 0},
+	{205, 205, 0},
+	{0, 0, 238},
+	{205, 0, 205},
+	{0, 205, 205},
+	{229, 229, 229},
+	{127, 127, 127},
+	{255, 0, 0},
+	{0, 255, 0},
+	{255, 255, 0},
+	{92, 92, 255},
+	{255, 0, 255},
+	{0, 255, 255},
+	{255, 255, 255},
+}
+
+// cube is the values of the 6 levels of the 256 colors palette cube
+var cube = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// Xterm256 returns the RGB value of a 256 colors palette index
+func Xterm256(i uint8) RGB {
+	switch {
+	case i < 16:
+		return ANSI[i]
+	case i < 232:
+		i -= 16
+		return RGB{cube[i/36], cube[i/6%6], cube[i%6]}
+	default:
+		v := 8 + 10*(i-232)
+		return RGB{v, v, v}
+	}
+}
+
+// Nearest256 returns the index of the 256 colors palette closest to c.
+// Only the color cube and the grayscale ramp are considered, the first
+// 16 colors depending on the terminal theme.
+func Nearest256(c RGB) uint8 {
+	ci := 16 + 36*cubeIndex(c.R) + 6*cubeIndex(c.G) + cubeIndex(c.B)
+	avg := (int(c.R) + int(c.G) + int(c.B)) / 3
+	gi := uint8(232)
+	if avg > 238 {
+		gi = 255
+	} else if avg > 8 {
+		gi = uint8(232 + (avg-3)/10)
+	}
+	if distance(c, Xterm256(gi)) < distance(c, Xterm256(ci)) {
+		return gi
+	}
+	return ci
+}
+
+func cubeIndex(v uint8) uint8 {
+	switch {
+	case v < 48:
+		return 0
+	case v < 115:
+		return 1
+	default:
+		return (v - 35) / 40
+	}
+}
+
+// Nearest16 returns the index of the ANSI color closest to c
+func Nearest16(c RGB) uint8 {
+	best, bd := uint8(0), math.MaxFloat64
+	for i, v := range ANSI {
+		if d := distance(c, v); d < bd {
+			best, bd = uint8(i), d
+		}
+	}
+	return best
+}
+
+// Downsample256 returns the ANSI color closest to a 256 colors palette index
+func Downsample256(i uint8) uint8 {
+	if i < 16 {
+		return i
+	}
+	return Nearest16(Xterm256(i))
+}
+
+// distance returns the squared euclidean distance between two colors in the
+// CIE L*a*b* color space, which approximates the perceived difference
+func distance(a, b RGB) float64 {
+	la, aa, ba := lab(a)
+	lb, ab, bb := lab(b)
+	return (la-lb)*(la-lb) + (aa-ab)*(aa-ab) + (ba-bb)*(ba-bb)
+}
+
+func lab(c RGB) (l, a, b float64) {
+	r, g, bl := linear(c.R), linear(c.G), linear(c.B)
+	// D65 white point
+	x := (0.4124*r + 0.3576*g + 0.1805*bl) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*bl
+	z := (0.0193*r + 0.1192*g + 0.9505*bl) / 1.08883
+	fx, fy, fz := labf(x), labf(y), labf(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+func linear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func labf(t float64) float64 {
+	if t > 216.0/24389 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27*t + 16) / 116
+}
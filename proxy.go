@@ -0,0 +1,93 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type proxyOptions struct {
+	keepAlive time.Duration
+	data      []byte
+}
+
+// ProxyOption configures Proxy
+type ProxyOption func(o *proxyOptions)
+
+// WithKeepAlive makes Proxy write data to b at the given interval, so that
+// the quiet sessions are not closed by the NAT and firewall idle timeouts.
+// The data defaults to a NUL byte, which the terminals ignore, even in the
+// middle of an escape sequence.
+func WithKeepAlive(interval time.Duration, data []byte) ProxyOption {
+	return func(o *proxyOptions) {
+		o.keepAlive = interval
+		o.data = data
+	}
+}
+
+// Proxy copies the data between a and b in both directions, e.g. between a
+// pty and a network connection, until one of them reaches the end of its
+// input, a copy fails, or ctx is cancelled. It then closes both a and b if
+// they implement io.Closer, and returns the copy error or the context error.
+// On linux, the data is moved with splice when both ends are file
+// descriptors, saving the copies to user space.
+func Proxy(ctx context.Context, a, b io.ReadWriter, opts ...ProxyOption) error {
+	var o proxyOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.data == nil {
+		o.data = []byte{0}
+	}
+	var tick <-chan time.Time
+	if o.keepAlive > 0 {
+		t := time.NewTicker(o.keepAlive)
+		defer t.Stop()
+		tick = t.C
+	}
+	errs := make(chan error, 2)
+	go func() {
+		_, err := copyStream(a, b)
+		errs <- err
+	}()
+	go func() {
+		_, err := copyStream(b, a)
+		errs <- err
+	}()
+	var err error
+loop:
+	for {
+		select {
+		case err = <-errs:
+			break loop
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		case <-tick:
+			if _, err = b.Write(o.data); err != nil {
+				break loop
+			}
+		}
+	}
+	// closing the ends releases the other copy
+	for _, v := range []io.ReadWriter{a, b} {
+		if c, ok := v.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+//go:build linux
+// +build linux
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"io"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// spliceMax is the maximum number of bytes moved by a splice call
+const spliceMax = 1 << 20
+
+func copyStream(dst io.Writer, src io.Reader) (int64, error) {
+	if n, ok, err := splice(dst, src); ok {
+		return n, err
+	}
+	return io.Copy(dst, src)
+}
+
+// splice moves the data from src to dst through a pipe if both are file
+// descriptors. It reports false if splice cannot be used, before anything
+// was copied.
+func splice(dst io.Writer, src io.Reader) (written int64, ok bool, err error) {
+	sc, ok1 := src.(syscall.Conn)
+	dc, ok2 := dst.(syscall.Conn)
+	if !ok1 || !ok2 {
+		return 0, false, nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, false, nil
+	}
+	wc, err := dc.SyscallConn()
+	if err != nil {
+		return 0, false, nil
+	}
+	var p [2]int
+	if err := unix.Pipe2(p[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		return 0, false, nil
+	}
+	defer unix.Close(p[0])
+	defer unix.Close(p[1])
+	// copyOut is set if dst does not support splice
+	copyOut := false
+	var buf []byte
+	for {
+		var n int64
+		var serr error
+		if err := rc.Read(func(fd uintptr) bool {
+			m, err := unix.Splice(int(fd), nil, p[1], nil, spliceMax, unix.SPLICE_F_MOVE|unix.SPLICE_F_NONBLOCK)
+			n, serr = int64(m), err
+			return serr != unix.EAGAIN
+		}); err != nil {
+			return written, true, err
+		}
+		if serr != nil {
+			// e.g. EINVAL if the file does not support splice
+			if written == 0 && (serr == unix.EINVAL || serr == unix.ENOSYS) {
+				return 0, false, nil
+			}
+			return written, true, serr
+		}
+		if n == 0 {
+			return written, true, nil
+		}
+		for n > 0 && !copyOut {
+			var m int64
+			if err := wc.Write(func(fd uintptr) bool {
+				v, err := unix.Splice(p[0], nil, int(fd), nil, int(n), unix.SPLICE_F_MOVE|unix.SPLICE_F_NONBLOCK)
+				m, serr = int64(v), err
+				return serr != unix.EAGAIN
+			}); err != nil {
+				return written, true, err
+			}
+			if serr == unix.EINVAL {
+				// dst does not support splice: copy the pipe content instead
+				copyOut = true
+				break
+			}
+			if serr != nil {
+				return written, true, serr
+			}
+			n -= m
+			written += m
+		}
+		for n > 0 && copyOut {
+			if buf == nil {
+				buf = make([]byte, 32*1024)
+			}
+			v, err := unix.Read(p[0], buf)
+			if err != nil {
+				return written, true, err
+			}
+			if _, err := dst.Write(buf[:v]); err != nil {
+				return written, true, err
+			}
+			n -= int64(v)
+			written += int64(v)
+		}
+	}
+}
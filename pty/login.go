@@ -0,0 +1,54 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pty
+
+const (
+	// DefaultUtmp is the default path of the utmp file
+	DefaultUtmp = "/var/run/utmp"
+	// DefaultWtmp is the default path of the wtmp file
+	DefaultWtmp = "/var/log/wtmp"
+)
+
+type loginOptions struct {
+	host string
+	utmp string
+	wtmp string
+}
+
+// LoginOption configures Login
+type LoginOption func(o *loginOptions)
+
+// WithHost sets the remote host the user logged in from
+func WithHost(host string) LoginOption {
+	return func(o *loginOptions) {
+		o.host = host
+	}
+}
+
+// WithUtmp sets the path of the utmp file, an empty path disables the
+// registration in utmp
+func WithUtmp(path string) LoginOption {
+	return func(o *loginOptions) {
+		o.utmp = path
+	}
+}
+
+// WithWtmp sets the path of the wtmp file, an empty path disables the
+// registration in wtmp
+func WithWtmp(path string) LoginOption {
+	return func(o *loginOptions) {
+		o.wtmp = path
+	}
+}
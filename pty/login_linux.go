@@ -0,0 +1,168 @@
+//go:build linux
+// +build linux
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pty
+
+import (
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	utDeadProcess = 8
+	utUserProcess = 7
+)
+
+// utmp is the glibc struct utmp
+type utmp struct {
+	typ     int16
+	_       [2]byte
+	pid     int32
+	line    [32]byte
+	id      [4]byte
+	user    [32]byte
+	host    [256]byte
+	exit    [2]int16
+	session int32
+	sec     int32
+	usec    int32
+	addr    [4]int32
+	_       [20]byte
+}
+
+const utmpSize = int(unsafe.Sizeof(utmp{}))
+
+func (u *utmp) bytes() []byte {
+	return (*[utmpSize]byte)(unsafe.Pointer(u))[:]
+}
+
+// Login registers the session of the user, run by the process pid, on the
+// tty, e.g. the PTY slave, in utmp and wtmp, as login(1) does, so that it is
+// listed by who(1) and last(1).
+// It returns logout, which marks the session as ended, to be called when
+// the process exited.
+func Login(tty *os.File, username string, pid int, opts ...LoginOption) (logout func() error, err error) {
+	o := loginOptions{utmp: DefaultUtmp, wtmp: DefaultWtmp}
+	for _, v := range opts {
+		v(&o)
+	}
+	line := strings.TrimPrefix(tty.Name(), "/dev/")
+	var u utmp
+	u.typ = utUserProcess
+	u.pid = int32(pid)
+	u.session = int32(pid)
+	copy(u.line[:], line)
+	if len(line) > len(u.id) {
+		line = line[len(line)-len(u.id):]
+	}
+	copy(u.id[:], line)
+	copy(u.user[:], username)
+	copy(u.host[:], o.host)
+	u.stamp()
+	if err := o.write(&u); err != nil {
+		return nil, err
+	}
+	return func() error {
+		d := u
+		d.typ = utDeadProcess
+		d.user = [32]byte{}
+		d.host = [256]byte{}
+		d.stamp()
+		return o.write(&d)
+	}, nil
+}
+
+func (u *utmp) stamp() {
+	now := time.Now()
+	u.sec, u.usec = int32(now.Unix()), int32(now.Nanosecond()/1000)
+}
+
+// write updates the entry of the tty in utmp and appends the record to wtmp
+func (o loginOptions) write(u *utmp) error {
+	if o.utmp != "" {
+		if err := update(o.utmp, u); err != nil {
+			return err
+		}
+	}
+	if o.wtmp != "" {
+		f, err := os.OpenFile(o.wtmp, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := f.Write(u.bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update replaces the utmp entry with the same id, or appends u
+func update(path string, u *utmp) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		return err
+	}
+	defer unix.Flock(int(f.Fd()), unix.LOCK_UN)
+	buf := make([]byte, utmpSize)
+	var off int64
+	for {
+		if _, err := io.ReadFull(f, buf); err != nil {
+			break
+		}
+		e := (*utmp)(unsafe.Pointer(&buf[0]))
+		if e.id == u.id && (e.typ == utUserProcess || e.typ == utDeadProcess || e.line == u.line) {
+			break
+		}
+		off += int64(utmpSize)
+	}
+	_, err = f.WriteAt(u.bytes(), off)
+	return err
+}
+
+// Own gives the tty to the user, as login(1) does: it sets its owner to the
+// user, its group to tty, if it exists, and its mode to 0620
+func Own(tty *os.File, u *user.User) error {
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return err
+	}
+	if g, err := user.LookupGroup("tty"); err == nil {
+		if id, err := strconv.Atoi(g.Gid); err == nil {
+			gid = id
+		}
+	}
+	if err := tty.Chown(uid, gid); err != nil {
+		return err
+	}
+	return tty.Chmod(0620)
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+// +build !linux
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pty
+
+import (
+	"os"
+	"os/user"
+
+	"go.linka.cloud/console"
+)
+
+// Login returns console.ErrUnsupported on this platform
+func Login(tty *os.File, username string, pid int, opts ...LoginOption) (logout func() error, err error) {
+	return nil, console.ErrUnsupported
+}
+
+// Own returns console.ErrUnsupported on this platform
+func Own(tty *os.File, u *user.User) error {
+	return console.ErrUnsupported
+}
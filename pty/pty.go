@@ -0,0 +1,140 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pty runs processes attached to a pseudo terminal.
+//
+// Start runs a command with a new PTY as its controlling terminal and
+// returns the master side as a console.Console, which can be used with
+// console.Proxy and resized when the local terminal is.
+//
+// Once the command and its children closed the slave side, the master reads
+// return io.EOF, or EIO with WithEIO. Closing the master hangs up the
+// command: its foreground process group receives SIGHUP, see WithHangup.
+// It is supported on Linux and macOS.
+package pty
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/caps"
+)
+
+type options struct {
+	size    console.WinSize
+	env     bool
+	profile caps.Profile
+	// noSession keeps the command in the caller session
+	noSession bool
+	eio       bool
+	hangup    os.Signal
+}
+
+// Option configures Start
+type Option func(o *options)
+
+// WithSize sets the initial size of the PTY
+func WithSize(size console.WinSize) Option {
+	return func(o *options) {
+		o.size = size
+	}
+}
+
+// WithoutSession keeps the command in the session of the caller: the PTY is
+// then not its controlling terminal, so it does not receive the signals
+// generated by the terminal, e.g. SIGINT on Ctrl-C, nor SIGHUP when it is
+// closed
+func WithoutSession() Option {
+	return func(o *options) {
+		o.noSession = true
+	}
+}
+
+// WithEIO makes the PTY master return the EIO error its reads fail with
+// once the command and its children closed the slave, instead of io.EOF
+func WithEIO() Option {
+	return func(o *options) {
+		o.eio = true
+	}
+}
+
+// WithHangup sets the signal sent to the foreground process group of the
+// PTY when the master is closed, defaults to SIGHUP. With nil, only the
+// session leader, if any, is hung up by the system.
+func WithHangup(sig os.Signal) Option {
+	return func(o *options) {
+		o.hangup = sig
+	}
+}
+
+// WithEnv makes Start set the terminal environment of the command from the
+// capability profile of the terminal it will be displayed on, and the PTY
+// size, see Environ
+func WithEnv(p caps.Profile) Option {
+	return func(o *options) {
+		o.env = true
+		o.profile = p
+	}
+}
+
+// Environ returns the base environment, os.Environ() if nil, with the
+// variables describing the terminal set from the capability profile and
+// the size:
+//   - TERM: the profile terminal type, or xterm-256color or xterm depending
+//     on the color support
+//   - COLORTERM: truecolor if supported, removed otherwise
+//   - LINES and COLUMNS: the size, if known
+//   - LANG, LC_ALL and LC_CTYPE: passed through from the current process if
+//     not set, so that the command uses the same encoding
+func Environ(base []string, p caps.Profile, size console.WinSize) []string {
+	if base == nil {
+		base = os.Environ()
+	}
+	env := make([]string, 0, len(base)+6)
+	set := make(map[string]bool)
+	for _, kv := range base {
+		k := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			k = kv[:i]
+		}
+		switch k {
+		case "TERM", "COLORTERM", "LINES", "COLUMNS":
+			continue
+		}
+		set[k] = true
+		env = append(env, kv)
+	}
+	t := p.Term
+	if t == "" || t == "dumb" && p.Color > caps.NoColor {
+		t = "xterm"
+		if p.Color >= caps.ANSI256 {
+			t = "xterm-256color"
+		}
+	}
+	env = append(env, "TERM="+t)
+	if p.Color == caps.TrueColor {
+		env = append(env, "COLORTERM=truecolor")
+	}
+	if size.Width > 0 && size.Height > 0 {
+		env = append(env, "LINES="+strconv.Itoa(int(size.Height)), "COLUMNS="+strconv.Itoa(int(size.Width)))
+	}
+	for _, k := range []string{"LANG", "LC_ALL", "LC_CTYPE"} {
+		if v, ok := os.LookupEnv(k); ok && !set[k] {
+			env = append(env, k+"="+v)
+		}
+	}
+	return env
+}
@@ -0,0 +1,268 @@
+//go:build linux || darwin
+// +build linux darwin
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pty
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.linka.cloud/console"
+)
+
+// output collects the output of a PTY master
+type output struct {
+	mu   sync.Mutex
+	b    bytes.Buffer
+	done chan struct{}
+}
+
+func collect(r io.Reader) *output {
+	o := &output{done: make(chan struct{})}
+	go func() {
+		defer close(o.done)
+		b := make([]byte, 1024)
+		for {
+			n, err := r.Read(b)
+			o.mu.Lock()
+			o.b.Write(b[:n])
+			o.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return o
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.b.String()
+}
+
+// wait waits for the output to contain s
+func (o *output) wait(t *testing.T, s string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if bytes.Contains([]byte(o.String()), []byte(s)) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("output %q does not contain %q", o.String(), s)
+}
+
+// wait waits for the command to exit
+func wait(t *testing.T, cmd *exec.Cmd) error {
+	t.Helper()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- cmd.Wait()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("command did not exit")
+		return nil
+	}
+}
+
+func shell(t *testing.T, script string) *exec.Cmd {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell")
+	}
+	if script == "" {
+		return exec.Command(sh)
+	}
+	return exec.Command(sh, "-c", script)
+}
+
+func TestStartShell(t *testing.T) {
+	cmd := shell(t, "")
+	m, err := Start(cmd, WithSize(console.WinSize{Height: 24, Width: 80}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	out := collect(m)
+	if _, err := io.WriteString(m, "stty size; tty; exit 3\n"); err != nil {
+		t.Fatal(err)
+	}
+	err = wait(t, cmd)
+	if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 3 {
+		t.Fatalf("exit: %v", err)
+	}
+	<-out.done
+	out.wait(t, "24 80")
+	if bytes.Contains([]byte(out.String()), []byte("not a tty")) {
+		t.Fatalf("the PTY is not the shell terminal: %q", out.String())
+	}
+}
+
+func TestStartResize(t *testing.T) {
+	cmd := shell(t, "")
+	m, err := Start(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	out := collect(m)
+	if err := m.Resize(console.WinSize{Height: 10, Width: 33}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(m, "stty size; exit\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(t, cmd); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "10 33")
+}
+
+func TestStartPixelSize(t *testing.T) {
+	cmd := shell(t, "exit")
+	want := console.WinSize{Height: 24, Width: 80, PixelWidth: 640, PixelHeight: 384}
+	m, err := Start(cmd, WithSize(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if err := wait(t, cmd); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("size %+v, want %+v", got, want)
+	}
+}
+
+func TestCloseHangup(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "session"},
+		{name: "without session", opts: []Option{WithoutSession()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := filepath.Join(t.TempDir(), "signal")
+			cmd := shell(t, "trap 'echo HUP > "+f+"; exit 1' HUP; echo ready; while :; do sleep 0.05; done")
+			m, err := Start(cmd, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := collect(m)
+			out.wait(t, "ready")
+			if err := m.Close(); err != nil {
+				t.Fatal(err)
+			}
+			wait(t, cmd)
+			b, err := ioutil.ReadFile(f)
+			if err != nil || string(b) != "HUP\n" {
+				t.Fatalf("not hung up: %q, %v", b, err)
+			}
+		})
+	}
+}
+
+func TestCloseAfterWait(t *testing.T) {
+	cmd := shell(t, "exit 0")
+	m, err := Start(cmd, WithoutSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := collect(m)
+	if err := wait(t, cmd); err != nil {
+		t.Fatal(err)
+	}
+	<-out.done
+	// the process was reaped, its pid may belong to another process
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
+
+func TestStartInheritedFiles(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cmd := shell(t, fmt.Sprintf("if [ -e /dev/fd/%[1]d ]; then echo fd %[1]d inherited; fi; if [ -e /dev/fd/3 ]; then echo extra inherited; fi", f.Fd()))
+	cmd.ExtraFiles = []*os.File{f}
+	m, err := Start(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	out := collect(m)
+	if err := wait(t, cmd); err != nil {
+		t.Fatal(err)
+	}
+	<-out.done
+	if s := out.String(); s != "extra inherited\r\n" {
+		t.Fatalf("output %q", s)
+	}
+}
+
+func TestEchoDisabled(t *testing.T) {
+	cmd := shell(t, "stty -echo; echo off; read x; stty echo; echo on; read y")
+	m, err := Start(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	e, ok := m.(console.EchoReporter)
+	if !ok {
+		t.Fatal("the PTY master does not report the echo state")
+	}
+	out := collect(m)
+	out.wait(t, "off")
+	if off, err := e.EchoDisabled(); err != nil || !off {
+		t.Fatalf("echo disabled: %v, %v, want true", off, err)
+	}
+	io.WriteString(m, "secret\n")
+	out.wait(t, "on")
+	if off, err := e.EchoDisabled(); err != nil || off {
+		t.Fatalf("echo disabled: %v, %v, want false", off, err)
+	}
+	io.WriteString(m, "plain\n")
+	if err := wait(t, cmd); err != nil {
+		t.Fatal(err)
+	}
+}
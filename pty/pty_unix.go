@@ -0,0 +1,174 @@
+//go:build linux || darwin
+// +build linux darwin
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pty
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"go.linka.cloud/console"
+)
+
+// Open returns a new PTY master and slave
+func Open() (master, slave *os.File, err error) {
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	master = os.NewFile(uintptr(fd), "/dev/ptmx")
+	name, err := unlock(fd)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	sfd, err := unix.Open(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, os.NewFile(uintptr(sfd), name), nil
+}
+
+// Start starts cmd with a new PTY as its standard streams, unless they are
+// already set, and, unless WithoutSession is used, as its controlling
+// terminal in a new session, with the command as the foreground process
+// group, as if it was started by a login shell.
+// The command only inherits its standard streams and cmd.ExtraFiles, as the
+// files are opened close-on-exec by Go.
+// It returns the PTY master, closed by the caller once the command exited.
+func Start(cmd *exec.Cmd, opts ...Option) (console.Console, error) {
+	o := options{hangup: syscall.SIGHUP}
+	for _, v := range opts {
+		v(&o)
+	}
+	m, s, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if o.size.Width > 0 && o.size.Height > 0 {
+		ws := &unix.Winsize{
+			Row:    o.size.Height,
+			Col:    o.size.Width,
+			Xpixel: o.size.PixelWidth,
+			Ypixel: o.size.PixelHeight,
+		}
+		if err := unix.IoctlSetWinsize(int(m.Fd()), unix.TIOCSWINSZ, ws); err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+	if o.env {
+		cmd.Env = Environ(cmd.Env, o.profile, o.size)
+	}
+	if cmd.Stdin == nil {
+		cmd.Stdin = s
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = s
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = s
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if !o.noSession {
+		cmd.SysProcAttr.Setsid = true
+		cmd.SysProcAttr.Setctty = true
+		cmd.SysProcAttr.Ctty = ctty(cmd, s)
+	}
+	if err := cmd.Start(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	c, err := console.FromFile(m)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	return &master{Console: c, proc: cmd.Process, eio: o.eio, hangup: o.hangup}, nil
+}
+
+// master is the PTY master of a command
+type master struct {
+	console.Console
+	// proc is the command process, which os/exec does not signal once it
+	// was waited for, as its pid may have been reused
+	proc   *os.Process
+	eio    bool
+	hangup os.Signal
+	once   sync.Once
+}
+
+func (m *master) Read(p []byte) (int, error) {
+	n, err := m.Console.Read(p)
+	if !m.eio && errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// EchoDisabled reports whether the command disabled the echo of the PTY,
+// e.g. to read a password
+func (m *master) EchoDisabled() (bool, error) {
+	if e, ok := m.Console.(console.EchoReporter); ok {
+		return e.EchoDisabled()
+	}
+	return false, console.ErrUnsupported
+}
+
+func (m *master) Close() error {
+	m.once.Do(func() {
+		s, ok := m.hangup.(syscall.Signal)
+		if !ok {
+			return
+		}
+		// without a session, the PTY has no process group and the command
+		// is not a group leader: only the command is signaled, if it was
+		// not waited for
+		pgid, err := unix.IoctlGetInt(int(m.Fd()), unix.TIOCGPGRP)
+		if err != nil || pgid <= 0 || unix.Kill(-pgid, s) != nil {
+			m.proc.Signal(s)
+		}
+	})
+	return m.Console.Close()
+}
+
+// ctty returns the descriptor of the PTY slave in the child process,
+// passing it as an extra file if it is not one of the standard streams
+func ctty(cmd *exec.Cmd, s *os.File) int {
+	for i, v := range []interface{}{cmd.Stdin, cmd.Stdout, cmd.Stderr} {
+		if f, ok := v.(*os.File); ok && f == s {
+			return i
+		}
+	}
+	for i, f := range cmd.ExtraFiles {
+		if f == s {
+			return 3 + i
+		}
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, s)
+	return 3 + len(cmd.ExtraFiles) - 1
+}
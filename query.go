@@ -0,0 +1,74 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrTimeout = errors.New("timeout waiting for console response")
+
+// inputWaiter is implemented by the consoles which are not backed by a
+// file descriptor to wait for input
+type inputWaiter interface {
+	waitInput(timeout time.Duration) (bool, error)
+}
+
+// Query writes the request to the console and reads its response until
+// complete returns true or the timeout expires.
+// The console must be in raw mode for the response not to be echoed
+// or line buffered.
+// The response is read one byte at a time so that user input following it
+// is left untouched.
+func Query(c Console, request string, timeout time.Duration, complete func(resp []byte) bool) ([]byte, error) {
+	if _, err := io.WriteString(c, request); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	var (
+		out []byte
+		b   [1]byte
+	)
+	for !complete(out) {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return out, ErrTimeout
+		}
+		ok, err := WaitInput(c, left)
+		if err != nil {
+			return out, err
+		}
+		if !ok {
+			return out, ErrTimeout
+		}
+		n, err := c.Read(b[:])
+		out = append(out, b[:n]...)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// WaitInput waits for the console input to be readable and reports whether
+// it is before the timeout
+func WaitInput(c Console, timeout time.Duration) (bool, error) {
+	if w, ok := c.(inputWaiter); ok {
+		return w.waitInput(timeout)
+	}
+	return waitInput(c.Fd(), timeout)
+}
@@ -0,0 +1,42 @@
+//go:build !windows && !js && !plan9 && !wasip1 && !dragonfly
+// +build !windows,!js,!plan9,!wasip1,!dragonfly
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitInput waits for fd to be readable.
+// select is used as poll does not support ttys on darwin.
+func waitInput(fd uintptr, timeout time.Duration) (bool, error) {
+	for {
+		var set unix.FdSet
+		set.Set(int(fd))
+		tv := unix.NsecToTimeval(timeout.Nanoseconds())
+		n, err := unix.Select(int(fd)+1, &set, nil, nil, &tv)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return n > 0, nil
+	}
+}
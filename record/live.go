@@ -0,0 +1,342 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.linka.cloud/console/term"
+)
+
+// DefaultBacklog is the default size of the recent output sent to the
+// viewers joining a Live session
+const DefaultBacklog = 64 << 10
+
+// viewerQueue is the number of frames queued for a viewer before it is
+// disconnected as too slow
+const viewerQueue = 256
+
+// Assets locates the xterm.js files loaded by the viewer page
+type Assets struct {
+	// Base is the URL of the xterm.js package, serving lib/xterm.js and
+	// css/xterm.css, e.g. a path served next to the Live handler
+	Base string
+	// ScriptIntegrity and StyleIntegrity are the subresource integrity
+	// hashes of lib/xterm.js and css/xterm.css, e.g. sha384-...,
+	// checked by the browsers when set
+	ScriptIntegrity string
+	StyleIntegrity  string
+}
+
+// DefaultAssets loads xterm.js from the jsDelivr CDN
+var DefaultAssets = Assets{Base: "https://cdn.jsdelivr.net/npm/xterm@5.3.0"}
+
+type liveOptions struct {
+	backlog int
+	assets  Assets
+}
+
+// LiveOption configures a Live session
+type LiveOption func(o *liveOptions)
+
+// WithBacklog sets the size of the recent output sent to the viewers when
+// they join, defaults to DefaultBacklog
+func WithBacklog(n int) LiveOption {
+	return func(o *liveOptions) {
+		o.backlog = n
+	}
+}
+
+// WithAssets sets the xterm.js files loaded by the viewer page,
+// defaults to DefaultAssets
+func WithAssets(a Assets) LiveOption {
+	return func(o *liveOptions) {
+		o.assets = a
+	}
+}
+
+// Live is a Writer streaming the session as it happens to the viewers
+// connected to its HTTP handler.
+//
+// The stream is served as server sent events, each one holding an
+// asciicast v2 line: the header with the current size first, then the
+// output and resize events, e.g. [1.5, "o", "$ ls\r\n"], whose data can be
+// written as is to an xterm.js terminal. The browsers are served a page
+// displaying the session with xterm.js, loaded from the Assets.
+// The viewers joining late receive the recent output first, and the ones
+// too slow to keep up are disconnected. The input is never streamed.
+type Live struct {
+	mu      sync.Mutex
+	o       liveOptions
+	start   time.Time
+	size    term.Size
+	backlog []byte
+	// partial is an incomplete UTF-8 character at the end of the output
+	partial []byte
+	viewers map[chan []byte]struct{}
+	closed  bool
+	page    []byte
+}
+
+// NewLive returns a Live session described by h
+func NewLive(h Header, opts ...LiveOption) *Live {
+	o := liveOptions{backlog: DefaultBacklog, assets: DefaultAssets}
+	for _, v := range opts {
+		v(&o)
+	}
+	if h.Time.IsZero() {
+		h.Time = time.Now()
+	}
+	var page bytes.Buffer
+	o.assets.Base = strings.TrimSuffix(o.assets.Base, "/")
+	livePage.Execute(&page, o.assets)
+	return &Live{o: o, start: h.Time, size: h.Size, viewers: make(map[chan []byte]struct{}), page: page.Bytes()}
+}
+
+func (l *Live) WriteEvent(e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	var frame []byte
+	switch e.Type {
+	case Output:
+		data := append(l.partial, e.Data...)
+		n := complete(data)
+		l.partial = append([]byte(nil), data[n:]...)
+		if n == 0 {
+			return nil
+		}
+		l.keep(data[:n])
+		frame = l.frame(e.Time, "o", string(data[:n]))
+	case Resize:
+		l.size = e.Size
+		frame = l.frame(e.Time, "r", fmt.Sprintf("%dx%d", e.Size.Cols, e.Size.Rows))
+	default:
+		return nil
+	}
+	for v := range l.viewers {
+		select {
+		case v <- frame:
+		default:
+			// too slow: disconnected
+			delete(l.viewers, v)
+			close(v)
+		}
+	}
+	return nil
+}
+
+// complete returns the length of b without its incomplete trailing UTF-8
+// character, if any
+func complete(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+// keep appends the output to the backlog, dropping the oldest lines beyond
+// its size
+func (l *Live) keep(b []byte) {
+	if l.o.backlog <= 0 {
+		return
+	}
+	l.backlog = append(l.backlog, b...)
+	if len(l.backlog) <= 2*l.o.backlog {
+		return
+	}
+	cut := len(l.backlog) - l.o.backlog
+	if i := bytes.IndexByte(l.backlog[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	for cut < len(l.backlog) && !utf8.RuneStart(l.backlog[cut]) {
+		cut++
+	}
+	l.backlog = append(l.backlog[:0], l.backlog[cut:]...)
+}
+
+// frame returns the server sent event holding the asciicast event
+func (l *Live) frame(at time.Duration, typ, data string) []byte {
+	b, _ := json.Marshal([]interface{}{at.Seconds(), typ, data})
+	return sse(b)
+}
+
+func sse(data []byte) []byte {
+	return []byte("data: " + string(data) + "\n\n")
+}
+
+// Close ends the viewers streams once they received the pending events
+func (l *Live) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	for v := range l.viewers {
+		delete(l.viewers, v)
+		close(v)
+	}
+	return nil
+}
+
+// join registers a new viewer and returns its frames, starting with the
+// header and the backlog
+func (l *Live) join() (chan []byte, [][]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, nil, false
+	}
+	h, _ := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     l.size.Cols,
+		"height":    l.size.Rows,
+		"timestamp": l.start.Unix(),
+	})
+	first := [][]byte{sse(h)}
+	if len(l.backlog) > 0 {
+		at := time.Since(l.start)
+		first = append(first, l.frame(at, "o", string(l.backlog)))
+	}
+	v := make(chan []byte, viewerQueue)
+	l.viewers[v] = struct{}{}
+	return v, first, true
+}
+
+func (l *Live) leave(v chan []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.viewers[v]; ok {
+		delete(l.viewers, v)
+		close(v)
+	}
+}
+
+// ServeHTTP streams the session to the viewer, or serves the viewer page
+// to the browsers
+func (l *Live) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(l.page)
+		return
+	}
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	v, first, ok := l.join()
+	if !ok {
+		http.Error(w, "session ended", http.StatusGone)
+		return
+	}
+	defer l.leave(v)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, b := range first {
+		if _, err := w.Write(b); err != nil {
+			return
+		}
+	}
+	f.Flush()
+	for {
+		select {
+		case b, ok := <-v:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(b); err != nil {
+				return
+			}
+			f.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+var livePage = template.Must(template.New("live").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Live session</title>
+<link rel="stylesheet" href="{{.Base}}/css/xterm.css"{{with .StyleIntegrity}} integrity="{{.}}"{{end}} crossorigin="anonymous">
+<script src="{{.Base}}/lib/xterm.js"{{with .ScriptIntegrity}} integrity="{{.}}"{{end}} crossorigin="anonymous"></script>
+</head>
+<body style="margin:0;background:#000">
+<div id="terminal"></div>
+<script>
+const t = new Terminal({disableStdin: true});
+t.open(document.getElementById("terminal"));
+const es = new EventSource(location.href);
+es.onmessage = (e) => {
+  const f = JSON.parse(e.data);
+  if (!Array.isArray(f)) {
+    // (re)connected: the backlog follows
+    t.reset();
+    if (f.width && f.height) t.resize(f.width, f.height);
+    return;
+  }
+  if (f[1] === "o") {
+    t.write(f[2]);
+  } else if (f[1] === "r") {
+    const [cols, rows] = f[2].split("x").map(Number);
+    t.resize(cols, rows);
+  }
+};
+</script>
+</body>
+</html>
+`))
+
+// MultiWriter returns a Writer writing the events to all the writers,
+// e.g. to a file and a Live session
+func MultiWriter(ws ...Writer) Writer {
+	return multiWriter(ws)
+}
+
+type multiWriter []Writer
+
+func (m multiWriter) WriteEvent(e Event) error {
+	for _, w := range m {
+		if err := w.WriteEvent(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiWriter) Close() error {
+	var err error
+	for _, w := range m {
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
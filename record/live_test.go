@@ -0,0 +1,78 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLivePage(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []LiveOption
+		want []string
+		not  []string
+	}{
+		{
+			name: "default",
+			want: []string{
+				`href="https://cdn.jsdelivr.net/npm/xterm@5.3.0/css/xterm.css" crossorigin="anonymous"`,
+				`src="https://cdn.jsdelivr.net/npm/xterm@5.3.0/lib/xterm.js" crossorigin="anonymous"`,
+			},
+			not: []string{"integrity"},
+		},
+		{
+			name: "self hosted",
+			opts: []LiveOption{WithAssets(Assets{Base: "/assets/xterm/", ScriptIntegrity: "sha384-script", StyleIntegrity: "sha384-style"})},
+			want: []string{
+				`href="/assets/xterm/css/xterm.css" integrity="sha384-style" crossorigin="anonymous"`,
+				`src="/assets/xterm/lib/xterm.js" integrity="sha384-script" crossorigin="anonymous"`,
+			},
+			not: []string{"cdn.jsdelivr.net"},
+		},
+		{
+			name: "escaped",
+			opts: []LiveOption{WithAssets(Assets{Base: `/x"><script>`})},
+			not:  []string{`/x"><script>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLive(Header{}, tt.opts...)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept", "text/html")
+			w := httptest.NewRecorder()
+			l.ServeHTTP(w, r)
+			b, _ := ioutil.ReadAll(w.Body)
+			page := string(b)
+			for _, v := range tt.want {
+				if !strings.Contains(page, v) {
+					t.Errorf("page does not contain %q:\n%s", v, page)
+				}
+			}
+			for _, v := range tt.not {
+				if strings.Contains(page, v) {
+					t.Errorf("page contains %q:\n%s", v, page)
+				}
+			}
+			if !strings.Contains(page, `const es = new EventSource(location.href);`) {
+				t.Errorf("page script altered:\n%s", page)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package record records the sessions of a Term and replays them.
+//
+// The recordings are streams of timed events written by a Writer and read
+// by a Reader, implemented for each supported file format: the script(1)
+// typescript and timing files, and ttyrec. A Live session streams the
+// recording as it happens to the viewers of its HTTP handler.
+package record
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/term"
+)
+
+// EventType is the type of a recorded event
+type EventType int
+
+const (
+	// Output is the data written to the Term
+	Output EventType = iota
+	// Input is the data read from the Term
+	Input
+	// Resize is a change of the Term size
+	Resize
+)
+
+// Event is a recorded event
+type Event struct {
+	// Time is the time elapsed since the beginning of the recording
+	Time time.Duration
+	Type EventType
+	// Data is the Output or Input data
+	Data []byte
+	// Size is the new size of a Resize event
+	Size term.Size
+}
+
+// Header describes a recording
+type Header struct {
+	// Time is the time the recording started
+	Time time.Time
+	// Size is the initial Term size
+	Size term.Size
+	// Term is the terminal type, e.g. xterm-256color
+	Term string
+	// Command is the recorded command, if known
+	Command string
+}
+
+// Writer writes the events of a recording in a file format.
+// The events not supported by the format are ignored.
+type Writer interface {
+	WriteEvent(e Event) error
+	// Close terminates the recording, it does not close the underlying
+	// writers
+	Close() error
+}
+
+// Reader reads the events of a recording
+type Reader interface {
+	// Header returns the recording header, as much as the format allows
+	Header() Header
+	// ReadEvent returns the next event, io.EOF at the end of the recording
+	ReadEvent() (Event, error)
+}
+
+type options struct {
+	input bool
+	echo  console.EchoReporter
+	now   func() time.Time
+}
+
+// Option configures a Recorder
+type Option func(o *options)
+
+// WithInput makes the Recorder record the input too.
+// echo is the console checked for the echo state, usually the PTY running the
+// session: the input read while its echo is disabled, e.g. passwords, or while
+// its state cannot be read, is not recorded. A nil echo records no input.
+func WithInput(echo console.EchoReporter) Option {
+	return func(o *options) {
+		o.input = true
+		o.echo = echo
+	}
+}
+
+// WithClock sets the clock used to time the events, defaults to time.Now
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// Recorder records timed events with a Writer
+type Recorder struct {
+	mu    sync.Mutex
+	w     Writer
+	o     options
+	start time.Time
+}
+
+// New returns a Recorder writing to w, timing the events from now
+func New(w Writer, opts ...Option) *Recorder {
+	o := options{now: time.Now}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &Recorder{w: w, o: o, start: o.now()}
+}
+
+// Record records an Output or Input event with a copy of data
+func (r *Recorder) Record(typ EventType, data []byte) error {
+	if typ == Input && !r.recordInput() {
+		return nil
+	}
+	return r.write(Event{Type: typ, Data: append([]byte(nil), data...)})
+}
+
+// recordInput reports whether the input can be recorded
+func (r *Recorder) recordInput() bool {
+	if !r.o.input || r.o.echo == nil {
+		return false
+	}
+	off, err := r.o.echo.EchoDisabled()
+	return err == nil && !off
+}
+
+// Resize records a Resize event
+func (r *Recorder) Resize(size term.Size) error {
+	return r.write(Event{Type: Resize, Size: size})
+}
+
+func (r *Recorder) write(e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.Time = r.o.now().Sub(r.start)
+	return r.w.WriteEvent(e)
+}
+
+// Close closes the Writer
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.w.Close()
+}
+
+// Wrap returns a Term recording the data written to t, and read from it
+// with WithInput, with r
+func Wrap(t term.Term, r *Recorder) term.Term {
+	return &recordTerm{Term: t, r: r}
+}
+
+type recordTerm struct {
+	term.Term
+	r *Recorder
+}
+
+func (t *recordTerm) Read(p []byte) (int, error) {
+	n, err := t.Term.Read(p)
+	if n > 0 {
+		if rerr := t.r.Record(Input, p[:n]); rerr != nil {
+			return n, rerr
+		}
+	}
+	return n, err
+}
+
+func (t *recordTerm) Write(p []byte) (int, error) {
+	n, err := t.Term.Write(p)
+	if n > 0 {
+		if rerr := t.r.Record(Output, p[:n]); rerr != nil {
+			return n, rerr
+		}
+	}
+	return n, err
+}
+
+type playOptions struct {
+	speed   float64
+	maxWait time.Duration
+	resize  func(term.Size)
+}
+
+// PlayOption configures Play
+type PlayOption func(o *playOptions)
+
+// WithSpeed sets the playback speed factor, defaults to 1
+func WithSpeed(f float64) PlayOption {
+	return func(o *playOptions) {
+		o.speed = f
+	}
+}
+
+// WithMaxWait caps the delay between two events, skipping the long
+// inactivity periods, 0 (the default) keeps the recorded delays
+func WithMaxWait(d time.Duration) PlayOption {
+	return func(o *playOptions) {
+		o.maxWait = d
+	}
+}
+
+// WithResize sets the function called with the Resize events
+func WithResize(fn func(term.Size)) PlayOption {
+	return func(o *playOptions) {
+		o.resize = fn
+	}
+}
+
+// Play writes the Output events read from r to w with the recorded timing.
+// It returns nil at the end of the recording.
+func Play(ctx context.Context, r Reader, w io.Writer, opts ...PlayOption) error {
+	o := playOptions{speed: 1}
+	for _, v := range opts {
+		v(&o)
+	}
+	var last time.Duration
+	for {
+		e, err := r.ReadEvent()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		d := e.Time - last
+		last = e.Time
+		if o.maxWait > 0 && d > o.maxWait {
+			d = o.maxWait
+		}
+		if o.speed > 0 {
+			d = time.Duration(float64(d) / o.speed)
+		}
+		if d > 0 {
+			t := time.NewTimer(d)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+		}
+		switch e.Type {
+		case Output:
+			if _, err := w.Write(e.Data); err != nil {
+				return err
+			}
+		case Resize:
+			if o.resize != nil {
+				o.resize(e.Size)
+			}
+		}
+	}
+}
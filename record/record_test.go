@@ -0,0 +1,76 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"errors"
+	"testing"
+)
+
+type echo struct {
+	off bool
+	err error
+}
+
+func (e *echo) EchoDisabled() (bool, error) {
+	return e.off, e.err
+}
+
+type events []Event
+
+func (e *events) WriteEvent(ev Event) error {
+	*e = append(*e, ev)
+	return nil
+}
+
+func (e *events) Close() error {
+	return nil
+}
+
+func TestRecordInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		record bool
+	}{
+		{name: "without input"},
+		{name: "echo on", opts: []Option{WithInput(&echo{})}, record: true},
+		{name: "echo off", opts: []Option{WithInput(&echo{off: true})}},
+		{name: "echo error", opts: []Option{WithInput(&echo{err: errors.New("unsupported")})}},
+		{name: "no echo source", opts: []Option{WithInput(nil)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e events
+			r := New(&e, tt.opts...)
+			if err := r.Record(Input, []byte("secret\r")); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Record(Output, []byte("\r\n")); err != nil {
+				t.Fatal(err)
+			}
+			want := 1
+			if tt.record {
+				want = 2
+			}
+			if len(e) != want {
+				t.Fatalf("got %d events, want %d", len(e), want)
+			}
+			if tt.record && (e[0].Type != Input || string(e[0].Data) != "secret\r") {
+				t.Fatalf("got %+v, want the input", e[0])
+			}
+		})
+	}
+}
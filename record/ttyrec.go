@@ -0,0 +1,135 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ttyrecHeader is the size of a ttyrec frame header: the seconds and
+// microseconds of the frame time and the data length, as little endian
+// uint32
+const ttyrecHeader = 12
+
+// maxTtyrecFrame bounds the frames read, the recorders write chunks of a
+// few kilobytes
+const maxTtyrecFrame = 16 << 20
+
+type ttyrecWriter struct {
+	w     io.Writer
+	start time.Time
+}
+
+// NewTtyrecWriter returns a Writer recording in the ttyrec format, as
+// played by ttyplay. The frames are timed from h.Time, or now if not set.
+// The format only holds the output: the Resize events are recorded as
+// the xterm window resize sequence, and the Input events are ignored.
+func NewTtyrecWriter(w io.Writer, h Header) Writer {
+	if h.Time.IsZero() {
+		h.Time = time.Now()
+	}
+	return &ttyrecWriter{w: w, start: h.Time}
+}
+
+func (w *ttyrecWriter) WriteEvent(e Event) error {
+	data := e.Data
+	switch e.Type {
+	case Input:
+		return nil
+	case Resize:
+		data = []byte(fmt.Sprintf("\x1b[8;%d;%dt", e.Size.Rows, e.Size.Cols))
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	t := w.start.Add(e.Time)
+	b := make([]byte, ttyrecHeader, ttyrecHeader+len(data))
+	binary.LittleEndian.PutUint32(b, uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(b[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(b[8:], uint32(len(data)))
+	_, err := w.w.Write(append(b, data...))
+	return err
+}
+
+func (w *ttyrecWriter) Close() error {
+	return nil
+}
+
+type ttyrecReader struct {
+	r     io.Reader
+	h     Header
+	first *Event
+}
+
+// NewTtyrecReader returns a Reader of a ttyrec recording. The Header only
+// holds the time of the first frame.
+func NewTtyrecReader(r io.Reader) (Reader, error) {
+	t := &ttyrecReader{r: r}
+	at, data, err := t.frame()
+	if err == io.EOF {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.h.Time = at
+	t.first = &Event{Type: Output, Data: data}
+	return t, nil
+}
+
+// frame reads the next frame
+func (t *ttyrecReader) frame() (time.Time, []byte, error) {
+	var h [ttyrecHeader]byte
+	if _, err := io.ReadFull(t.r, h[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return time.Time{}, nil, fmt.Errorf("record: truncated ttyrec frame header")
+		}
+		return time.Time{}, nil, err
+	}
+	sec := binary.LittleEndian.Uint32(h[:])
+	usec := binary.LittleEndian.Uint32(h[4:])
+	n := binary.LittleEndian.Uint32(h[8:])
+	if n > maxTtyrecFrame {
+		return time.Time{}, nil, fmt.Errorf("record: ttyrec frame too large: %d bytes", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(t.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return time.Time{}, nil, err
+	}
+	return time.Unix(int64(sec), int64(usec)*1000), data, nil
+}
+
+func (t *ttyrecReader) Header() Header {
+	return t.h
+}
+
+func (t *ttyrecReader) ReadEvent() (Event, error) {
+	if t.first != nil {
+		e := *t.first
+		t.first = nil
+		return e, nil
+	}
+	at, data, err := t.frame()
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Time: at.Sub(t.h.Time), Type: Output, Data: data}, nil
+}
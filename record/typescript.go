@@ -0,0 +1,318 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.linka.cloud/console/term"
+)
+
+// ErrInvalidTiming is returned when the timing file cannot be parsed
+var ErrInvalidTiming = errors.New("record: invalid timing")
+
+const (
+	scriptStarted = "Script started on "
+	scriptLayout  = "2006-01-02 15:04:05-07:00"
+	// scriptLegacyLayout is the date format of the BSD and old util-linux
+	// script headers
+	scriptLegacyLayout = "Mon Jan _2 15:04:05 2006"
+)
+
+type typescriptOptions struct {
+	advanced bool
+}
+
+// TypescriptOption configures the typescript Writer
+type TypescriptOption func(o *typescriptOptions)
+
+// WithAdvancedTiming makes the Writer use the util-linux advanced timing
+// format, which records the input and the resizes along the output,
+// instead of the classic one only supporting the output
+func WithAdvancedTiming() TypescriptOption {
+	return func(o *typescriptOptions) {
+		o.advanced = true
+	}
+}
+
+type typescriptWriter struct {
+	ts     io.Writer
+	timing io.Writer
+	h      Header
+	o      typescriptOptions
+	last   time.Duration
+}
+
+// NewTypescriptWriter returns a Writer recording in the script(1) format:
+// the data is written to typescript, following a header line, and the
+// delays and lengths of the chunks to timing, as consumed by scriptreplay.
+func NewTypescriptWriter(typescript, timing io.Writer, h Header, opts ...TypescriptOption) (Writer, error) {
+	w := &typescriptWriter{ts: typescript, timing: timing, h: h}
+	for _, v := range opts {
+		v(&w.o)
+	}
+	if w.h.Time.IsZero() {
+		w.h.Time = time.Now()
+	}
+	var b strings.Builder
+	b.WriteString(scriptStarted + w.h.Time.Format(scriptLayout) + " [")
+	if w.h.Command != "" {
+		fmt.Fprintf(&b, "COMMAND=%q ", w.h.Command)
+	}
+	if w.h.Term != "" {
+		fmt.Fprintf(&b, "TERM=%q ", w.h.Term)
+	}
+	fmt.Fprintf(&b, "COLUMNS=\"%d\" LINES=\"%d\"]\n", w.h.Size.Cols, w.h.Size.Rows)
+	if _, err := io.WriteString(w.ts, b.String()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *typescriptWriter) WriteEvent(e Event) error {
+	if !w.o.advanced && e.Type != Output {
+		return nil
+	}
+	delay := (e.Time - w.last).Seconds()
+	var line string
+	switch {
+	case e.Type == Resize:
+		line = fmt.Sprintf("S %.6f SIGWINCH ROWS=%d COLS=%d\n", delay, e.Size.Rows, e.Size.Cols)
+	case len(e.Data) == 0:
+		return nil
+	case w.o.advanced && e.Type == Input:
+		line = fmt.Sprintf("I %.6f %d\n", delay, len(e.Data))
+	case w.o.advanced:
+		line = fmt.Sprintf("O %.6f %d\n", delay, len(e.Data))
+	default:
+		line = fmt.Sprintf("%.6f %d\n", delay, len(e.Data))
+	}
+	if len(e.Data) > 0 {
+		if _, err := w.ts.Write(e.Data); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w.timing, line); err != nil {
+		return err
+	}
+	w.last = e.Time
+	return nil
+}
+
+func (w *typescriptWriter) Close() error {
+	_, err := fmt.Fprintf(w.ts, "\nScript done on %s\n", w.h.Time.Add(w.last).Format(scriptLayout))
+	return err
+}
+
+type typescriptReader struct {
+	ts     *bufio.Reader
+	timing *bufio.Scanner
+	h      Header
+	// line is a timing line read ahead
+	line string
+	time time.Duration
+}
+
+// NewTypescriptReader returns a Reader of a script(1) recording, with the
+// classic or the advanced timing format. The header line of the typescript
+// is parsed if present. With the advanced format, the input is expected to
+// be logged in the same typescript as the output.
+func NewTypescriptReader(typescript, timing io.Reader) (Reader, error) {
+	r := &typescriptReader{ts: bufio.NewReader(typescript), timing: bufio.NewScanner(timing)}
+	if p, _ := r.ts.Peek(len(scriptStarted)); string(p) == scriptStarted {
+		line, err := r.ts.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		r.h = parseScriptHeader(line)
+	}
+	// the advanced format may start with the header entries
+	for r.timing.Scan() {
+		f := strings.Fields(r.timing.Text())
+		if len(f) < 4 || f[0] != "H" {
+			r.line = r.timing.Text()
+			break
+		}
+		r.header(f[2], strings.Join(f[3:], " "))
+	}
+	if err := r.timing.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func parseScriptHeader(line string) Header {
+	var h Header
+	line = strings.TrimSpace(strings.TrimPrefix(line, scriptStarted))
+	date := line
+	if i := strings.IndexByte(line, '['); i >= 0 {
+		date = strings.TrimSpace(line[:i])
+		parseScriptFields(strings.Trim(line[i:], "[]"), h.set)
+	}
+	for _, l := range []string{scriptLayout, scriptLegacyLayout} {
+		if t, err := time.Parse(l, date); err == nil {
+			h.Time = t
+			break
+		}
+	}
+	return h
+}
+
+// parseScriptFields calls fn with the KEY="value" fields of s
+func parseScriptFields(s string, fn func(k, v string)) {
+	for {
+		s = strings.TrimLeft(s, " ")
+		i := strings.IndexByte(s, '=')
+		if i <= 0 {
+			return
+		}
+		k := s[:i]
+		s = s[i+1:]
+		var v string
+		switch {
+		case strings.HasPrefix(s, `"`):
+			// the value ends at the first unescaped quote
+			j := 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				v, s = s[1:], ""
+				break
+			}
+			var err error
+			if v, err = strconv.Unquote(s[:j+1]); err != nil {
+				v = s[1:j]
+			}
+			s = s[j+1:]
+		case strings.IndexByte(s, ' ') >= 0:
+			j := strings.IndexByte(s, ' ')
+			v, s = s[:j], s[j:]
+		default:
+			v, s = s, ""
+		}
+		fn(k, v)
+	}
+}
+
+func (h *Header) set(k, v string) {
+	switch k {
+	case "TERM":
+		h.Term = v
+	case "COMMAND":
+		h.Command = v
+	case "COLUMNS":
+		h.Size.Cols, _ = strconv.Atoi(v)
+	case "LINES":
+		h.Size.Rows, _ = strconv.Atoi(v)
+	}
+}
+
+func (r *typescriptReader) header(k, v string) {
+	if k == "START_TIME" {
+		if t, err := time.Parse(scriptLayout, v); err == nil {
+			r.h.Time = t
+		}
+		return
+	}
+	r.h.set(k, v)
+}
+
+func (r *typescriptReader) Header() Header {
+	return r.h
+}
+
+func (r *typescriptReader) ReadEvent() (Event, error) {
+	for {
+		line := r.line
+		r.line = ""
+		if line == "" {
+			if !r.timing.Scan() {
+				if err := r.timing.Err(); err != nil {
+					return Event{}, err
+				}
+				return Event{}, io.EOF
+			}
+			line = r.timing.Text()
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		typ := Output
+		switch f[0] {
+		case "O":
+			f = f[1:]
+		case "I":
+			typ, f = Input, f[1:]
+		case "S":
+			typ, f = Resize, f[1:]
+		case "H":
+			continue
+		}
+		if len(f) < 2 {
+			return Event{}, fmt.Errorf("%w: %q", ErrInvalidTiming, line)
+		}
+		delay, err := strconv.ParseFloat(f[0], 64)
+		if err != nil {
+			return Event{}, fmt.Errorf("%w: %q", ErrInvalidTiming, line)
+		}
+		r.time += time.Duration(delay * float64(time.Second))
+		e := Event{Time: r.time, Type: typ}
+		if typ == Resize {
+			e.Size = parseWinch(f[1:])
+			return e, nil
+		}
+		n, err := strconv.Atoi(f[1])
+		if err != nil || n < 0 {
+			return Event{}, fmt.Errorf("%w: %q", ErrInvalidTiming, line)
+		}
+		// the data is read as it comes rather than allocated upfront, as
+		// the size in the timing file is not trusted
+		var data bytes.Buffer
+		if _, err := io.CopyN(&data, r.ts, int64(n)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return Event{}, err
+		}
+		e.Data = data.Bytes()
+		return e, nil
+	}
+}
+
+// parseWinch parses the fields of a signal timing entry,
+// e.g. SIGWINCH ROWS=24 COLS=80
+func parseWinch(f []string) term.Size {
+	var s term.Size
+	for _, kv := range f {
+		if strings.HasPrefix(kv, "ROWS=") {
+			s.Rows, _ = strconv.Atoi(kv[5:])
+		} else if strings.HasPrefix(kv, "COLS=") {
+			s.Cols, _ = strconv.Atoi(kv[5:])
+		}
+	}
+	return s
+}
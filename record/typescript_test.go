@@ -0,0 +1,53 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTypescriptReaderMalformedTiming(t *testing.T) {
+	tests := []struct {
+		name   string
+		timing string
+		data   string
+		err    error
+	}{
+		{name: "valid", timing: "0.1 1\n", data: "x"},
+		{name: "oversized", timing: "0.1 9223372036854775807\n", err: io.ErrUnexpectedEOF},
+		{name: "truncated", timing: "0.1 2\n", err: io.ErrUnexpectedEOF},
+		{name: "negative", timing: "0.1 -1\n", err: ErrInvalidTiming},
+		{name: "not a number", timing: "0.1 x\n", err: ErrInvalidTiming},
+		{name: "missing size", timing: "0.1\n", err: ErrInvalidTiming},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewTypescriptReader(strings.NewReader("x"), strings.NewReader(tt.timing))
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := r.ReadEvent()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if string(e.Data) != tt.data {
+				t.Fatalf("got %q, want %q", e.Data, tt.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"go.linka.cloud/console/mux"
+	"go.linka.cloud/console/term"
+)
+
+// Dialer opens a new connection to the server
+type Dialer func(ctx context.Context) (io.ReadWriteCloser, error)
+
+type options struct {
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	attempts    int
+	onReconnect func(attempt int, err error)
+	mux         []mux.Option
+	probe       *Probe
+}
+
+// Option configures Attach
+type Option func(o *options)
+
+// WithBackoff sets the delay before the first reconnection attempt, doubled
+// after each failure up to max, defaults to 500ms and 30s
+func WithBackoff(initial, max time.Duration) Option {
+	return func(o *options) {
+		o.backoff, o.maxBackoff = initial, max
+	}
+}
+
+// WithMaxAttempts sets the maximum number of consecutive failed
+// reconnection attempts, 0 (the default) retries forever
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.attempts = n
+	}
+}
+
+// WithOnReconnect sets a callback notified before each reconnection
+// attempt with the error which caused it
+func WithOnReconnect(fn func(attempt int, err error)) Option {
+	return func(o *options) {
+		o.onReconnect = fn
+	}
+}
+
+// WithMuxOptions sets the options of the mux sessions,
+// e.g. mux.WithKeepAlive
+func WithMuxOptions(opts ...mux.Option) Option {
+	return func(o *options) {
+		o.mux = append(o.mux, opts...)
+	}
+}
+
+// WithProbe sets the Probe measuring the connection latency
+func WithProbe(p *Probe) Option {
+	return func(o *options) {
+		o.probe = p
+	}
+}
+
+// Attach runs a remote session on the Term, reconnecting with dial when the
+// connection is lost: the Term, and the local console state, are kept, and
+// the missed output is replayed.
+// It returns nil when the remote session ends or the Term is closed.
+func Attach(ctx context.Context, t term.Term, dial Dialer, opts ...Option) error {
+	o := options{backoff: 500 * time.Millisecond, maxBackoff: 30 * time.Second}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.probe != nil {
+		o.mux = append(o.mux, mux.WithProbe(o.probe.interval))
+	}
+	c := &client{t: t, input: make(chan []byte), done: make(chan struct{})}
+	defer close(c.done)
+	go c.readInput()
+	var h hello
+	delay := o.backoff
+	for attempt := 0; ; {
+		var err error
+		h, err = c.run(ctx, dial, h, o)
+		if err == nil || errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.Done():
+			return nil
+		default:
+		}
+		if c.connected {
+			// the connection worked: start over with the initial delay
+			attempt, delay, c.connected = 0, o.backoff, false
+		}
+		attempt++
+		if o.attempts > 0 && attempt > o.attempts {
+			return err
+		}
+		if o.onReconnect != nil {
+			o.onReconnect(attempt, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.Done():
+			return nil
+		}
+		if delay *= 2; delay > o.maxBackoff {
+			delay = o.maxBackoff
+		}
+	}
+}
+
+type client struct {
+	t term.Term
+	// input receives the Term input, closed when the Term read fails
+	input chan []byte
+	// done is closed when Attach returns
+	done chan struct{}
+	// pending is the input which could not be sent
+	pending []byte
+	// connected reports whether the last run reached the session
+	connected bool
+}
+
+func (c *client) readInput() {
+	defer close(c.input)
+	for {
+		buf := make([]byte, 1024)
+		n, err := c.t.Read(buf)
+		if n > 0 {
+			select {
+			case c.input <- buf[:n]:
+			case <-c.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// run connects to the session described by h and runs it until the
+// connection is lost, returning the state to resume it.
+// It returns a nil error when the session or the Term ended.
+func (c *client) run(ctx context.Context, dial Dialer, h hello, o options) (hello, error) {
+	conn, err := dial(ctx)
+	if err != nil {
+		return h, err
+	}
+	sess := mux.Client(conn, o.mux...)
+	defer sess.Close()
+	if o.probe != nil {
+		o.probe.set(sess)
+		defer o.probe.set(nil)
+	}
+	ch, err := sess.Open()
+	if err != nil {
+		return h, err
+	}
+	if _, err := io.WriteString(ch, h.String()); err != nil {
+		return h, err
+	}
+	r, err := readReply(ch)
+	if err != nil {
+		return h, err
+	}
+	c.connected = true
+	// the output from r.offset is replayed, which may skip the output
+	// dropped from the server scrollback
+	h = r
+	if err := ch.Resize(c.t.Size()); err != nil {
+		return h, err
+	}
+
+	var mu sync.Mutex
+	out := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := ch.Read(buf)
+			if n > 0 {
+				if _, err := c.t.Write(buf[:n]); err != nil {
+					out <- nil
+					return
+				}
+				mu.Lock()
+				h.offset += int64(n)
+				mu.Unlock()
+			}
+			if err != nil {
+				out <- err
+				return
+			}
+		}
+	}()
+	state := func() hello {
+		mu.Lock()
+		defer mu.Unlock()
+		return h
+	}
+	if len(c.pending) > 0 {
+		if n, err := ch.Write(c.pending); err != nil {
+			c.pending = c.pending[n:]
+			return state(), err
+		}
+		c.pending = nil
+	}
+	sizes := c.t.WatchSize()
+	for {
+		select {
+		case p, ok := <-c.input:
+			if !ok {
+				return state(), nil
+			}
+			if n, err := ch.Write(p); err != nil {
+				// only the part not written is sent again
+				c.pending = p[n:]
+				return state(), err
+			}
+		case sz, ok := <-sizes:
+			if !ok {
+				sizes = nil
+				continue
+			}
+			ch.Resize(sz)
+		case err := <-out:
+			select {
+			case <-sess.Done():
+				// the connection was lost
+				if err == nil || err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				if serr := sess.Err(); serr != nil {
+					err = serr
+				}
+				return state(), err
+			default:
+			}
+			// the server closed the channel: the session ended
+			if err == io.EOF {
+				err = nil
+			}
+			return state(), err
+		case <-c.t.Done():
+			return state(), nil
+		case <-ctx.Done():
+			return state(), ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"sync"
+	"time"
+
+	"go.linka.cloud/console/mux"
+)
+
+// Probe measures the latency of the remote session: the mux session pings
+// the server at the Probe interval, which answers with the ping timestamp.
+// Its Latency method can be passed to term.WithLatency to expose it in the
+// Term Stats.
+type Probe struct {
+	interval time.Duration
+	mu       sync.Mutex
+	sess     *mux.Session
+}
+
+// NewProbe returns a Probe measuring the latency at the given interval
+func NewProbe(interval time.Duration) *Probe {
+	return &Probe{interval: interval}
+}
+
+// Latency returns the last round trip time measured on the current
+// connection, 0 if not connected or not measured yet
+func (p *Probe) Latency() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sess == nil {
+		return 0
+	}
+	return p.sess.RTT()
+}
+
+func (p *Probe) set(s *mux.Session) {
+	p.mu.Lock()
+	p.sess = s
+	p.mu.Unlock()
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote serves terminal sessions over mux channels, with support
+// for reconnections: the sessions survive the connection losses, and the
+// clients resume them with a token, receiving the output they missed.
+//
+// The transport is left to the application: the Server serves the mux
+// sessions of the connections it accepts, and the client dials new
+// connections with a Dialer, e.g. websockets or TCP connections.
+//
+// After opening a channel, the client sends a handshake line, either
+// "NEW\n" or "RESUME <token> <offset>\n", where offset is the number of
+// output bytes already received. The server answers "OK <token> <offset>\n",
+// where offset is the position from which the output is sent, or
+// "ERR <message>\n". The channel then carries the session data.
+package remote
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSessionNotFound = errors.New("remote: session not found")
+	ErrHandshake       = errors.New("remote: invalid handshake")
+)
+
+// readLine reads a line byte by byte, so that nothing after it is consumed
+func readLine(r io.Reader) (string, error) {
+	var b strings.Builder
+	var c [1]byte
+	for b.Len() < 256 {
+		if _, err := io.ReadFull(r, c[:]); err != nil {
+			return "", err
+		}
+		if c[0] == '\n' {
+			return b.String(), nil
+		}
+		b.WriteByte(c[0])
+	}
+	return "", ErrHandshake
+}
+
+// hello is the client handshake
+type hello struct {
+	token  string
+	offset int64
+}
+
+func (h hello) String() string {
+	if h.token == "" {
+		return "NEW\n"
+	}
+	return fmt.Sprintf("RESUME %s %d\n", h.token, h.offset)
+}
+
+func readHello(r io.Reader) (hello, error) {
+	l, err := readLine(r)
+	if err != nil {
+		return hello{}, err
+	}
+	f := strings.Fields(l)
+	switch {
+	case len(f) == 1 && f[0] == "NEW":
+		return hello{}, nil
+	case len(f) == 3 && f[0] == "RESUME":
+		o, err := strconv.ParseInt(f[2], 10, 64)
+		if err != nil || o < 0 {
+			return hello{}, ErrHandshake
+		}
+		return hello{token: f[1], offset: o}, nil
+	}
+	return hello{}, ErrHandshake
+}
+
+// readReply reads the server handshake reply
+func readReply(r io.Reader) (hello, error) {
+	l, err := readLine(r)
+	if err != nil {
+		return hello{}, err
+	}
+	if strings.HasPrefix(l, "ERR ") {
+		msg := l[4:]
+		if msg == ErrSessionNotFound.Error() {
+			return hello{}, ErrSessionNotFound
+		}
+		return hello{}, errors.New(msg)
+	}
+	f := strings.Fields(l)
+	if len(f) != 3 || f[0] != "OK" {
+		return hello{}, ErrHandshake
+	}
+	o, err := strconv.ParseInt(f[2], 10, 64)
+	if err != nil {
+		return hello{}, ErrHandshake
+	}
+	return hello{token: f[1], offset: o}, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+// ring keeps the last bytes of the session output
+type ring struct {
+	buf []byte
+	// head is the position of the next write in buf
+	head int
+	// n is the number of bytes kept
+	n int
+	// end is the total number of bytes written
+	end int64
+}
+
+func newRing(size int) *ring {
+	return &ring{buf: make([]byte, size)}
+}
+
+// start returns the offset of the oldest byte kept
+func (r *ring) start() int64 {
+	return r.end - int64(r.n)
+}
+
+func (r *ring) write(p []byte) {
+	r.end += int64(len(p))
+	if len(p) > len(r.buf) {
+		p = p[len(p)-len(r.buf):]
+	}
+	n := copy(r.buf[r.head:], p)
+	copy(r.buf, p[n:])
+	r.head = (r.head + len(p)) % len(r.buf)
+	if r.n += len(p); r.n > len(r.buf) {
+		r.n = len(r.buf)
+	}
+}
+
+// since returns a copy of at most max bytes written from the offset, or from
+// the oldest byte kept if it was dropped, along with the offset they start
+// from
+func (r *ring) since(offset int64, max int) ([]byte, int64) {
+	if s := r.start(); offset < s {
+		offset = s
+	}
+	if offset > r.end {
+		offset = r.end
+	}
+	l := int(r.end - offset)
+	if l > max {
+		l = max
+	}
+	out := make([]byte, l)
+	from := (r.head - int(r.end-offset) + len(r.buf)) % len(r.buf)
+	n := copy(out, r.buf[from:])
+	copy(out[n:], r.buf)
+	return out, offset
+}
@@ -0,0 +1,354 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/mux"
+)
+
+// FlowControl is the strategy applied when a client does not read the
+// session output as fast as it is produced
+type FlowControl int
+
+const (
+	// PauseProducer stops reading the session output while the client is
+	// late by more than the scrollback size, which blocks the session
+	// program
+	PauseProducer FlowControl = iota
+	// DropOldest keeps reading the session output, dropping the oldest
+	// data the client did not receive yet when it is late by more than the
+	// scrollback size
+	DropOldest
+)
+
+// maxSend is the maximum size of the output sent at once
+const maxSend = 32 << 10
+
+type serverOptions struct {
+	scrollback int
+	linger     time.Duration
+	flow       FlowControl
+}
+
+// ServerOption configures a Server
+type ServerOption func(o *serverOptions)
+
+// WithScrollback sets the size of the output kept to be replayed to the
+// resuming clients, defaults to 1MiB
+func WithScrollback(size int) ServerOption {
+	return func(o *serverOptions) {
+		if size > 0 {
+			o.scrollback = size
+		}
+	}
+}
+
+// WithLinger sets how long a session is kept running without a client,
+// defaults to one minute
+func WithLinger(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.linger = d
+	}
+}
+
+// WithFlowControl sets the strategy applied to the slow clients,
+// defaults to PauseProducer.
+// The session output is buffered up to the scrollback size in both cases,
+// and the mux channels window bounds the data in flight.
+func WithFlowControl(f FlowControl) ServerOption {
+	return func(o *serverOptions) {
+		o.flow = f
+	}
+}
+
+// StartFunc starts a new session, e.g. a shell in a pty, and returns its
+// console. The session ends when the console output reaches its end.
+type StartFunc func(ctx context.Context) (console.Console, error)
+
+// Server serves resumable terminal sessions
+type Server struct {
+	start StartFunc
+	opts  serverOptions
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// NewServer returns a Server starting the sessions with start
+func NewServer(start StartFunc, opts ...ServerOption) *Server {
+	o := serverOptions{scrollback: 1 << 20, linger: time.Minute}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &Server{start: start, opts: o, sessions: make(map[string]*session)}
+}
+
+// Serve serves the channels opened by the client of the mux session until
+// it is closed. The sessions are started with ctx.
+func (s *Server) Serve(ctx context.Context, sess *mux.Session) error {
+	for {
+		ch, err := sess.Accept()
+		if err != nil {
+			if err == mux.ErrClosed {
+				return sess.Err()
+			}
+			return err
+		}
+		go s.handle(ctx, ch)
+	}
+}
+
+func (s *Server) handle(ctx context.Context, ch *mux.Channel) {
+	h, err := readHello(ch)
+	if err != nil {
+		ch.Close()
+		return
+	}
+	var ss *session
+	if h.token == "" {
+		ss, err = s.newSession(ctx)
+	} else {
+		ss, err = s.get(h.token)
+	}
+	if err != nil {
+		fmt.Fprintf(ch, "ERR %v\n", err)
+		ch.Close()
+		return
+	}
+	if err := ss.attach(ch, h.offset); err != nil {
+		ch.Close()
+		return
+	}
+	// the resizes are applied while the channel is attached
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		for {
+			select {
+			case sz := <-ch.WatchSize():
+				ss.con.Resize(sz.WinSize())
+			case <-ctx.Done():
+				return
+			case <-ss.done:
+				return
+			}
+		}
+	}()
+	io.Copy(ss.con, ch)
+	ss.detach(ch)
+}
+
+func (s *Server) newSession(ctx context.Context) (*session, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	con, err := s.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ss := &session{
+		s:     s,
+		token: token,
+		con:   con,
+		ring:  newRing(s.opts.scrollback),
+		done:  make(chan struct{}),
+	}
+	ss.cond = sync.NewCond(&ss.mu)
+	s.mu.Lock()
+	s.sessions[token] = ss
+	s.mu.Unlock()
+	go ss.pump()
+	return ss, nil
+}
+
+func (s *Server) get(token string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss, ok := s.sessions[token]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return ss, nil
+}
+
+func (s *Server) remove(token string) {
+	s.mu.Lock()
+	delete(s.sessions, token)
+	s.mu.Unlock()
+}
+
+// session is a running session, attached to at most one channel
+type session struct {
+	s     *Server
+	token string
+	con   console.Console
+
+	// mu guards the output state below, cond signals its changes
+	mu   sync.Mutex
+	cond *sync.Cond
+	ring *ring
+	ch   *mux.Channel
+	// sent is the offset of the output sent to ch
+	sent   int64
+	linger *time.Timer
+	ended  bool
+	done   chan struct{}
+}
+
+// pump reads the session output and keeps it in the ring, from where it is
+// sent to the attached channel
+func (ss *session) pump() {
+	size := maxSend
+	if size > len(ss.ring.buf) {
+		size = len(ss.ring.buf)
+	}
+	buf := make([]byte, size)
+	for {
+		n, err := ss.con.Read(buf)
+		if n > 0 {
+			ss.mu.Lock()
+			if ss.s.opts.flow == PauseProducer {
+				// wait for the client to catch up, so that the ring does
+				// not drop data it did not receive
+				for ss.ch != nil && ss.ring.end+int64(n)-ss.sent > int64(len(ss.ring.buf)) {
+					ss.cond.Wait()
+				}
+			}
+			ss.ring.write(buf[:n])
+			ss.cond.Broadcast()
+			ss.mu.Unlock()
+		}
+		if err != nil {
+			ss.end()
+			return
+		}
+	}
+}
+
+// send sends the output to the channel as long as it is attached, and
+// closes it once the output of the ended session was sent
+func (ss *session) send(ch *mux.Channel) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for {
+		for ss.ch == ch && ss.sent == ss.ring.end && !ss.ended {
+			ss.cond.Wait()
+		}
+		if ss.ch != ch {
+			return
+		}
+		if ss.sent == ss.ring.end {
+			ss.ch = nil
+			ch.Close()
+			return
+		}
+		// the data dropped from the ring is skipped
+		b, from := ss.ring.since(ss.sent, maxSend)
+		ss.mu.Unlock()
+		_, err := ch.Write(b)
+		ss.mu.Lock()
+		if ss.ch != ch {
+			return
+		}
+		if err != nil {
+			ss.ch = nil
+			ch.Close()
+			ss.startLinger()
+			ss.cond.Broadcast()
+			return
+		}
+		ss.sent = from + int64(len(b))
+		ss.cond.Broadcast()
+	}
+}
+
+// attach replaces the session channel, replaying the output from offset
+func (ss *session) attach(ch *mux.Channel, offset int64) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.ended {
+		fmt.Fprintf(ch, "ERR %v\n", ErrSessionNotFound)
+		return ErrSessionNotFound
+	}
+	if ss.linger != nil {
+		ss.linger.Stop()
+		ss.linger = nil
+	}
+	if ss.ch != nil {
+		ss.ch.Close()
+		ss.ch = nil
+		ss.cond.Broadcast()
+	}
+	_, from := ss.ring.since(offset, 0)
+	if _, err := fmt.Fprintf(ch, "OK %s %d\n", ss.token, from); err != nil {
+		ss.startLinger()
+		return err
+	}
+	ss.ch, ss.sent = ch, from
+	go ss.send(ch)
+	return nil
+}
+
+// detach releases the channel if it is still the attached one
+func (ss *session) detach(ch *mux.Channel) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.ch != ch {
+		return
+	}
+	ss.ch = nil
+	ch.Close()
+	ss.startLinger()
+	ss.cond.Broadcast()
+}
+
+// startLinger ends the session if no client attaches in time,
+// mu must be held
+func (ss *session) startLinger() {
+	if ss.ended || ss.linger != nil {
+		return
+	}
+	ss.linger = time.AfterFunc(ss.s.opts.linger, func() {
+		ss.mu.Lock()
+		idle := ss.ch == nil
+		ss.mu.Unlock()
+		if idle {
+			ss.con.Close()
+		}
+	})
+}
+
+// end is called when the session output reached its end, the attached
+// channel is closed by send once the remaining output was sent
+func (ss *session) end() {
+	ss.s.remove(ss.token)
+	ss.mu.Lock()
+	ss.ended = true
+	if ss.linger != nil {
+		ss.linger.Stop()
+	}
+	ss.cond.Broadcast()
+	ss.mu.Unlock()
+	close(ss.done)
+	ss.con.Close()
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/mux"
+)
+
+// program is a console writing its output then ending, the part after
+// the first one being written once resume is closed
+type program struct {
+	console.Console
+	first  io.Reader
+	resume chan struct{}
+	rest   io.Reader
+}
+
+func (p *program) Read(b []byte) (int, error) {
+	n, err := p.first.Read(b)
+	if err != io.EOF {
+		return n, err
+	}
+	<-p.resume
+	return p.rest.Read(b)
+}
+
+func (p *program) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (p *program) Resize(console.WinSize) error {
+	return nil
+}
+
+func (p *program) Close() error {
+	return nil
+}
+
+func TestSessionEndSendsOutput(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 20000)
+	tests := []struct {
+		name string
+		// received is the output received before the program resumes
+		received int
+	}{
+		{name: "attached before the output"},
+		{name: "attached during the output", received: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &program{
+				first:  bytes.NewReader(data[:1000]),
+				resume: make(chan struct{}),
+				rest:   bytes.NewReader(data[1000:]),
+			}
+			srv := NewServer(func(ctx context.Context) (console.Console, error) {
+				return p, nil
+			})
+			a, b := net.Pipe()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go srv.Serve(ctx, mux.Server(b))
+			sess := mux.Client(a)
+			defer sess.Close()
+			ch, err := sess.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(ch, hello{}.String()); err != nil {
+				t.Fatal(err)
+			}
+			if l, err := readLine(ch); err != nil || !strings.HasPrefix(l, "OK ") {
+				t.Fatalf("handshake: %q, %v", l, err)
+			}
+			got := make([]byte, tt.received)
+			if _, err := io.ReadFull(ch, got); err != nil {
+				t.Fatal(err)
+			}
+			close(p.resume)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				rest, rerr := ioutil.ReadAll(ch)
+				got, err = append(got, rest...), rerr
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("timeout waiting for the end of the output")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("got %d of %d bytes", len(got), len(data))
+			}
+		})
+	}
+}
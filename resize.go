@@ -0,0 +1,77 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultSyncInterval is the interval at which SyncSize polls the size of
+// the consoles which do not notify their size changes
+const DefaultSyncInterval = 500 * time.Millisecond
+
+// resizeFrom resizes c to the size of other
+func resizeFrom(c, other Console) error {
+	ws, err := other.Size()
+	if err != nil {
+		return err
+	}
+	return c.Resize(ws)
+}
+
+// SyncSize keeps dst the same size as src: it resizes dst to the size of
+// src, then each time src is resized, using the src notifications if it is
+// a SizeNotifier, polling its size every DefaultSyncInterval otherwise.
+// It returns when ctx is cancelled, or when resizing dst fails.
+func SyncSize(ctx context.Context, dst, src Console) error {
+	ws, err := src.Size()
+	if err != nil {
+		return err
+	}
+	if err := dst.Resize(ws); err != nil {
+		return err
+	}
+	var notify <-chan WinSize
+	if n, ok := src.(SizeNotifier); ok {
+		notify = n.NotifySize()
+	}
+	var tick <-chan time.Time
+	if notify == nil {
+		t := time.NewTicker(DefaultSyncInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		var nws WinSize
+		select {
+		case <-tick:
+			var err error
+			if nws, err = src.Size(); err != nil {
+				continue
+			}
+		case nws = <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if nws == ws {
+			continue
+		}
+		ws = nws
+		if err := dst.Resize(ws); err != nil {
+			return err
+		}
+	}
+}
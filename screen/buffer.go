@@ -0,0 +1,402 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package screen
+
+import (
+	"unicode/utf8"
+)
+
+// Cell is a single character cell of the screen.
+// A wide character takes two cells: the character and a continuation
+// cell on its right.
+type Cell struct {
+	Rune rune
+	// Combining are the combining marks following Rune in its grapheme
+	// cluster, e.g. accents, variation selectors or zero width joiners
+	Combining string
+	// Style is the SGR parameters applied to the cell, e.g. "1;31"
+	Style string
+	// Continuation is set on the right half of a wide character
+	Continuation bool
+}
+
+func (c Cell) rune() rune {
+	if c.Rune == 0 {
+		return ' '
+	}
+	return c.Rune
+}
+
+// Width returns the number of columns the cell character takes:
+// 2 for a wide character, 0 for a continuation cell, 1 otherwise
+func (c Cell) Width() int {
+	if c.Continuation {
+		return 0
+	}
+	if RuneWidth(c.Rune) == 2 {
+		return 2
+	}
+	return 1
+}
+
+// String returns the grapheme cluster of the cell, a space if it is empty
+// and an empty string if it is a continuation cell
+func (c Cell) String() string {
+	if c.Continuation {
+		return ""
+	}
+	if c.Combining == "" && c.Rune < utf8.RuneSelf {
+		return string(c.rune())
+	}
+	return string(c.rune()) + c.Combining
+}
+
+// Buffer is a grid of cells
+type Buffer struct {
+	width  int
+	height int
+	cells  []Cell
+	// wrapped reports for each row whether it continues on the next one
+	wrapped []bool
+	// dirty are the columns of each row changed since the buffer was last
+	// drawn by a Renderer, and damaged the ones to redraw even if unchanged
+	dirty   []span
+	damaged []span
+}
+
+// span is a range of columns, empty if lo >= hi
+type span struct {
+	lo, hi int
+}
+
+// NewBuffer returns an empty buffer of the given size
+func NewBuffer(width, height int) *Buffer {
+	b := &Buffer{width: width, height: height, cells: make([]Cell, width*height), wrapped: make([]bool, height), dirty: make([]span, height), damaged: make([]span, height)}
+	b.markAll()
+	return b
+}
+
+// Size returns the buffer width and height
+func (b *Buffer) Size() (width, height int) {
+	return b.width, b.height
+}
+
+// Cell returns the cell at the given column and row
+func (b *Buffer) Cell(x, y int) Cell {
+	if !b.in(x, y) {
+		return Cell{}
+	}
+	return b.cells[y*b.width+x]
+}
+
+// SetCell sets the cell at the given column and row.
+// Out of bounds positions are ignored.
+// A wide character also sets the continuation cell on its right, or is
+// replaced by a space if it is in the last column, as it does not fit.
+// The wide characters partially overwritten are erased, and continuation
+// cells are only kept if they already follow a wide character.
+func (b *Buffer) SetCell(x, y int, c Cell) {
+	if !b.in(x, y) {
+		return
+	}
+	i := y*b.width + x
+	if c.Continuation {
+		// continuation cells are set with their wide character, e.g. when
+		// copying the cells of a row
+		if b.cells[i].Continuation {
+			return
+		}
+		c = Cell{Style: c.Style}
+	}
+	w := c.Width()
+	if w == 2 && x == b.width-1 {
+		c = Cell{Rune: ' ', Style: c.Style}
+		w = 1
+	}
+	// the other halves of the wide characters may be erased too
+	b.mark(y, x-1, x+3)
+	b.erase(i, x)
+	b.cells[i] = c
+	if w == 2 {
+		b.erase(i+1, x+1)
+		b.cells[i+1] = Cell{Style: c.Style, Continuation: true}
+	}
+}
+
+// erase erases the other half of the wide character at index i, in
+// column x, if any, as it is about to be overwritten
+func (b *Buffer) erase(i, x int) {
+	c := b.cells[i]
+	switch {
+	case c.Continuation && x > 0:
+		b.cells[i-1] = Cell{Style: b.cells[i-1].Style}
+	case c.Width() == 2 && x < b.width-1:
+		b.cells[i+1] = Cell{Style: b.cells[i+1].Style}
+	}
+}
+
+// Combine appends the combining mark r to the cell at the given column and
+// row, or to the wide character it is the continuation of
+func (b *Buffer) Combine(x, y int, r rune) {
+	if !b.in(x, y) {
+		return
+	}
+	i := y*b.width + x
+	if b.cells[i].Continuation && x > 0 {
+		i--
+	}
+	b.mark(y, x-1, x+1)
+	b.cells[i].Combining += string(r)
+}
+
+// SetString writes s starting at the given column and row with the given style,
+// clipping it at the end of the row. The combining marks are merged with the
+// previous character, and the wide characters take two columns.
+// It returns the number of columns written.
+func (b *Buffer) SetString(x, y int, s string, style string) int {
+	n := 0
+	for _, r := range s {
+		w := RuneWidth(r)
+		if w == 0 {
+			if n > 0 && !isControl(r) {
+				b.Combine(x+n-1, y, r)
+			}
+			continue
+		}
+		if x+n+w > b.width {
+			break
+		}
+		b.SetCell(x+n, y, Cell{Rune: r, Style: style})
+		n += w
+	}
+	return n
+}
+
+// Wrapped reports whether the row was soft wrapped, i.e. its text continues
+// on the next row
+func (b *Buffer) Wrapped(y int) bool {
+	return y >= 0 && y < b.height && b.wrapped[y]
+}
+
+// SetWrapped marks the row as soft wrapped or not
+func (b *Buffer) SetWrapped(y int, v bool) {
+	if y >= 0 && y < b.height {
+		b.wrapped[y] = v
+	}
+}
+
+// Fill sets all the cells to c
+func (b *Buffer) Fill(c Cell) {
+	for i := range b.cells {
+		b.cells[i] = c
+	}
+	b.markAll()
+}
+
+// Clear empties all the cells
+func (b *Buffer) Clear() {
+	b.Fill(Cell{})
+	for i := range b.wrapped {
+		b.wrapped[i] = false
+	}
+}
+
+// Resize changes the buffer size, keeping the content of the cells
+// that are still in bounds
+func (b *Buffer) Resize(width, height int) {
+	if width == b.width && height == b.height {
+		return
+	}
+	cells := make([]Cell, width*height)
+	wrapped := make([]bool, height)
+	copy(wrapped, b.wrapped)
+	for y := 0; y < height && y < b.height; y++ {
+		for x := 0; x < width && x < b.width; x++ {
+			cells[y*width+x] = b.cells[y*b.width+x]
+		}
+		// a wide character cut in half is erased
+		if width < b.width && width > 0 && cells[y*width+width-1].Width() == 2 {
+			cells[y*width+width-1] = Cell{Style: cells[y*width+width-1].Style}
+		}
+	}
+	b.width, b.height, b.cells, b.wrapped = width, height, cells, wrapped
+	b.dirty, b.damaged = make([]span, height), make([]span, height)
+	b.markAll()
+}
+
+// ScrollUp moves the rows from top to bottom (included) up by n rows,
+// clearing the rows left at the bottom
+func (b *Buffer) ScrollUp(top, bottom, n int) {
+	b.scroll(top, bottom, n)
+}
+
+// ScrollDown moves the rows from top to bottom (included) down by n rows,
+// clearing the rows left at the top
+func (b *Buffer) ScrollDown(top, bottom, n int) {
+	b.scroll(top, bottom, -n)
+}
+
+func (b *Buffer) scroll(top, bottom, n int) {
+	if top < 0 {
+		top = 0
+	}
+	if bottom >= b.height {
+		bottom = b.height - 1
+	}
+	if top > bottom || n == 0 {
+		return
+	}
+	for y := top; y <= bottom; y++ {
+		b.mark(y, 0, b.width)
+	}
+	rows := bottom - top + 1
+	if n > rows || -n > rows {
+		n = rows * (n / abs(n))
+	}
+	w := b.width
+	if n > 0 {
+		copy(b.cells[top*w:], b.cells[(top+n)*w:(bottom+1)*w])
+		clearCells(b.cells[(bottom+1-n)*w : (bottom+1)*w])
+		copy(b.wrapped[top:], b.wrapped[top+n:bottom+1])
+		clearWrapped(b.wrapped[bottom+1-n : bottom+1])
+	} else {
+		copy(b.cells[(top-n)*w:(bottom+1)*w], b.cells[top*w:(bottom+1+n)*w])
+		clearCells(b.cells[top*w : (top-n)*w])
+		copy(b.wrapped[top-n:bottom+1], b.wrapped[top:bottom+1+n])
+		clearWrapped(b.wrapped[top : top-n])
+	}
+}
+
+func clearWrapped(wrapped []bool) {
+	for i := range wrapped {
+		wrapped[i] = false
+	}
+}
+
+func clearCells(cells []Cell) {
+	for i := range cells {
+		cells[i] = Cell{}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Clone returns a copy of the buffer
+func (b *Buffer) Clone() *Buffer {
+	c := &Buffer{width: b.width, height: b.height, cells: make([]Cell, len(b.cells)), wrapped: make([]bool, len(b.wrapped)), dirty: make([]span, len(b.dirty)), damaged: make([]span, len(b.damaged))}
+	copy(c.cells, b.cells)
+	copy(c.wrapped, b.wrapped)
+	copy(c.dirty, b.dirty)
+	copy(c.damaged, b.damaged)
+	return c
+}
+
+// InvalidateRect forces the next Renderer flush to redraw the cells of the
+// w x h rectangle at the given column and row, even if they did not change,
+// e.g. because something else was drawn over them. The rest of the screen
+// is only redrawn where it changed.
+func (b *Buffer) InvalidateRect(x, y, w, h int) {
+	// the wide character whose continuation is in the first column is
+	// redrawn too
+	for r := y; r < y+h; r++ {
+		b.mark(r, x-1, x+w)
+		if r >= 0 && r < len(b.damaged) {
+			extend(&b.damaged[r], x-1, x+w, b.width)
+		}
+	}
+}
+
+// mark marks the columns from lo to hi (excluded) of the row y dirty
+func (b *Buffer) mark(y, lo, hi int) {
+	if y < 0 || y >= len(b.dirty) {
+		return
+	}
+	extend(&b.dirty[y], lo, hi, b.width)
+}
+
+// extend extends the span d to the columns from lo to hi, clipped to width
+func extend(d *span, lo, hi, width int) {
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > width {
+		hi = width
+	}
+	if lo >= hi {
+		return
+	}
+	if d.lo >= d.hi {
+		d.lo, d.hi = lo, hi
+		return
+	}
+	if lo < d.lo {
+		d.lo = lo
+	}
+	if hi > d.hi {
+		d.hi = hi
+	}
+}
+
+func (b *Buffer) markAll() {
+	for y := range b.dirty {
+		b.dirty[y] = span{0, b.width}
+	}
+}
+
+// merge marks the dirty and damaged columns of o dirty and damaged
+func (b *Buffer) merge(o *Buffer) {
+	for y, d := range o.dirty {
+		b.mark(y, d.lo, d.hi)
+	}
+	for y, d := range o.damaged {
+		if y < len(b.damaged) {
+			extend(&b.damaged[y], d.lo, d.hi, b.width)
+		}
+	}
+}
+
+// clean marks all the cells clean, once drawn
+func (b *Buffer) clean() {
+	for y := range b.dirty {
+		b.dirty[y] = span{}
+	}
+	for y := range b.damaged {
+		b.damaged[y] = span{}
+	}
+}
+
+// isDamaged reports whether the cell must be redrawn even if unchanged
+func (b *Buffer) isDamaged(x, y int) bool {
+	return y < len(b.damaged) && x >= b.damaged[y].lo && x < b.damaged[y].hi
+}
+
+// isDirty reports whether a cell changed since the buffer was last drawn
+func (b *Buffer) isDirty() bool {
+	for _, d := range b.dirty {
+		if d.lo < d.hi {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Buffer) in(x, y int) bool {
+	return x >= 0 && y >= 0 && x < b.width && y < b.height
+}
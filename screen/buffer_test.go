@@ -0,0 +1,175 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package screen
+
+import (
+	"strings"
+	"testing"
+)
+
+// row returns the strings of the cells of the row y, separated by "|",
+// the continuation cells being empty
+func row(b *Buffer, y int) string {
+	w, _ := b.Size()
+	s := make([]string, w)
+	for x := range s {
+		s[x] = b.Cell(x, y).String()
+	}
+	return strings.Join(s, "|")
+}
+
+func TestBufferSetString(t *testing.T) {
+	tests := []struct {
+		name string
+		x    int
+		s    string
+		n    int
+		want string
+	}{
+		{name: "ascii", s: "abc", n: 3, want: "a|b|c| | | "},
+		{name: "cjk", s: "日本", n: 4, want: "日||本|| | "},
+		{name: "emoji", s: "😀a", n: 3, want: "😀||a| | | "},
+		{name: "zwj sequence", s: "👨\u200d👩", n: 4, want: "👨\u200d||👩|| | "},
+		{name: "combining mark", s: "e\u0301x", n: 2, want: "e\u0301|x| | | | "},
+		{name: "combining mark on wide", s: "日\u0301", n: 2, want: "日\u0301|| | | | "},
+		{name: "combining mark at line start", s: "\u0301ab", n: 2, want: "a|b| | | | "},
+		{name: "control", s: "a\tb", n: 2, want: "a|b| | | | "},
+		{name: "clipped", x: 4, s: "abc", n: 2, want: " | | | |a|b"},
+		{name: "wide clipped at last column", x: 5, s: "日", n: 0, want: " | | | | | "},
+		{name: "wide clipped after narrow", x: 4, s: "a日", n: 1, want: " | | | |a| "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer(6, 1)
+			if n := b.SetString(tt.x, 0, tt.s, ""); n != tt.n {
+				t.Errorf("SetString returned %d, want %d", n, tt.n)
+			}
+			if got := row(b, 0); got != tt.want {
+				t.Errorf("row %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBufferSetCell(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(b *Buffer)
+		want string
+	}{
+		{
+			name: "wide at last column",
+			set: func(b *Buffer) {
+				b.SetCell(5, 0, Cell{Rune: '日'})
+			},
+			want: " | | | | | ",
+		},
+		{
+			name: "right half of wide at last column overwritten",
+			set: func(b *Buffer) {
+				b.SetString(4, 0, "日", "")
+				b.SetCell(5, 0, Cell{Rune: 'x'})
+			},
+			want: " | | | | |x",
+		},
+		{
+			name: "left half of wide at last column overwritten",
+			set: func(b *Buffer) {
+				b.SetString(4, 0, "日", "")
+				b.SetCell(4, 0, Cell{Rune: 'x'})
+			},
+			want: " | | | |x| ",
+		},
+		{
+			name: "wide at last column over wide",
+			set: func(b *Buffer) {
+				b.SetString(4, 0, "日", "")
+				b.SetCell(5, 0, Cell{Rune: '本'})
+			},
+			want: " | | | | | ",
+		},
+		{
+			name: "wide over two wide",
+			set: func(b *Buffer) {
+				b.SetString(0, 0, "日本", "")
+				b.SetString(1, 0, "語", "")
+			},
+			want: " |語|| | | ",
+		},
+		{
+			name: "narrow over right half",
+			set: func(b *Buffer) {
+				b.SetString(0, 0, "日", "")
+				b.SetCell(1, 0, Cell{Rune: 'x'})
+			},
+			want: " |x| | | | ",
+		},
+		{
+			name: "continuation without wide",
+			set: func(b *Buffer) {
+				b.SetCell(2, 0, Cell{Continuation: true})
+			},
+			want: " | | | | | ",
+		},
+		{
+			name: "combining mark on continuation",
+			set: func(b *Buffer) {
+				b.SetString(0, 0, "日", "")
+				b.Combine(1, 0, '\u0301')
+			},
+			want: "日\u0301|| | | | ",
+		},
+		{
+			name: "out of bounds",
+			set: func(b *Buffer) {
+				b.SetCell(6, 0, Cell{Rune: 'x'})
+				b.SetCell(-1, 0, Cell{Rune: 'x'})
+				b.Combine(6, 0, '\u0301')
+			},
+			want: " | | | | | ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer(6, 1)
+			tt.set(b)
+			if got := row(b, 0); got != tt.want {
+				t.Errorf("row %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCellWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Cell
+		want int
+	}{
+		{name: "empty", c: Cell{}, want: 1},
+		{name: "narrow", c: Cell{Rune: 'a'}, want: 1},
+		{name: "wide", c: Cell{Rune: '日'}, want: 2},
+		{name: "emoji", c: Cell{Rune: '😀', Combining: "\ufe0f"}, want: 2},
+		{name: "continuation", c: Cell{Continuation: true}, want: 0},
+		{name: "control", c: Cell{Rune: '\t'}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.Width(); got != tt.want {
+				t.Errorf("Width() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
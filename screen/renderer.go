@@ -0,0 +1,270 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package screen
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.linka.cloud/console/ansi"
+)
+
+// Renderer draws buffers on a terminal, only writing the cells that changed
+// since the previous flush.
+// When the same buffer is flushed again, only its cells changed since are
+// compared, and nothing is done if none changed.
+type Renderer struct {
+	mu   sync.Mutex
+	w    *ansi.Writer
+	prev *Buffer
+	// invalid forces the next draw to redraw the whole screen, prev
+	// being kept to reuse its cells
+	invalid bool
+	// last is the buffer flushed last, its dirty cells being the ones
+	// changed since
+	last *Buffer
+
+	// interval is the minimum interval between two draws, next being the
+	// copy of the buffer drawn at the next frame, and track whether its
+	// dirty cells can be trusted
+	interval time.Duration
+	drawn    time.Time
+	next     *Buffer
+	track    bool
+	timer    *time.Timer
+	// debounce is the delay the draws are held for after the size of the
+	// flushed buffers changed, until settle, width and height being the
+	// size of the last buffer flushed
+	debounce      time.Duration
+	settle        time.Time
+	width, height int
+	// err is the error of the last frame drawn by the timer
+	err error
+}
+
+// RendererOption configures a Renderer
+type RendererOption func(r *Renderer)
+
+// WithFrameRate caps the draws to fps per second: the buffers flushed
+// within a frame of the previous draw are drawn at the next frame, only
+// the last one being drawn.
+// It saves most of the output, and the CPU used to produce it, when a
+// buffer is flushed on each change, e.g. by a busy TUI over SSH.
+func WithFrameRate(fps int) RendererOption {
+	return func(r *Renderer) {
+		if fps > 0 {
+			r.interval = time.Second / time.Duration(fps)
+		}
+	}
+}
+
+// WithResizeDebounce holds the draws for d after the size of the buffers
+// flushed changed, e.g. term.DefaultResizeDebounce, only the last buffer
+// being drawn, so that the screen is redrawn once when a window is dragged
+// instead of at each of its steps.
+func WithResizeDebounce(d time.Duration) RendererOption {
+	return func(r *Renderer) {
+		r.debounce = d
+	}
+}
+
+// bufs pools the flush buffers across renderers
+var bufs = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 4096)
+		return &b
+	},
+}
+
+// NewRenderer returns a Renderer writing to w.
+// Flushes are wrapped in synchronized updates if w supports them.
+func NewRenderer(w *ansi.Writer, opts ...RendererOption) *Renderer {
+	r := &Renderer{w: w}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Invalidate forces the next flush to redraw the whole screen, see
+// Buffer.InvalidateRect to only redraw a part of it
+func (r *Renderer) Invalidate() {
+	r.mu.Lock()
+	r.invalid = true
+	r.last = nil
+	r.mu.Unlock()
+}
+
+// Flush draws the buffer, or copies it to be drawn at the next frame if
+// the frame rate is capped, or once the size settled if the resizes are
+// debounced. The error of a frame drawn later is returned by the next Flush.
+func (r *Renderer) Flush(b *Buffer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.err; err != nil {
+		r.err = nil
+		return err
+	}
+	track := b == r.last && b.dirty != nil
+	r.last = b
+	if track && !b.isDirty() {
+		return nil
+	}
+	if r.debounce > 0 && r.prev != nil && (b.width != r.width || b.height != r.height) {
+		r.settle = time.Now().Add(r.debounce)
+	}
+	r.width, r.height = b.width, b.height
+	wait := r.interval - time.Since(r.drawn)
+	if s := time.Until(r.settle); s > wait {
+		wait = s
+	}
+	if wait <= 0 && r.next == nil {
+		err := r.draw(b, track)
+		b.clean()
+		return err
+	}
+	if r.next == nil {
+		r.next, r.track = b.Clone(), track
+	} else {
+		r.next.Resize(b.width, b.height)
+		copy(r.next.cells, b.cells)
+		copy(r.next.wrapped, b.wrapped)
+		r.next.merge(b)
+		r.track = r.track && track
+	}
+	b.clean()
+	if r.timer == nil {
+		r.timer = time.AfterFunc(wait, r.frame)
+	}
+	return nil
+}
+
+// FlushPending draws the buffer waiting for the next frame, if any, e.g.
+// before the application exits
+func (r *Renderer) FlushPending() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	err := r.err
+	r.err = nil
+	if r.next != nil {
+		err = r.draw(r.next, r.track)
+		r.next = nil
+	}
+	return err
+}
+
+// frame draws the buffer waiting for the frame
+func (r *Renderer) frame() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timer = nil
+	if r.next == nil {
+		return
+	}
+	// the size changed again since the timer was set
+	if wait := time.Until(r.settle); wait > 0 {
+		r.timer = time.AfterFunc(wait, r.frame)
+		return
+	}
+	if err := r.draw(r.next, r.track); err != nil {
+		r.err = err
+	}
+	r.next = nil
+}
+
+// draw writes the cells of b differing from the previous buffer drawn,
+// only comparing the dirty cells if track is true
+func (r *Renderer) draw(b *Buffer, track bool) error {
+	r.drawn = time.Now()
+	bp := bufs.Get().(*[]byte)
+	buf := (*bp)[:0]
+	defer func() {
+		*bp = buf[:0]
+		bufs.Put(bp)
+	}()
+	full := r.prev == nil || r.invalid || r.prev.width != b.width || r.prev.height != b.height
+	track = track && !full
+	if full {
+		buf = append(buf, ansi.ResetStyle+ansi.EraseScreen...)
+	}
+	style := ""
+	cx, cy := -1, -1
+	for y := 0; y < b.height; y++ {
+		lo, hi := 0, b.width
+		if track {
+			lo, hi = b.dirty[y].lo, b.dirty[y].hi
+		}
+		for x := lo; x < hi; x++ {
+			c := b.cells[y*b.width+x]
+			// the continuation cells are drawn with their wide character
+			if c.Continuation || !full && r.prev.cells[y*b.width+x] == c && !b.isDamaged(x, y) {
+				continue
+			}
+			if cx != x || cy != y {
+				buf = ansi.AppendCursorPosition(buf, y, x)
+			}
+			if c.Style != style {
+				buf = append(buf, ansi.ResetStyle...)
+				if c.Style != "" {
+					buf = ansi.AppendSGR(buf, c.Style)
+				}
+				style = c.Style
+			}
+			buf = appendRune(buf, c.rune())
+			buf = append(buf, c.Combining...)
+			cx, cy = x+c.Width(), y
+		}
+	}
+	if style != "" {
+		buf = append(buf, ansi.ResetStyle...)
+	}
+	switch {
+	case r.prev == nil:
+		r.prev = b.Clone()
+	case track:
+		for y, d := range b.dirty {
+			if d.lo < d.hi {
+				copy(r.prev.cells[y*b.width+d.lo:y*b.width+d.hi], b.cells[y*b.width+d.lo:y*b.width+d.hi])
+			}
+		}
+	default:
+		r.prev.width, r.prev.height = b.width, b.height
+		r.prev.cells = append(r.prev.cells[:0], b.cells...)
+	}
+	r.invalid = false
+	if len(buf) == 0 {
+		return nil
+	}
+	if err := r.w.BeginSync(); err != nil {
+		return err
+	}
+	if _, err := r.w.Write(buf); err != nil {
+		return err
+	}
+	return r.w.EndSync()
+}
+
+func appendRune(b []byte, r rune) []byte {
+	if r < utf8.RuneSelf {
+		return append(b, byte(r))
+	}
+	var e [utf8.UTFMax]byte
+	return append(b, e[:utf8.EncodeRune(e[:], r)]...)
+}
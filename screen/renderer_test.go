@@ -0,0 +1,139 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package screen
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"go.linka.cloud/console/ansi"
+)
+
+func BenchmarkRendererFlush(b *testing.B) {
+	const width, height = 120, 40
+	fill := func(buf *Buffer, i int) {
+		for y := 0; y < height; y++ {
+			style := ""
+			if (y+i)%3 == 0 {
+				style = "1;32"
+			}
+			buf.SetString(0, y, "The quick brown fox jumps over the lazy dog, 日本語のテキスト ", style)
+		}
+	}
+	b.Run("full", func(b *testing.B) {
+		r := NewRenderer(ansi.NewWriter(ioutil.Discard))
+		buf := NewBuffer(width, height)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			fill(buf, i)
+			r.Invalidate()
+			if err := r.Flush(buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("line", func(b *testing.B) {
+		r := NewRenderer(ansi.NewWriter(ioutil.Discard))
+		buf := NewBuffer(width, height)
+		fill(buf, 0)
+		r.Flush(buf)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			buf.SetString(0, i%height, "line changed", "")
+			if err := r.Flush(buf); err != nil {
+				b.Fatal(err)
+			}
+			buf.SetString(0, i%height, "The quick b", "")
+		}
+	})
+	b.Run("unchanged", func(b *testing.B) {
+		r := NewRenderer(ansi.NewWriter(ioutil.Discard))
+		buf := NewBuffer(width, height)
+		fill(buf, 0)
+		r.Flush(buf)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := r.Flush(buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// countWriter counts the draws written to it
+type countWriter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.n++
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func (w *countWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n
+}
+
+func TestRendererResizeDebounce(t *testing.T) {
+	w := &countWriter{}
+	r := NewRenderer(ansi.NewWriter(w), WithResizeDebounce(50*time.Millisecond))
+	b := NewBuffer(10, 2)
+	b.SetString(0, 0, "hello", "")
+	if err := r.Flush(b); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.count(); n != 1 {
+		t.Fatalf("draws %d, want 1", n)
+	}
+	for i := 1; i <= 10; i++ {
+		b.Resize(10+i, 2)
+		b.SetString(0, 0, "hello", "")
+		if err := r.Flush(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := w.count(); n != 1 {
+		t.Fatalf("draws %d while resizing, want 1", n)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for w.count() == 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("resized buffer not drawn")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := w.count(); n != 2 {
+		t.Fatalf("draws %d, want 2", n)
+	}
+	// the flushes at the same size are not held
+	b.SetString(0, 1, "world", "")
+	if err := r.Flush(b); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.count(); n != 3 {
+		t.Fatalf("draws %d, want 3", n)
+	}
+}
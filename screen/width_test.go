@@ -0,0 +1,108 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package screen
+
+import (
+	"testing"
+)
+
+func TestRuneWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		r    rune
+		want int
+	}{
+		{name: "ascii", r: 'a', want: 1},
+		{name: "tab", r: '\t', want: 0},
+		{name: "delete", r: '\x7f', want: 0},
+		{name: "c1 control", r: '\u0085', want: 0},
+		{name: "latin", r: 'é', want: 1},
+		{name: "box drawing", r: '│', want: 1},
+		{name: "combining acute", r: '\u0301', want: 0},
+		{name: "enclosing circle", r: '\u20dd', want: 0},
+		{name: "cjk", r: '日', want: 2},
+		{name: "hiragana", r: 'あ', want: 2},
+		{name: "hangul syllable", r: '한', want: 2},
+		{name: "hangul medial vowel", r: '\u1161', want: 0},
+		{name: "fullwidth", r: 'Ａ', want: 2},
+		{name: "halfwidth katakana", r: 'ｱ', want: 1},
+		{name: "cjk extension b", r: '\U00020000', want: 2},
+		{name: "emoji", r: '😀', want: 2},
+		{name: "emoji in bmp", r: '⌚', want: 2},
+		{name: "text presentation symbol", r: '☺', want: 1},
+		{name: "skin tone modifier", r: '\U0001f3fd', want: 2},
+		{name: "zero width joiner", r: '\u200d', want: 0},
+		{name: "zero width space", r: '\u200b', want: 0},
+		{name: "variation selector", r: '\ufe0f', want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RuneWidth(tt.r); got != tt.want {
+				t.Errorf("RuneWidth(%U) = %d, want %d", tt.r, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{name: "empty", s: "", want: 0},
+		{name: "ascii", s: "hello", want: 5},
+		{name: "cjk", s: "日本語", want: 6},
+		{name: "mixed", s: "a日b", want: 4},
+		{name: "precomposed", s: "é", want: 1},
+		{name: "decomposed", s: "e\u0301", want: 1},
+		{name: "leading combining mark", s: "\u0301a", want: 1},
+		{name: "hangul jamo", s: "\u1100\u1161\u11a8", want: 2},
+		{name: "emoji presentation", s: "❤\ufe0f", want: 1},
+		// the terminals following wcwidth draw each member of a ZWJ
+		// sequence, and each emoji modifier, on their own cells
+		{name: "zwj sequence", s: "👨\u200d👩\u200d👧", want: 6},
+		{name: "skin tone", s: "👍\U0001f3fd", want: 4},
+		{name: "flag", s: "\U0001f1eb\U0001f1f7", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StringWidth(tt.s); got != tt.want {
+				t.Errorf("StringWidth(%q) = %d, want %d", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibleWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{name: "plain", s: "hello", want: 5},
+		{name: "styled", s: "\x1b[1;31m日本\x1b[0m", want: 4},
+		{name: "hyperlink", s: "\x1b]8;;https://linka.cloud\x07link\x1b]8;;\x07", want: 4},
+		{name: "cursor movement", s: "a\x1b[2Cb", want: 2},
+		{name: "controls", s: "a\r\nb", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VisibleWidth(tt.s); got != tt.want {
+				t.Errorf("VisibleWidth(%q) = %d, want %d", tt.s, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"os"
+	"strconv"
+)
+
+// SizeSource is where a size comes from
+type SizeSource int
+
+const (
+	// SizeFromConsole is the size of the console
+	SizeFromConsole SizeSource = iota
+	// SizeFromEnv is the size set by the COLUMNS and LINES environment
+	// variables, e.g. by the shells
+	SizeFromEnv
+	// SizeFromTerminfo is the default size of the terminfo entry of $TERM
+	SizeFromTerminfo
+	// SizeFromDefault is DefaultSize
+	SizeFromDefault
+)
+
+func (s SizeSource) String() string {
+	switch s {
+	case SizeFromConsole:
+		return "console"
+	case SizeFromEnv:
+		return "env"
+	case SizeFromTerminfo:
+		return "terminfo"
+	case SizeFromDefault:
+		return "default"
+	default:
+		return "unknown"
+	}
+}
+
+// DefaultSize is the size used when no other is known
+var DefaultSize = WinSize{Width: 80, Height: 24}
+
+// SizeWithFallback returns the size of the console of f, falling back,
+// e.g. in pipelines or containers without a tty, to the COLUMNS and LINES
+// environment variables, the default size of the $TERM terminfo entry and
+// DefaultSize. f may be nil.
+// The width and height fall back independently, the source returned being
+// the one of the width. It is intended for the code only needing an
+// approximate width, e.g. to wrap text.
+func SizeWithFallback(f *os.File) (WinSize, SizeSource) {
+	var ws WinSize
+	src := SizeFromDefault
+	use := func(cols, lines int, s SizeSource) {
+		if ws.Width == 0 && cols > 0 && cols <= 0xffff {
+			ws.Width, src = uint16(cols), s
+		}
+		if ws.Height == 0 && lines > 0 && lines <= 0xffff {
+			ws.Height = uint16(lines)
+		}
+	}
+	if f != nil {
+		if c, err := FromFile(f); err == nil {
+			if cws, err := c.Size(); err == nil {
+				ws = cws
+			}
+		}
+		if ws.Width != 0 {
+			src = SizeFromConsole
+		}
+	}
+	use(atoi(os.Getenv("COLUMNS")), atoi(os.Getenv("LINES")), SizeFromEnv)
+	if ws.Width == 0 || ws.Height == 0 {
+		cols, lines := terminfoSize(os.Getenv("TERM"))
+		use(cols, lines, SizeFromTerminfo)
+	}
+	use(int(DefaultSize.Width), int(DefaultSize.Height), SizeFromDefault)
+	return ws, src
+}
+
+func atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+//go:build !windows && !js && !plan9 && !wasip1 && !dragonfly
+// +build !windows,!js,!plan9,!wasip1,!dragonfly
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"sync"
+
+	"github.com/moby/term"
+)
+
+// states are the original states of the terminals put in raw mode or with
+// echo disabled, by file descriptor: the consoles on the same descriptor
+// share it, and the last one reset restores it
+var states = struct {
+	sync.Mutex
+	m map[uintptr]*sharedState
+}{m: make(map[uintptr]*sharedState)}
+
+type sharedState struct {
+	state *term.State
+	refs  int
+}
+
+// acquire saves the original state of the console terminal, or takes a
+// reference on the one saved by another console on the same descriptor,
+// c.mu must be held
+func (c *console) acquire() error {
+	if c.shared != nil {
+		return nil
+	}
+	fd := c.f.Fd()
+	states.Lock()
+	defer states.Unlock()
+	s, ok := states.m[fd]
+	if !ok {
+		st, err := term.SaveState(fd)
+		if err != nil {
+			return err
+		}
+		s = &sharedState{state: st}
+		states.m[fd] = s
+	}
+	s.refs++
+	c.shared, c.state = s, s.state
+	return nil
+}
+
+// release drops the console reference on the original state and reports
+// whether it was the last one, c.mu must be held
+func (c *console) release(fd uintptr) bool {
+	if c.shared == nil {
+		return false
+	}
+	states.Lock()
+	defer states.Unlock()
+	s := c.shared
+	c.shared, c.state = nil, nil
+	if s.refs--; s.refs > 0 {
+		return false
+	}
+	if states.m[fd] == s {
+		delete(states.m, fd)
+	}
+	return true
+}
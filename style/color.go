@@ -0,0 +1,163 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package style
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.linka.cloud/console/caps"
+	"go.linka.cloud/console/palette"
+)
+
+type colorKind uint8
+
+const (
+	kindNone colorKind = iota
+	kindBasic
+	kindIndexed
+	kindRGB
+)
+
+// Color is a terminal color. The zero value is the terminal default color.
+type Color struct {
+	kind    colorKind
+	r, g, b uint8
+	index   uint8
+}
+
+// Basic returns one of the 16 ANSI colors, 8 to 15 being the bright ones
+func Basic(i uint8) Color {
+	return Color{kind: kindBasic, index: i % 16}
+}
+
+// Indexed returns a color of the 256 colors palette
+func Indexed(i uint8) Color {
+	return Color{kind: kindIndexed, index: i}
+}
+
+// RGB returns a 24 bits color
+func RGB(r, g, b uint8) Color {
+	return Color{kind: kindRGB, r: r, g: g, b: b}
+}
+
+// IsDefault reports whether the color is the terminal default color
+func (c Color) IsDefault() bool {
+	return c.kind == kindNone
+}
+
+var (
+	Black         = Basic(0)
+	Red           = Basic(1)
+	Green         = Basic(2)
+	Yellow        = Basic(3)
+	Blue          = Basic(4)
+	Magenta       = Basic(5)
+	Cyan          = Basic(6)
+	White         = Basic(7)
+	BrightBlack   = Basic(8)
+	BrightRed     = Basic(9)
+	BrightGreen   = Basic(10)
+	BrightYellow  = Basic(11)
+	BrightBlue    = Basic(12)
+	BrightMagenta = Basic(13)
+	BrightCyan    = Basic(14)
+	BrightWhite   = Basic(15)
+)
+
+// required returns the minimal profile able to render the color
+func (c Color) required() caps.ColorProfile {
+	switch c.kind {
+	case kindBasic:
+		return caps.ANSI
+	case kindIndexed:
+		return caps.ANSI256
+	case kindRGB:
+		return caps.TrueColor
+	default:
+		return caps.NoColor
+	}
+}
+
+// Downsample returns the closest color the profile can render
+func (c Color) Downsample(p caps.ColorProfile) Color {
+	if c.required() <= p {
+		return c
+	}
+	switch p {
+	case caps.NoColor:
+		return Color{}
+	case caps.ANSI256:
+		return Indexed(palette.Nearest256(palette.RGB{R: c.r, G: c.g, B: c.b}))
+	}
+	if c.kind == kindIndexed {
+		return Basic(palette.Downsample256(c.index))
+	}
+	return Basic(palette.Nearest16(palette.RGB{R: c.r, G: c.g, B: c.b}))
+}
+
+// Hex parses a "#rrggbb" or "#rgb" color
+func Hex(s string) (Color, error) {
+	if len(s) > 0 && s[0] == '#' {
+		s = s[1:]
+	}
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return Color{}, fmt.Errorf("invalid hex color %q", s)
+	}
+	return RGB(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+}
+
+// MustHex is like Hex but panics if the color is invalid
+func MustHex(s string) Color {
+	c, err := Hex(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// sgr returns the SGR parameters of the color as foreground or background,
+// downsampled to the profile
+func (c Color) sgr(p caps.ColorProfile, bg bool) string {
+	c = c.Downsample(p)
+	switch c.kind {
+	case kindNone:
+		return ""
+	case kindBasic:
+		base := 30
+		if c.index >= 8 {
+			base = 90
+		}
+		if bg {
+			base += 10
+		}
+		return strconv.Itoa(base + int(c.index%8))
+	case kindIndexed:
+		if bg {
+			return "48;5;" + strconv.Itoa(int(c.index))
+		}
+		return "38;5;" + strconv.Itoa(int(c.index))
+	default:
+		pre := "38;2;"
+		if bg {
+			pre = "48;2;"
+		}
+		return pre + strconv.Itoa(int(c.r)) + ";" + strconv.Itoa(int(c.g)) + ";" + strconv.Itoa(int(c.b))
+	}
+}
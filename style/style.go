@@ -0,0 +1,146 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package style
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/caps"
+)
+
+// Style describes how text is rendered
+type Style struct {
+	Fg        Color
+	Bg        Color
+	Bold      bool
+	Dim       bool
+	Italic    bool
+	Underline bool
+	Blink     bool
+	Reverse   bool
+	Strike    bool
+}
+
+// New returns an empty Style
+func New() Style {
+	return Style{}
+}
+
+func (s Style) Foreground(c Color) Style {
+	s.Fg = c
+	return s
+}
+
+func (s Style) Background(c Color) Style {
+	s.Bg = c
+	return s
+}
+
+func (s Style) WithBold() Style {
+	s.Bold = true
+	return s
+}
+
+func (s Style) WithDim() Style {
+	s.Dim = true
+	return s
+}
+
+func (s Style) WithItalic() Style {
+	s.Italic = true
+	return s
+}
+
+func (s Style) WithUnderline() Style {
+	s.Underline = true
+	return s
+}
+
+func (s Style) WithReverse() Style {
+	s.Reverse = true
+	return s
+}
+
+// SGR returns the Select Graphic Rendition parameters of the style for
+// the profile, e.g. "1;31".
+// With caps.NoColor, the colors are dropped but the text attributes are kept,
+// as mandated by NO_COLOR.
+func (s Style) SGR(p caps.ColorProfile) string {
+	var params []string
+	for _, v := range []struct {
+		set bool
+		p   string
+	}{
+		{s.Bold, "1"},
+		{s.Dim, "2"},
+		{s.Italic, "3"},
+		{s.Underline, "4"},
+		{s.Blink, "5"},
+		{s.Reverse, "7"},
+		{s.Strike, "9"},
+	} {
+		if v.set {
+			params = append(params, v.p)
+		}
+	}
+	if v := s.Fg.sgr(p, false); v != "" {
+		params = append(params, v)
+	}
+	if v := s.Bg.sgr(p, true); v != "" {
+		params = append(params, v)
+	}
+	return strings.Join(params, ";")
+}
+
+// RenderWith returns the text styled for the profile
+func (s Style) RenderWith(p caps.ColorProfile, text string) string {
+	sgr := s.SGR(p)
+	if sgr == "" {
+		return text
+	}
+	return ansi.SGR(sgr) + text + ansi.ResetStyle
+}
+
+// Render returns the text styled for the default profile
+func (s Style) Render(text string) string {
+	return s.RenderWith(Profile(), text)
+}
+
+var (
+	mu      sync.RWMutex
+	profile *caps.ColorProfile
+)
+
+// Profile returns the color profile used by Render, detected from
+// os.Stdout and the environment unless set with SetProfile
+func Profile() caps.ColorProfile {
+	mu.RLock()
+	p := profile
+	mu.RUnlock()
+	if p != nil {
+		return *p
+	}
+	return caps.DetectFor(os.Stdout).Color
+}
+
+// SetProfile sets the color profile used by Render
+func SetProfile(p caps.ColorProfile) {
+	mu.Lock()
+	defer mu.Unlock()
+	profile = &p
+}
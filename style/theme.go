@@ -0,0 +1,117 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package style
+
+import (
+	"sync"
+)
+
+// Role is a semantic role of styled text
+type Role string
+
+const (
+	RoleText    Role = "text"
+	RoleMuted   Role = "muted"
+	RoleAccent  Role = "accent"
+	RoleSuccess Role = "success"
+	RoleWarning Role = "warning"
+	RoleError   Role = "error"
+	RoleInfo    Role = "info"
+)
+
+// Theme maps semantic roles to styles
+type Theme struct {
+	Name string
+	// Dark reports whether the theme is meant for dark backgrounds
+	Dark  bool
+	Roles map[Role]Style
+}
+
+// Style returns the style of the role, or an empty style if the theme
+// does not define it
+func (t Theme) Style(r Role) Style {
+	return t.Roles[r]
+}
+
+// Render renders the text with the style of the role
+func (t Theme) Render(r Role, text string) string {
+	return t.Style(r).Render(text)
+}
+
+var (
+	Dark = Theme{
+		Name: "dark",
+		Dark: true,
+		Roles: map[Role]Style{
+			RoleText:    {},
+			RoleMuted:   {Fg: MustHex("#808080")},
+			RoleAccent:  {Fg: MustHex("#5fafff"), Bold: true},
+			RoleSuccess: {Fg: MustHex("#5fd75f")},
+			RoleWarning: {Fg: MustHex("#ffd75f")},
+			RoleError:   {Fg: MustHex("#ff5f5f"), Bold: true},
+			RoleInfo:    {Fg: MustHex("#87d7ff")},
+		},
+	}
+	Light = Theme{
+		Name: "light",
+		Roles: map[Role]Style{
+			RoleText:    {},
+			RoleMuted:   {Fg: MustHex("#6c6c6c")},
+			RoleAccent:  {Fg: MustHex("#005fd7"), Bold: true},
+			RoleSuccess: {Fg: MustHex("#008700")},
+			RoleWarning: {Fg: MustHex("#af5f00")},
+			RoleError:   {Fg: MustHex("#d70000"), Bold: true},
+			RoleInfo:    {Fg: MustHex("#0087af")},
+		},
+	}
+)
+
+var (
+	themesMu sync.RWMutex
+	themes   = map[string]Theme{
+		Dark.Name:  Dark,
+		Light.Name: Light,
+	}
+	current = Dark
+)
+
+// RegisterTheme makes a theme available by name
+func RegisterTheme(t Theme) {
+	themesMu.Lock()
+	defer themesMu.Unlock()
+	themes[t.Name] = t
+}
+
+// LookupTheme returns a registered theme
+func LookupTheme(name string) (Theme, bool) {
+	themesMu.RLock()
+	defer themesMu.RUnlock()
+	t, ok := themes[name]
+	return t, ok
+}
+
+// SetTheme sets the theme returned by CurrentTheme
+func SetTheme(t Theme) {
+	themesMu.Lock()
+	defer themesMu.Unlock()
+	current = t
+}
+
+// CurrentTheme returns the application theme, Dark by default
+func CurrentTheme() Theme {
+	themesMu.RLock()
+	defer themesMu.RUnlock()
+	return current
+}
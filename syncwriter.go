@@ -0,0 +1,86 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// SyncWriter serializes the writes to the underlying writer, so that the
+// output of concurrent writers, including their escape sequences, is never
+// interleaved within a single Write call
+type SyncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewSyncWriter returns a SyncWriter writing to w
+func NewSyncWriter(w io.Writer) *SyncWriter {
+	return &SyncWriter{w: w}
+}
+
+func (s *SyncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// Line returns a writer coalescing the partial lines: the data is buffered
+// until a newline is written, and the complete lines are written at once.
+// Each goroutine should use its own line writer, so that partial lines
+// written by different goroutines are not mixed.
+func (s *SyncWriter) Line() *LineWriter {
+	return &LineWriter{s: s}
+}
+
+// LineWriter is a line buffered writer returned by SyncWriter.Line
+type LineWriter struct {
+	s   *SyncWriter
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (l *LineWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := bytes.LastIndexByte(p, '\n')
+	if i < 0 {
+		l.buf = append(l.buf, p...)
+		return len(p), nil
+	}
+	b := p[:i+1]
+	if len(l.buf) > 0 {
+		b = append(l.buf, b...)
+	}
+	if _, err := l.s.Write(b); err != nil {
+		return 0, err
+	}
+	l.buf = append(l.buf[:0], p[i+1:]...)
+	return len(p), nil
+}
+
+// Flush writes the buffered partial line, if any
+func (l *LineWriter) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) == 0 {
+		return nil
+	}
+	_, err := l.s.Write(l.buf)
+	l.buf = l.buf[:0]
+	return err
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/pty"
+)
+
+type attachOptions struct {
+	pty     []pty.Option
+	signals []os.Signal
+	detach  os.Signal
+}
+
+// AttachOption configures Attach
+type AttachOption func(o *attachOptions)
+
+// WithPTY sets the options of the command PTY, e.g. pty.WithEnv
+func WithPTY(opts ...pty.Option) AttachOption {
+	return func(o *attachOptions) {
+		o.pty = append(o.pty, opts...)
+	}
+}
+
+// WithForwardSignals sets the signals received by the process which are
+// forwarded to the command, defaults to SIGINT, SIGQUIT and SIGTSTP.
+// Without signals, the forwarding is disabled.
+// While the command runs, the signals no longer stop the process, but they
+// are still delivered to the channels registered with signal.Notify.
+func WithForwardSignals(sigs ...os.Signal) AttachOption {
+	return func(o *attachOptions) {
+		o.signals = sigs
+	}
+}
+
+// WithDetachSignal sets the signal sent to the command when the Term is
+// detached, defaults to SIGHUP. With nil, the command only sees its PTY
+// being closed.
+func WithDetachSignal(sig os.Signal) AttachOption {
+	return func(o *attachOptions) {
+		o.detach = sig
+	}
+}
+
+// drainTimeout is how long the command output is still copied after it
+// exited, as its children may keep the PTY open
+const drainTimeout = 100 * time.Millisecond
+
+// ExitStatus describes how an attached command ended
+type ExitStatus struct {
+	// Code is the exit code, -1 if the command was killed by a signal
+	Code int
+	// Signal is the signal which killed the command, if any
+	Signal os.Signal
+}
+
+// Process is a command attached to a Term
+type Process struct {
+	cmd  *exec.Cmd
+	pty  console.Console
+	done chan struct{}
+	err  error
+}
+
+// Attach starts cmd on a new PTY, see pty.Start, connected to the Term:
+// the Term input is written to the PTY, the PTY output to the Term, and the
+// PTY is resized with the Term.
+// The signals forwarded are sent to the foreground process group of the PTY,
+// and so is the detach signal when the Term is detached.
+// When the command exits, the Term is closed, restoring the console, even if
+// the PTY is still open, e.g. by a background child. When the Term is closed
+// or ctx is cancelled, the PTY is closed, hanging up the command.
+func Attach(ctx context.Context, t Term, cmd *exec.Cmd, opts ...AttachOption) (*Process, error) {
+	o := attachOptions{signals: forwardSignals, detach: detachSignal}
+	for _, v := range opts {
+		v(&o)
+	}
+	sz := t.Size()
+	popts := append([]pty.Option{pty.WithSize(sz.WinSize())}, o.pty...)
+	c, err := pty.Start(cmd, popts...)
+	if err != nil {
+		return nil, err
+	}
+	p := &Process{cmd: cmd, pty: c, done: make(chan struct{})}
+	sigs := make(chan os.Signal, 1)
+	if len(o.signals) > 0 {
+		signal.Notify(sigs, o.signals...)
+	}
+	go p.run(ctx, t, sigs, o)
+	return p, nil
+}
+
+func (p *Process) run(ctx context.Context, t Term, sigs chan os.Signal, o attachOptions) {
+	defer close(p.done)
+	defer signal.Stop(sigs)
+	defer p.pty.Close()
+	exited := make(chan struct{})
+	go func() {
+		// the status is read from the ProcessState
+		p.cmd.Wait()
+		close(exited)
+	}()
+	go io.Copy(p.pty, t)
+	drained := make(chan struct{})
+	go func() {
+		// the read fails once the command and its children closed the PTY
+		io.Copy(t, p.pty)
+		close(drained)
+	}()
+	sizes := t.WatchSize()
+	for {
+		select {
+		case sig := <-sigs:
+			signalGroup(p.pty, p.Pid(), sig)
+		case sz, ok := <-sizes:
+			if !ok {
+				sizes = nil
+				continue
+			}
+			p.pty.Resize(sz.WinSize())
+		case <-exited:
+			select {
+			case <-drained:
+			case <-time.After(drainTimeout):
+			}
+			t.Close()
+			return
+		case <-t.Done():
+			if t.Reason() == ReasonDetached && o.detach != nil {
+				signalGroup(p.pty, p.Pid(), o.detach)
+			}
+			p.err = t.Wait()
+			// closing the PTY hangs up the command session
+			p.pty.Close()
+			<-exited
+			return
+		case <-ctx.Done():
+			p.err = ctx.Err()
+			t.Close()
+			p.pty.Close()
+			<-exited
+			return
+		}
+	}
+}
+
+// Pid returns the command process id
+func (p *Process) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// Signal sends sig to the foreground process group of the command PTY
+func (p *Process) Signal(sig os.Signal) error {
+	return signalGroup(p.pty, p.Pid(), sig)
+}
+
+// Done returns a channel closed when the command exited and the Term was
+// closed
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the command to exit and returns its status, with
+// ErrDetached if the Term was detached, the Term error if it was closed,
+// or the context error
+func (p *Process) Wait() (ExitStatus, error) {
+	<-p.done
+	st := p.cmd.ProcessState
+	if st == nil {
+		return ExitStatus{Code: -1}, p.err
+	}
+	return ExitStatus{Code: st.ExitCode(), Signal: exitSignal(st)}, p.err
+}
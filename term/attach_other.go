@@ -0,0 +1,38 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"os"
+
+	"go.linka.cloud/console"
+)
+
+var (
+	forwardSignals   []os.Signal
+	detachSignal     os.Signal
+	interruptSignals = []os.Signal{os.Interrupt}
+)
+
+func signalGroup(c console.Console, pid int, sig os.Signal) error {
+	return console.ErrUnsupported
+}
+
+func exitSignal(st *os.ProcessState) os.Signal {
+	return nil
+}
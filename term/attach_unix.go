@@ -0,0 +1,56 @@
+//go:build linux || darwin
+// +build linux darwin
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"go.linka.cloud/console"
+)
+
+var (
+	forwardSignals           = []os.Signal{syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP}
+	detachSignal   os.Signal = syscall.SIGHUP
+	// interruptSignals are ignored while RunInteractive runs a command
+	interruptSignals = []os.Signal{syscall.SIGINT, syscall.SIGQUIT}
+)
+
+// signalGroup sends sig to the foreground process group of the PTY,
+// or to the process group of pid if it is unknown
+func signalGroup(c console.Console, pid int, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return console.ErrUnsupported
+	}
+	pgid, err := unix.IoctlGetInt(int(c.Fd()), unix.TIOCGPGRP)
+	if err != nil || pgid <= 0 {
+		pgid = pid
+	}
+	return unix.Kill(-pgid, s)
+}
+
+// exitSignal returns the signal which killed the process, if any
+func exitSignal(st *os.ProcessState) os.Signal {
+	if ws, ok := st.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal()
+	}
+	return nil
+}
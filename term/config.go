@@ -0,0 +1,324 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/caps"
+	"go.linka.cloud/console/input"
+	"go.linka.cloud/console/keymap"
+	"go.linka.cloud/console/vt"
+)
+
+// ErrColorProfileOwned is returned by Apply when the color profile is
+// already overridden by another Term
+var ErrColorProfileOwned = errors.New("color profile overridden by another Term")
+
+// colorOwner is the Term overriding the color profile, which is process
+// wide, guarded by colorMu
+var (
+	colorMu    sync.Mutex
+	colorOwner *terminal
+)
+
+// Config holds the Term settings which can be loaded from the environment
+// or a file, and changed while the Term is running
+type Config struct {
+	// EscapeSequence is either a single key detaching the Term
+	// (e.g. "ctrl+]"), or a two characters SSH-style escape sequence
+	// (e.g. "~.")
+	EscapeSequence string `json:"escape_sequence,omitempty"`
+	// ColorProfile overrides the detected color profile, see
+	// caps.OverrideColor, until it is unset or the Term is closed.
+	// The profile is process wide: a single Term can override it at a
+	// time, the others failing with ErrColorProfileOwned.
+	ColorProfile string `json:"color_profile,omitempty"`
+	// Mouse enables mouse reporting
+	Mouse bool `json:"mouse,omitempty"`
+	// Scrollback is the number of lines kept by the components
+	// maintaining a scrollback, see VTOptions, 0 keeping their default.
+	// It is read when they are created.
+	Scrollback int `json:"scrollback,omitempty"`
+}
+
+const (
+	EnvEscape       = "CONSOLE_ESCAPE"
+	EnvColorProfile = "CONSOLE_COLOR_PROFILE"
+	EnvMouse        = "CONSOLE_MOUSE"
+	EnvScrollback   = "CONSOLE_SCROLLBACK"
+)
+
+// ConfigFromEnv returns the configuration set in the environment
+func ConfigFromEnv() (Config, error) {
+	var c Config
+	return c, c.fromEnv(os.Getenv)
+}
+
+func (c *Config) fromEnv(getenv func(string) string) error {
+	if v := getenv(EnvEscape); v != "" {
+		c.EscapeSequence = v
+	}
+	if v := getenv(EnvColorProfile); v != "" {
+		c.ColorProfile = v
+	}
+	if v := getenv(EnvMouse); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvMouse, err)
+		}
+		c.Mouse = b
+	}
+	if v := getenv(EnvScrollback); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvScrollback, err)
+		}
+		c.Scrollback = n
+	}
+	return nil
+}
+
+// LoadConfig reads the JSON configuration file, the environment variables
+// taking precedence over the file
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, c.fromEnv(os.Getenv)
+}
+
+// WatchConfig polls the configuration file and applies it to the Terms each
+// time it changes, until the context is cancelled.
+// Invalid configurations are reported to onError, if not nil, and ignored,
+// and the errors applying the configuration to a Term are reported without
+// preventing it from being applied to the others.
+func WatchConfig(ctx context.Context, path string, interval time.Duration, onError func(error), terms ...Term) {
+	if onError == nil {
+		onError = func(error) {}
+	}
+	var last time.Time
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if fi, err := os.Stat(path); err == nil && fi.ModTime() != last {
+			last = fi.ModTime()
+			if c, err := LoadConfig(path); err != nil {
+				onError(err)
+			} else {
+				for _, v := range terms {
+					if err := v.Apply(c); err != nil {
+						onError(err)
+					}
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// EscapeHandler returns the EscapeHandler matching the escape sequence,
+// or nil if none is set. A single key is forwarded when it detaches the Term,
+// see ExitRuneHandler.
+func (c Config) EscapeHandler() (EscapeHandler, error) {
+	return c.escapeHandler(false)
+}
+
+// escapeHandler returns the EscapeHandler matching the escape sequence,
+// swallowing the single key detaching the Term if swallow is true
+func (c Config) escapeHandler(swallow bool) (EscapeHandler, error) {
+	switch s := c.EscapeSequence; {
+	case s == "":
+		return nil, nil
+	case len(s) == 2 && s[0] < utf8.RuneSelf && s[1] < utf8.RuneSelf:
+		return &SSHEscape{Char: s[0], Detach: s[1]}, nil
+	default:
+		k, err := keymap.ParseKey(s)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := keyRune(k)
+		if !ok {
+			return nil, fmt.Errorf("invalid escape sequence %q: must be a character or a control character", s)
+		}
+		return ExitRuneHandler(r, swallow), nil
+	}
+}
+
+// keyRune returns the rune sent by the terminal for the key
+func keyRune(k input.KeyEvent) (rune, bool) {
+	switch {
+	case k.Key == input.KeyEscape && k.Mod == 0:
+		return 0x1b, true
+	case k.Key != input.KeyRune:
+		return 0, false
+	case k.Mod == 0:
+		return k.Rune, true
+	case k.Mod != input.ModCtrl:
+		return 0, false
+	case k.Rune == ' ':
+		return 0, true
+	case k.Rune >= 'a' && k.Rune <= 'z':
+		return k.Rune - 'a' + 1, true
+	case k.Rune >= '\\' && k.Rune <= '_':
+		return k.Rune - '\\' + 0x1c, true
+	}
+	return 0, false
+}
+
+// Color returns the color profile override, if set
+func (c Config) Color() (caps.ColorProfile, bool, error) {
+	if c.ColorProfile == "" {
+		return 0, false, nil
+	}
+	p, err := caps.ParseColorProfile(c.ColorProfile)
+	if err != nil {
+		return 0, false, err
+	}
+	return p, true, nil
+}
+
+// VTOptions returns the options of the vt.Terminal emulating the Term
+// screen, e.g. the intercept and export ones, matching the configuration
+func (c Config) VTOptions() []vt.Option {
+	var opts []vt.Option
+	if c.Scrollback > 0 {
+		opts = append(opts, vt.WithScrollback(c.Scrollback))
+	}
+	return opts
+}
+
+// WithConfig applies the configuration when the Term is created
+func WithConfig(c Config) Option {
+	return func(o *options) {
+		o.config = &c
+	}
+}
+
+// swapHandler is an EscapeHandler which can be replaced at runtime
+type swapHandler struct {
+	mu sync.Mutex
+	h  EscapeHandler
+}
+
+func (s *swapHandler) Handle(p []byte) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.h.Handle(p)
+}
+
+func (s *swapHandler) set(h EscapeHandler) {
+	s.mu.Lock()
+	s.h = h
+	s.mu.Unlock()
+}
+
+func (s *terminal) Config() Config {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+	return s.config
+}
+
+func (s *terminal) Apply(c Config) error {
+	h, err := c.escapeHandler(s.opts.swallowDetach)
+	if err != nil {
+		return err
+	}
+	color, override, err := c.Color()
+	if err != nil {
+		return err
+	}
+	if h == nil {
+		h = s.opts.escape
+	}
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+	if override && !s.claimColor() {
+		return ErrColorProfileOwned
+	}
+	defer func() {
+		if s.color == nil {
+			s.releaseColor()
+		}
+	}()
+	if c.Mouse && s.mouse == nil {
+		if _, err := io.WriteString(s, ansi.EnableMouse); err != nil {
+			return err
+		}
+		s.mouse = console.OnExit(func() error {
+			_, err := io.WriteString(s, ansi.DisableMouse)
+			return err
+		})
+	} else if !c.Mouse && s.mouse != nil {
+		err := s.mouse()
+		s.mouse = nil
+		if err != nil {
+			return err
+		}
+	}
+	if c.ColorProfile != s.config.ColorProfile {
+		if s.color != nil {
+			s.color()
+			s.color = nil
+		}
+		if override {
+			s.color = caps.OverrideColor(color)
+		}
+	}
+	s.escape.set(h)
+	s.config = c
+	return nil
+}
+
+// claimColor makes s the owner of the color profile override, unless
+// another Term is
+func (s *terminal) claimColor() bool {
+	colorMu.Lock()
+	defer colorMu.Unlock()
+	if colorOwner != nil && colorOwner != s {
+		return false
+	}
+	colorOwner = s
+	return true
+}
+
+// releaseColor releases the color profile override owned by s, if any
+func (s *terminal) releaseColor() {
+	colorMu.Lock()
+	if colorOwner == s {
+		colorOwner = nil
+	}
+	colorMu.Unlock()
+}
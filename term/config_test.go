@@ -0,0 +1,261 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.linka.cloud/console/caps"
+	"go.linka.cloud/console/vt"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{name: "empty"},
+		{
+			name: "all",
+			env:  map[string]string{EnvEscape: "~.", EnvColorProfile: "ansi256", EnvMouse: "true", EnvScrollback: "5000"},
+			want: Config{EscapeSequence: "~.", ColorProfile: "ansi256", Mouse: true, Scrollback: 5000},
+		},
+		{name: "invalid mouse", env: map[string]string{EnvMouse: "maybe"}, wantErr: true},
+		{name: "invalid scrollback", env: map[string]string{EnvScrollback: "many"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Config
+			err := c.fromEnv(func(k string) string {
+				return tt.env[k]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && c != tt.want {
+				t.Fatalf("config %+v, want %+v", c, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigEscapeHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		seq     string
+		want    EscapeHandler
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "ssh", seq: "~.", want: &SSHEscape{Char: '~', Detach: '.'}},
+		{name: "control key", seq: "ctrl+]", want: ExitRuneHandler(0x1d, false)},
+		{name: "character", seq: "q", want: ExitRuneHandler('q', false)},
+		{name: "function key", seq: "f10", wantErr: true},
+		{name: "alt key", seq: "alt+x", wantErr: true},
+		{name: "invalid", seq: "ctrl+nope", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := Config{EscapeSequence: tt.seq}.EscapeHandler()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(h, tt.want) {
+				t.Fatalf("handler %#v, want %#v", h, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplySwallowDetach(t *testing.T) {
+	m, _, _, tm := newTestTerm(t, WithSwallowDetach())
+	if err := tm.Apply(Config{EscapeSequence: "ctrl+q"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Write([]byte("ab\x11")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.Wait(); err != ErrDetached {
+		t.Fatalf("wait: %v, want %v", err, ErrDetached)
+	}
+	if b, err := read(t, tm, 16); string(b) != "ab" || err != nil {
+		t.Fatalf("read %q, %v, want %q", b, err, "ab")
+	}
+}
+
+func TestApplyColorProfile(t *testing.T) {
+	restore := caps.OverrideColor(caps.ANSI)
+	defer restore()
+	_, _, _, tm := newTestTerm(t)
+	if err := tm.Apply(Config{ColorProfile: "truecolor"}); err != nil {
+		t.Fatal(err)
+	}
+	if p := caps.DetectFor(os.Stdout).Color; p != caps.TrueColor {
+		t.Fatalf("profile %v, want %v", p, caps.TrueColor)
+	}
+	if err := tm.Apply(Config{ColorProfile: "invalid"}); err == nil {
+		t.Fatal("invalid profile applied")
+	}
+	if err := tm.Apply(Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if p := caps.DetectFor(os.Stdout).Color; p != caps.ANSI {
+		t.Fatalf("profile %v, want %v once unset", p, caps.ANSI)
+	}
+	if err := tm.Apply(Config{ColorProfile: "none"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if p := caps.DetectFor(os.Stdout).Color; p != caps.ANSI {
+		t.Fatalf("profile %v, want %v once closed", p, caps.ANSI)
+	}
+}
+
+func TestApplyColorProfileOwner(t *testing.T) {
+	restore := caps.OverrideColor(caps.ANSI)
+	defer restore()
+	_, _, _, t1 := newTestTerm(t)
+	_, _, _, t2 := newTestTerm(t)
+	if err := t1.Apply(Config{ColorProfile: "truecolor"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := t2.Apply(Config{ColorProfile: "none"}); !errors.Is(err, ErrColorProfileOwned) {
+		t.Fatalf("got %v, want %v", err, ErrColorProfileOwned)
+	}
+	if p := caps.DetectFor(os.Stdout).Color; p != caps.TrueColor {
+		t.Fatalf("profile %v, want %v", p, caps.TrueColor)
+	}
+	if err := t1.Apply(Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := t2.Apply(Config{ColorProfile: "none"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := t2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := t1.Apply(Config{ColorProfile: "truecolor"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := t1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if p := caps.DetectFor(os.Stdout).Color; p != caps.ANSI {
+		t.Fatalf("profile %v, want %v once closed", p, caps.ANSI)
+	}
+}
+
+func TestConfigVTOptions(t *testing.T) {
+	v := vt.New(10, 2, Config{Scrollback: 3}.VTOptions()...)
+	for i := 0; i < 10; i++ {
+		v.Write([]byte("line\r\n"))
+	}
+	if n := v.ScrollbackLen(); n != 3 {
+		t.Fatalf("scrollback %d, want %d", n, 3)
+	}
+}
+
+// configTerm records the configurations applied to it
+type configTerm struct {
+	Term
+	err     error
+	mu      sync.Mutex
+	applied []Config
+}
+
+func (c *configTerm) Apply(cfg Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applied = append(c.applied, cfg)
+	return c.err
+}
+
+func (c *configTerm) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.applied)
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"mouse": true}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	failing := &configTerm{err: errors.New("apply failed")}
+	ok := &configTerm{}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchConfig(ctx, path, time.Millisecond, func(err error) {
+			errs <- err
+		}, failing, ok)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	select {
+	case err := <-errs:
+		if err != failing.err {
+			t.Fatalf("error %v, want %v", err, failing.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error reported")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for ok.count() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("config not applied after an error")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if want := (Config{Mouse: true}); ok.applied[0] != want {
+		t.Fatalf("applied %+v, want %+v", ok.applied[0], want)
+	}
+	// an invalid file is reported and not applied
+	time.Sleep(10 * time.Millisecond)
+	if err := ioutil.WriteFile(path, []byte(`{`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, now, now); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err == failing.err {
+			t.Fatal("invalid config applied")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid config not reported")
+	}
+	if n := ok.count(); n != 1 {
+		t.Fatalf("applied %d configs, want 1", n)
+	}
+}
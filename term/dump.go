@@ -0,0 +1,337 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.linka.cloud/console/ansi"
+)
+
+// DumpEnv is the environment variable naming the file DumperFromEnv dumps to
+const DumpEnv = "CONSOLE_DUMP"
+
+// Direction is the direction of the dumped bytes
+type Direction int
+
+const (
+	// DirInput are the bytes read from the Term
+	DirInput Direction = iota
+	// DirOutput are the bytes written to the Term
+	DirOutput
+)
+
+func (d Direction) String() string {
+	if d == DirInput {
+		return "<"
+	}
+	return ">"
+}
+
+// Dumper writes the bytes crossing a Term to w, tcpdump style: a header with
+// the time, the direction and the length, the hex dump, and the decoded
+// text, control characters and escape sequences, e.g.
+//
+//	15:04:05.000000 > 8 bytes
+//	  0000  1b 5b 3f 32 35 6c 68 69                           |.[?25lhi|
+//	  CSI ?25l "hi"
+//
+// It is used with WithDump to diagnose rendering bugs, and can be toggled
+// at runtime, e.g. with ToggleOnSignal.
+type Dumper struct {
+	mu      sync.Mutex
+	w       io.Writer
+	enabled bool
+	// decoders keeps the escape sequences split across reads or writes
+	decoders [2]*decoder
+	now      func() time.Time
+}
+
+// NewDumper returns an enabled Dumper writing to w,
+// e.g. a file or a log.Logger's Writer
+func NewDumper(w io.Writer) *Dumper {
+	d := &Dumper{w: w, enabled: true, now: time.Now}
+	for i := range d.decoders {
+		d.decoders[i] = newDecoder()
+	}
+	return d
+}
+
+// DumperFromEnv returns a Dumper appending to the file named by the
+// CONSOLE_DUMP environment variable, or nil if it is not set.
+// The file is closed with the Dumper.
+func DumperFromEnv() (*Dumper, error) {
+	path := os.Getenv(DumpEnv)
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewDumper(f), nil
+}
+
+// Enabled reports whether the Dumper is dumping
+func (d *Dumper) Enabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.enabled
+}
+
+// SetEnabled starts or stops the dump
+func (d *Dumper) SetEnabled(v bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.enabled == v {
+		return
+	}
+	d.enabled = v
+	state := "stopped"
+	if v {
+		state = "started"
+		// the sequences in progress were not seen
+		for i := range d.decoders {
+			d.decoders[i] = newDecoder()
+		}
+	}
+	fmt.Fprintf(d.w, "%s dump %s\n", d.now().Format("15:04:05.000000"), state)
+}
+
+// Toggle starts the dump if it is stopped, stops it otherwise
+func (d *Dumper) Toggle() {
+	d.SetEnabled(!d.Enabled())
+}
+
+// ToggleOnSignal toggles the dump each time one of the signals is received,
+// SIGUSR1 if none is given, where supported.
+// It returns a function stopping the signals handling.
+func (d *Dumper) ToggleOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = toggleSignals
+	}
+	if len(sigs) == 0 {
+		return func() {}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				d.Toggle()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Dump writes p to the dump if enabled
+func (d *Dumper) Dump(dir Direction, p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.enabled {
+		return
+	}
+	b := make([]byte, 0, 80*(len(p)/16+3))
+	b = d.now().AppendFormat(b, "15:04:05.000000")
+	b = append(b, ' ')
+	b = append(b, dir.String()...)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(len(p)), 10)
+	b = append(b, " bytes\n"...)
+	b = appendHex(b, p)
+	dec := d.decoders[dir&1]
+	dec.Write(p)
+	if s := dec.flush(); s != "" {
+		b = append(b, "  "...)
+		b = append(b, s...)
+		b = append(b, '\n')
+	}
+	d.w.Write(b)
+}
+
+// Close closes the underlying writer if it is an io.Closer
+func (d *Dumper) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.enabled = false
+	if c, ok := d.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendHex appends the hex dump of p, 16 bytes per line
+func appendHex(b, p []byte) []byte {
+	for off := 0; off < len(p); off += 16 {
+		line := p[off:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		b = append(b, "  "...)
+		for s := 12; s >= 0; s -= 4 {
+			b = append(b, hexDigits[off>>uint(s)&0xf])
+		}
+		b = append(b, "  "...)
+		for i := 0; i < 16; i++ {
+			if i < len(line) {
+				b = append(b, hexDigits[line[i]>>4], hexDigits[line[i]&0xf], ' ')
+			} else {
+				b = append(b, "   "...)
+			}
+			if i == 7 {
+				b = append(b, ' ')
+			}
+		}
+		b = append(b, " |"...)
+		for _, c := range line {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			b = append(b, c)
+		}
+		b = append(b, "|\n"...)
+	}
+	return b
+}
+
+// decoder describes the bytes it is written as a list of tokens:
+// the quoted text, the control characters and the escape sequences
+type decoder struct {
+	*ansi.Parser
+	tokens []byte
+	text   []rune
+}
+
+func newDecoder() *decoder {
+	d := &decoder{}
+	d.Parser = ansi.NewParser(d)
+	return d
+}
+
+// flush returns the tokens decoded since the last call
+func (d *decoder) flush() string {
+	d.flushText()
+	s := string(d.tokens)
+	d.tokens = d.tokens[:0]
+	return s
+}
+
+func (d *decoder) flushText() {
+	if len(d.text) == 0 {
+		return
+	}
+	d.token(strconv.Quote(string(d.text)))
+	d.text = d.text[:0]
+}
+
+func (d *decoder) token(s string) {
+	if len(d.tokens) > 0 {
+		d.tokens = append(d.tokens, ' ')
+	}
+	d.tokens = append(d.tokens, s...)
+}
+
+var controls = map[byte]string{
+	0x00: "NUL", 0x03: "ETX", 0x04: "EOT", 0x07: "BEL", 0x08: "BS",
+	0x09: "HT", 0x0a: "LF", 0x0b: "VT", 0x0c: "FF", 0x0d: "CR",
+	0x0e: "SO", 0x0f: "SI", 0x18: "CAN", 0x1a: "SUB", 0x7f: "DEL",
+}
+
+func (d *decoder) Print(r rune) {
+	d.text = append(d.text, r)
+}
+
+func (d *decoder) Execute(c byte) {
+	d.flushText()
+	if s, ok := controls[c]; ok {
+		d.token(s)
+		return
+	}
+	d.token("^" + string(rune(c+'@')))
+}
+
+func (d *decoder) ESC(seq ansi.Sequence) {
+	d.flushText()
+	d.token("ESC " + string(seq.Intermediate) + string(rune(seq.Final)))
+}
+
+func (d *decoder) CSI(seq ansi.Sequence) {
+	d.flushText()
+	b := []byte("CSI ")
+	if seq.Prefix != 0 {
+		b = append(b, seq.Prefix)
+	}
+	for i, p := range seq.Params {
+		if i > 0 {
+			b = append(b, ';')
+		}
+		if p >= 0 {
+			b = strconv.AppendInt(b, int64(p), 10)
+		}
+	}
+	b = append(b, seq.Intermediate...)
+	b = append(b, seq.Final)
+	d.token(string(b))
+}
+
+func (d *decoder) OSC(data []byte) {
+	d.flushText()
+	s := strconv.Quote(string(data))
+	d.token("OSC " + s[1:len(s)-1])
+}
+
+type dumpReader struct {
+	r io.Reader
+	d *Dumper
+}
+
+func (r dumpReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.d.Dump(DirInput, p[:n])
+	return n, err
+}
+
+type dumpWriter struct {
+	w io.Writer
+	d *Dumper
+}
+
+func (w dumpWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.d.Dump(DirOutput, p[:n])
+	return n, err
+}
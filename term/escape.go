@@ -0,0 +1,131 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// EscapeHandler inspects the Term input to detect the detach sequence
+type EscapeHandler interface {
+	// Handle processes a chunk of input and returns the bytes to forward
+	// to the Term readers, and whether the Term must be detached
+	Handle(p []byte) (out []byte, detach bool)
+}
+
+// EscapeHandlerFunc is a function implementing EscapeHandler
+type EscapeHandlerFunc func(p []byte) ([]byte, bool)
+
+func (fn EscapeHandlerFunc) Handle(p []byte) ([]byte, bool) {
+	return fn(p)
+}
+
+// ExitRuneHandler returns the default EscapeHandler, detaching when the exit
+// rune is found anywhere in the input, even split across reads. The input
+// preceding it is forwarded, followed by the exit rune unless swallow is true.
+// The input is never held back: when the exit rune is split across reads, its
+// first bytes have already been forwarded and cannot be swallowed.
+func ExitRuneHandler(exit rune, swallow bool) EscapeHandler {
+	b := make([]byte, utf8.UTFMax)
+	return &exitRuneHandler{seq: b[:utf8.EncodeRune(b, exit)], swallow: swallow}
+}
+
+type exitRuneHandler struct {
+	seq     []byte
+	swallow bool
+	// matched is the length of the exit rune encoding prefix found at the
+	// end of the previous chunk
+	matched int
+}
+
+func (h *exitRuneHandler) Handle(p []byte) ([]byte, bool) {
+	if m := h.matched; m > 0 {
+		h.matched = 0
+		if rest := h.seq[m:]; bytes.HasPrefix(p, rest) {
+			if h.swallow {
+				return nil, true
+			}
+			return p[:len(rest)], true
+		}
+	}
+	if i := bytes.Index(p, h.seq); i >= 0 {
+		if h.swallow {
+			return p[:i], true
+		}
+		return p[:i+len(h.seq)], true
+	}
+	k := len(h.seq) - 1
+	if k > len(p) {
+		k = len(p)
+	}
+	for ; k > 0; k-- {
+		if bytes.HasSuffix(p, h.seq[:k]) {
+			h.matched = k
+			break
+		}
+	}
+	return p, false
+}
+
+// SSHEscape is an EscapeHandler implementing the OpenSSH escape semantics:
+// the escape character is only recognized right after a newline (or at the
+// beginning of the session), followed by Detach it detaches the Term, and
+// typed twice it is forwarded once. Any other character cancels the escape
+// and both are forwarded.
+type SSHEscape struct {
+	// Char is the escape character, defaults to '~'
+	Char byte
+	// Detach is the character detaching the Term after the escape
+	// character, defaults to '.'
+	Detach byte
+
+	started bool
+	newline bool
+	pending bool
+}
+
+// NewSSHEscape returns an SSHEscape using the OpenSSH defaults: "~."
+func NewSSHEscape() *SSHEscape {
+	return &SSHEscape{Char: '~', Detach: '.'}
+}
+
+func (e *SSHEscape) Handle(p []byte) ([]byte, bool) {
+	if !e.started {
+		e.started, e.newline = true, true
+	}
+	out := make([]byte, 0, len(p)+1)
+	for _, b := range p {
+		if e.pending {
+			e.pending = false
+			switch b {
+			case e.Detach:
+				return out, true
+			case e.Char:
+				out = append(out, b)
+				e.newline = false
+				continue
+			default:
+				out = append(out, e.Char)
+			}
+		} else if e.newline && b == e.Char {
+			e.pending = true
+			continue
+		}
+		out = append(out, b)
+		e.newline = b == '\r' || b == '\n'
+	}
+	return out, false
+}
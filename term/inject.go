@@ -0,0 +1,39 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/input"
+)
+
+func (s *terminal) Inject(p []byte) error {
+	if s.closed() {
+		return console.ErrClosed
+	}
+	if len(p) == 0 {
+		return nil
+	}
+	select {
+	case s.rch <- chunk{b: append([]byte(nil), p...)}:
+		return nil
+	case <-s.close:
+		return console.ErrClosed
+	}
+}
+
+func (s *terminal) InjectKey(k input.KeyEvent) error {
+	return s.Inject(input.Encode(k))
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/input"
+)
+
+// ErrKeyTimeout is returned by WaitForKey when no key was pressed in time
+var ErrKeyTimeout = errors.New("timeout waiting for a key")
+
+// WaitForKey waits for a key to be pressed on the process console, e.g. for
+// a "press any key to continue" prompt, and returns it, or ErrKeyTimeout
+// once the timeout elapsed. A timeout of 0 waits until ctx is done.
+// The console is put in raw mode while waiting, holding the terminal modes
+// ownership, which is waited for until ctx is done, see console.Acquire.
+// The whole sequence sent for the key is read, so that none of it is left to
+// the next reader, e.g. the "[A" of the up arrow, and the input following it
+// is left untouched.
+// It must not be called while a Term reads the console.
+func WaitForKey(ctx context.Context, timeout time.Duration) (input.KeyEvent, error) {
+	c, err := console.FromFile(os.Stdin)
+	if err != nil {
+		return input.KeyEvent{}, err
+	}
+	release, err := console.Acquire(ctx)
+	if err != nil {
+		return input.KeyEvent{}, err
+	}
+	defer release()
+	if err := c.SetRaw(); err != nil {
+		return input.KeyEvent{}, err
+	}
+	defer console.OnExit(c.Reset)()
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	r := &keyReader{c: c}
+	for {
+		ev, err := r.read(ctx, deadline)
+		if err != nil {
+			return input.KeyEvent{}, err
+		}
+		if k, ok := ev.(input.KeyEvent); ok {
+			return k, nil
+		}
+	}
+}
+
+// keyPoll is how often the context is checked while waiting for input
+const keyPoll = 100 * time.Millisecond
+
+// keyReader reads the events from a raw mode console one byte at a time
+type keyReader struct {
+	c   console.Console
+	buf []byte
+}
+
+// read returns the next event, waiting for it until the deadline, if not
+// zero, or ctx is done.
+// The rest of an escape sequence is waited for input.DefaultEscapeTimeout,
+// an escape not followed by it being the escape key.
+func (r *keyReader) read(ctx context.Context, deadline time.Time) (input.Event, error) {
+	var b [1]byte
+	for {
+		if ev, n := input.Parse(r.buf); n > 0 {
+			r.buf = r.buf[n:]
+			return ev, nil
+		}
+		d := deadline
+		if len(r.buf) > 0 {
+			d = time.Now().Add(input.DefaultEscapeTimeout)
+		}
+		ok, err := r.wait(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		if !ok && len(r.buf) == 0 {
+			return nil, ErrKeyTimeout
+		}
+		if !ok {
+			return r.incomplete(), nil
+		}
+		n, err := r.c.Read(b[:])
+		if err != nil {
+			return nil, err
+		}
+		r.buf = append(r.buf, b[:n]...)
+	}
+}
+
+// incomplete flushes the incomplete sequence read
+func (r *keyReader) incomplete() input.Event {
+	defer func() {
+		r.buf = r.buf[:0]
+	}()
+	if len(r.buf) == 1 && r.buf[0] == 0x1b {
+		return input.KeyEvent{Key: input.KeyEscape}
+	}
+	return input.UnknownEvent(append([]byte(nil), r.buf...))
+}
+
+// wait waits for the input to be readable until the deadline, if not zero,
+// and reports whether it is, or returns the ctx error
+func (r *keyReader) wait(ctx context.Context, deadline time.Time) (bool, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		step := keyPoll
+		if !deadline.IsZero() {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return false, nil
+			}
+			if left < step {
+				step = left
+			}
+		}
+		if ok, err := console.WaitInput(r.c, step); ok || err != nil {
+			return ok, err
+		}
+	}
+}
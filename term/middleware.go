@@ -0,0 +1,80 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"errors"
+	"io"
+)
+
+// InputMiddleware wraps the Term input reader.
+// Middlewares can inspect, rewrite, drop or inject input, and detach the
+// Term by returning ErrDetached from Read.
+type InputMiddleware func(next io.Reader) io.Reader
+
+// Chain returns the reader obtained by applying the middlewares to r:
+// the first middleware is the first to see the input
+func Chain(r io.Reader, mws ...InputMiddleware) io.Reader {
+	for _, mw := range mws {
+		r = mw(r)
+	}
+	return r
+}
+
+// Detach returns the middleware detecting the detach sequence with the
+// EscapeHandler. If confirm is not nil, the Term is only detached if it
+// returns true, otherwise the sequence is forwarded as regular input.
+func Detach(h EscapeHandler, confirm func() bool) InputMiddleware {
+	return func(next io.Reader) io.Reader {
+		return &detachReader{r: next, h: h, confirm: confirm}
+	}
+}
+
+type detachReader struct {
+	r       io.Reader
+	h       EscapeHandler
+	confirm func() bool
+	pending []byte
+	err     error
+}
+
+func (d *detachReader) Read(p []byte) (int, error) {
+	if len(d.pending) == 0 && d.err == nil {
+		n, err := d.r.Read(p)
+		out, detach := p[:n], false
+		if n > 0 {
+			out, detach = d.h.Handle(p[:n])
+		}
+		if detach && d.confirm != nil && !d.confirm() {
+			out, detach = p[:n], false
+		}
+		// the handler may return more bytes than it was given
+		d.pending = append(d.pending[:0], out...)
+		d.err = err
+		if detach {
+			d.err = ErrDetached
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	if len(d.pending) > 0 {
+		return n, nil
+	}
+	err := d.err
+	if !errors.Is(err, ErrDetached) {
+		d.err = nil
+	}
+	return n, err
+}
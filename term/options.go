@@ -0,0 +1,190 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"io"
+	"time"
+
+	"go.linka.cloud/console"
+)
+
+type options struct {
+	exitRune       rune
+	resizeInterval time.Duration
+	resizeDebounce time.Duration
+	out            io.Writer
+	stderr         io.Writer
+	stderrStyle    string
+	swallowDetach  bool
+	confirmDetach  func() bool
+	onDetach       func()
+	escape         EscapeHandler
+	middlewares    []InputMiddleware
+	config         *Config
+	translation    console.OutputTranslation
+	latency        func() time.Duration
+	dump           *Dumper
+	acquire        bool
+	altScreen      bool
+}
+
+func defaultOptions() options {
+	return options{
+		exitRune:       ExitRune,
+		resizeInterval: 500 * time.Millisecond,
+		resizeDebounce: DefaultResizeDebounce,
+	}
+}
+
+// Option configures a Term
+type Option func(o *options)
+
+// WithExitRune sets the rune closing the Term when typed, defaults to ExitRune
+func WithExitRune(r rune) Option {
+	return func(o *options) {
+		o.exitRune = r
+	}
+}
+
+// WithResizeInterval sets the interval at which the console size is polled
+func WithResizeInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.resizeInterval = d
+	}
+}
+
+// DefaultResizeDebounce is a debounce delay short enough not to be noticed
+// but long enough to coalesce the sizes sent while a window is dragged
+const DefaultResizeDebounce = 50 * time.Millisecond
+
+// WithResizeDebounce coalesces the size changes: a new size is only
+// published on WatchSize, and returned by Size, once the size did not
+// change for d, e.g. DefaultResizeDebounce, so that the layouts are
+// recomputed once per window drag instead of for each of its steps.
+// It defaults to DefaultResizeDebounce, 0 publishing the sizes immediately.
+func WithResizeDebounce(d time.Duration) Option {
+	return func(o *options) {
+		o.resizeDebounce = d
+	}
+}
+
+// WithOutput routes the Term writes to w instead of the console,
+// e.g. os.Stderr to keep stdout free for machine-readable output
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.out = w
+	}
+}
+
+// WithStderr sets the writer used by Term.Stderr, defaults to the Term output
+func WithStderr(w io.Writer) Option {
+	return func(o *options) {
+		o.stderr = w
+	}
+}
+
+// WithStderrStyle sets the SGR parameters (e.g. "31" for red) applied to
+// the data written to Term.Stderr
+func WithStderrStyle(sgr string) Option {
+	return func(o *options) {
+		o.stderrStyle = sgr
+	}
+}
+
+// WithSwallowDetach prevents the exit rune from being forwarded
+// to the Term readers when it detaches the Term
+func WithSwallowDetach() Option {
+	return func(o *options) {
+		o.swallowDetach = true
+	}
+}
+
+// WithDetachConfirm sets a hook called when the exit rune is typed:
+// the Term is only detached if it returns true, otherwise the rune is
+// forwarded as regular input
+func WithDetachConfirm(fn func() bool) Option {
+	return func(o *options) {
+		o.confirmDetach = fn
+	}
+}
+
+// WithOnDetach sets a callback notified when the Term is detached,
+// before it is closed
+func WithOnDetach(fn func()) Option {
+	return func(o *options) {
+		o.onDetach = fn
+	}
+}
+
+// WithEscapeHandler replaces the exit rune detection with a custom
+// EscapeHandler, e.g. NewSSHEscape().
+// WithExitRune and WithSwallowDetach have no effect when it is used.
+func WithEscapeHandler(h EscapeHandler) Option {
+	return func(o *options) {
+		o.escape = h
+	}
+}
+
+// WithInputMiddleware adds middlewares to the Term input chain.
+// They see the input before the detach sequence detection.
+func WithInputMiddleware(mws ...InputMiddleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, mws...)
+	}
+}
+
+// WithOutputTranslation sets the newline translation applied to the Term
+// output, e.g. console.TranslateCRLF to keep printing normal newlines while
+// the console is in raw mode. The translation is left to the console if it
+// is a console.RawOutputSetter the output is written to.
+func WithOutputTranslation(t console.OutputTranslation) Option {
+	return func(o *options) {
+		o.translation = t
+	}
+}
+
+// WithLatency sets the function returning the round trip time to the remote
+// end reported by Stats, e.g. a remote.Probe's Latency
+func WithLatency(fn func() time.Duration) Option {
+	return func(o *options) {
+		o.latency = fn
+	}
+}
+
+// WithDump dumps the bytes read from the input and written to the output
+// with d, see DumperFromEnv
+func WithDump(d *Dumper) Option {
+	return func(o *options) {
+		o.dump = d
+	}
+}
+
+// WithAltScreen makes the Term switch to the alternate screen when created,
+// and back to the main screen when closed or suspended
+func WithAltScreen() Option {
+	return func(o *options) {
+		o.altScreen = true
+	}
+}
+
+// WithAcquire makes the Term hold the process wide ownership of the terminal
+// modes while its console is in raw mode, see console.Acquire: creating
+// the Term waits for the current owner to release it
+func WithAcquire() Option {
+	return func(o *options) {
+		o.acquire = true
+	}
+}
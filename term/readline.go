@@ -0,0 +1,186 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/input"
+	"go.linka.cloud/console/screen"
+)
+
+// ErrInterrupted is returned by ReadLine when Ctrl-C is typed
+var ErrInterrupted = errors.New("interrupted")
+
+// ReadLine reads a line from the process standard input, without its line
+// ending, until Enter is typed, the input ends or ctx is done.
+// If the input is a console, it is put in raw mode while reading, whether
+// it was already or not, and the line is echoed, unless the echo was
+// disabled, and edited by ReadLine: Backspace, Ctrl-U and Ctrl-W erase,
+// Ctrl-D on an empty line returns io.EOF and Ctrl-C returns ErrInterrupted.
+// The console modes are restored before returning, and the cursor moved to
+// the next line unless the input ended. The terminal modes ownership is held
+// meanwhile, waiting for its current owner to release it, see
+// console.Acquire.
+// Otherwise the line is read as is, and a read in progress when ctx is done
+// is abandoned, its line being lost.
+// It must not be called while a Term reads the console.
+func ReadLine(ctx context.Context) (string, error) {
+	c, err := console.FromFile(os.Stdin)
+	if err != nil {
+		return readLine(ctx, os.Stdin)
+	}
+	echo := true
+	if r, ok := c.(console.EchoReporter); ok {
+		if off, err := r.EchoDisabled(); err == nil && off {
+			echo = false
+		}
+	}
+	release, err := console.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	if err := c.SetRaw(); err != nil {
+		return "", err
+	}
+	defer console.OnExit(c.Reset)()
+	l := &rawLine{c: c, echo: echo, r: &keyReader{c: c}}
+	return l.read(ctx)
+}
+
+// readLine reads a line from a reader which is not a console
+func readLine(ctx context.Context, r io.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		// the line is read one byte at a time not to consume the next ones
+		var b [1]byte
+		var s strings.Builder
+		for {
+			n, err := r.Read(b[:])
+			if n > 0 {
+				if b[0] == '\n' {
+					ch <- result{line: strings.TrimSuffix(s.String(), "\r")}
+					return
+				}
+				s.WriteByte(b[0])
+			}
+			if err != nil {
+				// the last line may not be terminated
+				if errors.Is(err, io.EOF) && s.Len() > 0 {
+					err = nil
+				}
+				ch <- result{line: s.String(), err: err}
+				return
+			}
+		}
+	}()
+	select {
+	case r := <-ch:
+		return r.line, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// rawLine is a line read from a raw mode console
+type rawLine struct {
+	c    console.Console
+	r    *keyReader
+	echo bool
+	buf  []rune
+}
+
+func (l *rawLine) read(ctx context.Context) (string, error) {
+	for {
+		ev, err := l.r.read(ctx, time.Time{})
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				l.write("\r\n")
+			}
+			return "", err
+		}
+		k, ok := ev.(input.KeyEvent)
+		if !ok {
+			continue
+		}
+		switch {
+		case k.Key == input.KeyEnter:
+			l.write("\r\n")
+			return string(l.buf), nil
+		case k.Key == input.KeyBackspace:
+			l.erase(len(l.buf) - 1)
+		case k.Key != input.KeyRune:
+		case k.Mod == 0 || k.Mod == input.ModShift:
+			if unicode.IsPrint(k.Rune) {
+				l.insert(k.Rune)
+			}
+		case k.Mod != input.ModCtrl:
+		case k.Rune == 'c':
+			l.write("^C\r\n")
+			return "", ErrInterrupted
+		case k.Rune == 'd':
+			if len(l.buf) == 0 {
+				return "", io.EOF
+			}
+		case k.Rune == 'u':
+			l.erase(0)
+		case k.Rune == 'w':
+			i := len(l.buf)
+			for i > 0 && unicode.IsSpace(l.buf[i-1]) {
+				i--
+			}
+			for i > 0 && !unicode.IsSpace(l.buf[i-1]) {
+				i--
+			}
+			l.erase(i)
+		}
+	}
+}
+
+func (l *rawLine) insert(r rune) {
+	l.buf = append(l.buf, r)
+	l.write(string(r))
+}
+
+// erase removes the runes from i, erasing them from the screen
+func (l *rawLine) erase(i int) {
+	if i < 0 || i >= len(l.buf) {
+		return
+	}
+	w := 0
+	for _, r := range l.buf[i:] {
+		w += screen.RuneWidth(r)
+	}
+	l.buf = l.buf[:i]
+	l.write(strings.Repeat("\b \b", w))
+}
+
+func (l *rawLine) write(s string) {
+	if l.echo {
+		io.WriteString(l.c, s)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime/debug"
+
+	"go.linka.cloud/console"
+)
+
+// PanicError is the value Run panics with when its function panicked,
+// holding the original panic value and stack, lost when a recovered
+// panic is raised again
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("%v\n\noriginal stack:\n%s", p.Value, p.Stack)
+}
+
+// Unwrap returns the panic value if it is an error
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Run runs fn on a Term created with the options on the current process'
+// console, e.g. the main loop of a TUI, with WithAltScreen and WithConfig
+// for the mouse, and closes it when fn returns.
+// If fn panics, the Term is closed and the functions registered with
+// console.OnExit are called, so that the terminal is usable, before Run
+// panics again with a PanicError.
+// The panics of the goroutines started by fn cannot be recovered.
+func Run(ctx context.Context, fn func(t Term) error, opts ...Option) (err error) {
+	t, err := New(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			t.Close()
+			console.Unwind()
+			panic(&PanicError{Value: r, Stack: stack})
+		}
+	}()
+	err = fn(t)
+	if cerr := t.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// RunInteractive runs cmd on the terminal while the Term is suspended, e.g.
+// an editor or a pager launched by a TUI, and resumes the Term once it
+// exited, see Term.Suspend.
+// The command standard streams default to the process ones, which are
+// expected to be the Term terminal. The interrupt signals typed for the
+// command do not stop the process while it runs, but they are still
+// delivered to the channels registered with signal.Notify. The command is
+// killed when ctx is done.
+// The error reports why the command could not be run or the Term resumed,
+// or the context error, its exit status is returned whether it succeeded
+// or not.
+func RunInteractive(ctx context.Context, t Term, cmd *exec.Cmd) (ExitStatus, error) {
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	if err := t.Suspend(); err != nil {
+		return ExitStatus{Code: -1}, err
+	}
+	// the signals are only ignored, the handlers registered with
+	// signal.Notify still receive them
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, interruptSignals...)
+	err := run(ctx, cmd)
+	signal.Stop(sigs)
+	if rerr := t.Resume(); err == nil {
+		err = rerr
+	}
+	st := cmd.ProcessState
+	if st == nil {
+		return ExitStatus{Code: -1}, err
+	}
+	return ExitStatus{Code: st.ExitCode(), Signal: exitSignal(st)}, err
+}
+
+// run runs cmd until it exits or ctx is done, its exit error is not
+// returned
+func run(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var eerr *exec.ExitError
+	if errors.As(err, &eerr) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+//go:build linux || darwin
+// +build linux darwin
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalHandlersKept(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, tm Term, cmd *exec.Cmd) error
+	}{
+		{
+			name: "attach",
+			run: func(t *testing.T, tm Term, cmd *exec.Cmd) error {
+				p, err := Attach(context.Background(), tm, cmd)
+				if err != nil {
+					return err
+				}
+				_, err = p.Wait()
+				return err
+			},
+		},
+		{
+			name: "run interactive",
+			run: func(t *testing.T, tm Term, cmd *exec.Cmd) error {
+				cmd.Stdin, cmd.Stdout, cmd.Stderr = strings.NewReader(""), ioutil.Discard, ioutil.Discard
+				_, err := RunInteractive(context.Background(), tm, cmd)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, tm := newTestTerm(t)
+			app := make(chan os.Signal, 1)
+			signal.Notify(app, syscall.SIGINT)
+			defer signal.Stop(app)
+			received := func() {
+				t.Helper()
+				select {
+				case <-app:
+				case <-time.After(5 * time.Second):
+					t.Fatal("the application handler did not receive the signal")
+				}
+			}
+			cmd := exec.Command("sh", "-c", "kill -INT $PPID; sleep 1")
+			if err := tt.run(t, tm, cmd); err != nil {
+				t.Fatal(err)
+			}
+			received()
+			syscall.Kill(os.Getpid(), syscall.SIGINT)
+			received()
+		})
+	}
+}
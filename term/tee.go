@@ -0,0 +1,81 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"io"
+
+	"go.linka.cloud/console/ansi"
+)
+
+type teeOptions struct {
+	strip bool
+}
+
+// TeeOption configures a Term.Tee
+type TeeOption func(o *teeOptions)
+
+// WithStripANSI removes the escape sequences from the output duplicated by
+// the tee, e.g. to log it to a file
+func WithStripANSI() TeeOption {
+	return func(o *teeOptions) {
+		o.strip = true
+	}
+}
+
+type tee struct {
+	w io.Writer
+}
+
+func (s *terminal) Tee(w io.Writer, opts ...TeeOption) (remove func()) {
+	var o teeOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	t := &tee{w: w}
+	if o.strip {
+		t.w = ansi.NewStripWriter(w)
+	}
+	s.wmu.Lock()
+	s.tees = append(s.tees, t)
+	s.wmu.Unlock()
+	return func() {
+		s.wmu.Lock()
+		s.untee(t)
+		s.wmu.Unlock()
+	}
+}
+
+// tee duplicates the output to the tees, it must be called with wmu held
+func (s *terminal) tee(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	for _, t := range s.tees {
+		if _, err := t.w.Write(p); err != nil {
+			s.untee(t)
+		}
+	}
+}
+
+// untee removes the tee, it must be called with wmu held
+func (s *terminal) untee(t *tee) {
+	for i, v := range s.tees {
+		if v == t {
+			s.tees = append(s.tees[:i:i], s.tees[i+1:]...)
+			return
+		}
+	}
+}
@@ -18,120 +18,840 @@ import (
 	"context"
 	"errors"
 	"io"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
 	"go.linka.cloud/console"
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/input"
 )
 
 var _ Term = (*terminal)(nil)
 
 var (
 	ExitRune = '\x1D'
+
+	ErrDetached = errors.New("detached")
+)
+
+// CloseReason describes why a Term was closed
+type CloseReason int
+
+const (
+	// ReasonNone means the Term is still running
+	ReasonNone CloseReason = iota
+	// ReasonClosed means Close was called
+	ReasonClosed
+	// ReasonDetached means the exit rune was typed
+	ReasonDetached
+	// ReasonEOF means the input reached its end
+	ReasonEOF
+	// ReasonError means reading the input failed
+	ReasonError
+	// ReasonContext means the Term context was cancelled
+	ReasonContext
 )
 
+func (r CloseReason) String() string {
+	switch r {
+	case ReasonNone:
+		return "none"
+	case ReasonClosed:
+		return "closed"
+	case ReasonDetached:
+		return "detached"
+	case ReasonEOF:
+		return "eof"
+	case ReasonError:
+		return "error"
+	case ReasonContext:
+		return "context"
+	default:
+		return "unknown"
+	}
+}
+
+// Size is the size of a Term
 type Size struct {
 	Rows int
 	Cols int
+	// PixelWidth and PixelHeight are the size in pixels, 0 if unknown
+	PixelWidth  int
+	PixelHeight int
+}
+
+// SizeOf returns the Size of a console window size
+func SizeOf(ws console.WinSize) Size {
+	return Size{Rows: int(ws.Height), Cols: int(ws.Width), PixelWidth: int(ws.PixelWidth), PixelHeight: int(ws.PixelHeight)}
+}
+
+// WinSize returns the console window size of the Size
+func (s Size) WinSize() console.WinSize {
+	return console.WinSize{Height: uint16(s.Rows), Width: uint16(s.Cols), PixelWidth: uint16(s.PixelWidth), PixelHeight: uint16(s.PixelHeight)}
 }
 
 type Term interface {
 	io.ReadWriteCloser
 	Size() Size
 	WatchSize() <-chan Size
+	// Stderr returns a writer for diagnostic output, styled and interleaved
+	// with the Term output
+	Stderr() io.Writer
+	// Done returns a channel closed when the Term is closed
+	Done() <-chan struct{}
+	// Wait blocks until the Term is closed and returns the error which
+	// caused it: nil after Close, ErrDetached, io.EOF, the context error
+	// or the read error
+	Wait() error
+	// Reason returns why the Term was closed
+	Reason() CloseReason
+	// CloseRead shuts down the reading side: pending and future reads
+	// return io.EOF while the Term keeps running
+	CloseRead() error
+	// CloseWrite shuts down the writing side: future writes fail, and the
+	// output is signaled the end of the stream, either by closing its write
+	// side if it supports it, or by sending an EOT (Ctrl-D), which is what
+	// a PTY master expects
+	CloseWrite() error
+	// Config returns the current configuration
+	Config() Config
+	// Apply changes the configuration of the running Term
+	Apply(c Config) error
+	// Stats returns the Term traffic counters and latency
+	Stats() Stats
+	// SetSize sets the Term size, e.g. from the resize messages of a remote
+	// terminal, resizing the console if it supports it, and notifies the
+	// WatchSize receivers
+	SetSize(Size) error
+	// Suspend restores the console modes and stops reading its input, so
+	// that another program can use the terminal, e.g. an editor or a shell
+	// started by the application, until Resume is called
+	Suspend() error
+	// Resume puts the console back in raw mode, resumes reading its input
+	// and sends its size on WatchSize, even if unchanged, for the
+	// application to redraw the screen
+	Resume() error
+	// Tee duplicates the output, Stderr included, to w until the returned
+	// function is called, without affecting the Term output. w should not
+	// block, and it is removed if a write fails.
+	Tee(w io.Writer, opts ...TeeOption) (remove func())
+	// Inject feeds p to the readers as if it was typed, after the input
+	// already read, e.g. for automation or tests. It is not inspected for
+	// the detach sequence, and blocks while the input queue is full.
+	Inject(p []byte) error
+	// InjectKey injects the input the terminal sends for the key
+	InjectKey(k input.KeyEvent) error
+	// Dumb reports whether the terminal does not interpret the escape
+	// sequences, its console being a console.Dumb, e.g. in a continuous
+	// integration service, so that the widgets only write plain lines
+	Dumb() bool
+	// DisableEcho disables the echo of the input, e.g. to read a password,
+	// until restore is called. The raw mode already disables it, so that
+	// it only changes the modes of a dumb console on a terminal. It returns
+	// console.ErrUnsupported if the input of a dumb Term is not a terminal.
+	DisableEcho() (restore func() error, err error)
+}
+
+// Stats are the Term statistics
+type Stats struct {
+	// BytesRead is the number of bytes returned by Read
+	BytesRead uint64
+	// BytesWritten is the number of bytes written to the output
+	BytesWritten uint64
+	// Latency is the round trip time to the remote end, as reported by
+	// the function set with WithLatency, 0 if unknown
+	Latency time.Duration
 }
 
 type terminal struct {
-	in      io.Reader
-	console console.Console
+	// read and written are the Stats counters, accessed atomically
+	read    uint64
+	written uint64
 
-	size  Size
-	mu    sync.RWMutex
-	sch   chan Size
-	sonce sync.Once
+	in  io.Reader
+	out io.Writer
+	// dst is the output before the newline translation
+	dst io.Writer
+	// wmu serializes the writes to out and stderr, and guards ebuf and
+	// tees
+	wmu  sync.Mutex
+	tees []*tee
+	// ebuf is the buffer reused to style the stderr writes
+	ebuf []byte
+	// raw is the input console, put in raw mode, if any
+	raw console.Console
+	// restore are the functions registered with console.OnExit restoring
+	// the console, called in the reverse order when the Term is closed
+	restore []func() error
+	// sizer is the console used to query the size
+	sizer console.Console
+	// onlcr is set when raw keeps its output processing to translate the
+	// newlines instead of the Term
+	onlcr bool
+	dumb  bool
+	opts  options
 
-	close chan struct{}
-	conce sync.Once
+	// escape is the current escape handler, set by Apply
+	escape *swapHandler
+	// cmu guards config, mouse and color
+	cmu    sync.Mutex
+	config Config
+	// mouse disables the mouse reporting if it is enabled
+	mouse func() error
+	// color restores the color profile overridden by the config, if any
+	color func()
+
+	// mu guards size, reason and err
+	mu   sync.RWMutex
+	size Size
+	// sch is owned by watchSize, the only goroutine sending on it and
+	// closing it
+	sch chan Size
+	// setch hands the sizes set with SetSize over to watchSize
+	setch chan Size
+	// redraw asks watchSize to query and send the console size
+	redraw chan struct{}
+
+	// smu guards suspended, the current suspension if any
+	smu       sync.Mutex
+	suspended *suspension
+	// nowait is set when the input readiness cannot be waited for, so that
+	// the pump cannot be stopped while it is reading
+	nowait int32
+	// pmu is held by the pump while it waits for the input, so that Close
+	// returns once the pump stopped using the input console
+	pmu sync.Mutex
+
+	// rch queues the chunks read from the input by the pump, so that the
+	// input keeps being inspected for the detach sequence while the
+	// application is not reading
+	rch chan chunk
+	// rclose is closed by CloseRead
+	rclose chan struct{}
+	rconce sync.Once
+	// wclosed is set by CloseWrite, guarded by wmu
+	wclosed bool
+	// rmu guards pending, the part of the last chunk not read yet
+	rmu     sync.Mutex
+	pending []byte
+	perr    error
+
+	close  chan struct{}
+	conce  sync.Once
+	reason CloseReason
+	err    error
 }
 
-func New(ctx context.Context) (Term, error) {
-	c := console.Current()
-	if err := c.SetRaw(); err != nil {
-		return nil, err
+// New returns a Term using the current process' console
+func New(ctx context.Context, opts ...Option) (Term, error) {
+	return NewFromConsole(ctx, console.Current(), opts...)
+}
+
+// NewFromConsole returns a Term using the provided console, which is put
+// in raw mode until the Term is closed
+func NewFromConsole(ctx context.Context, c console.Console, opts ...Option) (Term, error) {
+	return newTerm(ctx, c, c, c, c, opts...)
+}
+
+// NewFromIO returns a Term reading from in and writing to out.
+// Each side may or may not be a console (e.g. a redirected stdout):
+// the input is put in raw mode if it is a console, and the size is read from
+// the output if it is a console, from the input otherwise.
+// It returns console.ErrNotAConsole if none of them is a console.
+func NewFromIO(ctx context.Context, in io.Reader, out io.Writer, opts ...Option) (Term, error) {
+	ic, oc := asConsole(in), asConsole(out)
+	sizer := oc
+	if sizer == nil {
+		sizer = ic
+	}
+	if sizer == nil {
+		return nil, console.ErrNotAConsole
+	}
+	return newTerm(ctx, in, out, ic, sizer, opts...)
+}
+
+// rawOutput reports whether raw can keep its output processing in raw
+// mode and out is written to it
+func rawOutput(raw console.Console, out io.Writer) bool {
+	if _, ok := raw.(console.RawOutputSetter); !ok {
+		return false
 	}
-	ws, err := c.Size()
+	c, ok := out.(console.Console)
+	return ok && c.Fd() == raw.Fd()
+}
+
+// setRaw puts c in raw mode, keeping its output processing if onlcr is set
+func setRaw(c console.Console, onlcr bool) error {
+	if onlcr {
+		return c.(console.RawOutputSetter).SetRawOutput()
+	}
+	return c.SetRaw()
+}
+
+// asConsole returns v as a console if it is one, or nil
+func asConsole(v interface{}) console.Console {
+	switch c := v.(type) {
+	case console.Console:
+		return c
+	case *os.File:
+		if c, err := console.FromFile(c); err == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func newTerm(ctx context.Context, in io.Reader, out io.Writer, raw, sizer console.Console, opts ...Option) (Term, error) {
+	o := defaultOptions()
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.out != nil {
+		out = o.out
+	}
+	dst := out
+	if o.dump != nil {
+		// dump the bytes as sent to and received from the console
+		out = dumpWriter{w: out, d: o.dump}
+		in = dumpReader{r: in, d: o.dump}
+	}
+	// the console translates the newlines itself if it keeps its output
+	// processing in raw mode and the output is written to it
+	onlcr := o.translation == console.TranslateCRLF && rawOutput(raw, dst)
+	if !onlcr {
+		out = console.TranslateOutput(out, o.translation)
+	}
+	if o.escape == nil {
+		o.escape = ExitRuneHandler(o.exitRune, o.swallowDetach)
+	}
+	escape := &swapHandler{h: o.escape}
+	in = Chain(in, append(o.middlewares, Detach(escape, o.confirmDetach))...)
+	var restore []func() error
+	reset := func() {
+		unwind(restore)
+	}
+	if raw != nil && o.acquire {
+		release, err := console.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		restore = append(restore, console.OnExit(func() error {
+			release()
+			return nil
+		}))
+	}
+	if raw != nil {
+		if err := setRaw(raw, onlcr); err != nil {
+			reset()
+			return nil, err
+		}
+		restore = append(restore, console.OnExit(func() error {
+			return resetConsole(raw)
+		}))
+	}
+	ws, err := sizer.Size()
 	if err != nil {
+		reset()
 		return nil, err
 	}
-	if err := c.Resize(ws); err != nil && !errors.Is(err, console.ErrUnsupported) {
+	if err := sizer.Resize(ws); err != nil && !errors.Is(err, console.ErrUnsupported) {
+		reset()
 		return nil, err
 	}
 
-	pr, pw := io.Pipe()
-	r := io.TeeReader(c, pw)
 	term := &terminal{
-		in:      r,
-		console: c,
-		size:    Size{Rows: int(ws.Height), Cols: int(ws.Width)},
+		in:      in,
+		out:     out,
+		dst:     dst,
+		raw:     raw,
+		restore: restore,
+		sizer:   sizer,
+		onlcr:   onlcr,
+		dumb:    console.IsDumb(sizer),
+		opts:    o,
+		escape:  escape,
+		size:    SizeOf(ws),
+		sch:     make(chan Size, 1),
+		setch:   make(chan Size),
+		redraw:  make(chan struct{}),
+		rch:     make(chan chunk, inputQueue),
+		rclose:  make(chan struct{}),
 		close:   make(chan struct{}),
 	}
 
+	if o.config != nil {
+		if err := term.Apply(*o.config); err != nil {
+			reset()
+			return nil, err
+		}
+	}
+	if o.altScreen {
+		if _, err := io.WriteString(term, ansi.EnterAltScreen); err != nil {
+			term.closeWith(ReasonError, err)
+			return nil, err
+		}
+		term.restore = append(term.restore, console.OnExit(func() error {
+			_, err := io.WriteString(term, ansi.ExitAltScreen)
+			return err
+		}))
+	}
+
+	go term.pump()
+
 	go func() {
-		for {
-			time.Sleep(500 * time.Millisecond)
-			if err := ctx.Err(); err != nil {
-				return
+		select {
+		case <-ctx.Done():
+			term.closeWith(ReasonContext, ctx.Err())
+		case <-term.close:
+		}
+	}()
+
+	go term.watchSize(ws)
+
+	return term, nil
+}
+
+// watchSize updates the size when the console is resized, using the console
+// notifications if supported, polling it otherwise.
+// It publishes the new sizes, and the ones set with SetSize, on sch, only
+// keeping the latest one if it is not received, and closes it when the Term
+// is closed. The sizes are debounced if resizeDebounce is set.
+func (s *terminal) watchSize(ws console.WinSize) {
+	defer close(s.sch)
+	var notify <-chan console.WinSize
+	if n, ok := s.sizer.(console.SizeNotifier); ok {
+		notify = n.NotifySize()
+	}
+	var tick <-chan time.Time
+	if notify == nil {
+		t := time.NewTicker(s.opts.resizeInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+	// cur is the published size, which differs from the console size ws
+	// after SetSize if the console cannot be resized
+	cur := SizeOf(ws)
+	// pending is the size published when the debounce timer fires
+	var (
+		pending Size
+		timer   *time.Timer
+		fire    <-chan time.Time
+	)
+	update := func(size Size) {
+		if s.opts.resizeDebounce <= 0 {
+			if size != cur {
+				cur = size
+				s.publish(size)
 			}
-			nws, err := c.Size()
-			if err != nil {
-				continue
+			return
+		}
+		pending = size
+		if timer == nil {
+			timer = time.NewTimer(s.opts.resizeDebounce)
+		} else {
+			if !timer.Stop() && fire != nil {
+				<-timer.C
 			}
-			if nws.Height == ws.Height && nws.Width == ws.Width {
+			timer.Reset(s.opts.resizeDebounce)
+		}
+		fire = timer.C
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		var nws console.WinSize
+		select {
+		case <-tick:
+			var err error
+			if nws, err = s.sizer.Size(); err != nil {
 				continue
 			}
-			ws = nws
-			term.mu.Lock()
-			term.size = Size{Rows: int(ws.Height), Cols: int(ws.Width)}
-			term.mu.Unlock()
-
-			term.mu.RLock()
-			if term.sch != nil {
-				term.sch <- term.size
+		case nws = <-notify:
+		case size := <-s.setch:
+			update(size)
+			continue
+		case <-fire:
+			fire = nil
+			if pending != cur {
+				cur = pending
+				s.publish(pending)
 			}
-			term.mu.RUnlock()
+			continue
+		case <-s.redraw:
+			if fire != nil && !timer.Stop() {
+				<-timer.C
+			}
+			fire = nil
+			if nws, err := s.sizer.Size(); err == nil {
+				ws = nws
+				cur = SizeOf(ws)
+			}
+			s.publish(cur)
+			continue
+		case <-s.close:
+			return
 		}
-	}()
+		if nws == ws {
+			continue
+		}
+		ws = nws
+		update(SizeOf(ws))
+	}
+}
 
-	go func() {
-		defer term.Close()
+// publish sets the size and sends it on sch, it must only be called by
+// watchSize
+func (s *terminal) publish(size Size) {
+	s.mu.Lock()
+	s.size = size
+	s.mu.Unlock()
+	select {
+	case <-s.sch:
+	default:
+	}
+	s.sch <- size
+}
+
+// inputQueue is the number of chunks the pump can read ahead of the
+// application
+const inputQueue = 64
+
+type chunk struct {
+	b   []byte
+	err error
+}
+
+// pump reads the input and hands it over to Read, so that readers can be
+// released when the Term is closed even if the input read is blocking.
+// It reads ahead of the application up to inputQueue chunks, and never waits
+// for the application to detach the Term: if the queue is full, the chunk
+// preceding the detach sequence is dropped.
+// It stops reading while the Term is suspended.
+func (s *terminal) pump() {
+	for {
+		if !s.waitResumed() {
+			return
+		}
+		if !s.waitInput() {
+			continue
+		}
 		buf := make([]byte, 512)
-		for {
-			n, err := pr.Read(buf)
-			if errors.Is(err, io.EOF) {
-				return
+		n, err := s.in.Read(buf)
+		detach := errors.Is(err, ErrDetached)
+		if detach {
+			err = nil
+		}
+		if detach {
+			select {
+			case s.rch <- chunk{b: buf[:n]}:
+			default:
 			}
-			if err != nil {
+		} else if n > 0 || err != nil {
+			select {
+			case s.rch <- chunk{b: buf[:n], err: err}:
+			case <-s.close:
 				return
 			}
-			if n == 0 {
-				continue
-			}
-			if r, _ := utf8.DecodeRune(buf[:n]); r == ExitRune {
-				return
+		}
+		if detach {
+			if s.opts.onDetach != nil {
+				s.opts.onDetach()
 			}
+			s.closeWith(ReasonDetached, ErrDetached)
+			return
 		}
-	}()
+		if errors.Is(err, io.EOF) {
+			s.closeWith(ReasonEOF, io.EOF)
+			return
+		}
+		if err != nil {
+			s.closeWith(ReasonError, err)
+			return
+		}
+	}
+}
 
-	return term, nil
+// suspendPoll is how often the pump checks whether the Term was suspended
+// while it waits for input
+const suspendPoll = 100 * time.Millisecond
+
+// suspension is a Suspend call, parked is closed when the pump stopped
+// reading and resumed by Resume
+type suspension struct {
+	parked  chan struct{}
+	resumed chan struct{}
+}
+
+// waitResumed parks the pump while the Term is suspended, and reports
+// whether the Term is still open
+func (s *terminal) waitResumed() bool {
+	s.smu.Lock()
+	sp := s.suspended
+	s.smu.Unlock()
+	if sp == nil {
+		return !s.closed()
+	}
+	close(sp.parked)
+	select {
+	case <-sp.resumed:
+		return true
+	case <-s.close:
+		return false
+	}
+}
+
+// waitInput waits for the input console to be readable for suspendPoll, so
+// that the pump does not read the input of the program using the terminal
+// while the Term is suspended. It reports whether the input can be read,
+// which is always the case if the readiness cannot be waited for.
+func (s *terminal) waitInput() bool {
+	if s.raw == nil || atomic.LoadInt32(&s.nowait) != 0 {
+		return true
+	}
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	if s.closed() {
+		return false
+	}
+	ok, err := console.WaitInput(s.raw, suspendPoll)
+	if err != nil {
+		atomic.StoreInt32(&s.nowait, 1)
+		return true
+	}
+	return ok
+}
+
+func (s *terminal) Suspend() error {
+	if s.closed() {
+		return console.ErrClosed
+	}
+	s.smu.Lock()
+	if s.suspended != nil {
+		s.smu.Unlock()
+		return nil
+	}
+	if s.Config().Mouse {
+		io.WriteString(s, ansi.DisableMouse)
+	}
+	if s.opts.altScreen {
+		io.WriteString(s, ansi.ExitAltScreen)
+	}
+	if s.raw != nil {
+		if err := resetConsole(s.raw); err != nil {
+			s.smu.Unlock()
+			return err
+		}
+	}
+	sp := &suspension{parked: make(chan struct{}), resumed: make(chan struct{})}
+	s.suspended = sp
+	s.smu.Unlock()
+	// a blocking read cannot be interrupted, it receives the next input
+	if s.raw == nil || atomic.LoadInt32(&s.nowait) != 0 {
+		return nil
+	}
+	select {
+	case <-sp.parked:
+	case <-sp.resumed:
+	case <-s.close:
+	}
+	return nil
+}
+
+func (s *terminal) Resume() error {
+	if s.closed() {
+		return console.ErrClosed
+	}
+	s.smu.Lock()
+	defer s.smu.Unlock()
+	sp := s.suspended
+	if sp == nil {
+		return nil
+	}
+	if s.raw != nil {
+		if err := setRaw(s.raw, s.onlcr); err != nil {
+			return err
+		}
+	}
+	s.suspended = nil
+	close(sp.resumed)
+	if s.opts.altScreen {
+		io.WriteString(s, ansi.EnterAltScreen)
+	}
+	if s.Config().Mouse {
+		io.WriteString(s, ansi.EnableMouse)
+	}
+	select {
+	case s.redraw <- struct{}{}:
+		return nil
+	case <-s.close:
+		return console.ErrClosed
+	}
 }
 
 func (s *terminal) Read(p []byte) (n int, err error) {
-	return s.in.Read(p)
+	defer func() {
+		atomic.AddUint64(&s.read, uint64(n))
+	}()
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	select {
+	case <-s.rclose:
+		return 0, io.EOF
+	default:
+	}
+	if len(s.pending) > 0 {
+		n = copy(p, s.pending)
+		s.pending = s.pending[n:]
+		if len(s.pending) > 0 {
+			return n, nil
+		}
+		return n, s.perr
+	}
+	select {
+	case c := <-s.rch:
+		return s.deliver(p, c)
+	case <-s.close:
+		// the input read before the Term was closed is still delivered,
+		// e.g. the data typed before the detach sequence
+		select {
+		case c := <-s.rch:
+			return s.deliver(p, c)
+		default:
+			return 0, io.EOF
+		}
+	case <-s.rclose:
+		return 0, io.EOF
+	}
+}
+
+// deliver copies the chunk to p and keeps the remaining part pending,
+// rmu must be held
+func (s *terminal) deliver(p []byte, c chunk) (int, error) {
+	n := copy(p, c.b)
+	s.pending = c.b[n:]
+	if len(s.pending) > 0 {
+		s.perr = c.err
+		return n, nil
+	}
+	return n, c.err
+}
+
+func (s *terminal) CloseRead() error {
+	s.rconce.Do(func() {
+		close(s.rclose)
+	})
+	return nil
+}
+
+func (s *terminal) CloseWrite() error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if s.wclosed {
+		return nil
+	}
+	s.wclosed = true
+	if cw, ok := s.dst.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	_, err := s.out.Write([]byte{0x04})
+	return err
 }
 
 func (s *terminal) Write(p []byte) (n int, err error) {
-	return s.console.Write(p)
+	if s.closed() {
+		return 0, console.ErrClosed
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if s.wclosed {
+		return 0, io.ErrClosedPipe
+	}
+	n, err = s.out.Write(p)
+	atomic.AddUint64(&s.written, uint64(n))
+	s.tee(p[:n])
+	return n, err
+}
+
+func (s *terminal) Stats() Stats {
+	st := Stats{
+		BytesRead:    atomic.LoadUint64(&s.read),
+		BytesWritten: atomic.LoadUint64(&s.written),
+	}
+	if s.opts.latency != nil {
+		st.Latency = s.opts.latency()
+	}
+	return st
+}
+
+func (s *terminal) Dumb() bool {
+	return s.dumb
+}
+
+func (s *terminal) DisableEcho() (func() error, error) {
+	if s.closed() {
+		return nil, console.ErrClosed
+	}
+	switch {
+	case s.raw != nil && console.IsDumb(s.raw):
+		if err := s.raw.DisableEcho(); err != nil {
+			return nil, err
+		}
+		return console.OnExit(s.raw.Reset), nil
+	case s.raw == nil && s.dumb:
+		return nil, console.ErrUnsupported
+	}
+	return func() error { return nil }, nil
+}
+
+// resetConsole restores the console modes, ignoring the dumb consoles
+// over a pipe which have none
+func resetConsole(c console.Console) error {
+	if err := c.Reset(); err != nil && !errors.Is(err, console.ErrUnsupported) {
+		return err
+	}
+	return nil
+}
+
+func (s *terminal) Stderr() io.Writer {
+	return stderr{s}
+}
+
+type stderr struct {
+	s *terminal
+}
+
+func (e stderr) Write(p []byte) (int, error) {
+	if e.s.closed() {
+		return 0, console.ErrClosed
+	}
+	w := e.s.opts.stderr
+	if w == nil {
+		w = e.s.out
+	}
+	e.s.wmu.Lock()
+	defer e.s.wmu.Unlock()
+	if e.s.wclosed && w == e.s.out {
+		return 0, io.ErrClosedPipe
+	}
+	if e.s.opts.stderrStyle == "" {
+		n, err := w.Write(p)
+		e.s.tee(p[:n])
+		return n, err
+	}
+	b := ansi.AppendSGR(e.s.ebuf[:0], e.s.opts.stderrStyle)
+	b = append(b, p...)
+	b = append(b, ansi.ResetStyle...)
+	e.s.ebuf = b
+	if _, err := w.Write(b); err != nil {
+		return 0, err
+	}
+	e.s.tee(b)
+	return len(p), nil
 }
 
 func (s *terminal) Size() Size {
@@ -140,24 +860,93 @@ func (s *terminal) Size() Size {
 	return s.size
 }
 
-func (s *terminal) WatchSize() <-chan Size {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.sch == nil {
-		s.sch = make(chan Size, 1)
+func (s *terminal) SetSize(size Size) error {
+	if s.closed() {
+		return console.ErrClosed
+	}
+	if err := s.sizer.Resize(size.WinSize()); err != nil && !errors.Is(err, console.ErrUnsupported) {
+		return err
+	}
+	select {
+	case s.setch <- size:
+		return nil
+	case <-s.close:
+		return console.ErrClosed
 	}
+}
+
+func (s *terminal) WatchSize() <-chan Size {
 	return s.sch
 }
 
+// closed reports whether the Term was closed
+func (s *terminal) closed() bool {
+	select {
+	case <-s.close:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *terminal) Done() <-chan struct{} {
+	return s.close
+}
+
+func (s *terminal) Wait() error {
+	<-s.close
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+func (s *terminal) Reason() CloseReason {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.reason
+}
+
+// unwind calls the restore functions in the reverse order and returns the
+// first error
+func unwind(restore []func() error) error {
+	var err error
+	for i := len(restore) - 1; i >= 0; i-- {
+		if rerr := restore[i](); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
 func (s *terminal) Close() error {
+	err := s.closeWith(ReasonClosed, nil)
+	// wait for the pump to stop waiting for the input, so that the caller
+	// can close the console
+	s.pmu.Lock()
+	s.pmu.Unlock()
+	return err
+}
+
+func (s *terminal) closeWith(reason CloseReason, cause error) error {
 	var err error
 	s.conce.Do(func() {
-		s.mu.Lock()
-		defer s.mu.Unlock()
-		err = s.console.Reset()
-		if s.sch != nil {
-			close(s.sch)
+		s.cmu.Lock()
+		if s.mouse != nil {
+			s.mouse()
+			s.mouse = nil
+		}
+		if s.color != nil {
+			s.color()
+			s.color = nil
+			s.releaseColor()
 		}
+		s.cmu.Unlock()
+		err = unwind(s.restore)
+		// the state is set before close is closed, so that Wait and Reason
+		// see it as soon as Done is closed
+		s.mu.Lock()
+		s.reason, s.err = reason, cause
+		s.mu.Unlock()
 		close(s.close)
 	})
 	return err
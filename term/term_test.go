@@ -0,0 +1,465 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package term
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.linka.cloud/console"
+	"go.linka.cloud/console/pty"
+)
+
+// output collects what the Term writes to the PTY
+type output struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.b.Write(p)
+}
+
+// wait waits for the output to contain s
+func (o *output) wait(t *testing.T, s string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		o.mu.Lock()
+		ok := bytes.Contains(o.b.Bytes(), []byte(s))
+		o.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("output does not contain %q", s)
+}
+
+// newTestTerm returns a Term on the slave side of a PTY, and its master
+func newTestTerm(t *testing.T, opts ...Option) (*os.File, *os.File, *output, Term) {
+	t.Helper()
+	m, s, err := pty.Open()
+	if err != nil {
+		t.Skipf("no PTY: %v", err)
+	}
+	c, err := console.FromFile(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Resize(console.WinSize{Height: 24, Width: 80}); err != nil {
+		t.Fatal(err)
+	}
+	tm, err := NewFromConsole(context.Background(), c, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &output{}
+	go io.Copy(out, m)
+	t.Cleanup(func() {
+		tm.Close()
+		m.Close()
+		s.Close()
+	})
+	return m, s, out, tm
+}
+
+// read reads from the Term with a timeout
+func read(t *testing.T, tm Term, n int) ([]byte, error) {
+	t.Helper()
+	type result struct {
+		b   []byte
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		b := make([]byte, n)
+		n, err := tm.Read(b)
+		ch <- result{b[:n], err}
+	}()
+	select {
+	case r := <-ch:
+		return r.b, r.err
+	case <-time.After(5 * time.Second):
+		t.Fatal("read timed out")
+		return nil, nil
+	}
+}
+
+func TestTermReadWrite(t *testing.T) {
+	m, _, out, tm := newTestTerm(t)
+	if _, err := m.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for len(got) < len("hello") {
+		b, err := read(t, tm, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, b...)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("read %q, want %q", got, "hello")
+	}
+	if _, err := io.WriteString(tm, "world"); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "world")
+	st := tm.Stats()
+	if st.BytesRead != 5 || st.BytesWritten != 5 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestTermDetach(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "forwarded", want: "ab\x1d"},
+		{name: "swallowed", opts: []Option{WithSwallowDetach()}, want: "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _, _, tm := newTestTerm(t, tt.opts...)
+			if _, err := m.Write([]byte("ab\x1d")); err != nil {
+				t.Fatal(err)
+			}
+			if err := tm.Wait(); err != ErrDetached {
+				t.Fatalf("wait: %v, want %v", err, ErrDetached)
+			}
+			if r := tm.Reason(); r != ReasonDetached {
+				t.Fatalf("reason: %v, want %v", r, ReasonDetached)
+			}
+			b, err := read(t, tm, 16)
+			if string(b) != tt.want || err != nil {
+				t.Fatalf("read %q, %v, want %q", b, err, tt.want)
+			}
+			if _, err := read(t, tm, 16); err != io.EOF {
+				t.Fatalf("read: %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestTermClose(t *testing.T) {
+	_, _, _, tm := newTestTerm(t)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := tm.Read(make([]byte, 16))
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := tm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err != io.EOF {
+			t.Fatalf("pending read: %v, want %v", err, io.EOF)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending read not released")
+	}
+	if err := tm.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+	if err := tm.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if r := tm.Reason(); r != ReasonClosed {
+		t.Fatalf("reason: %v, want %v", r, ReasonClosed)
+	}
+	select {
+	case <-tm.Done():
+	default:
+		t.Fatal("done not closed")
+	}
+	if _, err := tm.Write([]byte("x")); !errors.Is(err, console.ErrClosed) {
+		t.Errorf("write: %v", err)
+	}
+	if _, err := tm.Stderr().Write([]byte("x")); !errors.Is(err, console.ErrClosed) {
+		t.Errorf("stderr write: %v", err)
+	}
+	if err := tm.Suspend(); !errors.Is(err, console.ErrClosed) {
+		t.Errorf("suspend: %v", err)
+	}
+	if err := tm.Resume(); !errors.Is(err, console.ErrClosed) {
+		t.Errorf("resume: %v", err)
+	}
+	if err := tm.SetSize(Size{Rows: 10, Cols: 10}); !errors.Is(err, console.ErrClosed) {
+		t.Errorf("set size: %v", err)
+	}
+	if err := tm.Inject([]byte("x")); !errors.Is(err, console.ErrClosed) {
+		t.Errorf("inject: %v", err)
+	}
+	if _, ok := <-tm.WatchSize(); ok {
+		t.Error("size channel not closed")
+	}
+}
+
+func TestTermContext(t *testing.T) {
+	m, s, err := pty.Open()
+	if err != nil {
+		t.Skipf("no PTY: %v", err)
+	}
+	defer m.Close()
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	tm, err := NewFromIO(ctx, s, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := tm.Wait(); err != context.Canceled {
+		t.Fatalf("wait: %v, want %v", err, context.Canceled)
+	}
+	if r := tm.Reason(); r != ReasonContext {
+		t.Fatalf("reason: %v, want %v", r, ReasonContext)
+	}
+	// Close waits for the pump before the PTY is closed
+	if err := tm.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTermSuspend(t *testing.T) {
+	m, s, _, tm := newTestTerm(t)
+	if err := tm.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	// a second call is a no-op
+	if err := tm.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	// the input goes to the program using the terminal, in canonical mode
+	if _, err := m.Write([]byte("typed\n")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 16)
+	n, err := s.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "typed\n" {
+		t.Fatalf("suspended input %q, want %q", b[:n], "typed\n")
+	}
+	if err := tm.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case size := <-tm.WatchSize():
+		if size.Rows != 24 || size.Cols != 80 {
+			t.Fatalf("size %+v", size)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no size after resume")
+	}
+	if _, err := m.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := read(t, tm, 16); string(b) != "x" || err != nil {
+		t.Fatalf("read %q, %v, want %q", b, err, "x")
+	}
+}
+
+func TestTermTranslateCRLF(t *testing.T) {
+	_, _, out, tm := newTestTerm(t, WithOutputTranslation(console.TranslateCRLF))
+	if _, err := io.WriteString(tm, "a\nb"); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "a\r\nb")
+	// the raw mode is restored with the newline translation
+	if err := tm.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(tm, "c\nd"); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "c\r\nd")
+}
+
+func TestTermSetSize(t *testing.T) {
+	_, s, _, tm := newTestTerm(t)
+	want := Size{Rows: 10, Cols: 40}
+	if err := tm.SetSize(want); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case size := <-tm.WatchSize():
+		if size != want {
+			t.Fatalf("size %+v, want %+v", size, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no size")
+	}
+	if size := tm.Size(); size != want {
+		t.Fatalf("size %+v, want %+v", size, want)
+	}
+	c, err := console.FromFile(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws, err := c.Size(); err != nil || ws.Height != 10 || ws.Width != 40 {
+		t.Fatalf("console size %+v, %v", ws, err)
+	}
+}
+
+// TestTermConcurrentClose exercises the Term operations racing with Close
+func TestTermConcurrentClose(t *testing.T) {
+	m, _, _, tm := newTestTerm(t)
+	var wg sync.WaitGroup
+	ops := []func(i int) error{
+		func(i int) error {
+			return tm.SetSize(Size{Rows: 10 + i, Cols: 10 + i})
+		},
+		func(int) error {
+			_, err := tm.Write([]byte("x"))
+			return err
+		},
+		func(int) error {
+			_, err := tm.Read(make([]byte, 16))
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		},
+		func(int) error {
+			if err := tm.Suspend(); err != nil {
+				return err
+			}
+			return tm.Resume()
+		},
+		func(int) error {
+			_, err := m.Write([]byte("y"))
+			return err
+		},
+	}
+	for _, op := range ops {
+		op := op
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				if err := op(i % 10); err != nil {
+					if !errors.Is(err, console.ErrClosed) {
+						t.Error(err)
+					}
+					return
+				}
+				select {
+				case <-tm.Done():
+					return
+				default:
+				}
+			}
+		}()
+	}
+	go func() {
+		for range tm.WatchSize() {
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := tm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+}
+
+func BenchmarkTermWrite(b *testing.B) {
+	m, s, err := pty.Open()
+	if err != nil {
+		b.Skipf("no PTY: %v", err)
+	}
+	defer m.Close()
+	defer s.Close()
+	for _, size := range []int{16, 256, 4096} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			tm, err := NewFromIO(context.Background(), s, s, WithOutput(ioutil.Discard))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer tm.Close()
+			p := bytes.Repeat([]byte("x"), size)
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := tm.Write(p); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTermAcquire(t *testing.T) {
+	release, ok := console.TryAcquire()
+	if !ok {
+		t.Fatal("not acquired")
+	}
+	m, s, err := pty.Open()
+	if err != nil {
+		release()
+		t.Skipf("no PTY: %v", err)
+	}
+	defer m.Close()
+	defer s.Close()
+	c, err := console.FromFile(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := NewFromConsole(ctx, c, WithAcquire()); err != context.DeadlineExceeded {
+		t.Fatalf("new while held: %v, want %v", err, context.DeadlineExceeded)
+	}
+	release()
+	tm, err := NewFromConsole(context.Background(), c, WithAcquire())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := console.TryAcquire(); ok {
+		t.Fatal("not held by the Term")
+	}
+	// a stale release does not release the Term ownership
+	release()
+	if _, ok := console.TryAcquire(); ok {
+		t.Fatal("released by a previous owner")
+	}
+	if err := tm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	release, ok = console.TryAcquire()
+	if !ok {
+		t.Fatal("not released by Close")
+	}
+	release()
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// terminfoDirs returns the directories searched for the terminfo entries,
+// as ncurses does
+func terminfoDirs() []string {
+	var dirs []string
+	if d := os.Getenv("TERMINFO"); d != "" {
+		dirs = append(dirs, d)
+	}
+	if h, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(h, ".terminfo"))
+	}
+	for _, d := range strings.Split(os.Getenv("TERMINFO_DIRS"), ":") {
+		if d != "" {
+			dirs = append(dirs, d)
+		}
+	}
+	return append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo")
+}
+
+// terminfoSize returns the cols and lines capabilities of the compiled
+// terminfo entry of the terminal, 0 if unknown
+func terminfoSize(term string) (cols, lines int) {
+	if term == "" || strings.ContainsAny(term, "/\\") {
+		return 0, 0
+	}
+	for _, d := range terminfoDirs() {
+		// the entries are in a directory named after their first letter,
+		// or its hexadecimal code on case insensitive file systems
+		for _, sub := range []string{term[:1], strconv.FormatUint(uint64(term[0]), 16)} {
+			b, err := ioutil.ReadFile(filepath.Join(d, sub, term))
+			if err != nil {
+				continue
+			}
+			return parseTerminfoSize(b)
+		}
+	}
+	return 0, 0
+}
+
+// parseTerminfoSize returns the cols and lines numbers of a compiled
+// terminfo entry, see term(5)
+func parseTerminfoSize(b []byte) (cols, lines int) {
+	if len(b) < 12 {
+		return 0, 0
+	}
+	// the legacy format has 16 bits numbers, the extended one 32 bits ones
+	size := 0
+	switch binary.LittleEndian.Uint16(b) {
+	case 0432:
+		size = 2
+	case 01036:
+		size = 4
+	default:
+		return 0, 0
+	}
+	names := int(binary.LittleEndian.Uint16(b[2:]))
+	bools := int(binary.LittleEndian.Uint16(b[4:]))
+	count := int(binary.LittleEndian.Uint16(b[6:]))
+	// the numbers are aligned on an even offset
+	off := 12 + names + bools
+	off += off % 2
+	num := func(i int) int {
+		p := off + i*size
+		if i >= count || p+size > len(b) {
+			return 0
+		}
+		var v int
+		if size == 2 {
+			v = int(int16(binary.LittleEndian.Uint16(b[p:])))
+		} else {
+			v = int(int32(binary.LittleEndian.Uint32(b[p:])))
+		}
+		// -1 is an absent capability, -2 a cancelled one
+		if v < 0 {
+			return 0
+		}
+		return v
+	}
+	return num(0), num(2)
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"io"
+	"sync"
+)
+
+// OutputTranslation is the newline translation applied to the output
+type OutputTranslation int
+
+const (
+	// TranslateNone writes the output untouched, which is the raw mode behavior
+	TranslateNone OutputTranslation = iota
+	// TranslateCRLF turns "\n" into "\r\n", as the terminal does in cooked
+	// mode (onlcr), so that normal newlines can still be printed in raw mode
+	TranslateCRLF
+)
+
+// TranslateOutput returns a writer applying the translation t to the data
+// written to w
+func TranslateOutput(w io.Writer, t OutputTranslation) io.Writer {
+	if t == TranslateNone {
+		return w
+	}
+	return &crlfWriter{w: w}
+}
+
+type crlfWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+	// cr reports whether the last byte written was a carriage return
+	cr bool
+	// buf is reused to translate the writes containing newlines
+	buf []byte
+}
+
+func (c *crlfWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := p
+	translated := false
+	start := 0
+	for i, v := range p {
+		if v == '\n' && !(i == 0 && c.cr || i > 0 && p[i-1] == '\r') {
+			if !translated {
+				b, translated = c.buf[:0], true
+			}
+			b = append(b, p[start:i]...)
+			b = append(b, '\r')
+			start = i
+		}
+	}
+	if translated {
+		b = append(b, p[start:]...)
+		c.buf = b
+	}
+	if _, err := c.w.Write(b); err != nil {
+		return 0, err
+	}
+	if len(p) > 0 {
+		c.cr = p[len(p)-1] == '\r'
+	}
+	return len(p), nil
+}
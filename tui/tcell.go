@@ -0,0 +1,161 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tui
+
+import (
+	"io"
+	"sync"
+
+	"go.linka.cloud/console/term"
+)
+
+// Tty exposes a Term as a tcell Tty (up to tcell v2.7, for the later
+// versions, WindowSize must be wrapped to return a tcell.WindowSize).
+// The Term is already in raw mode: Start and Stop only control whether the
+// input is read, and Close leaves the Term open.
+type Tty struct {
+	t term.Term
+
+	mu     sync.Mutex
+	drain  chan struct{}
+	closed chan struct{}
+	once   sync.Once
+	resize func()
+
+	input   chan []byte
+	pending []byte
+	start   sync.Once
+}
+
+// NewTty returns a Tty using the Term, it consumes the Term WatchSize
+// channel
+func NewTty(t term.Term) *Tty {
+	return &Tty{t: t, drain: make(chan struct{}), closed: make(chan struct{}), input: make(chan []byte)}
+}
+
+// Start starts reading the input
+func (y *Tty) Start() error {
+	y.mu.Lock()
+	select {
+	case <-y.drain:
+		y.drain = make(chan struct{})
+	default:
+	}
+	y.mu.Unlock()
+	y.start.Do(func() {
+		go y.read()
+		go y.watch()
+	})
+	return nil
+}
+
+// Stop stops reading the input, the pending reads return
+func (y *Tty) Stop() error {
+	return y.Drain()
+}
+
+// Drain makes the pending reads return
+func (y *Tty) Drain() error {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	select {
+	case <-y.drain:
+	default:
+		close(y.drain)
+	}
+	return nil
+}
+
+// NotifyResize sets the callback notified when the Term is resized
+func (y *Tty) NotifyResize(cb func()) {
+	y.mu.Lock()
+	y.resize = cb
+	y.mu.Unlock()
+}
+
+// WindowSize returns the Term size
+func (y *Tty) WindowSize() (width, height int, err error) {
+	sz := y.t.Size()
+	return sz.Cols, sz.Rows, nil
+}
+
+// Read reads the Term input, it returns without data once drained
+func (y *Tty) Read(p []byte) (int, error) {
+	if len(y.pending) > 0 {
+		n := copy(p, y.pending)
+		y.pending = y.pending[n:]
+		return n, nil
+	}
+	y.mu.Lock()
+	drain := y.drain
+	y.mu.Unlock()
+	select {
+	case b, ok := <-y.input:
+		if !ok {
+			return 0, io.EOF
+		}
+		n := copy(p, b)
+		y.pending = b[n:]
+		return n, nil
+	case <-drain:
+		return 0, nil
+	case <-y.closed:
+		return 0, io.EOF
+	}
+}
+
+// Write writes to the Term
+func (y *Tty) Write(p []byte) (int, error) {
+	return y.t.Write(p)
+}
+
+// Close stops the Tty, the Term is left open, but the chunk of input
+// being read when it is closed is dropped
+func (y *Tty) Close() error {
+	y.once.Do(func() {
+		close(y.closed)
+	})
+	return nil
+}
+
+// read hands the Term input over to Read, so that the reads can be drained
+func (y *Tty) read() {
+	defer close(y.input)
+	for {
+		buf := make([]byte, 512)
+		n, err := y.t.Read(buf)
+		if n > 0 {
+			select {
+			case y.input <- buf[:n]:
+			case <-y.closed:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (y *Tty) watch() {
+	for range y.t.WatchSize() {
+		y.mu.Lock()
+		cb := y.resize
+		y.mu.Unlock()
+		if cb != nil {
+			cb()
+		}
+	}
+}
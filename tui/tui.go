@@ -0,0 +1,44 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tui runs TUI frameworks on a Term, so that applications built on
+// them can use the sessions, recording and remote features of this module.
+//
+// Tty implements the tcell Tty interface:
+//
+//	screen, err := tcell.NewTerminfoScreenFromTty(tui.NewTty(t))
+//
+// bubbletea only needs the Term as its input and output, and the size
+// changes sent as messages:
+//
+//	p := tea.NewProgram(model, tea.WithInput(t), tea.WithOutput(t))
+//	go tui.WatchSize(t, func(cols, rows int) {
+//		p.Send(tea.WindowSizeMsg{Width: cols, Height: rows})
+//	})
+package tui
+
+import (
+	"go.linka.cloud/console/term"
+)
+
+// WatchSize calls fn with the current size of the Term, then with each new
+// size until the Term is closed.
+// It consumes the Term WatchSize channel.
+func WatchSize(t term.Term, fn func(cols, rows int)) {
+	sz := t.Size()
+	fn(sz.Cols, sz.Rows)
+	for sz := range t.WatchSize() {
+		fn(sz.Cols, sz.Rows)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+// charset is a character set designated to G0 or G1 (ISO 2022)
+type charset byte
+
+const (
+	charsetASCII charset = iota
+	// charsetUK replaces '#' with the pound sign
+	charsetUK
+	// charsetGraphics is the DEC special graphics set, used by ncurses
+	// to draw the lines and boxes
+	charsetGraphics
+)
+
+// charsetOf returns the charset designated by the final byte of
+// ESC ( F or ESC ) F, ASCII if unsupported
+func charsetOf(final byte) charset {
+	switch final {
+	case '0':
+		return charsetGraphics
+	case 'A':
+		return charsetUK
+	default:
+		return charsetASCII
+	}
+}
+
+// decGraphics maps the DEC special graphics characters from 0x5f to 0x7e
+// to their Unicode equivalent
+var decGraphics = [...]rune{
+	' ', // 0x5f blank
+	'◆', // ` diamond
+	'▒', // a checkerboard
+	'␉', // b HT
+	'␌', // c FF
+	'␍', // d CR
+	'␊', // e LF
+	'°', // f degree
+	'±', // g plus/minus
+	'␤', // h NL
+	'␋', // i VT
+	'┘', // j lower right corner
+	'┐', // k upper right corner
+	'┌', // l upper left corner
+	'└', // m lower left corner
+	'┼', // n crossing lines
+	'⎺', // o scan line 1
+	'⎻', // p scan line 3
+	'─', // q scan line 5, horizontal line
+	'⎼', // r scan line 7
+	'⎽', // s scan line 9
+	'├', // t left tee
+	'┤', // u right tee
+	'┴', // v bottom tee
+	'┬', // w top tee
+	'│', // x vertical line
+	'≤', // y less than or equal
+	'≥', // z greater than or equal
+	'π', // { pi
+	'≠', // | not equal
+	'£', // } pound sign
+	'·', // ~ centered dot
+}
+
+// translate returns the character r stands for in the charset
+func (c charset) translate(r rune) rune {
+	switch c {
+	case charsetGraphics:
+		if r >= 0x5f && r <= 0x7e {
+			return decGraphics[r-0x5f]
+		}
+	case charsetUK:
+		if r == '#' {
+			return '£'
+		}
+	}
+	return r
+}
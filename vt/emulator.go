@@ -0,0 +1,422 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+import (
+	"strconv"
+	"strings"
+
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/screen"
+)
+
+type cursor struct {
+	col, row int
+	style    sgr
+	charsets [2]charset
+	gl       int
+}
+
+// emulator is the ansi.Handler updating the screen
+type emulator struct {
+	cols, rows int
+	buf        *screen.Buffer
+	// alt is the main screen saved while the alternate screen is active
+	alt *screen.Buffer
+
+	col, row int
+	// wrap is set when a character was printed in the last column:
+	// the next one is printed on the next line
+	wrap   bool
+	style  sgr
+	saved  cursor
+	hidden bool
+	// top and bottom are the scrolling region rows (included)
+	top, bottom int
+	nowrap      bool
+	title       string
+	// charsets are the G0 and G1 character sets, and gl the one in use,
+	// selected by SI and SO
+	charsets [2]charset
+	gl       int
+	// history are the lines scrolled off the top of the main screen,
+	// kept across resets
+	history *scrollback
+	// scrolled is the number of lines scrolled off the top of the main
+	// screen, kept across resets
+	scrolled int
+	// truncate disables the reflow on resize
+	truncate bool
+}
+
+func (e *emulator) init(cols, rows int) {
+	*e = emulator{cols: cols, rows: rows, buf: screen.NewBuffer(cols, rows), bottom: rows - 1, history: e.history, scrolled: e.scrolled, truncate: e.truncate}
+}
+
+func (e *emulator) resize(cols, rows int) {
+	switch {
+	case e.truncate || cols <= 0 || rows <= 0:
+		e.buf.Resize(cols, rows)
+		if e.alt != nil {
+			e.alt.Resize(cols, rows)
+		}
+	case e.alt == nil:
+		e.buf, e.col, e.row = e.reflow(e.buf, e.col, e.row, e.wrap, cols, rows)
+	default:
+		// the alternate screen is redrawn by the application, the main
+		// screen is reflowed with the cursor saved when switching
+		e.buf.Resize(cols, rows)
+		e.alt, e.saved.col, e.saved.row = e.reflow(e.alt, e.saved.col, e.saved.row, false, cols, rows)
+	}
+	e.cols, e.rows = cols, rows
+	e.top, e.bottom = 0, rows-1
+	e.move(e.col, e.row)
+}
+
+func (e *emulator) line(row, from int) string {
+	var b strings.Builder
+	if row < 0 {
+		if i := e.history.len() + row; i >= 0 {
+			cells := e.history.line(i).cells
+			for x := from; x < len(cells); x++ {
+				b.WriteString(cells[x].String())
+			}
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+	for x := from; x < e.cols; x++ {
+		b.WriteString(e.buf.Cell(x, row).String())
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// move moves the cursor, clamping it to the screen
+func (e *emulator) move(col, row int) {
+	e.col, e.row, e.wrap = clamp(col, 0, e.cols-1), clamp(row, 0, e.rows-1), false
+}
+
+func clamp(v, min, max int) int {
+	if v > max {
+		v = max
+	}
+	if v < min {
+		v = min
+	}
+	return v
+}
+
+func (e *emulator) Print(r rune) {
+	r = e.charsets[e.gl].translate(r)
+	w := screen.RuneWidth(r)
+	if w == 0 {
+		e.combine(r)
+		return
+	}
+	if w > e.cols {
+		// replaced by a space by the buffer
+		w = 1
+	}
+	if e.wrap || w == 2 && e.col == e.cols-1 && !e.nowrap {
+		// a wide character does not fit in the last column: it is printed
+		// on the next line
+		e.buf.SetWrapped(e.row, true)
+		e.col, e.wrap = 0, false
+		e.lineFeed()
+	}
+	if w == 2 && e.col == e.cols-1 {
+		// no autowrap: the character is printed in the last two columns
+		e.col = e.cols - 2
+	}
+	e.buf.SetCell(e.col, e.row, screen.Cell{Rune: r, Style: e.style.String()})
+	if e.col+w < e.cols {
+		e.col += w
+	} else if !e.nowrap {
+		e.col = e.cols - 1
+		e.wrap = true
+	}
+}
+
+// combine merges the combining mark with the last printed character
+func (e *emulator) combine(r rune) {
+	col := e.col
+	if !e.wrap {
+		col--
+	}
+	if col < 0 {
+		return
+	}
+	e.buf.Combine(col, e.row, r)
+}
+
+// save saves the cursor (DECSC)
+func (e *emulator) save() {
+	e.saved = cursor{col: e.col, row: e.row, style: e.style, charsets: e.charsets, gl: e.gl}
+}
+
+// restore restores the cursor saved by save (DECRC)
+func (e *emulator) restore() {
+	e.move(e.saved.col, e.saved.row)
+	e.style, e.charsets, e.gl = e.saved.style, e.saved.charsets, e.saved.gl
+}
+
+func (e *emulator) lineFeed() {
+	if e.row == e.bottom {
+		e.scrollUp(1)
+	} else if e.row < e.rows-1 {
+		e.row++
+	}
+}
+
+// copyRow returns a copy of the row y
+func (e *emulator) copyRow(y int) line {
+	l := line{cells: make([]screen.Cell, e.cols), wrapped: e.buf.Wrapped(y)}
+	for x := range l.cells {
+		l.cells[x] = e.buf.Cell(x, y)
+	}
+	return l
+}
+
+// scrollUp scrolls the scrolling region up by n lines, saving the lines
+// scrolled off the top of the main screen in the scrollback
+func (e *emulator) scrollUp(n int) {
+	if e.top == 0 && e.alt == nil {
+		for y := 0; y < n && y <= e.bottom; y++ {
+			e.history.push(e.copyRow(y))
+			e.scrolled++
+		}
+	}
+	e.buf.ScrollUp(e.top, e.bottom, n)
+}
+
+func (e *emulator) reverseIndex() {
+	if e.row == e.top {
+		e.buf.ScrollDown(e.top, e.bottom, 1)
+	} else if e.row > 0 {
+		e.row--
+	}
+}
+
+func (e *emulator) Execute(c byte) {
+	switch c {
+	case '\r':
+		e.col, e.wrap = 0, false
+	case '\n', '\v', '\f':
+		e.wrap = false
+		e.lineFeed()
+	case '\b':
+		e.move(e.col-1, e.row)
+	case '\t':
+		e.move((e.col/8+1)*8, e.row)
+	case 0x0e:
+		e.gl = 1
+	case 0x0f:
+		e.gl = 0
+	}
+}
+
+func (e *emulator) ESC(seq ansi.Sequence) {
+	if len(seq.Intermediate) == 1 {
+		switch seq.Intermediate[0] {
+		case '(':
+			e.charsets[0] = charsetOf(seq.Final)
+		case ')':
+			e.charsets[1] = charsetOf(seq.Final)
+		}
+		return
+	}
+	if len(seq.Intermediate) > 0 {
+		return
+	}
+	switch seq.Final {
+	case 'D':
+		e.lineFeed()
+	case 'E':
+		e.col = 0
+		e.lineFeed()
+	case 'M':
+		e.reverseIndex()
+	case '7':
+		e.save()
+	case '8':
+		e.restore()
+	case 'c':
+		e.init(e.cols, e.rows)
+	}
+}
+
+func (e *emulator) CSI(seq ansi.Sequence) {
+	if seq.Prefix == '?' {
+		e.mode(seq)
+		return
+	}
+	if seq.Prefix != 0 || len(seq.Intermediate) > 0 {
+		return
+	}
+	n := seq.Param(0, 1)
+	switch seq.Final {
+	case 'A':
+		e.move(e.col, e.row-n)
+	case 'B', 'e':
+		e.move(e.col, e.row+n)
+	case 'C', 'a':
+		e.move(e.col+n, e.row)
+	case 'D':
+		e.move(e.col-n, e.row)
+	case 'E':
+		e.move(0, e.row+n)
+	case 'F':
+		e.move(0, e.row-n)
+	case 'G', '`':
+		e.move(n-1, e.row)
+	case 'd':
+		e.move(e.col, n-1)
+	case 'H', 'f':
+		e.move(seq.Param(1, 1)-1, n-1)
+	case 'J':
+		e.eraseDisplay(seq.Param(0, 0))
+	case 'K':
+		e.eraseLine(seq.Param(0, 0))
+	case 'L':
+		if e.row >= e.top && e.row <= e.bottom {
+			e.buf.ScrollDown(e.row, e.bottom, n)
+		}
+	case 'M':
+		if e.row >= e.top && e.row <= e.bottom {
+			e.buf.ScrollUp(e.row, e.bottom, n)
+		}
+	case 'S':
+		e.scrollUp(n)
+	case 'T':
+		e.buf.ScrollDown(e.top, e.bottom, n)
+	case 'P':
+		e.shift(e.col, -n)
+	case '@':
+		e.shift(e.col, n)
+	case 'X':
+		for x := e.col; x < e.col+n && x < e.cols; x++ {
+			e.buf.SetCell(x, e.row, screen.Cell{})
+		}
+	case 'r':
+		top, bottom := seq.Param(0, 1)-1, seq.Param(1, e.rows)-1
+		if top < bottom && bottom < e.rows {
+			e.top, e.bottom = top, bottom
+			e.move(0, 0)
+		}
+	case 's':
+		e.save()
+	case 'u':
+		e.move(e.saved.col, e.saved.row)
+	case 'm':
+		e.style.apply(seq.Params)
+	}
+}
+
+// shift moves the cells of the cursor row from col by n columns,
+// to the right if n is positive, to the left otherwise
+func (e *emulator) shift(col, n int) {
+	// the cells are read from a copy of the row, as setting a cell may
+	// erase the wide character next to it
+	row := make([]screen.Cell, e.cols)
+	for x := range row {
+		row[x] = e.buf.Cell(x, e.row)
+	}
+	for x := col; x < e.cols; x++ {
+		c := screen.Cell{}
+		if x-n >= col && x-n < e.cols {
+			c = row[x-n]
+		}
+		e.buf.SetCell(x, e.row, c)
+	}
+}
+
+func (e *emulator) eraseLine(mode int) {
+	from, to := e.col, e.cols
+	switch mode {
+	case 1:
+		from, to = 0, e.col+1
+	case 2:
+		from = 0
+	}
+	for x := from; x < to; x++ {
+		e.buf.SetCell(x, e.row, screen.Cell{})
+	}
+}
+
+func (e *emulator) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		e.eraseLine(0)
+		for y := e.row + 1; y < e.rows; y++ {
+			e.clearRow(y)
+		}
+	case 1:
+		e.eraseLine(1)
+		for y := 0; y < e.row; y++ {
+			e.clearRow(y)
+		}
+	case 2:
+		e.buf.Clear()
+	case 3:
+		e.history.clear()
+	}
+}
+
+func (e *emulator) clearRow(y int) {
+	for x := 0; x < e.cols; x++ {
+		e.buf.SetCell(x, y, screen.Cell{})
+	}
+	e.buf.SetWrapped(y, false)
+}
+
+func (e *emulator) mode(seq ansi.Sequence) {
+	set := seq.Final == 'h'
+	if !set && seq.Final != 'l' {
+		return
+	}
+	for _, p := range seq.Params {
+		switch p {
+		case 7:
+			e.nowrap = !set
+		case 25:
+			e.hidden = !set
+		case 47, 1047, 1049:
+			if set == (e.alt != nil) {
+				continue
+			}
+			if p == 1049 && set {
+				e.save()
+			}
+			if set {
+				e.alt, e.buf = e.buf, screen.NewBuffer(e.cols, e.rows)
+			} else {
+				e.buf, e.alt = e.alt, nil
+			}
+			if p == 1049 && !set {
+				e.restore()
+			}
+		}
+	}
+}
+
+func (e *emulator) OSC(data []byte) {
+	s := string(data)
+	i := strings.IndexByte(s, ';')
+	if i < 0 {
+		return
+	}
+	if n, err := strconv.Atoi(s[:i]); err == nil && (n == 0 || n == 2) {
+		e.title = s[i+1:]
+	}
+}
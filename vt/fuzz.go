@@ -0,0 +1,43 @@
+//go:build gofuzz
+// +build gofuzz
+
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+// Fuzz is the go-fuzz (and libFuzzer, through go-fuzz-build -libfuzzer)
+// entry point: it checks that the Terminal does not panic on malformed
+// output, e.g. out of range cursor moves or scroll regions, with a size
+// taken from the first two bytes and a resize in the middle of the stream.
+// The corpus is in testdata/corpus.
+func Fuzz(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+	cols, rows := int(data[0]%200)+1, int(data[1]%100)+1
+	data = data[2:]
+	t := New(cols, rows)
+	half := len(data) / 2
+	t.Write(data[:half])
+	t.Resize(cols/2+1, rows+1)
+	t.Write(data[half:])
+	c, r := t.Cursor()
+	w, h := t.Size()
+	if c < 0 || r < 0 || c > w || r >= h {
+		panic("cursor out of the screen")
+	}
+	_ = t.String()
+	return 1
+}
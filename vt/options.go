@@ -0,0 +1,65 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+import (
+	"go.linka.cloud/console/ansi"
+)
+
+// DefaultScrollback is the default maximum number of scrollback lines
+const DefaultScrollback = 1000
+
+type options struct {
+	scrollback int
+	truncate   bool
+	parser     []ansi.ParserOption
+}
+
+// Option configures a Terminal
+type Option func(o *options)
+
+// WithScrollback sets the maximum number of lines scrolled off the top of
+// the screen kept in the scrollback, the oldest are dropped first,
+// defaults to DefaultScrollback, 0 disables it
+func WithScrollback(lines int) Option {
+	return func(o *options) {
+		o.scrollback = lines
+	}
+}
+
+// WithoutReflow makes the resizes truncate the rows, or pad them, instead
+// of rewrapping the soft wrapped lines to the new width, which is the
+// legacy terminals behavior
+func WithoutReflow() Option {
+	return func(o *options) {
+		o.truncate = true
+	}
+}
+
+// WithMaxOSC sets the maximum OSC data size, e.g. of the window title,
+// defaults to ansi.DefaultMaxOSC
+func WithMaxOSC(n int) Option {
+	return func(o *options) {
+		o.parser = append(o.parser, ansi.WithMaxOSC(n))
+	}
+}
+
+// WithMaxParams sets the maximum number of parameters of a control
+// sequence, defaults to ansi.DefaultMaxParams
+func WithMaxParams(n int) Option {
+	return func(o *options) {
+		o.parser = append(o.parser, ansi.WithMaxParams(n))
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+import (
+	"go.linka.cloud/console/screen"
+)
+
+// reflow returns the buffer resized to cols and rows with the soft wrapped
+// lines, including the scrollback ones, rewrapped to the new width, and
+// the new position of the cursor at col, row in buf, pending reporting
+// whether it is waiting to wrap.
+// The rows pushed off the top of the screen go to the scrollback, and the
+// rows pulled back when the screen grows are taken from it.
+func (e *emulator) reflow(buf *screen.Buffer, col, row int, pending bool, cols, rows int) (*screen.Buffer, int, int) {
+	oldCols, oldRows := buf.Size()
+	// the logical lines are the rows joined with the following ones when
+	// soft wrapped
+	var lines [][]screen.Cell
+	var cur []screen.Cell
+	// unfill removes the last column left empty by a wide character
+	// printed on the next row, which is not part of the line
+	unfill := func(next []screen.Cell) {
+		if n := len(cur); n > 0 && len(next) > 0 && next[0].Width() == 2 && cur[n-1] == (screen.Cell{}) {
+			cur = cur[:n-1]
+		}
+	}
+	add := func(cells []screen.Cell, wrapped bool) {
+		unfill(cells)
+		cur = append(cur, cells...)
+		if !wrapped {
+			lines = append(lines, trim(cur))
+			cur = nil
+		}
+	}
+	for i := 0; i < e.history.len(); i++ {
+		l := e.history.line(i)
+		add(l.cells, l.wrapped)
+	}
+	last := row
+	for y := oldRows - 1; y > row; y-- {
+		if !blank(buf, y) {
+			last = y
+			break
+		}
+	}
+	cline, coff := 0, 0
+	for y := 0; y <= last; y++ {
+		cells := make([]screen.Cell, oldCols)
+		for x := range cells {
+			cells[x] = buf.Cell(x, y)
+		}
+		if y == row {
+			unfill(cells)
+			cline, coff = len(lines), len(cur)+col
+			if pending {
+				coff++
+			}
+		}
+		add(cells, buf.Wrapped(y) && y < last)
+	}
+
+	var out []line
+	crow, ccol := 0, 0
+	for i, l := range lines {
+		r := line{}
+		found := false
+		for j, c := range l {
+			if c.Continuation {
+				continue
+			}
+			w := c.Width()
+			if w > cols {
+				c, w = screen.Cell{Rune: ' ', Style: c.Style}, 1
+			}
+			if len(r.cells)+w > cols {
+				r.wrapped = true
+				out = append(out, r)
+				r = line{}
+			}
+			if i == cline && !found && coff < j+w {
+				crow, ccol, found = len(out), len(r.cells), true
+			}
+			r.cells = append(r.cells, c)
+			if w == 2 {
+				r.cells = append(r.cells, screen.Cell{Style: c.Style, Continuation: true})
+			}
+		}
+		if i == cline && !found {
+			// the cursor is after the end of the line
+			extra := coff - len(l)
+			ccol = len(r.cells) + extra
+			if ccol >= cols && extra == 0 {
+				ccol = cols - 1
+			}
+			for ccol >= cols {
+				out = append(out, r)
+				r = line{}
+				ccol -= cols
+			}
+			crow = len(out)
+		}
+		out = append(out, r)
+	}
+
+	// the screen shows the last rows, keeping the cursor on it
+	end := len(out)
+	top := end - rows
+	if top < 0 {
+		top = 0
+	}
+	if crow < top {
+		// the rows below the cursor are dropped
+		top, end = crow, crow+rows
+	}
+	e.history.clear()
+	for _, r := range out[:top] {
+		e.history.push(r)
+	}
+	b := screen.NewBuffer(cols, rows)
+	for y, r := range out[top:end] {
+		for x, c := range r.cells {
+			if !c.Continuation {
+				b.SetCell(x, y, c)
+			}
+		}
+		b.SetWrapped(y, r.wrapped)
+	}
+	return b, ccol, crow - top
+}
+
+// trim removes the empty cells at the end of the line
+func trim(cells []screen.Cell) []screen.Cell {
+	n := len(cells)
+	for n > 0 && cells[n-1].Rune == 0 && cells[n-1].Combining == "" && !cells[n-1].Continuation {
+		n--
+	}
+	return cells[:n]
+}
+
+// blank reports whether the row y is empty
+func blank(buf *screen.Buffer, y int) bool {
+	cols, _ := buf.Size()
+	for x := 0; x < cols; x++ {
+		if buf.Cell(x, y).Rune != 0 {
+			return false
+		}
+	}
+	return !buf.Wrapped(y)
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+import (
+	"go.linka.cloud/console/screen"
+)
+
+// line is a screen row
+type line struct {
+	cells []screen.Cell
+	// wrapped reports whether the row continues on the next one
+	wrapped bool
+}
+
+// scrollback is a ring of the rows scrolled off the top of the screen
+type scrollback struct {
+	lines []line
+	// start is the index of the oldest line once the ring is full
+	start int
+	max   int
+}
+
+func (s *scrollback) push(l line) {
+	if s.max <= 0 {
+		return
+	}
+	if len(s.lines) < s.max {
+		s.lines = append(s.lines, l)
+		return
+	}
+	s.lines[s.start] = l
+	s.start = (s.start + 1) % s.max
+}
+
+// line returns the i-th line, the oldest first
+func (s *scrollback) line(i int) line {
+	return s.lines[(s.start+i)%len(s.lines)]
+}
+
+func (s *scrollback) len() int {
+	return len(s.lines)
+}
+
+func (s *scrollback) clear() {
+	s.lines, s.start = nil, 0
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Position is a position in the scrollback and the screen: the rows from 0
+// to ScrollbackLen are the scrollback lines, the oldest first, followed by
+// the screen rows
+type Position struct {
+	Row, Col int
+}
+
+// Match is a search match, from Start to End (excluded), which may be on
+// different rows if the line was soft wrapped
+type Match struct {
+	Start, End Position
+	Text       string
+}
+
+type searchOptions struct {
+	regexp     bool
+	ignoreCase bool
+}
+
+// SearchOption configures Search
+type SearchOption func(o *searchOptions)
+
+// WithRegexp makes the query a regular expression
+func WithRegexp() SearchOption {
+	return func(o *searchOptions) {
+		o.regexp = true
+	}
+}
+
+// WithIgnoreCase makes the search case insensitive
+func WithIgnoreCase() SearchOption {
+	return func(o *searchOptions) {
+		o.ignoreCase = true
+	}
+}
+
+// Search returns the matches of query in the scrollback and the screen, in
+// order. The soft wrapped rows are searched as a single line.
+// It only returns an error if the regular expression is invalid.
+func (t *Terminal) Search(query string, opts ...SearchOption) ([]Match, error) {
+	var o searchOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	if !o.regexp {
+		query = regexp.QuoteMeta(query)
+	}
+	if o.ignoreCase {
+		query = "(?i)" + query
+	}
+	re, err := regexp.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var matches []Match
+	var text strings.Builder
+	// pos is the position of the cell of each byte of the text, and
+	// widths its width
+	var pos []Position
+	var widths []int
+	search := func() {
+		s := text.String()
+		for _, m := range re.FindAllStringIndex(s, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			end := pos[m[1]-1]
+			end.Col += widths[m[1]-1]
+			matches = append(matches, Match{Start: pos[m[0]], End: end, Text: s[m[0]:m[1]]})
+		}
+		text.Reset()
+		pos, widths = pos[:0], widths[:0]
+	}
+	h := t.e.history
+	n := h.len()
+	for y := 0; y < n+t.e.rows; y++ {
+		var l line
+		if y < n {
+			l = h.line(y)
+		} else {
+			l = t.e.copyRow(y - n)
+		}
+		cells := trim(l.cells)
+		for x, c := range cells {
+			s := c.String()
+			text.WriteString(s)
+			for i := 0; i < len(s); i++ {
+				pos = append(pos, Position{Row: y, Col: x})
+				widths = append(widths, c.Width())
+			}
+		}
+		if !l.wrapped {
+			search()
+		}
+	}
+	search()
+	return matches, nil
+}
+
+// ScrollbackLen returns the number of lines in the scrollback
+func (t *Terminal) ScrollbackLen() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.history.len()
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sgr is the graphic rendition state
+type sgr struct {
+	// attrs are the attributes 1 (bold) to 9 (strike)
+	attrs [10]bool
+	fg    string
+	bg    string
+}
+
+// apply updates the state with the SGR parameters
+func (s *sgr) apply(params []int) {
+	if len(params) == 0 {
+		*s = sgr{}
+		return
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p <= 0:
+			*s = sgr{}
+		case p < 10:
+			s.attrs[p] = true
+		case p == 22:
+			s.attrs[1], s.attrs[2] = false, false
+		case p > 22 && p < 30:
+			s.attrs[p-20] = false
+		case p >= 30 && p <= 37, p >= 90 && p <= 97:
+			s.fg = strconv.Itoa(p)
+		case p == 39:
+			s.fg = ""
+		case p >= 40 && p <= 47, p >= 100 && p <= 107:
+			s.bg = strconv.Itoa(p)
+		case p == 49:
+			s.bg = ""
+		case p == 38 || p == 48:
+			c, n := extended(params[i:])
+			i += n
+			if p == 38 {
+				s.fg = c
+			} else {
+				s.bg = c
+			}
+		}
+	}
+}
+
+// ExtendedColor is a 256 or true color set by the SGR parameters 38 and 48
+type ExtendedColor struct {
+	// RGB is set for a true color, Index for a 256 color otherwise
+	RGB     bool
+	Index   uint8
+	R, G, B uint8
+}
+
+// ParseExtendedColor decodes the 256 or true color parameters starting with
+// 38 or 48, e.g. 38;5;208 or 48;2;255;128;0. It returns the color, whether
+// the parameters are valid, and the number of parameters used after the
+// first, which are all the remaining ones if they are not.
+func ParseExtendedColor(params []int) (c ExtendedColor, n int, ok bool) {
+	if len(params) >= 3 && params[1] == 5 {
+		if !byteParams(params[2:3]) {
+			return ExtendedColor{}, 2, false
+		}
+		return ExtendedColor{Index: uint8(params[2])}, 2, true
+	}
+	if len(params) >= 5 && params[1] == 2 {
+		if !byteParams(params[2:5]) {
+			return ExtendedColor{}, 4, false
+		}
+		return ExtendedColor{RGB: true, R: uint8(params[2]), G: uint8(params[3]), B: uint8(params[4])}, 4, true
+	}
+	if len(params) == 0 {
+		return ExtendedColor{}, 0, false
+	}
+	return ExtendedColor{}, len(params) - 1, false
+}
+
+// byteParams reports whether the parameters are in the 0-255 range
+func byteParams(params []int) bool {
+	for _, v := range params {
+		if v < 0 || v > 255 {
+			return false
+		}
+	}
+	return true
+}
+
+// extended decodes the 256 or true color parameters starting with 38 or 48
+// and returns the color parameters and the number of parameters used after
+// the first
+func extended(params []int) (string, int) {
+	c, n, ok := ParseExtendedColor(params)
+	switch {
+	case !ok:
+		return "", n
+	case c.RGB:
+		return strconv.Itoa(params[0]) + ";2;" + strconv.Itoa(int(c.R)) + ";" + strconv.Itoa(int(c.G)) + ";" + strconv.Itoa(int(c.B)), n
+	default:
+		return strconv.Itoa(params[0]) + ";5;" + strconv.Itoa(int(c.Index)), n
+	}
+}
+
+// String returns the SGR parameters of the state, e.g. "1;31"
+func (s sgr) String() string {
+	if s == (sgr{}) {
+		return ""
+	}
+	var v []string
+	for i, ok := range s.attrs {
+		if ok {
+			v = append(v, strconv.Itoa(i))
+		}
+	}
+	if s.fg != "" {
+		v = append(v, s.fg)
+	}
+	if s.bg != "" {
+		v = append(v, s.bg)
+	}
+	return strings.Join(v, ";")
+}
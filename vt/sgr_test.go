@@ -0,0 +1,63 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+import (
+	"testing"
+)
+
+func TestParseExtendedColor(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []int
+		color  ExtendedColor
+		n      int
+		ok     bool
+	}{
+		{name: "256 colors", params: []int{38, 5, 208}, color: ExtendedColor{Index: 208}, n: 2, ok: true},
+		{name: "true color", params: []int{48, 2, 255, 128, 0, 1}, color: ExtendedColor{RGB: true, R: 255, G: 128}, n: 4, ok: true},
+		{name: "index out of range", params: []int{38, 5, 300, 1}, n: 2},
+		{name: "component out of range", params: []int{38, 2, 0, 256, 0}, n: 4},
+		{name: "truncated", params: []int{38, 2, 1, 2}, n: 3},
+		{name: "unknown", params: []int{38, 3, 1}, n: 2},
+		{name: "alone", params: []int{38}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, n, ok := ParseExtendedColor(tt.params)
+			if c != tt.color || n != tt.n || ok != tt.ok {
+				t.Fatalf("got %+v, %d, %v, want %+v, %d, %v", c, n, ok, tt.color, tt.n, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSGRExtended(t *testing.T) {
+	tests := []struct {
+		params []int
+		want   string
+	}{
+		{params: []int{1, 38, 5, 208, 48, 2, 1, 2, 3}, want: "1;38;5;208;48;2;1;2;3"},
+		{params: []int{38, 5, 300, 4}, want: "4"},
+		{params: []int{31, 38, 2, 1}, want: ""},
+	}
+	for _, tt := range tests {
+		var s sgr
+		s.apply(tt.params)
+		if got := s.String(); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.params, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vt is a virtual terminal emulator: it interprets a terminal output
+// stream and maintains the resulting screen, so that it can be inspected,
+// e.g. to read the line being edited in a shell.
+//
+// It supports the cursor movements, erasing, scrolling regions, the
+// alternate screen and the SGR attributes, which is what the shells and
+// most full screen applications use. The G0 and G1 character sets can be
+// designated the DEC special graphics, whose line drawing characters are
+// stored as their Unicode box drawing equivalent, so that the screen
+// content is readable as is. The wide characters take two cells, and the
+// combining marks are merged with the character they follow.
+//
+// The memory used is bounded whatever the stream: the scrollback keeps a
+// maximum number of lines, and the parser drops the OSC data and the
+// parameters beyond its limits, see the Options.
+package vt
+
+import (
+	"strings"
+	"sync"
+
+	"go.linka.cloud/console/ansi"
+	"go.linka.cloud/console/screen"
+)
+
+// Terminal is a virtual terminal. It is safe for concurrent use.
+type Terminal struct {
+	mu sync.Mutex
+	p  *ansi.Parser
+	e  emulator
+}
+
+// New returns a Terminal of the given size
+func New(cols, rows int, opts ...Option) *Terminal {
+	o := options{scrollback: DefaultScrollback}
+	for _, v := range opts {
+		v(&o)
+	}
+	t := &Terminal{}
+	t.e.history = &scrollback{max: o.scrollback}
+	t.e.truncate = o.truncate
+	t.e.init(cols, rows)
+	t.p = ansi.NewParser(&t.e, o.parser...)
+	return t
+}
+
+// Write interprets the terminal output
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.p.Write(p)
+}
+
+// Resize changes the terminal size, rewrapping the soft wrapped lines of
+// the main screen to the new width, or keeping the content still in bounds
+// with WithoutReflow
+func (t *Terminal) Resize(cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.e.resize(cols, rows)
+}
+
+// Size returns the terminal width and height
+func (t *Terminal) Size() (cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.cols, t.e.rows
+}
+
+// Cursor returns the zero based cursor column and row
+func (t *Terminal) Cursor() (col, row int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.col, t.e.row
+}
+
+// Scrolled returns the number of lines scrolled off the top of the main
+// screen since the terminal was created, e.g. to follow a row as the
+// screen scrolls. The resizes reflowing the lines do not update it.
+func (t *Terminal) Scrolled() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.scrolled
+}
+
+// CursorVisible reports whether the cursor is shown
+func (t *Terminal) CursorVisible() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.e.hidden
+}
+
+// Cell returns the cell at the given column and row
+func (t *Terminal) Cell(col, row int) screen.Cell {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.buf.Cell(col, row)
+}
+
+// Line returns the text of the given row, without the trailing spaces.
+// The negative rows are the scrollback lines, -1 being the last one.
+func (t *Terminal) Line(row int) string {
+	return t.LineFrom(0, row)
+}
+
+// LineFrom returns the text of the given row from the column col, without
+// the trailing spaces, see Line
+func (t *Terminal) LineFrom(col, row int) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.line(row, col)
+}
+
+// String returns the text of the screen, one line per row
+func (t *Terminal) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b strings.Builder
+	for y := 0; y < t.e.rows; y++ {
+		if y > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(t.e.line(y, 0))
+	}
+	return b.String()
+}
+
+// Scrollback returns the text of the lines scrolled off the top of the
+// screen, the oldest first, without the trailing spaces
+func (t *Terminal) Scrollback() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.e.history
+	lines := make([]string, h.len())
+	for i := range lines {
+		var b strings.Builder
+		for _, c := range h.line(i).cells {
+			b.WriteString(c.String())
+		}
+		lines[i] = strings.TrimRight(b.String(), " ")
+	}
+	return lines
+}
+
+// Snapshot returns the cells of the scrollback lines followed by the
+// screen rows, without the empty cells at the end of the rows and the
+// empty rows at the end of the screen
+func (t *Terminal) Snapshot() [][]screen.Cell {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.e.history
+	rows := make([][]screen.Cell, 0, h.len()+t.e.rows)
+	for i := 0; i < h.len(); i++ {
+		rows = append(rows, trim(append([]screen.Cell(nil), h.line(i).cells...)))
+	}
+	for y := 0; y < t.e.rows; y++ {
+		rows = append(rows, trim(t.e.copyRow(y).cells))
+	}
+	n := len(rows)
+	for n > h.len() && len(rows[n-1]) == 0 {
+		n--
+	}
+	return rows[:n]
+}
+
+// Screen returns a copy of the screen
+func (t *Terminal) Screen() *screen.Buffer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.buf.Clone()
+}
+
+// AltScreen reports whether the alternate screen is active,
+// as used by full screen applications
+func (t *Terminal) AltScreen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.alt != nil
+}
+
+// Title returns the window title set by the application
+func (t *Terminal) Title() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.e.title
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vt
+
+import (
+	"testing"
+)
+
+func TestScrolledLines(t *testing.T) {
+	v := New(10, 2, WithScrollback(2))
+	v.Write([]byte("a\r\nb\r\nc\r\nd"))
+	if got := v.Scrolled(); got != 2 {
+		t.Fatalf("scrolled: got %d, want 2", got)
+	}
+	v.Write([]byte("\x1bc"))
+	v.Write([]byte("e\r\nf\r\ng"))
+	if got := v.Scrolled(); got != 3 {
+		t.Fatalf("scrolled after reset: got %d, want 3", got)
+	}
+	for row, want := range map[int]string{-3: "", -2: "b", -1: "e", 0: "f", 1: "g"} {
+		if got := v.Line(row); got != want {
+			t.Errorf("line %d: got %q, want %q", row, got, want)
+		}
+	}
+	if got := v.LineFrom(1, -1); got != "" {
+		t.Errorf("line -1 from 1: got %q, want empty", got)
+	}
+}